@@ -1,11 +1,18 @@
 package account
 
 import (
-	"example/hivemind-be/db"
+	"errors"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/events"
+	"example/hivemind-be/metrics"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
 	"example/hivemind-be/token"
 	"example/hivemind-be/utils"
+	"fmt"
 	"net/http"
 	"net/mail"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,15 +21,18 @@ import (
 	"github.com/lib/pq"
 )
 
-type Account struct {
-	ID       int32       `json:"Id" gorm:"primaryKey:type:int32"`
-	Username string      `json:"Username"`
-	Email    string      `json:"Email"`
-	Password string      `json:"Password"`
-	UUID     string      `json:"Uuid"`
-	Deleted  bool        `json:"Deleted"`
-	Banned   bool        `json:"Banned"`
-	Created  pq.NullTime `json:"Created"`
+// Handler serves the account routes from the account repository.
+type Handler struct {
+	Repos repository.Repos
+}
+
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+// accounts is the account repository bound to the request.
+func (h *Handler) accounts(c *gin.Context) repository.AccountRepo {
+	return h.Repos.WithContext(c.Request.Context()).Accounts
 }
 
 type ResponseAccount struct {
@@ -31,6 +41,7 @@ type ResponseAccount struct {
 	UUID     string      `json:"Uuid"`
 	Deleted  bool        `json:"Deleted"`
 	Banned   bool        `json:"Banned"`
+	Admin    bool        `json:"Admin"`
 	Created  pq.NullTime `json:"Created"`
 }
 
@@ -43,93 +54,104 @@ type UpdatePassword struct {
 	New string `json:"New"`
 }
 
-func CreateAccount(c *gin.Context) {
-	var acc Account
+type ShadowbanActivity = models.ShadowbanActivity
+
+func (h *Handler) CreateAccount(c *gin.Context) {
+	var acc models.Account
 
-	if err := c.BindJSON(&acc); err != nil {
+	if err := c.ShouldBindJSON(&acc); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
 		return
 	}
 
-	addr, err := mail.ParseAddress(strings.ToLower(acc.Email))
+	created, err := Create(h.Repos.WithContext(c.Request.Context()), acc.Username, acc.Email, acc.Password, false)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Email address format is not valid. Please us a valid email address.",
-		})
+		apperr.Write(c, err)
 		return
 	}
+	c.JSON(http.StatusCreated, created)
+}
 
-	validPass := utils.ValidatePasswordComplexity(acc.Password)
+// Create validates and stores a new account with a hashed password, along with its AccountCreated event.
+// Only the admin CLI creates administrators.
+func Create(repos repository.Repos, username string, email string, password string, admin bool) (models.Account, error) {
+	addr, err := mail.ParseAddress(strings.ToLower(email))
+	if err != nil {
+		return models.Account{}, apperr.New(apperr.ValidationFailed, "Email address format is not valid. Please us a valid email address.")
+	}
+
+	validPass := utils.ValidatePasswordComplexity(password)
 	if !validPass {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Password does not meet complexity requirements. Please use a password with at least 12 characters, 1 uppercase letter, 1 lowercase letter, 1 number, and 1 special character.",
-		})
-		return
+		return models.Account{}, apperr.New(apperr.ValidationFailed, "Password does not meet complexity requirements. Please use a password with at least 12 characters, 1 uppercase letter, 1 lowercase letter, 1 number, and 1 special character.")
 	}
 
-	hashedPassword, err := utils.HashPassword(acc.Password)
+	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Error: A error occurred creating the account. Please try again.",
-		})
-		return
+		return models.Account{}, apperr.Wrap(apperr.Internal, "Error: A error occurred creating the account. Please try again.", err)
 	}
 
-	acc.Username = strings.ToLower(acc.Username)
-	acc.Email = strings.ToLower(addr.Address)
-	acc.Password = hashedPassword
-	acc.UUID = uuid.NewString()
-	acc.Deleted = false
-	acc.Banned = false
-	acc.Created = pq.NullTime{Time: time.Now(), Valid: true}
-
-	if result := db.Db.Create(&acc); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Could not create account. Please try again.",
-		})
-		return
+	acc := models.Account{
+		Username:     strings.ToLower(username),
+		Email:        strings.ToLower(addr.Address),
+		Password:     hashedPassword,
+		UUID:         uuid.NewString(),
+		Deleted:      false,
+		Banned:       false,
+		Admin:        admin,
+		Shadowbanned: false,
+		Created:      pq.NullTime{Time: time.Now(), Valid: true},
 	}
 
-	c.JSON(http.StatusCreated, acc)
+	err = repos.Tx.Transaction(func(tx repository.Repos) error {
+		if err := tx.Accounts.Create(&acc); err != nil {
+			return err
+		}
+		event := events.Account(events.AccountCreated, acc)
+		return tx.Events.Append(&event)
+	})
+	if err != nil {
+		if apperr.CodeOf(err) == apperr.Conflict {
+			return models.Account{}, apperr.Wrap(apperr.Conflict, "An account with this email or username already exists.", err)
+		}
+		return models.Account{}, apperr.Wrap(apperr.Internal, "Could not create account. Please try again.", err)
+	}
+	return acc, nil
 }
 
-func AccountLogin(c *gin.Context) {
-	var acc Account
-	var account Account
+func (h *Handler) AccountLogin(c *gin.Context) {
+	var acc models.Account
 
-	if err := c.BindJSON(&acc); err != nil {
+	if err := c.ShouldBindJSON(&acc); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
 		return
 	}
 
-	if result := db.Db.Where("email = ?", strings.ToLower(acc.Email)).First(&account); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Account not found. Please try again.",
-		})
+	account, err := h.accounts(c).GetByEmail(strings.ToLower(acc.Email))
+	if err != nil {
+		metrics.Logins.WithLabelValues(metrics.Result(false)).Inc()
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.AccountNotFound, "Account not found. Please try again."))
 		return
 	}
 
 	if !utils.DoPasswordsMatch(account.Password, acc.Password) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Login unsuccessful. Please try again.",
-		})
+		metrics.Logins.WithLabelValues(metrics.Result(false)).Inc()
+		apperr.Write(c, apperr.New(apperr.InvalidCredentials, "Login unsuccessful. Please try again."))
 		return
 	}
 
 	authToken, err := token.CreateToken(account.Username, account.UUID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Error: A error occurred creating the token. Please try again.",
-		})
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "Error: A error occurred creating the token. Please try again.", err))
 		return
 	}
 
 	refreshToken, err := token.CreateRefreshToken(account.Username, account.UUID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Error: A error occurred creating the refresh token. Please try again.",
-		})
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "Error: A error occurred creating the refresh token. Please try again.", err))
 		return
 	}
 
+	metrics.Logins.WithLabelValues(metrics.Result(true)).Inc()
 	c.JSON(http.StatusOK, gin.H{
 		"Token":        authToken,
 		"RefreshToken": refreshToken,
@@ -148,18 +170,16 @@ func ValidateAccountToken(c *gin.Context) {
 	})
 }
 
-func GetAccount(c *gin.Context) {
+func (h *Handler) GetAccount(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
 	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	var account Account
-	if result := db.Db.Where("uuid = ?", claims.AccountUUID).First(&account); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Account not found. Please try again.",
-		})
+	account, err := h.accounts(c).GetByUUID(claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.AccountNotFound, "Account not found. Please try again."))
 		return
 	}
 
@@ -169,13 +189,14 @@ func GetAccount(c *gin.Context) {
 		UUID:     account.UUID,
 		Deleted:  account.Deleted,
 		Banned:   account.Banned,
+		Admin:    account.Admin,
 		Created:  account.Created,
 	}
 
 	c.JSON(http.StatusOK, responseAccount)
 }
 
-func ChangePassword(c *gin.Context) {
+func (h *Handler) ChangePassword(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
 	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
@@ -183,54 +204,43 @@ func ChangePassword(c *gin.Context) {
 	}
 
 	var changePassword UpdatePassword
-	if err := c.BindJSON(&changePassword); err != nil {
+	if err := c.ShouldBindJSON(&changePassword); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
 		return
 	}
 
 	validPass := utils.ValidatePasswordComplexity(changePassword.New)
 	if !validPass {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "New Password does not meet complexity requirements. Please use a password with at least 12 characters, 1 uppercase letter, 1 lowercase letter, 1 number, and 1 special character.",
-		})
+		apperr.Write(c, apperr.New(apperr.ValidationFailed, "New Password does not meet complexity requirements. Please use a password with at least 12 characters, 1 uppercase letter, 1 lowercase letter, 1 number, and 1 special character."))
 		return
 	}
 
-	var account Account
-	if result := db.Db.Where("uuid = ?", claims.AccountUUID).First(&account); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Account not found. Please try again.",
-		})
+	account, err := h.accounts(c).GetByUUID(claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.AccountNotFound, "Account not found. Please try again."))
 		return
 	}
 
 	if !utils.DoPasswordsMatch(account.Password, changePassword.Old) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Old password is incorrect. Please try again.",
-		})
+		apperr.Write(c, apperr.New(apperr.InvalidCredentials, "Old password is incorrect. Please try again."))
 		return
 	}
 
 	if utils.DoPasswordsMatch(account.Password, changePassword.New) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "New password cannot match old password. Please try again.",
-		})
+		apperr.Write(c, apperr.New(apperr.ValidationFailed, "New password cannot match old password. Please try again."))
 		return
 	}
 
 	hashedPassword, err := utils.HashPassword(changePassword.New)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Error: A error occurred creating the account. Please try again.",
-		})
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "Error: A error occurred creating the account. Please try again.", err))
 		return
 	}
 
 	account.Password = hashedPassword
 
-	if result := db.Db.Save(&account); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Could not update account. Please try again.",
-		})
+	if err := h.accounts(c).Save(&account); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "Could not update account. Please try again.", err))
 		return
 	}
 
@@ -242,40 +252,156 @@ func ChangePassword(c *gin.Context) {
 func RefreshAuthToken(c *gin.Context) {
 	var refreshToken RefreshToken
 
-	if err := c.BindJSON(&refreshToken); err != nil {
+	if err := c.ShouldBindJSON(&refreshToken); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
 		return
 	}
 
 	_, validRefToken := utils.ValidateRefreshAuthentication(c, refreshToken.RefreshToken)
 	if !validRefToken {
+		metrics.TokenRefreshes.WithLabelValues(metrics.Result(false)).Inc()
 		return
 	}
 
 	claims, err := token.ParseRefreshToken(refreshToken.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Error: A error occurred parsing the refresh token. Please try again.",
-		})
+		apperr.Write(c, apperr.New(apperr.Unauthorized, "Error: A error occurred parsing the refresh token. Please try again."))
 		return
 	}
 
 	authToken, err := token.CreateToken(claims.Username, claims.AccountUUID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Error: A error occurred creating the token. Please try again.",
-		})
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "Error: A error occurred creating the token. Please try again.", err))
 		return
 	}
 	refToken, err := token.CreateRefreshToken(claims.Username, claims.AccountUUID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Error: A error occurred creating the refresh token. Please try again.",
-		})
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "Error: A error occurred creating the refresh token. Please try again.", err))
 		return
 	}
 
+	metrics.TokenRefreshes.WithLabelValues(metrics.Result(true)).Inc()
 	c.JSON(http.StatusOK, gin.H{
 		"Token":        authToken,
 		"RefreshToken": refToken,
 	})
 }
+
+// CheckAdmin reports whether the account is a site administrator, through accounts. An account that does
+// not exist is not one.
+func CheckAdmin(accounts repository.AccountRepo, accountUUID string) (bool, error) {
+	account, err := accounts.GetByUUID(accountUUID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return account.Admin && !account.Deleted && !account.Banned, nil
+}
+
+// RequireAdmin writes a 403 response and returns false when the account is not a site administrator, looked
+// up through accounts. A failed lookup is written as a server error.
+func RequireAdmin(c *gin.Context, accounts repository.AccountRepo, accountUUID string) bool {
+	admin, err := CheckAdmin(accounts, accountUUID)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error checking your permissions. Please try again.", err))
+		return false
+	}
+	if !admin {
+		apperr.Write(c, apperr.New(apperr.Forbidden, "Only administrators can perform this action."))
+		return false
+	}
+	return true
+}
+
+func (h *Handler) ShadowbanAccountByUuid(c *gin.Context) {
+	h.setShadowban(c, true)
+}
+
+func (h *Handler) UnShadowbanAccountByUuid(c *gin.Context) {
+	h.setShadowban(c, false)
+}
+
+// setShadowban changes the account's shadowban and stops or resumes counting its existing votes to match,
+// in one transaction.
+func (h *Handler) setShadowban(c *gin.Context, shadowbanned bool) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	repos := h.Repos.WithContext(c.Request.Context())
+	if !RequireAdmin(c, repos.Accounts, claims.AccountUUID) {
+		return
+	}
+	account, err := repos.Accounts.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.AccountNotFound, "Account not found. Please try again."))
+		return
+	}
+
+	sign := int32(1)
+	if shadowbanned {
+		sign = -1
+	}
+
+	err = repos.Tx.Transaction(func(tx repository.Repos) error {
+		changed, err := tx.Accounts.SetShadowbanned(account.UUID, shadowbanned)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			if shadowbanned {
+				return apperr.New(apperr.AlreadyShadowbanned, fmt.Sprintf("%s is already shadowbanned!", account.Username))
+			}
+			return apperr.New(apperr.NotShadowbanned, fmt.Sprintf("%s has not been shadowbanned!", account.Username))
+		}
+		return tx.Votes.AddAccountVotes(account.UUID, sign)
+	})
+	if err != nil {
+		if apperr.CodeOf(err) == apperr.Internal {
+			err = apperr.Wrap(apperr.Internal, "Could not update account. Please try again.", err)
+		}
+		apperr.Write(c, err)
+		return
+	}
+
+	mes := fmt.Sprintf("%s has been shadowbanned!", account.Username)
+	if !shadowbanned {
+		mes = fmt.Sprintf("%s has been unshadowbanned!", account.Username)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"Message": mes,
+	})
+}
+
+// GetShadowbanReport lists every shadowbanned account with what it has posted and voted on in the last
+// `days` days (30 by default).
+func (h *Handler) GetShadowbanReport(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if !RequireAdmin(c, h.accounts(c), claims.AccountUUID) {
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 {
+		apperr.Write(c, apperr.New(apperr.BadRequest, "days must be a positive number."))
+		return
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	report, err := h.accounts(c).ShadowbanReport(since)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "Could not build the shadowban report. Please try again.", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
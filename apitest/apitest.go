@@ -0,0 +1,149 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/config"
+	"example/hivemind-be/migrate"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/token"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var setup sync.Once
+
+// Setup configures token signing once per test binary.
+func Setup() {
+	setup.Do(func() {
+		gin.SetMode(gin.TestMode)
+		token.Configure(config.TokenConfig{
+			Secret:          "test-secret",
+			RefreshSecret:   "test-refresh-secret",
+			Lifetime:        time.Hour,
+			RefreshLifetime: time.Hour,
+		})
+	})
+}
+
+// Database connects to the database TEST_DATABASE_URL points at and migrates it, or skips the test when it
+// is not set. Tests sharing it should only touch rows they create.
+func Database(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	gdb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatalf("connecting to the test database: %v", err)
+	}
+	if _, err := migrate.Up(gdb); err != nil {
+		t.Fatalf("migrating the test database: %v", err)
+	}
+	return gdb
+}
+
+// Account saves an account named username and returns it with an Authorization header value for it.
+func Account(t testing.TB, repos repository.Repos, username string) (models.Account, string) {
+	t.Helper()
+	account := models.Account{
+		Username: username,
+		Email:    username + "@example.com",
+		UUID:     uuid.NewString(),
+		Created:  pq.NullTime{Time: time.Now(), Valid: true},
+	}
+	if err := repos.Accounts.Create(&account); err != nil {
+		t.Fatalf("creating account %s: %v", username, err)
+	}
+	signed, err := token.CreateToken(account.Username, account.UUID)
+	if err != nil {
+		t.Fatalf("signing a token for %s: %v", username, err)
+	}
+	return account, "Bearer " + signed
+}
+
+// Admin saves an administrator account named username and returns it with an Authorization header value
+// for it.
+func Admin(t testing.TB, repos repository.Repos, username string) (models.Account, string) {
+	t.Helper()
+	account, auth := Account(t, repos, username)
+	account.Admin = true
+	if err := repos.Accounts.Save(&account); err != nil {
+		t.Fatalf("making %s an administrator: %v", username, err)
+	}
+	return account, auth
+}
+
+// Hive saves a hive named name, created by owner.
+func Hive(t testing.TB, repos repository.Repos, owner models.Account, name string) models.Hive {
+	t.Helper()
+	hive := models.Hive{
+		Name:        name,
+		Creator:     owner.Username,
+		Description: "A hive for tests.",
+		UUID:        uuid.NewString(),
+		AccountUUID: owner.UUID,
+		Created:     pq.NullTime{Time: time.Now(), Valid: true},
+	}
+	if err := repos.Hives.Create(&hive); err != nil {
+		t.Fatalf("creating hive %s: %v", name, err)
+	}
+	return hive
+}
+
+// Do sends a request through handler with auth as its Authorization header and body, when not nil, as
+// JSON, and returns the recorded response.
+func Do(t testing.TB, handler http.Handler, method string, path string, auth string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatalf("encoding the request body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// Decode expects the response to have status and unmarshals its body into dst, unless dst is nil.
+func Decode(t testing.TB, w *httptest.ResponseRecorder, status int, dst interface{}) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body.String())
+	}
+	if dst == nil {
+		return
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), dst); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body.String(), err)
+	}
+}
+
+// Code returns the error code of an error response.
+func Code(t testing.TB, w *httptest.ResponseRecorder) apperr.Code {
+	t.Helper()
+	var envelope apperr.Envelope
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body.String(), err)
+	}
+	return envelope.Code
+}
@@ -0,0 +1,354 @@
+package appeal
+
+import (
+	"example/hivemind-be/account"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/ban"
+	"example/hivemind-be/hive"
+	"example/hivemind-be/models"
+	"example/hivemind-be/notification"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/utils"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	StatusPending  = models.AppealPending
+	StatusApproved = models.AppealApproved
+	StatusDenied   = models.AppealDenied
+)
+
+type Appeal = models.Appeal
+
+type AppealEvent = models.AppealEvent
+
+type AppealWithEvents struct {
+	Appeal Appeal        `json:"Appeal"`
+	Ban    ban.Ban       `json:"Ban"`
+	Events []AppealEvent `json:"Events"`
+}
+
+type AppealMessage struct {
+	Message string `json:"Message"`
+}
+
+// Handler serves the appeal routes through the appeal and ban repositories.
+type Handler struct {
+	Repos repository.Repos
+}
+
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+// repos is the repositories with their queries bound to the request.
+func (h *Handler) repos(c *gin.Context) repository.Repos {
+	return h.Repos.WithContext(c.Request.Context())
+}
+
+func recordEvent(tx repository.Repos, appealUUID string, action string, actorUUID string, message string) error {
+	event := AppealEvent{
+		AppealUUID: appealUUID,
+		Action:     action,
+		ActorUUID:  actorUUID,
+		Message:    message,
+		Created:    pq.NullTime{Time: time.Now(), Valid: true},
+	}
+	return tx.Appeals.AddEvent(&event)
+}
+
+// loadBan looks up the ban an appeal is about, writing an error response and returning false when it is
+// missing.
+func loadBan(c *gin.Context, repos repository.Repos, banUUID string) (ban.Ban, bool) {
+	banRecord, err := repos.Bans.GetByUUID(banUUID)
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.BanNotFound, "Ban not found. Please try again."))
+		return banRecord, false
+	}
+	return banRecord, true
+}
+
+// write sends err, with message in place of the details of an internal error.
+func write(c *gin.Context, err error, message string) {
+	if apperr.CodeOf(err) == apperr.Internal {
+		err = apperr.Wrap(apperr.Internal, message, err)
+	}
+	apperr.Write(c, err)
+}
+
+func (h *Handler) CreateAppeal(c *gin.Context) {
+	var appealMessage AppealMessage
+
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&appealMessage); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
+		return
+	}
+
+	if !utils.ValidateCommentMessage(appealMessage.Message) {
+		apperr.Write(c, apperr.New(apperr.ValidationFailed, "Message must be between 1 and 2048 characters."))
+		return
+	}
+
+	repos := h.repos(c)
+	banRecord, found := loadBan(c, repos, c.Param("uuid"))
+	if !found {
+		return
+	}
+	//only the banned account can appeal, and to anyone else the ban does not exist
+	if banRecord.AccountUUID != claims.AccountUUID {
+		apperr.Write(c, apperr.New(apperr.BanNotFound, "Ban not found. Please try again."))
+		return
+	}
+
+	appeal := Appeal{
+		UUID:        uuid.NewString(),
+		BanUUID:     banRecord.UUID,
+		AccountUUID: claims.AccountUUID,
+		HiveUUID:    banRecord.HiveUUID,
+		Message:     appealMessage.Message,
+		Status:      StatusPending,
+		Created:     pq.NullTime{Time: time.Now(), Valid: true},
+		Decided:     pq.NullTime{Valid: false},
+	}
+
+	err := repos.Tx.Transaction(func(tx repository.Repos) error {
+		//the ban is locked before its appeal, the same order lifting it and deciding the appeal lock them in
+		banRecord, err := tx.Bans.GetForUpdate(banRecord.UUID)
+		if err != nil {
+			return err
+		}
+		if !banRecord.Active {
+			return apperr.New(apperr.BanLifted, "This ban has already been lifted!")
+		}
+		if err := tx.Appeals.Create(&appeal); err != nil {
+			if apperr.CodeOf(err) == apperr.Conflict {
+				return apperr.New(apperr.AlreadyAppealed, "This ban has already been appealed!")
+			}
+			return err
+		}
+		banRecord.AppealStatus = StatusPending
+		if err := tx.Bans.Save(&banRecord); err != nil {
+			return err
+		}
+		return recordEvent(tx, appeal.UUID, "filed", claims.AccountUUID, appeal.Message)
+	})
+	if err != nil {
+		write(c, err, "There was an error filing this appeal. Please try again.")
+		return
+	}
+
+	c.JSON(http.StatusCreated, appeal)
+}
+
+// GetAppealsByHiveUuid is the appeal queue of a hive. Pending appeals are listed unless `status` is given.
+func (h *Handler) GetAppealsByHiveUuid(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	uuid := c.Param("uuid")
+	if !hive.RequireModerator(c, h.repos(c), uuid, claims.AccountUUID) {
+		return
+	}
+
+	appeals, err := h.repos(c).Appeals.ListQueue(uuid, c.DefaultQuery("status", StatusPending))
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, appeals)
+}
+
+// GetSiteAppeals is the appeal queue for site-wide bans.
+func (h *Handler) GetSiteAppeals(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if !account.RequireAdmin(c, h.repos(c).Accounts, claims.AccountUUID) {
+		return
+	}
+
+	appeals, err := h.repos(c).Appeals.ListQueue("", c.DefaultQuery("status", StatusPending))
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, appeals)
+}
+
+func (h *Handler) GetAppealsByAccount(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	appeals, err := h.repos(c).Appeals.ListByAccount(claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, appeals)
+}
+
+// GetAppealByUuid returns an appeal with its ban and history to the appellant or a moderator of the ban.
+func (h *Handler) GetAppealByUuid(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	repos := h.repos(c)
+	appeal, err := repos.Appeals.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.AppealNotFound, "Appeal not found. Please try again."))
+		return
+	}
+
+	banRecord, found := loadBan(c, repos, appeal.BanUUID)
+	if !found {
+		return
+	}
+
+	if appeal.AccountUUID != claims.AccountUUID {
+		allowed, err := ban.CanModerate(repos, banRecord, claims.AccountUUID)
+		if err != nil {
+			apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error checking your permissions. Please try again.", err))
+			return
+		}
+		if !allowed {
+			apperr.Write(c, apperr.New(apperr.Forbidden, "You cannot view this appeal."))
+			return
+		}
+	}
+
+	events, err := repos.Appeals.ListEvents(appeal.UUID)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error loading this appeal. Please try again.", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, AppealWithEvents{
+		Appeal: appeal,
+		Ban:    banRecord,
+		Events: events,
+	})
+}
+
+func (h *Handler) ApproveAppealByUuid(c *gin.Context) {
+	h.decideAppeal(c, StatusApproved)
+}
+
+func (h *Handler) DenyAppealByUuid(c *gin.Context) {
+	h.decideAppeal(c, StatusDenied)
+}
+
+// decideAppeal records a moderator's decision on the appeal and on its ban. Approving an appeal lifts the ban
+// it was filed against.
+func (h *Handler) decideAppeal(c *gin.Context, status string) {
+	var appealMessage AppealMessage
+
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&appealMessage); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
+		return
+	}
+
+	if !utils.ValidateCommentMessage(appealMessage.Message) {
+		apperr.Write(c, apperr.New(apperr.ValidationFailed, "Message must be between 1 and 2048 characters."))
+		return
+	}
+
+	repos := h.repos(c)
+	appeal, err := repos.Appeals.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.AppealNotFound, "Appeal not found. Please try again."))
+		return
+	}
+
+	banRecord, found := loadBan(c, repos, appeal.BanUUID)
+	if !found {
+		return
+	}
+
+	allowed, err := ban.CanModerate(repos, banRecord, claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error checking your permissions. Please try again.", err))
+		return
+	}
+	if !allowed {
+		apperr.Write(c, apperr.New(apperr.Forbidden, "You cannot decide this appeal."))
+		return
+	}
+
+	err = repos.Tx.Transaction(func(tx repository.Repos) error {
+		//the ban is locked before the appeal, so the appeal is decided once and not while its ban is lifted
+		banRecord, err = tx.Bans.GetForUpdate(banRecord.UUID)
+		if err != nil {
+			return err
+		}
+		appeal, err = tx.Appeals.GetForUpdate(appeal.UUID)
+		if err != nil {
+			return err
+		}
+		if appeal.Status != StatusPending {
+			return apperr.New(apperr.AlreadyResolved, "This appeal has already been decided!")
+		}
+
+		appeal.Status = status
+		appeal.Response = appealMessage.Message
+		appeal.DecidedBy = claims.AccountUUID
+		appeal.Decided = pq.NullTime{Time: time.Now(), Valid: true}
+		banRecord.AppealStatus = status
+		banRecord.DecidedBy = claims.AccountUUID
+		if err := tx.Appeals.Save(&appeal); err != nil {
+			return err
+		}
+		if status == StatusApproved && banRecord.Active {
+			if err := ban.Lift(tx, &banRecord, claims.AccountUUID); err != nil {
+				return err
+			}
+		} else if err := tx.Bans.Save(&banRecord); err != nil {
+			return err
+		}
+		if err := recordEvent(tx, appeal.UUID, status, claims.AccountUUID, appealMessage.Message); err != nil {
+			return err
+		}
+		return notification.Notify(tx, notification.Notification{
+			AccountUUID: appeal.AccountUUID,
+			Type:        notification.AppealDecision,
+			HiveUUID:    appeal.HiveUUID,
+			SourceUUID:  appeal.UUID,
+			Message:     fmt.Sprintf("Your appeal was %s: %s", status, appealMessage.Message),
+		})
+	})
+	if err != nil {
+		write(c, err, "There was an error deciding this appeal. Please try again.")
+		return
+	}
+
+	c.JSON(http.StatusOK, appeal)
+}
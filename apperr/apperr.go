@@ -0,0 +1,196 @@
+package apperr
+
+import (
+	"errors"
+	"example/hivemind-be/logging"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Code is a stable, machine readable error code. Clients may switch on it, so codes are never renamed.
+type Code string
+
+const (
+	BadRequest         Code = "BAD_REQUEST"
+	ValidationFailed   Code = "VALIDATION_FAILED"
+	Unauthorized       Code = "UNAUTHORIZED"
+	TokenExpired       Code = "TOKEN_EXPIRED"
+	InvalidCredentials Code = "INVALID_CREDENTIALS"
+	Forbidden          Code = "FORBIDDEN"
+	Banned             Code = "BANNED"
+
+	NotFound             Code = "NOT_FOUND"
+	AccountNotFound      Code = "ACCOUNT_NOT_FOUND"
+	HiveNotFound         Code = "HIVE_NOT_FOUND"
+	ContentNotFound      Code = "CONTENT_NOT_FOUND"
+	CommentNotFound      Code = "COMMENT_NOT_FOUND"
+	BanNotFound          Code = "BAN_NOT_FOUND"
+	AppealNotFound       Code = "APPEAL_NOT_FOUND"
+	ModQueueItemNotFound Code = "MODQUEUE_ITEM_NOT_FOUND"
+	JobNotFound          Code = "JOB_NOT_FOUND"
+	WebhookNotFound      Code = "WEBHOOK_NOT_FOUND"
+	DeliveryNotFound     Code = "DELIVERY_NOT_FOUND"
+	NotificationNotFound Code = "NOTIFICATION_NOT_FOUND"
+
+	Conflict            Code = "CONFLICT"
+	AlreadyVoted        Code = "ALREADY_VOTED"
+	NotVoted            Code = "NOT_VOTED"
+	AlreadyDeleted      Code = "ALREADY_DELETED"
+	NotDeleted          Code = "NOT_DELETED"
+	AlreadyBanned       Code = "ALREADY_BANNED"
+	NotBanned           Code = "NOT_BANNED"
+	AlreadyArchived     Code = "ALREADY_ARCHIVED"
+	NotArchived         Code = "NOT_ARCHIVED"
+	AlreadyShadowbanned Code = "ALREADY_SHADOWBANNED"
+	NotShadowbanned     Code = "NOT_SHADOWBANNED"
+	AlreadyReported     Code = "ALREADY_REPORTED"
+	AlreadyAppealed     Code = "ALREADY_APPEALED"
+	AlreadyResolved     Code = "ALREADY_RESOLVED"
+	BanLifted           Code = "BAN_LIFTED"
+	ContentLocked       Code = "CONTENT_LOCKED"
+	ReplyToReply        Code = "REPLY_TO_REPLY"
+	JobNotDead          Code = "JOB_NOT_DEAD"
+	AlreadyMember       Code = "ALREADY_MEMBER"
+	NotMember           Code = "NOT_MEMBER"
+	WebhookDisabled     Code = "WEBHOOK_DISABLED"
+	TooManyWebhooks     Code = "TOO_MANY_WEBHOOKS"
+	AlreadyPublished    Code = "ALREADY_PUBLISHED"
+
+	RateLimited Code = "RATE_LIMITED"
+	Internal    Code = "INTERNAL"
+)
+
+var statuses = map[Code]int{
+	BadRequest:         http.StatusBadRequest,
+	ValidationFailed:   http.StatusUnprocessableEntity,
+	Unauthorized:       http.StatusUnauthorized,
+	TokenExpired:       http.StatusUnauthorized,
+	InvalidCredentials: http.StatusUnauthorized,
+	Forbidden:          http.StatusForbidden,
+	Banned:             http.StatusForbidden,
+
+	NotFound:             http.StatusNotFound,
+	AccountNotFound:      http.StatusNotFound,
+	HiveNotFound:         http.StatusNotFound,
+	ContentNotFound:      http.StatusNotFound,
+	CommentNotFound:      http.StatusNotFound,
+	BanNotFound:          http.StatusNotFound,
+	AppealNotFound:       http.StatusNotFound,
+	ModQueueItemNotFound: http.StatusNotFound,
+	JobNotFound:          http.StatusNotFound,
+	WebhookNotFound:      http.StatusNotFound,
+	DeliveryNotFound:     http.StatusNotFound,
+	NotificationNotFound: http.StatusNotFound,
+
+	Conflict:            http.StatusConflict,
+	AlreadyVoted:        http.StatusConflict,
+	NotVoted:            http.StatusConflict,
+	AlreadyDeleted:      http.StatusConflict,
+	NotDeleted:          http.StatusConflict,
+	AlreadyBanned:       http.StatusConflict,
+	NotBanned:           http.StatusConflict,
+	AlreadyArchived:     http.StatusConflict,
+	NotArchived:         http.StatusConflict,
+	AlreadyShadowbanned: http.StatusConflict,
+	NotShadowbanned:     http.StatusConflict,
+	AlreadyReported:     http.StatusConflict,
+	AlreadyAppealed:     http.StatusConflict,
+	AlreadyResolved:     http.StatusConflict,
+	BanLifted:           http.StatusConflict,
+	ContentLocked:       http.StatusConflict,
+	ReplyToReply:        http.StatusUnprocessableEntity,
+	JobNotDead:          http.StatusConflict,
+	AlreadyMember:       http.StatusConflict,
+	NotMember:           http.StatusConflict,
+	WebhookDisabled:     http.StatusConflict,
+	TooManyWebhooks:     http.StatusConflict,
+	AlreadyPublished:    http.StatusConflict,
+
+	RateLimited: http.StatusTooManyRequests,
+	Internal:    http.StatusInternalServerError,
+}
+
+// Status is the HTTP status the code is sent with.
+func (code Code) Status() int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is a domain error. Message is safe to show to the caller and Err keeps the underlying cause, which
+// is only ever logged.
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// CodeOf returns the code of a domain error. Any other error is Internal.
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return Internal
+}
+
+// NotFoundAs replaces a generic not found error from a lookup with a more specific one. Other errors are
+// returned unchanged.
+func NotFoundAs(err error, code Code, message string) error {
+	if CodeOf(err) == NotFound {
+		return Wrap(code, message, err)
+	}
+	return err
+}
+
+// Envelope is the body of every error response.
+type Envelope struct {
+	Error     string `json:"Error"`
+	Code      Code   `json:"Code"`
+	RequestID string `json:"RequestId,omitempty"` //matches the X-Request-ID response header
+}
+
+// Write aborts the request with err in the error envelope. Server errors are logged with their cause and
+// sent with a generic message so internal details never reach the client.
+func Write(c *gin.Context, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Wrap(Internal, "", err)
+	}
+
+	status := e.Code.Status()
+	message := e.Message
+	if status >= http.StatusInternalServerError {
+		logging.FromContext(c).Error("request failed", "method", c.Request.Method, "route", c.FullPath(), "error", err)
+		if message == "" {
+			message = "Something went wrong. Please try again."
+		}
+	}
+
+	c.AbortWithStatusJSON(status, Envelope{
+		Error:     message,
+		Code:      e.Code,
+		RequestID: logging.RequestID(c),
+	})
+}
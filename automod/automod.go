@@ -0,0 +1,324 @@
+package automod
+
+import (
+	"errors"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/events"
+	"example/hivemind-be/hive"
+	"example/hivemind-be/markdown"
+	"example/hivemind-be/mention"
+	"example/hivemind-be/models"
+	"example/hivemind-be/modqueue"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/utils"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AutoModerator posts its replies as this account. Migrations create it with a password that is not a
+// bcrypt hash, so nobody can log in as it.
+const (
+	AutoModeratorName = "automoderator"
+	AutoModeratorUUID = "00000000-0000-0000-0000-00000000a070"
+)
+
+type AutomodConfig = models.AutomodConfig
+
+// Handler serves the AutoModerator routes through the automod repository.
+type Handler struct {
+	Repos repository.Repos
+}
+
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+// repos is the repositories with their queries bound to the request.
+func (h *Handler) repos(c *gin.Context) repository.Repos {
+	return h.Repos.WithContext(c.Request.Context())
+}
+
+type UpdateRules struct {
+	Rules string `json:"Rules"`
+}
+
+// Rules is a hive's rule set as the rules routes return it. A saved rule set that no longer parses has Error
+// set instead of Parsed, so moderators can still load it to fix it.
+type Rules struct {
+	Config AutomodConfig    `json:"Config"`
+	Parsed []Rule           `json:"Parsed"`
+	Error  *apperr.Envelope `json:"Error,omitempty"`
+}
+
+type DryRunMatch struct {
+	ItemType string  `json:"ItemType"`
+	ItemUUID string  `json:"ItemUuid"`
+	Title    string  `json:"Title"`
+	Message  string  `json:"Message"`
+	Verdict  Verdict `json:"Verdict"`
+}
+
+// Dispatch carries out the parts of a verdict that need the subject to exist: queueing it for moderators,
+// locking the thread a comment belongs to and posting canned replies. Removal and flair are applied by the
+// caller before the subject is saved. tx should be the transaction the subject is saved in, so the subject
+// and everything done to it are saved together.
+func Dispatch(tx repository.Repos, verdict Verdict, subject Subject) error {
+	if len(verdict.Matched) == 0 {
+		return nil
+	}
+
+	if verdict.Flag {
+		if err := modqueue.Enqueue(tx.ModQueue, subject.HiveUUID, subject.Type, subject.UUID, "automod", verdict.Reason); err != nil {
+			return err
+		}
+	}
+
+	lock := verdict.Lock && subject.Type == "comment"
+	if !lock && len(verdict.Replies) == 0 {
+		return nil
+	}
+
+	content, err := tx.Contents.GetForUpdate(subject.ContentUUID)
+	if err != nil {
+		return err
+	}
+	if lock {
+		content.Locked = true
+		if err := tx.Contents.Save(&content); err != nil {
+			return err
+		}
+	}
+	if len(verdict.Replies) > 0 {
+		return reply(tx, content, verdict.Replies, subject)
+	}
+	return nil
+}
+
+// reply posts each of messages as the AutoModerator account, the way a comment posted by an account is
+// saved, and counts them in content and its hive.
+func reply(tx repository.Repos, content models.Content, messages []string, subject Subject) error {
+	if _, err := tx.Accounts.GetByUUID(AutoModeratorUUID); errors.Is(err, repository.ErrNotFound) {
+		slog.Warn("the AutoModerator account is missing, so its replies are not posted", "account", AutoModeratorUUID)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	//replies to a reply are attached to its parent since only one level of replies is allowed
+	parentUUID := ""
+	if subject.Type == "comment" {
+		parentUUID = subject.UUID
+		if subject.ParentUUID != "" {
+			parentUUID = subject.ParentUUID
+		}
+	}
+
+	for _, message := range messages {
+		comment := models.Comment{
+			Author:      AutoModeratorName,
+			Message:     message,
+			MessageHtml: markdown.Render(message),
+			UUID:        uuid.NewString(),
+			AccountUUID: AutoModeratorUUID,
+			ContentUUID: content.UUID,
+			ParentUUID:  parentUUID,
+			Created:     pq.NullTime{Time: time.Now(), Valid: true},
+			LastEdited:  pq.NullTime{Valid: false},
+		}
+		var err error
+		comment.Mentions, err = mention.Resolve(tx.Accounts, tx.Hives, message)
+		if err != nil {
+			return err
+		}
+		if err := tx.Comments.Create(&comment); err != nil {
+			return err
+		}
+		if err := tx.Mentions.Replace("comment", comment.UUID, comment.Mentions); err != nil {
+			return err
+		}
+		version := models.Revision{
+			ItemType:   "comment",
+			ItemUUID:   comment.UUID,
+			Message:    comment.Message,
+			EditorUUID: AutoModeratorUUID,
+			Created:    comment.Created,
+		}
+		if err := tx.Revisions.Append(&version); err != nil {
+			return err
+		}
+		event := events.Comment(events.CommentCreated, comment, subject.HiveUUID, AutoModeratorUUID)
+		if err := tx.Events.Append(&event); err != nil {
+			return err
+		}
+	}
+
+	replies := repository.Counts{Comments: int32(len(messages))}
+	if err := tx.Contents.AddCounts(content.UUID, replies); err != nil {
+		return err
+	}
+	//comments on deleted content are left out of the hive totals
+	if content.Deleted {
+		return nil
+	}
+	return tx.Hives.AddCounts(subject.HiveUUID, replies)
+}
+
+func (h *Handler) GetAutomodRules(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	uuid := c.Param("uuid")
+	repos := h.repos(c)
+	if !hive.RequireModerator(c, repos, uuid, claims.AccountUUID) {
+		return
+	}
+
+	config, err := loadConfig(repos.Automod, uuid)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error loading the rules. Please try again.", err))
+		return
+	}
+
+	response := Rules{Config: config}
+	if response.Parsed, err = ParseRules(config.Rules); err != nil {
+		response.Error = &apperr.Envelope{Error: err.Error(), Code: apperr.ValidationFailed}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// loadConfig returns the hive's saved rule set, or an empty one for the hive when it has none.
+func loadConfig(configs repository.AutomodRepo, hiveUUID string) (AutomodConfig, error) {
+	config, err := configs.GetConfig(hiveUUID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return AutomodConfig{HiveUUID: hiveUUID}, nil
+	}
+	return config, err
+}
+
+func (h *Handler) UpdateAutomodRules(c *gin.Context) {
+	var updateRules UpdateRules
+
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&updateRules); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
+		return
+	}
+
+	uuid := c.Param("uuid")
+	repos := h.repos(c)
+	if !hive.RequireModerator(c, repos, uuid, claims.AccountUUID) {
+		return
+	}
+
+	rules, err := ParseRules(updateRules.Rules)
+	if err != nil {
+		apperr.Write(c, apperr.New(apperr.ValidationFailed, err.Error()))
+		return
+	}
+
+	configs := repos.Automod
+	config, err := loadConfig(configs, uuid)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error loading the rules. Please try again.", err))
+		return
+	}
+	config.Rules = updateRules.Rules
+	config.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
+
+	if err := configs.SaveConfig(&config); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error saving the rules. Please try again.", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, Rules{Config: config, Parsed: rules})
+}
+
+// DryRunAutomodRules evaluates a rule set against the hive's existing content and comments without
+// applying any action. The saved rule set is used when the request does not include one. It looks at the
+// newest limit posts and comments, at most 500, which is also the default.
+func (h *Handler) DryRunAutomodRules(c *gin.Context) {
+	var updateRules UpdateRules
+
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&updateRules); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
+		return
+	}
+
+	uuid := c.Param("uuid")
+	repos := h.repos(c)
+	if !hive.RequireModerator(c, repos, uuid, claims.AccountUUID) {
+		return
+	}
+
+	var rules []Rule
+	var err error
+	if updateRules.Rules != "" {
+		if rules, err = ParseRules(updateRules.Rules); err != nil {
+			err = apperr.New(apperr.ValidationFailed, err.Error())
+		}
+	} else {
+		rules, err = LoadRules(repos.Automod, uuid)
+	}
+	if err != nil {
+		if apperr.CodeOf(err) == apperr.Internal {
+			err = apperr.Wrap(apperr.Internal, "There was an error loading the rules. Please try again.", err)
+		}
+		apperr.Write(c, err)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "500"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 500
+	}
+
+	subjects, err := repos.Automod.ListSubjects(uuid, limit)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error loading the hive's posts. Please try again.", err))
+		return
+	}
+	matches := []DryRunMatch{}
+	for _, subject := range subjects {
+		verdict, err := EvaluateRules(repos, rules, subject)
+		if err != nil {
+			apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error evaluating the rules. Please try again.", err))
+			return
+		}
+		if len(verdict.Matched) == 0 {
+			continue
+		}
+		matches = append(matches, DryRunMatch{
+			ItemType: subject.Type,
+			ItemUUID: subject.UUID,
+			Title:    subject.Title,
+			Message:  subject.Message,
+			Verdict:  verdict,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Rules":   len(rules),
+		"Checked": len(subjects),
+		"Matches": matches,
+	})
+}
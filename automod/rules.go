@@ -0,0 +1,318 @@
+package automod
+
+import (
+	"errors"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ActionRemove = "remove"
+	ActionFlag   = "flag"
+	ActionLock   = "lock"
+	ActionFlair  = "flair"
+	ActionReply  = "reply"
+)
+
+// Rule is a single AutoModerator rule. A rule matches when every condition it sets is true.
+type Rule struct {
+	Name       string     `json:"Name" yaml:"Name"`
+	Type       string     `json:"Type" yaml:"Type"` //content, comment or any (default)
+	Conditions Conditions `json:"Conditions" yaml:"Conditions"`
+	Actions    []string   `json:"Actions" yaml:"Actions"`
+	Flair      string     `json:"Flair" yaml:"Flair"` //used by the flair action, which only content rules can take
+	Reply      string     `json:"Reply" yaml:"Reply"` //used by the reply action
+	Reason     string     `json:"Reason" yaml:"Reason"`
+
+	title   *regexp.Regexp
+	message *regexp.Regexp
+}
+
+type Conditions struct {
+	Title           string   `json:"Title" yaml:"Title"`                     //regex matched against the content title
+	Message         string   `json:"Message" yaml:"Message"`                 //regex matched against the message
+	Domains         []string `json:"Domains" yaml:"Domains"`                 //matches links to any of these domains or their subdomains
+	AccountAgeBelow int      `json:"AccountAgeBelow" yaml:"AccountAgeBelow"` //days
+	KarmaBelow      *int64   `json:"KarmaBelow" yaml:"KarmaBelow"`
+	ReportsAtLeast  int64    `json:"ReportsAtLeast" yaml:"ReportsAtLeast"`
+}
+
+// Subject is the item a rule set is evaluated against.
+type Subject = models.AutomodSubject
+
+// Verdict is the combined outcome of every rule that matched a subject.
+type Verdict struct {
+	Matched []string `json:"Matched"`
+	Remove  bool     `json:"Remove"`
+	Flag    bool     `json:"Flag"`
+	Lock    bool     `json:"Lock"`
+	Flair   string   `json:"Flair"`
+	Replies []string `json:"Replies"`
+	Reason  string   `json:"Reason"`
+}
+
+// author holds the account facts that conditions may need. It is loaded at most once per evaluation.
+type author struct {
+	accounts repository.AccountRepo
+	loaded   bool
+	created  time.Time
+	karma    int64
+}
+
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"')\]]+`)
+
+// ParseRules parses a JSON or YAML rule set and compiles its patterns.
+func ParseRules(source string) ([]Rule, error) {
+	var rules []Rule
+	if strings.TrimSpace(source) == "" {
+		return rules, nil
+	}
+
+	// YAML is a superset of JSON, so a single decoder handles both formats
+	if err := yaml.Unmarshal([]byte(source), &rules); err != nil {
+		return nil, fmt.Errorf("rules could not be parsed: %w", err)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if rule.Type == "" {
+			rule.Type = "any"
+		}
+		if rule.Type != "any" && rule.Type != "content" && rule.Type != "comment" {
+			return nil, fmt.Errorf("%s: type must be content, comment or any", rule.Name)
+		}
+		if len(rule.Actions) == 0 {
+			return nil, fmt.Errorf("%s: at least one action is required", rule.Name)
+		}
+		for _, action := range rule.Actions {
+			switch action {
+			case ActionRemove, ActionFlag, ActionLock:
+			case ActionFlair:
+				if rule.Flair == "" {
+					return nil, fmt.Errorf("%s: the flair action requires Flair", rule.Name)
+				}
+				//comments have no flair
+				if rule.Type != "content" {
+					return nil, fmt.Errorf("%s: the flair action requires Type content", rule.Name)
+				}
+			case ActionReply:
+				if rule.Reply == "" {
+					return nil, fmt.Errorf("%s: the reply action requires Reply", rule.Name)
+				}
+			default:
+				return nil, fmt.Errorf("%s: unknown action %q", rule.Name, action)
+			}
+		}
+
+		var err error
+		if rule.Conditions.Title != "" {
+			if rule.title, err = regexp.Compile(rule.Conditions.Title); err != nil {
+				return nil, fmt.Errorf("%s: invalid title pattern: %w", rule.Name, err)
+			}
+		}
+		if rule.Conditions.Message != "" {
+			if rule.message, err = regexp.Compile(rule.Conditions.Message); err != nil {
+				return nil, fmt.Errorf("%s: invalid message pattern: %w", rule.Name, err)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// LoadRules returns the parsed rule set of a hive. Hives without a rule set have no rules, and a saved rule
+// set that no longer parses is a ValidationFailed error.
+func LoadRules(configs repository.AutomodRepo, hiveUUID string) ([]Rule, error) {
+	config, err := configs.GetConfig(hiveUUID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ParseRules(config.Rules)
+	if err != nil {
+		return nil, apperr.New(apperr.ValidationFailed, err.Error())
+	}
+	return rules, nil
+}
+
+// activeRules is LoadRules for evaluating new and reported items. A saved rule set that no longer parses is
+// logged and skipped, so posting keeps working until a moderator saves a valid one.
+func activeRules(configs repository.AutomodRepo, hiveUUID string) ([]Rule, error) {
+	config, err := configs.GetConfig(hiveUUID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ParseRules(config.Rules)
+	if err != nil {
+		slog.Warn("skipping an AutoModerator rule set that does not parse", "hive", hiveUUID, "error", err)
+		return nil, nil
+	}
+	return rules, nil
+}
+
+// Evaluate runs the subject's hive rule set against it. Hives without a valid rule set give an empty verdict.
+func Evaluate(repos repository.Repos, subject Subject) (Verdict, error) {
+	rules, err := activeRules(repos.Automod, subject.HiveUUID)
+	if err != nil {
+		return Verdict{}, err
+	}
+	return EvaluateRules(repos, rules, subject)
+}
+
+// EvaluateReported runs only the rules with a report count condition. It is used when an item is reported
+// so that rules which already ran when the item was created do not act on it again.
+func EvaluateReported(repos repository.Repos, subject Subject) (Verdict, error) {
+	rules, err := activeRules(repos.Automod, subject.HiveUUID)
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	var reportRules []Rule
+	for _, rule := range rules {
+		if rule.Conditions.ReportsAtLeast > 0 {
+			reportRules = append(reportRules, rule)
+		}
+	}
+	return EvaluateRules(repos, reportRules, subject)
+}
+
+// EvaluateRules runs the given rules against a subject and merges the actions of every matching rule. The
+// account and report conditions are looked up through repos.
+func EvaluateRules(repos repository.Repos, rules []Rule, subject Subject) (Verdict, error) {
+	var verdict Verdict
+	acc := author{accounts: repos.Accounts}
+
+	for _, rule := range rules {
+		matched, err := rule.matches(repos, subject, &acc)
+		if err != nil {
+			return Verdict{}, err
+		}
+		if !matched {
+			continue
+		}
+
+		verdict.Matched = append(verdict.Matched, rule.Name)
+		if verdict.Reason == "" {
+			verdict.Reason = rule.Reason
+		}
+		for _, action := range rule.Actions {
+			switch action {
+			case ActionRemove:
+				verdict.Remove = true
+			case ActionFlag:
+				verdict.Flag = true
+			case ActionLock:
+				verdict.Lock = true
+			case ActionFlair:
+				verdict.Flair = rule.Flair
+			case ActionReply:
+				verdict.Replies = append(verdict.Replies, rule.Reply)
+			}
+		}
+	}
+
+	if len(verdict.Matched) > 0 && verdict.Reason == "" {
+		verdict.Reason = "Matched AutoModerator rule: " + strings.Join(verdict.Matched, ", ")
+	}
+	return verdict, nil
+}
+
+func (rule Rule) matches(repos repository.Repos, subject Subject, acc *author) (bool, error) {
+	if rule.Type != "any" && rule.Type != subject.Type {
+		return false, nil
+	}
+
+	cond := rule.Conditions
+	if rule.title != nil && !rule.title.MatchString(subject.Title) {
+		return false, nil
+	}
+	if rule.message != nil && !rule.message.MatchString(subject.Message) {
+		return false, nil
+	}
+	if len(cond.Domains) > 0 && !linksToDomain(subject, cond.Domains) {
+		return false, nil
+	}
+	if cond.AccountAgeBelow > 0 || cond.KarmaBelow != nil {
+		if err := acc.load(subject.AccountUUID); err != nil {
+			return false, err
+		}
+		if cond.AccountAgeBelow > 0 && time.Since(acc.created) >= time.Duration(cond.AccountAgeBelow)*24*time.Hour {
+			return false, nil
+		}
+		if cond.KarmaBelow != nil && acc.karma >= *cond.KarmaBelow {
+			return false, nil
+		}
+	}
+	if cond.ReportsAtLeast > 0 {
+		reports, err := repos.Reports.CountByItem(subject.UUID)
+		if err != nil {
+			return false, err
+		}
+		if reports < cond.ReportsAtLeast {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// load reads the account's creation time and its karma. An account that no longer exists is as old as
+// can be, so it only matches karma conditions.
+func (acc *author) load(accountUUID string) error {
+	if acc.loaded {
+		return nil
+	}
+
+	account, err := acc.accounts.GetByUUID(accountUUID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	karma, err := acc.accounts.Karma(accountUUID)
+	if err != nil {
+		return err
+	}
+	acc.created = account.Created.Time
+	acc.karma = karma
+	acc.loaded = true
+	return nil
+}
+
+func linksToDomain(subject Subject, domains []string) bool {
+	links := linkPattern.FindAllString(subject.Message, -1)
+	if subject.Link != "" {
+		links = append(links, subject.Link)
+	}
+
+	for _, link := range links {
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		parsed, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+		for _, domain := range domains {
+			domain = strings.TrimPrefix(strings.ToLower(domain), "www.")
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
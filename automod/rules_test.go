@@ -0,0 +1,229 @@
+package automod_test
+
+import (
+	"example/hivemind-be/automod"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestParseRules(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		source string
+		want   []string //rule names, when the rules parse
+		err    string   //part of the error, when they do not
+	}{
+		{name: "empty", source: "  \n"},
+		{name: "yaml", source: "- Name: links\n  Actions: [flag]\n- Actions: [remove]\n", want: []string{"links", "rule 2"}},
+		{name: "json", source: `[{"Name": "links", "Type": "comment", "Actions": ["flag"]}]`, want: []string{"links"}},
+		{name: "malformed", source: "- Name: [", err: "could not be parsed"},
+		{name: "unknown type", source: "- Type: post\n  Actions: [flag]", err: "type must be"},
+		{name: "no actions", source: "- Name: idle", err: "at least one action"},
+		{name: "unknown action", source: "- Actions: [ban]", err: `unknown action "ban"`},
+		{name: "flair without text", source: "- Type: content\n  Actions: [flair]", err: "requires Flair"},
+		{name: "flair on comments", source: "- Type: comment\n  Actions: [flair]\n  Flair: Spoiler", err: "requires Type content"},
+		{name: "flair on any", source: "- Actions: [flair]\n  Flair: Spoiler", err: "requires Type content"},
+		{name: "reply without text", source: "- Actions: [reply]", err: "requires Reply"},
+		{name: "bad title pattern", source: "- Conditions: {Title: \"(\"}\n  Actions: [flag]", err: "invalid title pattern"},
+		{name: "bad message pattern", source: "- Conditions: {Message: \"[\"}\n  Actions: [flag]", err: "invalid message pattern"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := automod.ParseRules(test.source)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error = %v, want one containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, rule := range rules {
+				names = append(names, rule.Name)
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("rules = %v, want %v", names, test.want)
+			}
+		})
+	}
+}
+
+// newAuthor saves an account created age ago whose one post has karma net votes.
+func newAuthor(t *testing.T, repos repository.Repos, uuid string, age time.Duration, karma int32) {
+	t.Helper()
+	account := models.Account{Username: uuid, Email: uuid + "@example.com", UUID: uuid, Created: pq.NullTime{Time: time.Now().Add(-age), Valid: true}}
+	if err := repos.Accounts.Create(&account); err != nil {
+		t.Fatal(err)
+	}
+	post := models.Content{UUID: uuid + "-post", AccountUUID: uuid, Upvote: karma}
+	if err := repos.Contents.Create(&post); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEvaluateRules(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	newAuthor(t, repos, "newcomer", time.Hour, 0)
+	newAuthor(t, repos, "regular", 90*24*time.Hour, 50)
+	for _, reporter := range []string{"a", "b", "c"} {
+		report := models.Report{UUID: reporter, ItemUUID: "reported", AccountUUID: reporter}
+		if err := repos.Reports.Create(&report); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := automod.Subject{Type: "content", UUID: "post", AccountUUID: "regular", Title: "Hello", Message: "Hi"}
+	with := func(change func(subject *automod.Subject)) automod.Subject {
+		subject := content
+		change(&subject)
+		return subject
+	}
+
+	for _, test := range []struct {
+		name    string
+		source  string
+		subject automod.Subject
+		want    automod.Verdict
+	}{
+		{
+			name:    "title pattern",
+			source:  "- Name: shouting\n  Conditions: {Title: \"^[A-Z ]+$\"}\n  Actions: [flag]",
+			subject: with(func(s *automod.Subject) { s.Title = "BUY THIS" }),
+			want:    automod.Verdict{Matched: []string{"shouting"}, Flag: true, Reason: "Matched AutoModerator rule: shouting"},
+		},
+		{
+			name:    "title pattern misses",
+			source:  "- Name: shouting\n  Conditions: {Title: \"^[A-Z ]+$\"}\n  Actions: [flag]",
+			subject: content,
+		},
+		{
+			name:    "message pattern",
+			source:  "- Name: ads\n  Conditions: {Message: \"(?i)buy now\"}\n  Actions: [remove]\n  Reason: No ads.",
+			subject: with(func(s *automod.Subject) { s.Message = "Buy now!" }),
+			want:    automod.Verdict{Matched: []string{"ads"}, Remove: true, Reason: "No ads."},
+		},
+		{
+			name:    "linked domain in the message",
+			source:  "- Name: shorteners\n  Conditions: {Domains: [bit.ly]}\n  Actions: [remove]",
+			subject: with(func(s *automod.Subject) { s.Message = "See https://www.Bit.ly/abc" }),
+			want:    automod.Verdict{Matched: []string{"shorteners"}, Remove: true, Reason: "Matched AutoModerator rule: shorteners"},
+		},
+		{
+			name:    "subdomain of the link",
+			source:  "- Name: shorteners\n  Conditions: {Domains: [bit.ly]}\n  Actions: [remove]",
+			subject: with(func(s *automod.Subject) { s.Link = "https://go.bit.ly/abc" }),
+			want:    automod.Verdict{Matched: []string{"shorteners"}, Remove: true, Reason: "Matched AutoModerator rule: shorteners"},
+		},
+		{
+			name:    "lookalike domain",
+			source:  "- Name: shorteners\n  Conditions: {Domains: [bit.ly]}\n  Actions: [remove]",
+			subject: with(func(s *automod.Subject) { s.Link = "https://notbit.ly/abc" }),
+		},
+		{
+			name:    "new account",
+			source:  "- Name: newcomers\n  Conditions: {AccountAgeBelow: 7}\n  Actions: [flag]",
+			subject: with(func(s *automod.Subject) { s.AccountUUID = "newcomer" }),
+			want:    automod.Verdict{Matched: []string{"newcomers"}, Flag: true, Reason: "Matched AutoModerator rule: newcomers"},
+		},
+		{
+			name:    "old account",
+			source:  "- Name: newcomers\n  Conditions: {AccountAgeBelow: 7}\n  Actions: [flag]",
+			subject: content,
+		},
+		{
+			name:    "low karma",
+			source:  "- Name: karma\n  Conditions: {KarmaBelow: 10}\n  Actions: [flag]",
+			subject: with(func(s *automod.Subject) { s.AccountUUID = "newcomer" }),
+			want:    automod.Verdict{Matched: []string{"karma"}, Flag: true, Reason: "Matched AutoModerator rule: karma"},
+		},
+		{
+			name:    "enough karma",
+			source:  "- Name: karma\n  Conditions: {KarmaBelow: 10}\n  Actions: [flag]",
+			subject: content,
+		},
+		{
+			name:    "reported enough",
+			source:  "- Name: reports\n  Conditions: {ReportsAtLeast: 3}\n  Actions: [lock]",
+			subject: with(func(s *automod.Subject) { s.UUID = "reported" }),
+			want:    automod.Verdict{Matched: []string{"reports"}, Lock: true, Reason: "Matched AutoModerator rule: reports"},
+		},
+		{
+			name:    "not reported enough",
+			source:  "- Name: reports\n  Conditions: {ReportsAtLeast: 4}\n  Actions: [lock]",
+			subject: with(func(s *automod.Subject) { s.UUID = "reported" }),
+		},
+		{
+			name:    "flair and reply",
+			source:  "- Name: questions\n  Type: content\n  Conditions: {Title: \"\\\\?$\"}\n  Actions: [flair, reply]\n  Flair: Question\n  Reply: Thanks for asking.",
+			subject: with(func(s *automod.Subject) { s.Title = "Why?" }),
+			want:    automod.Verdict{Matched: []string{"questions"}, Flair: "Question", Replies: []string{"Thanks for asking."}, Reason: "Matched AutoModerator rule: questions"},
+		},
+		{
+			name:    "rule for another type",
+			source:  "- Name: comments\n  Type: comment\n  Actions: [remove]",
+			subject: content,
+		},
+		{
+			name:    "every condition must hold",
+			source:  "- Name: both\n  Conditions: {Message: \"Hi\", AccountAgeBelow: 7}\n  Actions: [remove]",
+			subject: content,
+		},
+		{
+			name:    "actions of every matching rule",
+			source:  "- Name: first\n  Actions: [flag]\n  Reason: First.\n- Name: second\n  Actions: [remove, reply]\n  Reply: Removed.\n  Reason: Second.",
+			subject: content,
+			want:    automod.Verdict{Matched: []string{"first", "second"}, Flag: true, Remove: true, Replies: []string{"Removed."}, Reason: "First."},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := automod.ParseRules(test.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verdict, err := automod.EvaluateRules(repos, rules, test.subject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(verdict, test.want) {
+				t.Errorf("verdict = %+v, want %+v", verdict, test.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateReportedOnlyRunsReportRules(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	config := models.AutomodConfig{HiveUUID: "hive", Rules: "- Name: always\n  Actions: [flag]\n- Name: reports\n  Conditions: {ReportsAtLeast: 1}\n  Actions: [remove]"}
+	if err := repos.Automod.SaveConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+	report := models.Report{UUID: "report", ItemUUID: "post", AccountUUID: "reporter"}
+	if err := repos.Reports.Create(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	subject := automod.Subject{Type: "content", UUID: "post", HiveUUID: "hive"}
+	verdict, err := automod.EvaluateReported(repos, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(verdict.Matched, []string{"reports"}) || verdict.Flag || !verdict.Remove {
+		t.Errorf("verdict = %+v, want only the report rule", verdict)
+	}
+
+	//a rule set saved before it stopped parsing is skipped rather than failing the post
+	config.Rules = "- Actions: [ban]"
+	if err := repos.Automod.SaveConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+	if verdict, err := automod.Evaluate(repos, subject); err != nil || len(verdict.Matched) != 0 {
+		t.Errorf("Evaluate = %+v, %v, want an empty verdict", verdict, err)
+	}
+}
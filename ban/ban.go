@@ -0,0 +1,304 @@
+package ban
+
+import (
+	"errors"
+	"example/hivemind-be/account"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/events"
+	"example/hivemind-be/hive"
+	"example/hivemind-be/models"
+	"example/hivemind-be/notification"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/utils"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Ban = models.Ban
+
+type NewBan struct {
+	AccountUUID string `json:"AccountUuid"`
+	Reason      string `json:"Reason"`
+}
+
+// Handler serves the ban routes through the ban repository.
+type Handler struct {
+	Repos repository.Repos
+}
+
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+// repos is the repositories with their queries bound to the request.
+func (h *Handler) repos(c *gin.Context) repository.Repos {
+	return h.Repos.WithContext(c.Request.Context())
+}
+
+// CanModerate reports whether the account may lift the ban or decide its appeals, through repos. Site-wide
+// bans are handled by administrators and hive bans by the hive's moderators.
+func CanModerate(repos repository.Repos, ban Ban, accountUUID string) (bool, error) {
+	if ban.HiveUUID == "" {
+		return account.CheckAdmin(repos.Accounts, accountUUID)
+	}
+	return hive.CheckModerator(repos.Hives, repos.Accounts, ban.HiveUUID, accountUUID)
+}
+
+// Lift deactivates a ban and records BanLifted through tx, which should be a transaction. An appeal of the
+// ban still pending is closed, since there is nothing left to decide. Lifting the last site-wide ban of an
+// account also clears its banned flag.
+func Lift(tx repository.Repos, ban *Ban, liftedBy string) error {
+	now := pq.NullTime{Time: time.Now(), Valid: true}
+	appeal, err := tx.Appeals.GetByBan(ban.UUID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if err == nil && appeal.Status == models.AppealPending {
+		appeal.Status = models.AppealClosed
+		appeal.DecidedBy = liftedBy
+		appeal.Decided = now
+		if err := tx.Appeals.Save(&appeal); err != nil {
+			return err
+		}
+		closed := models.AppealEvent{
+			AppealUUID: appeal.UUID,
+			Action:     models.AppealClosed,
+			ActorUUID:  liftedBy,
+			Message:    "The ban was lifted.",
+			Created:    now,
+		}
+		if err := tx.Appeals.AddEvent(&closed); err != nil {
+			return err
+		}
+		ban.AppealStatus = models.AppealClosed
+		ban.DecidedBy = liftedBy
+	}
+
+	ban.Active = false
+	ban.Lifted = now
+	ban.LiftedBy = liftedBy
+	if err := tx.Bans.Save(ban); err != nil {
+		return err
+	}
+	event := events.Ban(events.BanLifted, ban.UUID, ban.HiveUUID, liftedBy, ban)
+	if err := tx.Events.Append(&event); err != nil {
+		return err
+	}
+
+	if ban.HiveUUID != "" {
+		return nil
+	}
+
+	remaining, err := tx.Bans.HasActive(ban.AccountUUID, "")
+	if err != nil || remaining {
+		return err
+	}
+	return tx.Accounts.SetBanned(ban.AccountUUID, false)
+}
+
+func (h *Handler) CreateHiveBan(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	uuid := c.Param("uuid")
+	if !hive.RequireModerator(c, h.repos(c), uuid, claims.AccountUUID) {
+		return
+	}
+
+	h.createBan(c, uuid, claims.AccountUUID)
+}
+
+func (h *Handler) CreateSiteBan(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if !account.RequireAdmin(c, h.repos(c).Accounts, claims.AccountUUID) {
+		return
+	}
+
+	h.createBan(c, "", claims.AccountUUID)
+}
+
+func (h *Handler) createBan(c *gin.Context, hiveUUID string, bannedBy string) {
+	var newBan NewBan
+
+	if err := c.ShouldBindJSON(&newBan); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
+		return
+	}
+
+	if !utils.ValidateReportReason(newBan.Reason) {
+		apperr.Write(c, apperr.New(apperr.ValidationFailed, "Reason must be between 1 and 256 characters."))
+		return
+	}
+
+	repos := h.repos(c)
+	target, err := repos.Accounts.GetByUUID(newBan.AccountUUID)
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.AccountNotFound, "Account not found. Please try again."))
+		return
+	}
+
+	ban, err := Create(repos, target, hiveUUID, newBan.Reason, bannedBy)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, ban)
+}
+
+// Create bans the account from the hive, or from the whole site when hiveUUID is empty. Site-wide bans also
+// set the account's banned flag.
+func Create(repos repository.Repos, target models.Account, hiveUUID string, reason string, bannedBy string) (Ban, error) {
+	ban := Ban{
+		UUID:        uuid.NewString(),
+		AccountUUID: target.UUID,
+		HiveUUID:    hiveUUID,
+		Reason:      reason,
+		BannedBy:    bannedBy,
+		Active:      true,
+		Created:     pq.NullTime{Time: time.Now(), Valid: true},
+		Lifted:      pq.NullTime{Valid: false},
+	}
+
+	err := repos.Tx.Transaction(func(tx repository.Repos) error {
+		existing, err := tx.Bans.HasActive(target.UUID, hiveUUID)
+		if err != nil {
+			return err
+		}
+		if existing {
+			return apperr.New(apperr.AlreadyBanned, target.Username+" is already banned!")
+		}
+		if err := tx.Bans.Create(&ban); err != nil {
+			return err
+		}
+		event := events.Ban(events.BanCreated, ban.UUID, ban.HiveUUID, bannedBy, ban)
+		if err := tx.Events.Append(&event); err != nil {
+			return err
+		}
+		if err := notifyBanned(tx, ban); err != nil {
+			return err
+		}
+		if hiveUUID == "" {
+			return tx.Accounts.SetBanned(target.UUID, true)
+		}
+		return nil
+	})
+	if err != nil {
+		if apperr.CodeOf(err) != apperr.Internal {
+			return Ban{}, err
+		}
+		return Ban{}, apperr.Wrap(apperr.Internal, "There was an error creating this ban. Please try again.", err)
+	}
+	return ban, nil
+}
+
+// notifyBanned tells the account it was banned and why, without naming the moderator.
+func notifyBanned(tx repository.Repos, ban Ban) error {
+	message := "You have been banned from Hivemind. Reason: " + ban.Reason
+	if ban.HiveUUID != "" {
+		banned, err := tx.Hives.GetByUUID(ban.HiveUUID)
+		if err != nil {
+			return err
+		}
+		message = fmt.Sprintf("You have been banned from h/%s. Reason: %s", banned.Name, ban.Reason)
+	}
+	return notification.Notify(tx, notification.Notification{
+		AccountUUID: ban.AccountUUID,
+		Type:        notification.ModAction,
+		HiveUUID:    ban.HiveUUID,
+		SourceUUID:  ban.UUID,
+		Message:     message,
+	})
+}
+
+func (h *Handler) LiftBanByUuid(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	repos := h.repos(c)
+	ban, err := repos.Bans.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.BanNotFound, "Ban not found. Please try again."))
+		return
+	}
+
+	allowed, err := CanModerate(repos, ban, claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error checking your permissions. Please try again.", err))
+		return
+	}
+	if !allowed {
+		apperr.Write(c, apperr.New(apperr.Forbidden, "You cannot lift this ban."))
+		return
+	}
+
+	err = repos.Tx.Transaction(func(tx repository.Repos) error {
+		//the ban is checked again under a lock, so it is lifted once however many moderators lift it at once
+		ban, err = tx.Bans.GetForUpdate(ban.UUID)
+		if err != nil {
+			return err
+		}
+		if !ban.Active {
+			return apperr.New(apperr.BanLifted, "This ban has already been lifted!")
+		}
+		return Lift(tx, &ban, claims.AccountUUID)
+	})
+	if err != nil {
+		if apperr.CodeOf(err) == apperr.Internal {
+			err = apperr.Wrap(apperr.Internal, "There was an error lifting this ban. Please try again.", err)
+		}
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ban)
+}
+
+func (h *Handler) GetBansByHiveUuid(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	uuid := c.Param("uuid")
+	if !hive.RequireModerator(c, h.repos(c), uuid, claims.AccountUUID) {
+		return
+	}
+
+	bans, err := h.repos(c).Bans.ListByHive(uuid)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bans)
+}
+
+func (h *Handler) GetBansByAccount(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	bans, err := h.repos(c).Bans.ListByAccount(claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bans)
+}
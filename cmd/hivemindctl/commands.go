@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"example/hivemind-be/account"
+	"example/hivemind-be/ban"
+	"example/hivemind-be/counters"
+	"example/hivemind-be/hive"
+	"example/hivemind-be/migrate"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type migrationRow struct {
+	Version int64  `json:"Version"`
+	Name    string `json:"Name"`
+}
+
+func migrationTable(done []migrate.Migration) table {
+	t := table{headers: []string{"VERSION", "NAME"}}
+	rows := []migrationRow{}
+	for _, migration := range done {
+		rows = append(rows, migrationRow{Version: migration.Version, Name: migration.Name})
+		t.rows = append(t.rows, []string{strconv.FormatInt(migration.Version, 10), migration.Name})
+	}
+	t.value = rows
+	return t
+}
+
+func (c *cli) migrateUp(args []string) error {
+	if _, err := c.parse(c.flags("migrate up"), args, 0, 0); err != nil {
+		return err
+	}
+	gdb, err := c.db()
+	if err != nil {
+		return err
+	}
+	done, err := migrate.Up(gdb)
+	if printErr := c.print(migrationTable(done)); err == nil {
+		err = printErr
+	}
+	return err
+}
+
+func (c *cli) migrateDown(args []string) error {
+	values, err := c.parse(c.flags("migrate down"), args, 0, 1)
+	if err != nil {
+		return err
+	}
+	steps := 1
+	if len(values) == 1 {
+		if steps, err = strconv.Atoi(values[0]); err != nil || steps < 1 {
+			return fmt.Errorf("steps must be a positive number")
+		}
+	}
+	gdb, err := c.db()
+	if err != nil {
+		return err
+	}
+	done, err := migrate.Down(gdb, steps)
+	if printErr := c.print(migrationTable(done)); err == nil {
+		err = printErr
+	}
+	return err
+}
+
+func (c *cli) migrateStatus(args []string) error {
+	if _, err := c.parse(c.flags("migrate status"), args, 0, 0); err != nil {
+		return err
+	}
+	gdb, err := c.db()
+	if err != nil {
+		return err
+	}
+	statuses, err := migrate.Status(gdb)
+	if err != nil {
+		return err
+	}
+	t := table{headers: []string{"VERSION", "NAME", "APPLIED"}, value: statuses}
+	for _, status := range statuses {
+		applied := "pending"
+		if status.Applied {
+			applied = status.AppliedAt.Format(time.RFC3339)
+		}
+		t.rows = append(t.rows, []string{strconv.FormatInt(status.Version, 10), status.Name, applied})
+	}
+	return c.print(t)
+}
+
+// createAdmin reads the password from stdin rather than a flag so it stays out of the shell history and the
+// process list.
+func (c *cli) createAdmin(args []string) error {
+	flags := c.flags("admin create")
+	username := flags.String("username", "", "username of the new administrator")
+	email := flags.String("email", "", "email address of the new administrator")
+	if _, err := c.parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *username == "" || *email == "" {
+		return fmt.Errorf("admin create needs -username and -email")
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("reading the password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	gdb, err := c.db()
+	if err != nil {
+		return err
+	}
+	created, err := account.Create(repository.NewGormRepos(gdb), *username, *email, password, true)
+	if err != nil {
+		return err
+	}
+	return c.print(accountTable(created))
+}
+
+type accountRow struct {
+	UUID     string `json:"Uuid"`
+	Username string `json:"Username"`
+	Email    string `json:"Email"`
+	Admin    bool   `json:"Admin"`
+	Banned   bool   `json:"Banned"`
+}
+
+func accountTable(acc models.Account) table {
+	return table{
+		headers: []string{"UUID", "USERNAME", "EMAIL", "ADMIN", "BANNED"},
+		rows:    [][]string{{acc.UUID, acc.Username, acc.Email, strconv.FormatBool(acc.Admin), strconv.FormatBool(acc.Banned)}},
+		value:   accountRow{UUID: acc.UUID, Username: acc.Username, Email: acc.Email, Admin: acc.Admin, Banned: acc.Banned},
+	}
+}
+
+func findAccount(gdb *gorm.DB, ref string) (models.Account, error) {
+	var acc models.Account
+	result := gdb.Where("uuid = ? OR username = ?", ref, strings.ToLower(ref)).First(&acc)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return acc, fmt.Errorf("no account %q", ref)
+	}
+	return acc, result.Error
+}
+
+func findHive(gdb *gorm.DB, ref string) (models.Hive, error) {
+	var found models.Hive
+	result := gdb.Where("uuid = ? OR name = ?", ref, ref).First(&found)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return found, fmt.Errorf("no hive %q", ref)
+	}
+	return found, result.Error
+}
+
+func banTable(bans ...ban.Ban) table {
+	t := table{headers: []string{"UUID", "ACCOUNT", "HIVE", "ACTIVE", "REASON"}, value: bans}
+	for _, b := range bans {
+		scope := b.HiveUUID
+		if scope == "" {
+			scope = "site-wide"
+		}
+		t.rows = append(t.rows, []string{b.UUID, b.AccountUUID, scope, strconv.FormatBool(b.Active), b.Reason})
+	}
+	return t
+}
+
+func (c *cli) banAccount(args []string) error {
+	flags := c.flags("ban account")
+	reason := flags.String("reason", "", "why the account is banned, shown to it")
+	by := flags.String("by", "", "administrator the ban is recorded against")
+	hiveRef := flags.String("hive", "", "ban from this hive only")
+	values, err := c.parse(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if *reason == "" || *by == "" {
+		return fmt.Errorf("ban account needs -reason and -by")
+	}
+
+	gdb, err := c.db()
+	if err != nil {
+		return err
+	}
+	target, err := findAccount(gdb, values[0])
+	if err != nil {
+		return err
+	}
+	actor, err := findAccount(gdb, *by)
+	if err != nil {
+		return err
+	}
+	hiveUUID := ""
+	if *hiveRef != "" {
+		found, err := findHive(gdb, *hiveRef)
+		if err != nil {
+			return err
+		}
+		hiveUUID = found.UUID
+	}
+
+	created, err := ban.Create(repository.NewGormRepos(gdb), target, hiveUUID, *reason, actor.UUID)
+	if err != nil {
+		return err
+	}
+	return c.print(banTable(created))
+}
+
+func (c *cli) unbanAccount(args []string) error {
+	flags := c.flags("unban account")
+	by := flags.String("by", "", "administrator the ban is lifted by")
+	hiveRef := flags.String("hive", "", "lift the ban from this hive instead of the site-wide one")
+	values, err := c.parse(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if *by == "" {
+		return fmt.Errorf("unban account needs -by")
+	}
+
+	gdb, err := c.db()
+	if err != nil {
+		return err
+	}
+	target, err := findAccount(gdb, values[0])
+	if err != nil {
+		return err
+	}
+	actor, err := findAccount(gdb, *by)
+	if err != nil {
+		return err
+	}
+
+	hiveUUID := ""
+	if *hiveRef != "" {
+		found, err := findHive(gdb, *hiveRef)
+		if err != nil {
+			return err
+		}
+		hiveUUID = found.UUID
+	}
+	repos := repository.NewGormRepos(gdb)
+	all, err := repos.Bans.ListByAccount(target.UUID)
+	if err != nil {
+		return err
+	}
+	var bans []ban.Ban
+	for _, b := range all {
+		if b.Active && b.HiveUUID == hiveUUID {
+			bans = append(bans, b)
+		}
+	}
+	if len(bans) == 0 {
+		return fmt.Errorf("%s is not banned", target.Username)
+	}
+
+	err = repos.Tx.Transaction(func(tx repository.Repos) error {
+		for i := range bans {
+			if err := ban.Lift(tx, &bans[i], actor.UUID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(banTable(bans...))
+}
+
+func (c *cli) setHiveBanned(args []string, banned bool) error {
+	name := "unban hive"
+	if banned {
+		name = "ban hive"
+	}
+	values, err := c.parse(c.flags(name), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	gdb, err := c.db()
+	if err != nil {
+		return err
+	}
+	found, err := findHive(gdb, values[0])
+	if err != nil {
+		return err
+	}
+
+	hives := hive.NewHiveService(repository.NewGormRepos(gdb))
+	change := hives.Unban
+	if banned {
+		change = hives.Ban
+	}
+	updated, err := change(found.UUID, "")
+	if err != nil {
+		return err
+	}
+	return c.print(table{
+		headers: []string{"UUID", "NAME", "BANNED", "ARCHIVED"},
+		rows:    [][]string{{updated.UUID, updated.Name, strconv.FormatBool(updated.Banned), strconv.FormatBool(updated.Archived)}},
+		value:   updated,
+	})
+}
+
+func (c *cli) reconcileCounters(name string, args []string, repair bool) error {
+	flags := c.flags(name)
+	if !repair {
+		flags.BoolVar(&repair, "repair", false, "overwrite the drifted counters")
+	}
+	if _, err := c.parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+	gdb, err := c.db()
+	if err != nil {
+		return err
+	}
+	report, err := counters.Reconcile(c.ctx, gdb, repair)
+	if err != nil {
+		return err
+	}
+	if len(report.Drift) == 0 && c.format == "table" {
+		return c.message("No drift.")
+	}
+
+	t := table{headers: []string{"COUNTER", "UUID", "STORED", "ACTUAL"}, value: report}
+	for _, drift := range report.Drift {
+		stored := "null"
+		if drift.Stored != nil {
+			stored = strconv.FormatInt(*drift.Stored, 10)
+		}
+		t.rows = append(t.rows, []string{drift.Counter, drift.UUID, stored, strconv.FormatInt(drift.Actual, 10)})
+	}
+	if err := c.print(t); err != nil {
+		return err
+	}
+	if report.Repaired && c.format == "table" {
+		fmt.Fprintf(os.Stderr, "\nRepaired %d rows.\n", len(report.Drift))
+	}
+	return nil
+}
+
+type secret struct {
+	Variable string `json:"Variable"`
+	Value    string `json:"Value"`
+}
+
+// rotateKeys prints new signing secrets with the current ones moved to the previous secret variables, so
+// tokens issued before the rotation stay valid until they expire. Once the refresh token lifetime has passed
+// the previous secrets can be removed.
+func (c *cli) rotateKeys(args []string) error {
+	if _, err := c.parse(c.flags("keys rotate"), args, 0, 0); err != nil {
+		return err
+	}
+	secretValue, err := newSecret()
+	if err != nil {
+		return err
+	}
+	refreshValue, err := newSecret()
+	if err != nil {
+		return err
+	}
+
+	secrets := []secret{
+		{"TOKEN_SECRET", secretValue},
+		{"REFRESH_TOKEN_SECRET", refreshValue},
+		{"TOKEN_PREVIOUS_SECRET", c.cfg.Token.Secret},
+		{"REFRESH_TOKEN_PREVIOUS_SECRET", c.cfg.Token.RefreshSecret},
+	}
+	t := table{headers: []string{"VARIABLE", "VALUE"}, value: secrets}
+	for _, s := range secrets {
+		t.rows = append(t.rows, []string{s.Variable, s.Value})
+	}
+	if err := c.print(t); err != nil {
+		return err
+	}
+	if c.format == "table" {
+		fmt.Fprintf(os.Stderr, "\nSet these, for example with `fly secrets set`, and remove the previous secrets after %s.\n", c.cfg.Token.RefreshLifetime)
+	}
+	return nil
+}
+
+// newSecret is 48 random bytes, 64 characters once encoded.
+func newSecret() (string, error) {
+	key := make([]byte, 48)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key), nil
+}
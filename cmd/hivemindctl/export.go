@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"example/hivemind-be/automod"
+	"example/hivemind-be/models"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// exportVersion is bumped whenever the export format changes in a way older imports cannot read.
+const exportVersion = 1
+
+// importBatchSize bounds the rows sent in one INSERT.
+const importBatchSize = 500
+
+// hiveExport is a hive with its automod rules, its members and everything posted and voted in it. Accounts
+// are referred to by uuid and have to exist wherever the hive is imported. Bans, reports, webhooks and the
+// mod queue are not exported.
+type hiveExport struct {
+	Version      int                    `json:"Version"`
+	Exported     time.Time              `json:"Exported"`
+	Hive         models.Hive            `json:"Hive"`
+	Automod      *automod.AutomodConfig `json:"Automod,omitempty"`
+	Members      []models.HiveMember    `json:"Members"`
+	Contents     []models.Content       `json:"Contents"`
+	Comments     []models.Comment       `json:"Comments"`
+	ContentVotes []models.ContentVote   `json:"ContentVotes"`
+	CommentVotes []models.CommentVote   `json:"CommentVotes"`
+}
+
+func (c *cli) exportHive(args []string) error {
+	flags := c.flags("hive export")
+	file := flags.String("file", "", "write to this file instead of stdout")
+	values, err := c.parse(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	gdb, err := c.db()
+	if err != nil {
+		return err
+	}
+	found, err := findHive(gdb, values[0])
+	if err != nil {
+		return err
+	}
+
+	export := hiveExport{Version: exportVersion, Exported: time.Now().UTC(), Hive: found}
+	var rules automod.AutomodConfig
+	if result := gdb.Where("hive_uuid = ?", found.UUID).Limit(1).Find(&rules); result.Error != nil {
+		return result.Error
+	} else if result.RowsAffected > 0 {
+		export.Automod = &rules
+	}
+
+	contents := gdb.Model(&models.Content{}).Select("uuid").Where("hive_uuid = ?", found.UUID)
+	comments := gdb.Model(&models.Comment{}).Select("uuid").Where("content_uuid IN (?)", contents)
+	queries := []struct {
+		dst   interface{}
+		query *gorm.DB
+	}{
+		{&export.Members, gdb.Where("hive_uuid = ?", found.UUID).Order("id")},
+		{&export.Contents, gdb.Where("hive_uuid = ?", found.UUID).Order("id")},
+		{&export.Comments, gdb.Where("content_uuid IN (?)", contents).Order("id")},
+		{&export.ContentVotes, gdb.Where("content_uuid IN (?)", contents).Order("id")},
+		{&export.CommentVotes, gdb.Where("comment_uuid IN (?)", comments).Order("id")},
+	}
+	for _, q := range queries {
+		if result := q.query.Find(q.dst); result.Error != nil {
+			return result.Error
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return err
+	}
+
+	//with no file the export itself is the output
+	if *file == "" {
+		return nil
+	}
+	return c.print(importTable(export))
+}
+
+// importHive loads an export into an empty slot: neither the hive's uuid nor its name may be taken, and
+// every account it refers to has to exist. Rows get new ids but keep their uuids.
+func (c *cli) importHive(args []string) error {
+	flags := c.flags("hive import")
+	file := flags.String("file", "", "read from this file instead of stdin")
+	if _, err := c.parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	var export hiveExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return fmt.Errorf("reading the export: %w", err)
+	}
+	if export.Version != exportVersion {
+		return fmt.Errorf("export version %d cannot be imported, expected %d", export.Version, exportVersion)
+	}
+
+	gdb, err := c.db()
+	if err != nil {
+		return err
+	}
+
+	var taken int64
+	if result := gdb.Model(&models.Hive{}).Where("uuid = ? OR name = ?", export.Hive.UUID, export.Hive.Name).Count(&taken); result.Error != nil {
+		return result.Error
+	}
+	if taken > 0 {
+		return fmt.Errorf("a hive named %s or with uuid %s already exists", export.Hive.Name, export.Hive.UUID)
+	}
+	if missing, err := missingAccounts(gdb, export); err != nil {
+		return err
+	} else if len(missing) > 0 {
+		return fmt.Errorf("%d accounts in the export do not exist, such as %s", len(missing), missing[0])
+	}
+
+	err = gdb.Transaction(func(tx *gorm.DB) error {
+		export.Hive.ID = 0
+		if result := tx.Create(&export.Hive); result.Error != nil {
+			return result.Error
+		}
+		if export.Automod != nil {
+			export.Automod.ID = 0
+			if result := tx.Create(export.Automod); result.Error != nil {
+				return result.Error
+			}
+		}
+		for i := range export.Members {
+			export.Members[i].ID = 0
+		}
+		for i := range export.Contents {
+			export.Contents[i].ID = 0
+		}
+		for i := range export.Comments {
+			export.Comments[i].ID = 0
+		}
+		for i := range export.ContentVotes {
+			export.ContentVotes[i].ID = 0
+		}
+		for i := range export.CommentVotes {
+			export.CommentVotes[i].ID = 0
+		}
+		if err := insert(tx, export.Members); err != nil {
+			return err
+		}
+		if err := insert(tx, export.Contents); err != nil {
+			return err
+		}
+		if err := insert(tx, export.Comments); err != nil {
+			return err
+		}
+		if err := insert(tx, export.ContentVotes); err != nil {
+			return err
+		}
+		return insert(tx, export.CommentVotes)
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(importTable(export))
+}
+
+func insert[T any](tx *gorm.DB, rows []T) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(rows, importBatchSize).Error
+}
+
+// missingAccounts lists the accounts the export refers to that do not exist.
+func missingAccounts(gdb *gorm.DB, export hiveExport) ([]string, error) {
+	referenced := map[string]bool{export.Hive.AccountUUID: true}
+	for _, member := range export.Members {
+		referenced[member.AccountUUID] = true
+	}
+	for _, content := range export.Contents {
+		referenced[content.AccountUUID] = true
+	}
+	for _, comment := range export.Comments {
+		referenced[comment.AccountUUID] = true
+	}
+	for _, vote := range export.ContentVotes {
+		referenced[vote.AccountUUID] = true
+	}
+	for _, vote := range export.CommentVotes {
+		referenced[vote.AccountUUID] = true
+	}
+	uuids := make([]string, 0, len(referenced))
+	for uuid := range referenced {
+		uuids = append(uuids, uuid)
+	}
+
+	var existing []string
+	if result := gdb.Model(&models.Account{}).Where("uuid IN ?", uuids).Pluck("uuid", &existing); result.Error != nil {
+		return nil, result.Error
+	}
+	for _, uuid := range existing {
+		delete(referenced, uuid)
+	}
+	var missing []string
+	for uuid := range referenced {
+		missing = append(missing, uuid)
+	}
+	return missing, nil
+}
+
+type exportSummary struct {
+	Hive         string `json:"Hive"`
+	UUID         string `json:"Uuid"`
+	Contents     int    `json:"Contents"`
+	Comments     int    `json:"Comments"`
+	ContentVotes int    `json:"ContentVotes"`
+	CommentVotes int    `json:"CommentVotes"`
+}
+
+func importTable(export hiveExport) table {
+	summary := exportSummary{
+		Hive:         export.Hive.Name,
+		UUID:         export.Hive.UUID,
+		Contents:     len(export.Contents),
+		Comments:     len(export.Comments),
+		ContentVotes: len(export.ContentVotes),
+		CommentVotes: len(export.CommentVotes),
+	}
+	return table{
+		headers: []string{"HIVE", "UUID", "CONTENTS", "COMMENTS", "CONTENT VOTES", "COMMENT VOTES"},
+		rows: [][]string{{summary.Hive, summary.UUID, strconv.Itoa(summary.Contents), strconv.Itoa(summary.Comments),
+			strconv.Itoa(summary.ContentVotes), strconv.Itoa(summary.CommentVotes)}},
+		value: summary,
+	}
+}
@@ -0,0 +1,164 @@
+// hivemindctl runs operational tasks against a Hivemind deployment. It reads the same config file,
+// environment and flags as the server, so it can be run wherever the server runs, such as `fly ssh console`.
+package main
+
+import (
+	"context"
+	"errors"
+	"example/hivemind-be/config"
+	"example/hivemind-be/db"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"gorm.io/gorm"
+)
+
+const usage = `usage: hivemindctl [config flags] <command> [flags]
+
+commands:
+  migrate up | down [steps] | status     apply, revert or list schema migrations
+  admin create -username U -email E      create an administrator, reading the password from stdin
+  ban account <account> -reason R -by A  ban an account site-wide, or from one hive with -hive
+  unban account <account> -by A          lift an account's site-wide ban, or its ban from -hive
+  ban hive <hive>                        ban a hive
+  unban hive <hive>                      lift a hive's ban
+  counters check [-repair]               report counters that differ from what they count
+  counters rebuild                       same as counters check -repair
+  purge -older-than DAYS [-dry-run]      hard delete content and comments soft deleted before then
+  hive export <hive> [-file F]           write a hive and everything posted in it as JSON
+  hive import [-file F]                  load a hive written by hive export
+  keys rotate                            generate new token signing secrets
+
+Accounts are given by username or uuid and hives by name or uuid. Every command takes -output table
+(the default) or -output json.`
+
+// cli carries what every command needs. The database is connected on first use so commands that do not
+// need it, such as keys rotate, work without one.
+type cli struct {
+	ctx    context.Context
+	cfg    config.Config
+	format string
+}
+
+func main() {
+	//the server logs JSON to stdout, here stdout is for results so logs go to stderr
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	c := &cli{ctx: ctx, cfg: cfg}
+	err = c.run(args[0], args[1], args[2:])
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "hivemindctl:", err)
+		os.Exit(1)
+	}
+}
+
+func (c *cli) run(group string, action string, args []string) error {
+	switch group + " " + action {
+	case "migrate up":
+		return c.migrateUp(args)
+	case "migrate down":
+		return c.migrateDown(args)
+	case "migrate status":
+		return c.migrateStatus(args)
+	case "admin create":
+		return c.createAdmin(args)
+	case "ban account":
+		return c.banAccount(args)
+	case "unban account":
+		return c.unbanAccount(args)
+	case "ban hive":
+		return c.setHiveBanned(args, true)
+	case "unban hive":
+		return c.setHiveBanned(args, false)
+	case "counters check":
+		return c.reconcileCounters("counters check", args, false)
+	case "counters rebuild":
+		return c.reconcileCounters("counters rebuild", args, true)
+	case "hive export":
+		return c.exportHive(args)
+	case "hive import":
+		return c.importHive(args)
+	case "keys rotate":
+		return c.rotateKeys(args)
+	}
+	//purge has no action, its first argument is a flag
+	if group == "purge" {
+		return c.purge(append([]string{action}, args...))
+	}
+	return fmt.Errorf("unknown command %q\n\n%s", group+" "+action, usage)
+}
+
+// db connects to the database the first time it is called.
+func (c *cli) db() (*gorm.DB, error) {
+	if db.Db == nil {
+		if err := db.ConnectDatabase(c.cfg.Database); err != nil {
+			return nil, err
+		}
+	}
+	return db.Db.WithContext(c.ctx), nil
+}
+
+// flags starts the flag set of a command with the -output flag every command shares.
+func (c *cli) flags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&c.format, "output", "table", "table or json")
+	return flags
+}
+
+// parse parses flags given before or after the positional arguments and returns the positional ones, of
+// which there must be between least and most.
+func (c *cli) parse(flags *flag.FlagSet, args []string, least int, most int) ([]string, error) {
+	var values []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		values = append(values, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	if c.format != "table" && c.format != "json" {
+		return nil, fmt.Errorf("-output must be table or json, got %q", c.format)
+	}
+	if len(values) < least || len(values) > most {
+		return nil, fmt.Errorf("%s takes %s, got %q", flags.Name(), arguments(least, most), strings.Join(values, " "))
+	}
+	return values, nil
+}
+
+func arguments(least int, most int) string {
+	switch {
+	case most == 0:
+		return "no arguments"
+	case least == most:
+		return fmt.Sprintf("%d argument(s)", least)
+	}
+	return fmt.Sprintf("%d to %d arguments", least, most)
+}
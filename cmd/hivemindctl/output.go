@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// table is a command's result as rows for people. value is the same result written by -output json.
+type table struct {
+	headers []string
+	rows    [][]string
+	value   interface{}
+}
+
+func (c *cli) print(t table) error {
+	if c.format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(t.value)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(t.headers, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// message prints a one line result, as {"Message": ...} for -output json.
+func (c *cli) message(format string, args ...interface{}) error {
+	text := fmt.Sprintf(format, args...)
+	if c.format == "json" {
+		return c.print(table{value: map[string]string{"Message": text}})
+	}
+	_, err := fmt.Println(text)
+	return err
+}
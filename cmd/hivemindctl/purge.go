@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var errDryRun = errors.New("dry run")
+
+// purgeSteps delete the purged rows and whatever refers to them, in foreign key order. The purged_contents
+// and purged_comments temporary tables hold what is being purged.
+var purgeSteps = []struct {
+	table string
+	query string
+}{
+	{"comment_votes", "DELETE FROM comment_votes WHERE comment_uuid IN (SELECT uuid FROM purged_comments)"},
+	{"mentions", "DELETE FROM mentions WHERE (source_type = 'comment' AND source_uuid IN (SELECT uuid FROM purged_comments)) OR (source_type = 'content' AND source_uuid IN (SELECT uuid FROM purged_contents))"},
+	{"reports", "DELETE FROM reports WHERE item_uuid IN (SELECT uuid FROM purged_comments UNION SELECT uuid FROM purged_contents)"},
+	{"revisions", "DELETE FROM revisions WHERE (item_type = 'comment' AND item_uuid IN (SELECT uuid FROM purged_comments)) OR (item_type = 'content' AND item_uuid IN (SELECT uuid FROM purged_contents))"},
+	{"comments", "DELETE FROM comments WHERE uuid IN (SELECT uuid FROM purged_comments)"},
+	{"content_votes", "DELETE FROM content_votes WHERE content_uuid IN (SELECT uuid FROM purged_contents)"},
+	{"contents", "DELETE FROM contents WHERE uuid IN (SELECT uuid FROM purged_contents)"},
+}
+
+type purged struct {
+	Table string `json:"Table"`
+	Rows  int64  `json:"Rows"`
+}
+
+// purge hard deletes content and comments that were soft deleted (deletion sets LastEdited) before the
+// cutoff. Comments on purged content go with it, and a deleted comment is kept while it has replies that are
+// not being purged. Mod queue history is kept.
+func (c *cli) purge(args []string) error {
+	flags := c.flags("purge")
+	days := flags.Int("older-than", 0, "purge what was deleted more than this many days ago")
+	dryRun := flags.Bool("dry-run", false, "report what would be purged without deleting it")
+	if _, err := c.parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *days < 1 {
+		return fmt.Errorf("-older-than must be a positive number of days")
+	}
+	cutoff := time.Now().AddDate(0, 0, -*days)
+
+	gdb, err := c.db()
+	if err != nil {
+		return err
+	}
+
+	var results []purged
+	err = gdb.Transaction(func(tx *gorm.DB) error {
+		setup := []string{
+			`CREATE TEMPORARY TABLE purged_contents ON COMMIT DROP AS
+				SELECT uuid FROM contents WHERE deleted AND last_edited < @cutoff`,
+			`CREATE TEMPORARY TABLE purged_comments ON COMMIT DROP AS
+				SELECT c.uuid FROM comments c
+				WHERE c.content_uuid IN (SELECT uuid FROM purged_contents)
+				OR (c.deleted AND c.last_edited < @cutoff AND NOT EXISTS (
+					SELECT 1 FROM comments r WHERE r.parent_uuid = c.uuid AND NOT (r.deleted AND r.last_edited < @cutoff)))`,
+		}
+		for _, statement := range setup {
+			if result := tx.Exec(statement, map[string]interface{}{"cutoff": cutoff}); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		for _, step := range purgeSteps {
+			result := tx.Exec(step.query)
+			if result.Error != nil {
+				return fmt.Errorf("purging %s: %w", step.table, result.Error)
+			}
+			results = append(results, purged{Table: step.table, Rows: result.RowsAffected})
+		}
+		//the deletes ran to count the rows, rolling back undoes them
+		if *dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return err
+	}
+
+	t := table{headers: []string{"TABLE", "ROWS"}, value: results}
+	for _, result := range results {
+		t.rows = append(t.rows, []string{result.Table, strconv.FormatInt(result.Rows, 10)})
+	}
+	return c.print(t)
+}
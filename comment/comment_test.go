@@ -0,0 +1,247 @@
+package comment_test
+
+import (
+	"example/hivemind-be/apitest"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/comment"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// newRouter serves the comment routes the way routes.Routes does, on repos.
+func newRouter(repos repository.Repos) *gin.Engine {
+	apitest.Setup()
+	h := comment.NewHandler(repos)
+	router := gin.New()
+	router.POST("/content/uuid/:uuid/comment", h.CreateComment)
+	router.POST("/content/uuid/:uuid/comment/:parentuuid/reply", h.CreateCommentReply)
+	router.GET("/content/uuid/:uuid/comment", h.GetCommentsByContentUuid)
+	router.GET("/comment/uuid/:uuid/revisions", h.GetCommentRevisionsByUuid)
+	router.PATCH("/comment/uuid/:uuid/delete", h.DeleteCommentByUuid)
+	router.PATCH("/comment/uuid/:uuid/undelete", h.UndeleteCommentByUuid)
+	router.PATCH("/comment/uuid/:uuid/update", h.UpdateCommentByUuid)
+	router.PATCH("/comment/uuid/:uuid/add-upvote", h.AddCommentUpvoteByUuid)
+	router.PATCH("/comment/uuid/:uuid/add-downvote", h.AddCommentDownvoteByUuid)
+	router.PATCH("/comment/uuid/:uuid/remove-downvote", h.RemoveCommentDownvoteByUuid)
+	return router
+}
+
+// fixture is a hive with one post in it, counted in the hive.
+type fixture struct {
+	repos   repository.Repos
+	router  *gin.Engine
+	hive    models.Hive
+	content models.Content
+	auth    string //the author of the post
+}
+
+func newFixture(t *testing.T, change func(content *models.Content)) fixture {
+	t.Helper()
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	author, auth := apitest.Account(t, repos, "author")
+	hive := apitest.Hive(t, repos, author, "golang")
+	content := models.Content{
+		Hive:        hive.Name,
+		Title:       "Hello",
+		Author:      author.Username,
+		Message:     "Hi",
+		UUID:        uuid.NewString(),
+		HiveUUID:    hive.UUID,
+		AccountUUID: author.UUID,
+		Created:     pq.NullTime{Time: time.Now(), Valid: true},
+	}
+	if change != nil {
+		change(&content)
+	}
+	if err := repos.Contents.Create(&content); err != nil {
+		t.Fatal(err)
+	}
+	if !content.Draft {
+		hive.TotalContent = 1
+		if err := repos.Hives.Save(&hive); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fixture{repos: repos, router: router, hive: hive, content: content, auth: auth}
+}
+
+func (f fixture) comment(t *testing.T, auth string, message string) models.Comment {
+	t.Helper()
+	var created models.Comment
+	w := apitest.Do(t, f.router, http.MethodPost, "/content/uuid/"+f.content.UUID+"/comment", auth, models.Comment{Message: message})
+	apitest.Decode(t, w, http.StatusCreated, &created)
+	return created
+}
+
+// counts returns the comment count of the post and the comment and vote totals of the hive.
+func (f fixture) counts(t *testing.T) (int32, models.Hive) {
+	t.Helper()
+	content, err := f.repos.Contents.GetByUUID(f.content.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hive, err := f.repos.Hives.GetByUUID(f.hive.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content.CommentCount, hive
+}
+
+func TestCreateCommentCountsOnContentAndHive(t *testing.T) {
+	f := newFixture(t, nil)
+	_, auth := apitest.Account(t, f.repos, "commenter")
+
+	created := f.comment(t, auth, "Nice post, @author")
+	if len(created.Mentions) != 1 || created.Mentions[0].Name != "author" {
+		t.Errorf("Mentions = %+v, want author", created.Mentions)
+	}
+	count, hive := f.counts(t)
+	if count != 1 || hive.TotalComments != 1 {
+		t.Errorf("CommentCount = %d, TotalComments = %d, want 1 and 1", count, hive.TotalComments)
+	}
+
+	var listed []models.Comment
+	apitest.Decode(t, apitest.Do(t, f.router, http.MethodGet, "/content/uuid/"+f.content.UUID+"/comment", auth, nil), http.StatusOK, &listed)
+	if len(listed) != 1 || listed[0].UUID != created.UUID {
+		t.Errorf("listed = %+v, want the one comment", listed)
+	}
+}
+
+func TestRepliesOnlyGoOneLevelDeep(t *testing.T) {
+	f := newFixture(t, nil)
+	parent := f.comment(t, f.auth, "Parent")
+
+	var reply models.Comment
+	w := apitest.Do(t, f.router, http.MethodPost, "/content/uuid/"+f.content.UUID+"/comment/"+parent.UUID+"/reply", f.auth, models.Comment{Message: "Reply"})
+	apitest.Decode(t, w, http.StatusCreated, &reply)
+	if reply.ParentUUID != parent.UUID {
+		t.Errorf("ParentUUID = %q, want %q", reply.ParentUUID, parent.UUID)
+	}
+
+	w = apitest.Do(t, f.router, http.MethodPost, "/content/uuid/"+f.content.UUID+"/comment/"+reply.UUID+"/reply", f.auth, models.Comment{Message: "Too deep"})
+	if code := apitest.Code(t, w); code != apperr.ReplyToReply {
+		t.Errorf("replying to a reply: code = %s, want %s", code, apperr.ReplyToReply)
+	}
+	if count, _ := f.counts(t); count != 2 {
+		t.Errorf("CommentCount = %d, want 2", count)
+	}
+}
+
+func TestCannotCommentOnDraftsOrLockedContent(t *testing.T) {
+	draft := newFixture(t, func(content *models.Content) { content.Draft = true })
+	w := apitest.Do(t, draft.router, http.MethodPost, "/content/uuid/"+draft.content.UUID+"/comment", draft.auth, models.Comment{Message: "Hi"})
+	if w.Code != http.StatusNotFound || apitest.Code(t, w) != apperr.ContentNotFound {
+		t.Errorf("commenting on a draft: %d %s, want 404", w.Code, w.Body.String())
+	}
+
+	locked := newFixture(t, func(content *models.Content) { content.Locked = true })
+	w = apitest.Do(t, locked.router, http.MethodPost, "/content/uuid/"+locked.content.UUID+"/comment", locked.auth, models.Comment{Message: "Hi"})
+	if code := apitest.Code(t, w); code != apperr.ContentLocked {
+		t.Errorf("commenting on locked content: code = %s, want %s", code, apperr.ContentLocked)
+	}
+	if count, hive := locked.counts(t); count != 0 || hive.TotalComments != 0 {
+		t.Errorf("a refused comment was counted: CommentCount = %d, TotalComments = %d", count, hive.TotalComments)
+	}
+}
+
+func TestCommentVotesAndDeletionKeepCountersInStep(t *testing.T) {
+	f := newFixture(t, nil)
+	_, voterAuth := apitest.Account(t, f.repos, "voter")
+	created := f.comment(t, f.auth, "Vote on me")
+	path := "/comment/uuid/" + created.UUID
+
+	apitest.Decode(t, apitest.Do(t, f.router, http.MethodPatch, path+"/add-downvote", voterAuth, nil), http.StatusOK, nil)
+	w := apitest.Do(t, f.router, http.MethodPatch, path+"/add-upvote", voterAuth, nil)
+	if code := apitest.Code(t, w); code != apperr.AlreadyVoted {
+		t.Errorf("voting both ways: code = %s, want %s", code, apperr.AlreadyVoted)
+	}
+	if _, hive := f.counts(t); hive.TotalDownvotes != 1 {
+		t.Errorf("TotalDownvotes = %d, want 1", hive.TotalDownvotes)
+	}
+
+	apitest.Decode(t, apitest.Do(t, f.router, http.MethodPatch, path+"/delete", f.auth, nil), http.StatusOK, nil)
+	count, hive := f.counts(t)
+	if count != 0 || hive.TotalComments != 0 || hive.TotalDownvotes != 0 {
+		t.Errorf("after deleting: CommentCount = %d, TotalComments = %d, TotalDownvotes = %d, want 0, 0 and 0", count, hive.TotalComments, hive.TotalDownvotes)
+	}
+
+	//votes on a deleted comment are recorded but left out of the hive totals
+	apitest.Decode(t, apitest.Do(t, f.router, http.MethodPatch, path+"/remove-downvote", voterAuth, nil), http.StatusOK, nil)
+	if _, hive := f.counts(t); hive.TotalDownvotes != 0 {
+		t.Errorf("TotalDownvotes = %d after retracting on a deleted comment, want 0", hive.TotalDownvotes)
+	}
+
+	apitest.Decode(t, apitest.Do(t, f.router, http.MethodPatch, path+"/undelete", f.auth, nil), http.StatusOK, nil)
+	count, hive = f.counts(t)
+	if count != 1 || hive.TotalComments != 1 || hive.TotalDownvotes != 0 {
+		t.Errorf("after restoring: CommentCount = %d, TotalComments = %d, TotalDownvotes = %d, want 1, 1 and 0", count, hive.TotalComments, hive.TotalDownvotes)
+	}
+	w = apitest.Do(t, f.router, http.MethodPatch, path+"/undelete", f.auth, nil)
+	if code := apitest.Code(t, w); code != apperr.NotDeleted {
+		t.Errorf("restoring twice: code = %s, want %s", code, apperr.NotDeleted)
+	}
+}
+
+func TestUpdateCommentKeepsRevisions(t *testing.T) {
+	f := newFixture(t, nil)
+	created := f.comment(t, f.auth, "First\nSecond")
+	path := "/comment/uuid/" + created.UUID
+
+	var updated models.Comment
+	apitest.Decode(t, apitest.Do(t, f.router, http.MethodPatch, path+"/update", f.auth, models.Comment{Message: "First\nThird"}), http.StatusOK, &updated)
+	if updated.Message != "First\nThird" {
+		t.Errorf("Message = %q", updated.Message)
+	}
+
+	var revisions []struct {
+		Version int32
+		Changes []struct {
+			Field string
+			Lines []struct{ Op, Text string }
+		}
+	}
+	apitest.Decode(t, apitest.Do(t, f.router, http.MethodGet, path+"/revisions", f.auth, nil), http.StatusOK, &revisions)
+	if len(revisions) != 2 || len(revisions[1].Changes) != 1 {
+		t.Fatalf("revisions = %+v, want two versions with one change", revisions)
+	}
+	lines := revisions[1].Changes[0].Lines
+	if len(lines) != 3 || lines[0].Op != "=" || lines[1].Op != "-" || lines[2].Op != "+" {
+		t.Errorf("diff = %+v, want First kept, Second removed and Third added", lines)
+	}
+}
+
+func TestOnlyAuthorsAndModeratorsChangeComments(t *testing.T) {
+	f := newFixture(t, nil)
+	_, auth := apitest.Account(t, f.repos, "commenter")
+	_, otherAuth := apitest.Account(t, f.repos, "other")
+	created := f.comment(t, auth, "Nice post")
+	path := "/comment/uuid/" + created.UUID
+
+	w := apitest.Do(t, f.router, http.MethodPatch, path+"/update", otherAuth, models.Comment{Message: "Mine now"})
+	if code := apitest.Code(t, w); code != apperr.Forbidden {
+		t.Errorf("updating by another account: code = %s, want %s", code, apperr.Forbidden)
+	}
+	w = apitest.Do(t, f.router, http.MethodPatch, path+"/delete", otherAuth, nil)
+	if code := apitest.Code(t, w); code != apperr.Forbidden {
+		t.Errorf("deleting by another account: code = %s, want %s", code, apperr.Forbidden)
+	}
+
+	//the post's author owns the hive, so moderates the comment and sees its history once it is deleted
+	apitest.Decode(t, apitest.Do(t, f.router, http.MethodPatch, path+"/delete", f.auth, nil), http.StatusOK, nil)
+	var revisions []struct{ Version int32 }
+	apitest.Decode(t, apitest.Do(t, f.router, http.MethodGet, path+"/revisions", f.auth, nil), http.StatusOK, &revisions)
+	if len(revisions) != 1 {
+		t.Errorf("%d revisions, want 1", len(revisions))
+	}
+	if w := apitest.Do(t, f.router, http.MethodGet, path+"/revisions", otherAuth, nil); w.Code == http.StatusOK {
+		t.Errorf("another account saw the revisions of a deleted comment: %s", w.Body.String())
+	}
+}
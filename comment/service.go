@@ -0,0 +1,457 @@
+package comment
+
+import (
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/automod"
+	"example/hivemind-be/events"
+	"example/hivemind-be/hive"
+	"example/hivemind-be/markdown"
+	"example/hivemind-be/mention"
+	"example/hivemind-be/models"
+	"example/hivemind-be/modqueue"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/revision"
+	"example/hivemind-be/spam"
+	"example/hivemind-be/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// CommentService holds the rules for commenting, replying and deleting comments and keeping the content and
+// hive counters in step. Changes are saved in one transaction through Tx together with their events.
+type CommentService struct {
+	Accounts  repository.AccountRepo
+	Hives     repository.HiveRepo
+	Contents  repository.ContentRepo
+	Comments  repository.CommentRepo
+	Mentions  repository.MentionRepo
+	Revisions repository.RevisionRepo
+	Bans      repository.BanRepo
+	Tx        repository.Transactor
+}
+
+func NewCommentService(repos repository.Repos) *CommentService {
+	return &CommentService{Accounts: repos.Accounts, Hives: repos.Hives, Contents: repos.Contents, Comments: repos.Comments, Mentions: repos.Mentions, Revisions: repos.Revisions, Bans: repos.Bans, Tx: repos.Tx}
+}
+
+// revisionOf is the comment as edited by editorUUID, to be saved as its next version.
+func revisionOf(comment models.Comment, editorUUID string) models.Revision {
+	return models.Revision{
+		ItemType:   "comment",
+		ItemUUID:   comment.UUID,
+		Message:    comment.Message,
+		EditorUUID: editorUUID,
+		Created:    pq.NullTime{Time: time.Now(), Valid: true},
+	}
+}
+
+type CommentWithReplies struct {
+	Parent  models.Comment   `json:"Comment"`
+	Replies []models.Comment `json:"Replies"`
+}
+
+// AutomodSubject describes the comment to the AutoModerator engine.
+func AutomodSubject(comment models.Comment, hiveUUID string) automod.Subject {
+	return automod.Subject{
+		Type:        "comment",
+		UUID:        comment.UUID,
+		HiveUUID:    hiveUUID,
+		ContentUUID: comment.ContentUUID,
+		ParentUUID:  comment.ParentUUID,
+		AccountUUID: comment.AccountUUID,
+		Message:     comment.Message,
+	}
+}
+
+// mask replaces the message of deleted and removed comments with a placeholder and drops their mentions,
+// then renders every message.
+func mask(comments []models.Comment) {
+	for i := range comments {
+		if comments[i].Deleted || comments[i].Removed {
+			comments[i].Message = "This comment has been removed."
+			if comments[i].Deleted {
+				comments[i].Message = "This comment has been deleted."
+			}
+			comments[i].Mentions = []models.Mention{}
+		}
+		comments[i].MessageHtml = markdown.Render(comments[i].Message)
+	}
+}
+
+// withMentions sets the stored mentions on each of comments.
+func (s *CommentService) withMentions(comments []models.Comment) error {
+	uuids := make([]string, len(comments))
+	for i := range comments {
+		uuids[i] = comments[i].UUID
+	}
+	mentions, err := mention.BySource(s.Mentions, "comment", uuids)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Mentions = mentions[comments[i].UUID]
+	}
+	return nil
+}
+
+func validate(message string) error {
+	if !utils.ValidateCommentMessage(message) {
+		return apperr.New(apperr.ValidationFailed, "Message must be between 1 and 2048 characters.")
+	}
+	return nil
+}
+
+// isModerator reports whether the account moderates the hive of the content.
+func (s *CommentService) isModerator(content models.Content, accountUUID string) (bool, error) {
+	moderator, err := hive.CheckModerator(s.Hives, s.Accounts, content.HiveUUID, accountUUID)
+	if err != nil {
+		return false, apperr.Wrap(apperr.Internal, "There was an error checking your permissions. Please try again.", err)
+	}
+	return moderator, nil
+}
+
+// authorize returns Forbidden unless the actor is the author of the comment or a moderator of the hive of
+// the content it is on.
+func (s *CommentService) authorize(comment models.Comment, content models.Content, actorUUID string) error {
+	if comment.AccountUUID == actorUUID {
+		return nil
+	}
+	moderator, err := s.isModerator(content, actorUUID)
+	if err != nil {
+		return err
+	}
+	if !moderator {
+		return apperr.New(apperr.Forbidden, "Only the author or a moderator of this hive can change this comment.")
+	}
+	return nil
+}
+
+func (s *CommentService) ListByContent(contentUUID string, viewerUUID string) ([]models.Comment, error) {
+	comments, err := s.Comments.ListVisibleByContent(contentUUID, viewerUUID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.withMentions(comments); err != nil {
+		return nil, err
+	}
+	mask(comments)
+	return comments, nil
+}
+
+func (s *CommentService) Get(uuid string, viewerUUID string) (models.Comment, error) {
+	comment, err := s.Comments.GetVisibleByUUID(uuid, viewerUUID)
+	if err != nil {
+		return models.Comment{}, apperr.NotFoundAs(err, apperr.CommentNotFound, "Comment not found.")
+	}
+	comments := []models.Comment{comment}
+	if err := s.withMentions(comments); err != nil {
+		return models.Comment{}, err
+	}
+	mask(comments)
+	return comments[0], nil
+}
+
+func (s *CommentService) GetWithReplies(uuid string, viewerUUID string) (CommentWithReplies, error) {
+	comment, err := s.Get(uuid, viewerUUID)
+	if err != nil {
+		return CommentWithReplies{}, err
+	}
+	replies, err := s.Comments.ListVisibleReplies(uuid, viewerUUID)
+	if err != nil {
+		return CommentWithReplies{}, err
+	}
+	if err := s.withMentions(replies); err != nil {
+		return CommentWithReplies{}, err
+	}
+	mask(replies)
+	return CommentWithReplies{Parent: comment, Replies: replies}, nil
+}
+
+// Create comments on the content, or replies to parentUUID when it is set. Replies to replies are refused.
+// AutoModerator rules and the spam classifier run before the comment is saved, and a comment either of
+// them holds back is saved removed.
+func (s *CommentService) Create(author string, accountUUID string, contentUUID string, parentUUID string, message string) (models.Comment, error) {
+	if err := validate(message); err != nil {
+		return models.Comment{}, err
+	}
+
+	content, err := s.Contents.GetByUUID(contentUUID)
+	if err != nil {
+		return models.Comment{}, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+	}
+	//drafts cannot be commented on before they are published
+	if content.Draft {
+		return models.Comment{}, apperr.New(apperr.ContentNotFound, "Content not found.")
+	}
+
+	if content.Locked {
+		return models.Comment{}, apperr.New(apperr.ContentLocked, "This content is locked and cannot be commented on.")
+	}
+
+	banned, err := s.Bans.IsBanned(accountUUID, content.HiveUUID)
+	if err != nil {
+		return models.Comment{}, apperr.Wrap(apperr.Internal, "There was an error checking your bans. Please try again.", err)
+	}
+	if banned {
+		return models.Comment{}, apperr.New(apperr.Banned, "You are banned from posting in this hive.")
+	}
+
+	if parentUUID != "" {
+		parentComment, err := s.Comments.GetByUUID(parentUUID)
+		if err != nil {
+			return models.Comment{}, apperr.NotFoundAs(err, apperr.CommentNotFound, "Comment not found.")
+		}
+		if parentComment.ParentUUID != "" {
+			return models.Comment{}, apperr.New(apperr.ReplyToReply, "Cannot reply to a reply. Please reply to the parent comment.")
+		}
+	}
+
+	newComment := models.Comment{
+		Author:      author,
+		Message:     message,
+		UUID:        uuid.NewString(),
+		AccountUUID: accountUUID,
+		ContentUUID: content.UUID,
+		ParentUUID:  parentUUID,
+		Created:     pq.NullTime{Time: time.Now(), Valid: true},
+		LastEdited:  pq.NullTime{Valid: false},
+	}
+
+	newComment.MessageHtml = markdown.Render(newComment.Message)
+	newComment.Mentions, err = mention.Resolve(s.Accounts, s.Hives, newComment.Message)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	err = s.Tx.Transaction(func(tx repository.Repos) error {
+		//the content stays locked until the comment is counted, so it cannot be locked or deleted meanwhile
+		content, err := tx.Contents.GetForUpdate(contentUUID)
+		if err != nil {
+			return apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+		}
+		if content.Locked {
+			return apperr.New(apperr.ContentLocked, "This content is locked and cannot be commented on.")
+		}
+
+		subject := AutomodSubject(newComment, content.HiveUUID)
+		verdict, err := automod.Evaluate(tx, subject)
+		if err != nil {
+			return err
+		}
+		newComment.Removed = verdict.Remove
+
+		//likely spam is held for moderators instead of being published
+		spamResult, err := spam.Check(tx.Spam, spam.Item{
+			Type:        "comment",
+			UUID:        newComment.UUID,
+			HiveUUID:    content.HiveUUID,
+			AccountUUID: newComment.AccountUUID,
+			Message:     newComment.Message,
+		})
+		if err != nil {
+			return err
+		}
+		if spamResult.Spam {
+			newComment.Removed = true
+		}
+
+		if err := tx.Comments.Create(&newComment); err != nil {
+			return err
+		}
+		if err := tx.Mentions.Replace("comment", newComment.UUID, newComment.Mentions); err != nil {
+			return err
+		}
+		version := revisionOf(newComment, accountUUID)
+		if err := tx.Revisions.Append(&version); err != nil {
+			return err
+		}
+		if err := tx.Contents.AddCounts(content.UUID, repository.Counts{Comments: 1}); err != nil {
+			return err
+		}
+		//comments on deleted content are left out of the hive totals
+		if !content.Deleted {
+			if err := tx.Hives.AddCounts(content.HiveUUID, repository.Counts{Comments: 1}); err != nil {
+				return err
+			}
+		}
+		event := events.Comment(events.CommentCreated, newComment, content.HiveUUID, accountUUID)
+		if err := tx.Events.Append(&event); err != nil {
+			return err
+		}
+		if err := automod.Dispatch(tx, verdict, subject); err != nil {
+			return err
+		}
+		if spamResult.Spam {
+			return modqueue.Enqueue(tx.ModQueue, content.HiveUUID, "comment", newComment.UUID, "spam", spamResult.Reason())
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Comment{}, err
+	}
+	return newComment, nil
+}
+
+// History returns every version of the comment with what changed in each. Only moderators of the hive see
+// the revisions of deleted and removed comments.
+func (s *CommentService) History(uuid string, viewerUUID string) ([]revision.Entry, error) {
+	comment, err := s.Comments.GetByUUID(uuid)
+	if err != nil {
+		return nil, apperr.NotFoundAs(err, apperr.CommentNotFound, "Comment not found.")
+	}
+	content, err := s.Contents.GetByUUID(comment.ContentUUID)
+	if err != nil {
+		return nil, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+	}
+	moderator, err := s.isModerator(content, viewerUUID)
+	if err != nil {
+		return nil, err
+	}
+	if !moderator {
+		if _, err := s.Comments.GetVisibleByUUID(uuid, viewerUUID); err != nil {
+			return nil, apperr.NotFoundAs(err, apperr.CommentNotFound, "Comment not found.")
+		}
+		if comment.Deleted || comment.Removed {
+			return nil, apperr.New(apperr.Forbidden, "Only moderators of this hive can see the revisions of deleted or removed comments.")
+		}
+	}
+	revisions, err := s.Revisions.List("comment", comment.UUID)
+	if err != nil {
+		return nil, err
+	}
+	return revision.History(revisions), nil
+}
+
+func (s *CommentService) Delete(uuid string, actorUUID string) (models.Comment, error) {
+	return s.setDeleted(uuid, actorUUID, true)
+}
+
+func (s *CommentService) Undelete(uuid string, actorUUID string) (models.Comment, error) {
+	return s.setDeleted(uuid, actorUUID, false)
+}
+
+// setDeleted soft deletes or restores the comment and keeps the content and hive counters in step. The hive
+// totals also drop the comment's votes, and leave it alone when the content itself is deleted.
+func (s *CommentService) setDeleted(uuid string, actorUUID string, deleted bool) (models.Comment, error) {
+	comment, err := s.Comments.GetByUUID(uuid)
+	if err != nil {
+		return models.Comment{}, apperr.NotFoundAs(err, apperr.CommentNotFound, "Comment not found.")
+	}
+
+	content, err := s.Contents.GetByUUID(comment.ContentUUID)
+	if err != nil {
+		return models.Comment{}, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+	}
+	if err := s.authorize(comment, content, actorUUID); err != nil {
+		return models.Comment{}, err
+	}
+
+	eventType := events.CommentRestored
+	if deleted {
+		eventType = events.CommentDeleted
+	}
+	err = s.Tx.Transaction(func(tx repository.Repos) error {
+		//the content is locked before the comment, the same order voting on the comment locks them in
+		content, err := tx.Contents.GetForUpdate(comment.ContentUUID)
+		if err != nil {
+			return apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+		}
+		comment, err = tx.Comments.GetForUpdate(uuid)
+		if err != nil {
+			return apperr.NotFoundAs(err, apperr.CommentNotFound, "Comment not found.")
+		}
+		if comment.Deleted == deleted {
+			if deleted {
+				return apperr.New(apperr.AlreadyDeleted, "comment has already been deleted!")
+			}
+			return apperr.New(apperr.NotDeleted, "comment has not been deleted!")
+		}
+
+		delta := int32(1)
+		if deleted {
+			delta = -1
+		}
+		comment.Deleted = deleted
+		comment.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
+		if err := tx.Comments.Save(&comment); err != nil {
+			return err
+		}
+		if err := tx.Contents.AddCounts(content.UUID, repository.Counts{Comments: delta}); err != nil {
+			return err
+		}
+		if !content.Deleted {
+			err := tx.Hives.AddCounts(content.HiveUUID, repository.Counts{
+				Comments:  delta,
+				Upvotes:   delta * comment.Upvote,
+				Downvotes: delta * comment.Downvote,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		event := events.Comment(eventType, comment, content.HiveUUID, actorUUID)
+		return tx.Events.Append(&event)
+	})
+	if err != nil {
+		return models.Comment{}, err
+	}
+	comments := []models.Comment{comment}
+	if err := s.withMentions(comments); err != nil {
+		return models.Comment{}, err
+	}
+	comments[0].MessageHtml = markdown.Render(comments[0].Message)
+	return comments[0], nil
+}
+
+func (s *CommentService) Update(uuid string, actorUUID string, message string) (models.Comment, error) {
+	if err := validate(message); err != nil {
+		return models.Comment{}, err
+	}
+
+	comment, err := s.Comments.GetByUUID(uuid)
+	if err != nil {
+		return models.Comment{}, apperr.NotFoundAs(err, apperr.CommentNotFound, "Comment not found.")
+	}
+
+	content, err := s.Contents.GetByUUID(comment.ContentUUID)
+	if err != nil {
+		return models.Comment{}, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+	}
+	if err := s.authorize(comment, content, actorUUID); err != nil {
+		return models.Comment{}, err
+	}
+
+	mentions, err := mention.Resolve(s.Accounts, s.Hives, message)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	err = s.Tx.Transaction(func(tx repository.Repos) error {
+		//the comment is read again under a lock, so votes and moderation made since are not overwritten
+		comment, err = tx.Comments.GetForUpdate(uuid)
+		if err != nil {
+			return apperr.NotFoundAs(err, apperr.CommentNotFound, "Comment not found.")
+		}
+		comment.Message = message
+		comment.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
+		comment.MessageHtml = markdown.Render(comment.Message)
+		comment.Mentions = mentions
+		if err := tx.Comments.Save(&comment); err != nil {
+			return err
+		}
+		if err := tx.Mentions.Replace("comment", comment.UUID, comment.Mentions); err != nil {
+			return err
+		}
+		version := revisionOf(comment, actorUUID)
+		if err := tx.Revisions.Append(&version); err != nil {
+			return err
+		}
+		event := events.Comment(events.CommentUpdated, comment, content.HiveUUID, actorUUID)
+		return tx.Events.Append(&event)
+	})
+	if err != nil {
+		return models.Comment{}, err
+	}
+	return comment, nil
+}
@@ -0,0 +1,393 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is every setting the server reads at startup. Values come from the defaults below, then the YAML
+// file named by -config or CONFIG_FILE, then the environment (and .env), then command line flags.
+type Config struct {
+	Server         ServerConfig   `yaml:"server"`
+	Database       DatabaseConfig `yaml:"database"`
+	Token          TokenConfig    `yaml:"token"`
+	Tracing        TracingConfig  `yaml:"tracing"`
+	Counters       CountersConfig `yaml:"counters"`
+	Jobs           JobsConfig     `yaml:"jobs"`
+	Webhooks       WebhooksConfig `yaml:"webhooks"`
+	LogLevel       string         `yaml:"logLevel"` //debug, info, warn or error
+	MigrateOnStart bool           `yaml:"migrateOnStart"`
+}
+
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`
+	CORSOrigins     []string      `yaml:"corsOrigins"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"` //how long in-flight requests get to finish on shutdown
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslMode"`
+
+	ConnectTimeout time.Duration `yaml:"connectTimeout"` //how long to keep retrying the first connection
+}
+
+// DSN is the Postgres connection string for the database.
+func (d DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s", d.Host, d.User, d.Password, d.Name, d.Port, d.SSLMode)
+}
+
+// TokenConfig holds the signing secrets. The previous secrets are optional and only verify tokens, so tokens
+// issued before a key rotation keep working until they expire.
+type TokenConfig struct {
+	Secret                string        `yaml:"secret"`
+	RefreshSecret         string        `yaml:"refreshSecret"`
+	PreviousSecret        string        `yaml:"previousSecret"`
+	PreviousRefreshSecret string        `yaml:"previousRefreshSecret"`
+	Lifetime              time.Duration `yaml:"lifetime"`
+	RefreshLifetime       time.Duration `yaml:"refreshLifetime"`
+}
+
+// TracingConfig picks where spans are sent. The OTLP exporter reads its endpoint and headers from the
+// standard OTEL_EXPORTER_OTLP_* variables.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"` //otlp, stdout or none
+	ServiceName string  `yaml:"serviceName"`
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+// CountersConfig schedules the reconciliation of denormalized counters. An interval of 0 turns it off.
+type CountersConfig struct {
+	ReconcileInterval time.Duration `yaml:"reconcileInterval"`
+	Repair            bool          `yaml:"repair"` //overwrite drifted counters instead of only reporting them
+}
+
+// JobsConfig sizes the background job workers. With no workers the server leaves the queue to a separate
+// `worker` process.
+type JobsConfig struct {
+	Workers      int           `yaml:"workers"`
+	PollInterval time.Duration `yaml:"pollInterval"` //how often idle workers check for due jobs
+	Timeout      time.Duration `yaml:"timeout"`      //how long one run of a job may take
+}
+
+// WebhooksConfig bounds webhook deliveries. Private addresses are refused unless AllowPrivate is set, so hive
+// moderators cannot make the server call into its own network.
+type WebhooksConfig struct {
+	Timeout      time.Duration `yaml:"timeout"`      //how long a receiver gets to answer
+	MaxFailures  int           `yaml:"maxFailures"`  //failed attempts in a row before a webhook is disabled
+	AllowPrivate bool          `yaml:"allowPrivate"` //allow loopback and private network addresses
+}
+
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+var tracingExporters = []string{"otlp", "stdout", "none"}
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// minSecretLength is the shortest signing secret accepted, 32 bytes being the size of an HS256 key.
+const minSecretLength = 32
+
+func defaults() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			CORSOrigins:     []string{"*"},
+			ShutdownTimeout: 25 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Port:           5432,
+			SSLMode:        "require",
+			ConnectTimeout: time.Minute,
+		},
+		Token: TokenConfig{
+			Lifetime:        2 * time.Minute,
+			RefreshLifetime: 120 * time.Minute,
+		},
+		Counters: CountersConfig{
+			ReconcileInterval: time.Hour,
+		},
+		Jobs: JobsConfig{
+			Workers:      2,
+			PollInterval: time.Second,
+			Timeout:      5 * time.Minute,
+		},
+		Webhooks: WebhooksConfig{
+			Timeout:     10 * time.Second,
+			MaxFailures: 10,
+		},
+		LogLevel: "info",
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "hivemind-be",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load builds the config from every source and validates it. args are the command line arguments after the
+// program name, and the ones left after the flags (such as `migrate up`) are returned.
+func Load(args []string) (Config, []string, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Config{}, nil, fmt.Errorf("reading .env: %w", err)
+	}
+
+	flags := flag.NewFlagSet("hivemind-be", flag.ContinueOnError)
+	file := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML config file")
+	addr := flags.String("addr", "", "address the HTTP server listens on")
+	dbHost := flags.String("db-host", "", "database host")
+	dbPort := flags.Int("db-port", 0, "database port")
+	dbName := flags.String("db-name", "", "database name")
+	sslMode := flags.String("db-sslmode", "", "database sslmode")
+	migrateOnStart := flags.Bool("migrate-on-start", false, "apply pending migrations before serving")
+	logLevel := flags.String("log-level", "", "debug, info, warn or error")
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg := defaults()
+	if *file != "" {
+		contents, err := os.ReadFile(*file)
+		if err != nil {
+			return Config{}, nil, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(contents, &cfg); err != nil {
+			return Config{}, nil, fmt.Errorf("parsing config file %s: %w", *file, err)
+		}
+	}
+
+	problems := cfg.readEnv()
+
+	//flags override everything, but only the ones actually set
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "db-host":
+			cfg.Database.Host = *dbHost
+		case "db-port":
+			cfg.Database.Port = *dbPort
+		case "db-name":
+			cfg.Database.Name = *dbName
+		case "db-sslmode":
+			cfg.Database.SSLMode = *sslMode
+		case "migrate-on-start":
+			cfg.MigrateOnStart = *migrateOnStart
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		}
+	})
+
+	if problems = append(problems, cfg.problems()...); len(problems) > 0 {
+		return Config{}, nil, invalid(problems)
+	}
+	return cfg, flags.Args(), nil
+}
+
+// readEnv overrides the config with every variable that is set and returns the ones that could not be parsed.
+func (cfg *Config) readEnv() []string {
+	var problems []string
+	str := func(name string, dst *string) {
+		if value, ok := os.LookupEnv(name); ok {
+			*dst = value
+		}
+	}
+	num := func(name string, dst *int) {
+		if value, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s must be a number, got %q", name, value))
+				return
+			}
+			*dst = n
+		}
+	}
+	duration := func(name string, dst *time.Duration) {
+		if value, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s must be a duration such as 2m or 2h, got %q", name, value))
+				return
+			}
+			*dst = d
+		}
+	}
+	ratio := func(name string, dst *float64) {
+		if value, ok := os.LookupEnv(name); ok {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s must be a number, got %q", name, value))
+				return
+			}
+			*dst = f
+		}
+	}
+	boolean := func(name string, dst *bool) {
+		if value, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s must be true or false, got %q", name, value))
+				return
+			}
+			*dst = b
+		}
+	}
+
+	str("HTTP_ADDR", &cfg.Server.Addr)
+	if value, ok := os.LookupEnv("CORS_ORIGINS"); ok {
+		cfg.Server.CORSOrigins = splitList(value)
+	}
+	duration("SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+	str("DB_HOST", &cfg.Database.Host)
+	num("DB_PORT", &cfg.Database.Port)
+	str("DB_ACCOUNT", &cfg.Database.User)
+	str("DB_PASSWORD", &cfg.Database.Password)
+	str("DB_NAME", &cfg.Database.Name)
+	str("DB_SSLMODE", &cfg.Database.SSLMode)
+	duration("DB_CONNECT_TIMEOUT", &cfg.Database.ConnectTimeout)
+	str("TOKEN_SECRET", &cfg.Token.Secret)
+	str("REFRESH_TOKEN_SECRET", &cfg.Token.RefreshSecret)
+	str("TOKEN_PREVIOUS_SECRET", &cfg.Token.PreviousSecret)
+	str("REFRESH_TOKEN_PREVIOUS_SECRET", &cfg.Token.PreviousRefreshSecret)
+	duration("TOKEN_LIFETIME", &cfg.Token.Lifetime)
+	duration("REFRESH_TOKEN_LIFETIME", &cfg.Token.RefreshLifetime)
+	str("LOG_LEVEL", &cfg.LogLevel)
+	str("TRACING_EXPORTER", &cfg.Tracing.Exporter)
+	str("OTEL_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	ratio("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio)
+	duration("COUNTER_RECONCILE_INTERVAL", &cfg.Counters.ReconcileInterval)
+	boolean("COUNTER_RECONCILE_REPAIR", &cfg.Counters.Repair)
+	num("JOB_WORKERS", &cfg.Jobs.Workers)
+	duration("JOB_POLL_INTERVAL", &cfg.Jobs.PollInterval)
+	duration("JOB_TIMEOUT", &cfg.Jobs.Timeout)
+	duration("WEBHOOK_TIMEOUT", &cfg.Webhooks.Timeout)
+	num("WEBHOOK_MAX_FAILURES", &cfg.Webhooks.MaxFailures)
+	boolean("WEBHOOK_ALLOW_PRIVATE", &cfg.Webhooks.AllowPrivate)
+	boolean("MIGRATE_ON_START", &cfg.MigrateOnStart)
+	return problems
+}
+
+// Validate reports every problem with the config at once.
+func (cfg Config) Validate() error {
+	if problems := cfg.problems(); len(problems) > 0 {
+		return invalid(problems)
+	}
+	return nil
+}
+
+func (cfg Config) problems() []string {
+	var problems []string
+	required := func(name string, value string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, name+" is required")
+		}
+	}
+
+	required("HTTP_ADDR", cfg.Server.Addr)
+	if len(cfg.Server.CORSOrigins) == 0 {
+		problems = append(problems, "CORS_ORIGINS needs at least one origin")
+	}
+	if cfg.Server.ShutdownTimeout <= 0 {
+		problems = append(problems, "SHUTDOWN_TIMEOUT must be positive")
+	}
+
+	required("DB_HOST", cfg.Database.Host)
+	required("DB_ACCOUNT", cfg.Database.User)
+	required("DB_NAME", cfg.Database.Name)
+	if cfg.Database.Port < 1 || cfg.Database.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("DB_PORT must be between 1 and 65535, got %d", cfg.Database.Port))
+	}
+	if !contains(sslModes, cfg.Database.SSLMode) {
+		problems = append(problems, fmt.Sprintf("DB_SSLMODE must be one of %s, got %q", strings.Join(sslModes, ", "), cfg.Database.SSLMode))
+	}
+	if cfg.Database.ConnectTimeout < 0 {
+		problems = append(problems, "DB_CONNECT_TIMEOUT cannot be negative")
+	}
+
+	if len(cfg.Token.Secret) < minSecretLength {
+		problems = append(problems, fmt.Sprintf("TOKEN_SECRET must be at least %d characters", minSecretLength))
+	}
+	if len(cfg.Token.RefreshSecret) < minSecretLength {
+		problems = append(problems, fmt.Sprintf("REFRESH_TOKEN_SECRET must be at least %d characters", minSecretLength))
+	}
+	if cfg.Token.PreviousSecret != "" && len(cfg.Token.PreviousSecret) < minSecretLength {
+		problems = append(problems, fmt.Sprintf("TOKEN_PREVIOUS_SECRET must be at least %d characters when set", minSecretLength))
+	}
+	if cfg.Token.PreviousRefreshSecret != "" && len(cfg.Token.PreviousRefreshSecret) < minSecretLength {
+		problems = append(problems, fmt.Sprintf("REFRESH_TOKEN_PREVIOUS_SECRET must be at least %d characters when set", minSecretLength))
+	}
+	if cfg.Token.Secret != "" && cfg.Token.Secret == cfg.Token.RefreshSecret {
+		problems = append(problems, "TOKEN_SECRET and REFRESH_TOKEN_SECRET must be different")
+	}
+	if cfg.Token.Lifetime <= 0 {
+		problems = append(problems, "TOKEN_LIFETIME must be positive")
+	}
+	if cfg.Token.RefreshLifetime <= cfg.Token.Lifetime {
+		problems = append(problems, "REFRESH_TOKEN_LIFETIME must be longer than TOKEN_LIFETIME")
+	}
+
+	if cfg.Counters.ReconcileInterval < 0 {
+		problems = append(problems, "COUNTER_RECONCILE_INTERVAL cannot be negative")
+	}
+	if cfg.Jobs.Workers < 0 {
+		problems = append(problems, "JOB_WORKERS cannot be negative")
+	}
+	if cfg.Jobs.PollInterval <= 0 {
+		problems = append(problems, "JOB_POLL_INTERVAL must be positive")
+	}
+	if cfg.Jobs.Timeout <= 0 {
+		problems = append(problems, "JOB_TIMEOUT must be positive")
+	}
+	if cfg.Webhooks.Timeout <= 0 {
+		problems = append(problems, "WEBHOOK_TIMEOUT must be positive")
+	}
+	if cfg.Webhooks.MaxFailures < 1 {
+		problems = append(problems, "WEBHOOK_MAX_FAILURES must be at least 1")
+	}
+
+	if !contains(logLevels, cfg.LogLevel) {
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL must be one of %s, got %q", strings.Join(logLevels, ", "), cfg.LogLevel))
+	}
+	if !contains(tracingExporters, cfg.Tracing.Exporter) {
+		problems = append(problems, fmt.Sprintf("TRACING_EXPORTER must be one of %s, got %q", strings.Join(tracingExporters, ", "), cfg.Tracing.Exporter))
+	}
+	required("OTEL_SERVICE_NAME", cfg.Tracing.ServiceName)
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		problems = append(problems, fmt.Sprintf("TRACING_SAMPLE_RATIO must be between 0 and 1, got %g", cfg.Tracing.SampleRatio))
+	}
+	return problems
+}
+
+func invalid(problems []string) error {
+	return errors.New("invalid config:\n  " + strings.Join(problems, "\n  "))
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
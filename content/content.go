@@ -1,44 +1,35 @@
 package content
 
 import (
-	"example/hivemind-be/db"
-	"example/hivemind-be/hive"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/metrics"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
 	"example/hivemind-be/utils"
+	"example/hivemind-be/vote"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"github.com/lib/pq"
 )
 
-// GORM uses the name of your type as the DB table to query. Here the type is Message so gorm will use the messages table by default.
-type Content struct {
-	ID           int32       `json:"Id" gorm:"primaryKey:type:int32"` //cannot be updated
-	Hive         string      `json:"Hive"`                            //cannot be updated
-	Title        string      `json:"Title"`                           //can be updated
-	Author       string      `json:"Author"`                          //cannot be updated
-	Message      string      `json:"Message"`                         //can be updated
-	UUID         string      `json:"Uuid"`                            //cannot be update
-	HiveUUID     string      `json:"HiveUuid"`                        //cannot be update
-	AccountUUID  string      `json:"AccountUuid"`                     //cannot be update
-	Link         string      `json:"Link" gorm:"default:null"`        //can be updated
-	ImageLink    string      `json:"ImageLink" gorm:"default:null"`   //can be updated
-	Upvote       int32       `json:"Upvote"`                          //cannot be updated
-	Downvote     int32       `json:"Downvote"`                        //cannot be updated
-	CommentCount int32       `json:"CommentCount"`                    //cannot be updated
-	Deleted      bool        `json:"Deleted"`                         //can be updated
-	Created      pq.NullTime `json:"Created"`                         //cannot be updated
-	LastEdited   pq.NullTime `json:"LastEdited"`                      //updated when an update occurs
+// Handler serves the content routes through the content and vote services.
+type Handler struct {
+	Repos repository.Repos
 }
 
-type ContentVote struct {
-	ID          int32
-	AccountUUID string
-	ContentUUID string
-	Upvote      bool
-	Downvote    bool
-	LastEdited  pq.NullTime
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+// contents is the content service with its queries bound to the request.
+func (h *Handler) contents(c *gin.Context) *ContentService {
+	return NewContentService(h.Repos.WithContext(c.Request.Context()))
+}
+
+// votes is the vote service with its queries bound to the request.
+func (h *Handler) votes(c *gin.Context) *vote.VoteService {
+	return vote.NewVoteService(h.Repos.WithContext(c.Request.Context()))
 }
 
 type VoteResults struct {
@@ -46,143 +37,90 @@ type VoteResults struct {
 	Downvotes []string `json:"Downvotes"`
 }
 
-func GetContent(c *gin.Context) {
-	var content []Content
-
+func (h *Handler) GetContent(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	if result := db.Db.Order("id asc").Find(&content); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	content, err := h.contents(c).List(claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, &content)
 }
 
-func GetContentById(c *gin.Context) {
-	var content Content
-
+func (h *Handler) GetContentById(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	id := c.Param("id")
-	if result := db.Db.First(&content, id); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperr.Write(c, apperr.New(apperr.BadRequest, "id must be a number."))
+		return
+	}
+	content, err := h.contents(c).GetByID(id, claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, content)
 }
 
-func GetContentByUuid(c *gin.Context) {
-	var content Content
-
+func (h *Handler) GetContentByUuid(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	uuid := c.Param("uuid")
-	if result := db.Db.Where("uuid = ?", uuid).First(&content); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	content, err := h.contents(c).Get(c.Param("uuid"), claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, content)
 }
 
-func GetContentByHiveUuid(c *gin.Context) {
-	var content []Content
-
+func (h *Handler) GetContentByHiveUuid(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	uuid := c.Param("uuid")
-	if result := db.Db.Where("hive_uuid = ?", uuid).Find(&content); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	content, err := h.contents(c).ListByHive(c.Param("uuid"), claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, content)
 }
 
-func CreateContent(c *gin.Context) {
-	var content Content
-	var hive hive.Hive
-
+// GetContentRevisionsByUuid lists every version of the content, oldest first, each with its changes from the
+// one before.
+func (h *Handler) GetContentRevisionsByUuid(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
 	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	if err := c.BindJSON(&content); err != nil {
+	revisions, err := h.contents(c).History(c.Param("uuid"), claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
-
-	if !utils.ValidateContentTitle(content.Title) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Title must be between 1 and 256 characters.",
-		})
-		return
-	}
-
-	if !utils.ValidateContentMessage(content.Message) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Message must be between 1 and 5000 characters.",
-		})
-		return
-	}
-
-	if result := db.Db.Where("name = ?", content.Hive).First(&hive); result.Error != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{
-			"Error": "Hive not found! Please use an existing hive or create a new hive first.",
-		})
-		return
-	}
-
-	content.Author = claims.Username
-	content.UUID = uuid.NewString()
-	content.HiveUUID = hive.UUID
-	content.AccountUUID = claims.AccountUUID
-	content.Upvote = 0
-	content.Downvote = 0
-	content.CommentCount = 0
-	content.Deleted = false
-	content.LastEdited = pq.NullTime{Valid: false}
-	content.Created = pq.NullTime{Time: time.Now(), Valid: true}
-
-	if result := db.Db.Create(&content); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"Error": "There was an error creating this content. Please try again.",
-		})
-		return
-	}
-
-	hive.TotalContent += 1
-	db.Db.Save(&hive)
-	c.JSON(http.StatusCreated, content)
+	c.JSON(http.StatusOK, revisions)
 }
 
-func AddContentUpvoteByUuid(c *gin.Context) {
-	var content Content
-	var hive hive.Hive
-	var contentVote ContentVote
+func (h *Handler) CreateContent(c *gin.Context) {
+	var content models.Content
 
 	authToken := c.GetHeader("Authorization")
 	claims, validToken := utils.ValidateAuthentication(c, authToken)
@@ -190,418 +128,159 @@ func AddContentUpvoteByUuid(c *gin.Context) {
 		return
 	}
 
-	uuid := c.Param("uuid")
-
-	//check content exsit
-	if result := db.Db.Where("uuid = ?", uuid).First(&content); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
-		return
-	}
-
-	//check hive exist
-	if result := db.Db.Where("uuid = ?", content.HiveUUID).First(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	if err := c.ShouldBindJSON(&content); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
 		return
 	}
 
-	voteQuery := map[string]interface{}{
-		"account_uuid": claims.AccountUUID,
-		"content_uuid": uuid,
-	}
-
-	//check account vote
-	if result := db.Db.Where(voteQuery).First(&contentVote); result.Error != nil {
-		//user has no record
-		content.Upvote += 1
-		hive.TotalUpvotes += 1
-		contentVote.AccountUUID = claims.AccountUUID
-		contentVote.ContentUUID = uuid
-		contentVote.Upvote = true
-		contentVote.Downvote = false
-		contentVote.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
-		db.Db.Save(&content)
-		db.Db.Save(&hive)
-		db.Db.Save(&contentVote)
-		c.JSON(http.StatusOK, gin.H{
-			"Message": "User successfully upvoted!",
-		})
+	content, err := h.contents(c).Create(claims.Username, claims.AccountUUID, content)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
-
-	//error if user has already voted
-	if contentVote.Upvote || contentVote.Downvote {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "User has already voted on this content!",
-		})
-		return
-	}
-
-	//user has false for both upvote and downvote
-	if !contentVote.Upvote && !contentVote.Downvote {
-		content.Upvote += 1
-		hive.TotalUpvotes += 1
-		contentVote.Upvote = true
-		contentVote.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
-		db.Db.Save(&content)
-		db.Db.Save(&hive)
-		db.Db.Save(&contentVote)
-		c.JSON(http.StatusOK, gin.H{
-			"Message": "User successfully upvoted!",
-		})
-		return
+	if !content.Draft {
+		metrics.ContentPosted.Inc()
 	}
+	c.JSON(http.StatusCreated, content)
 }
 
-func RemoveContentUpvoteByUuid(c *gin.Context) {
-	var content Content
-	var hive hive.Hive
-	var contentVote ContentVote
-
+// PublishContentByUuid publishes one of the account's drafts now, whether or not it is scheduled.
+func (h *Handler) PublishContentByUuid(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
 	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	uuid := c.Param("uuid")
-
-	//check content exist
-	if result := db.Db.Where("uuid = ?", uuid).First(&content); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
-		return
-	}
-
-	//check hive exist
-	if result := db.Db.Where("uuid = ?", content.HiveUUID).First(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	content, err := h.contents(c).Publish(c.Param("uuid"), claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
-
-	voteQuery := map[string]interface{}{
-		"account_uuid": claims.AccountUUID,
-		"content_uuid": uuid,
-	}
-
-	//check account vote
-	if result := db.Db.Where(voteQuery).First(&contentVote); result.Error != nil {
-		//user has not voted at all
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "User has not voted on this content!",
-		})
-		return
-	}
-
-	//error if user has not already upvoted or has downvoted
-	if !contentVote.Upvote || contentVote.Downvote {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "User has not upvoted on this content!",
-		})
-		return
-	}
-
-	content.Upvote -= 1
-	hive.TotalUpvotes -= 1
-	contentVote.Upvote = false
-	contentVote.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
-	db.Db.Save(&content)
-	db.Db.Save(&hive)
-	db.Db.Save(&contentVote)
-	c.JSON(http.StatusOK, gin.H{
-		"Message": "User upvote removed sucessfully!",
-	})
+	metrics.ContentPosted.Inc()
+	c.JSON(http.StatusOK, content)
 }
 
-func AddContentDownvoteByUuid(c *gin.Context) {
-	var content Content
-	var hive hive.Hive
-	var contentVote ContentVote
-
+// GetDrafts lists the account's drafts, newest first.
+func (h *Handler) GetDrafts(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
 	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	uuid := c.Param("uuid")
-
-	//check content exist
-	if result := db.Db.Where("uuid = ?", uuid).First(&content); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	drafts, err := h.contents(c).Drafts(claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
-
-	//check hive exist
-	if result := db.Db.Where("uuid = ?", content.HiveUUID).First(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
-		return
+	if drafts == nil {
+		drafts = []models.Content{}
 	}
+	c.JSON(http.StatusOK, drafts)
+}
 
-	voteQuery := map[string]interface{}{
-		"account_uuid": claims.AccountUUID,
-		"content_uuid": uuid,
-	}
-
-	//check account vote
-	if result := db.Db.Where(voteQuery).First(&contentVote); result.Error != nil {
-		//user has no record
-		content.Downvote += 1
-		hive.TotalDownvotes += 1
-		contentVote.AccountUUID = claims.AccountUUID
-		contentVote.ContentUUID = uuid
-		contentVote.Upvote = false
-		contentVote.Downvote = true
-		contentVote.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
-		db.Db.Save(&content)
-		db.Db.Save(&hive)
-		db.Db.Save(&contentVote)
-		c.JSON(http.StatusOK, gin.H{
-			"Message": "User successfully downvoted!",
-		})
-		return
-	}
+func (h *Handler) AddContentUpvoteByUuid(c *gin.Context) {
+	h.changeVote(c, vote.Up, true, "User successfully upvoted!")
+}
 
-	//error if user has already voted
-	if contentVote.Upvote || contentVote.Downvote {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "User has already voted on this content!",
-		})
-		return
-	}
+func (h *Handler) RemoveContentUpvoteByUuid(c *gin.Context) {
+	h.changeVote(c, vote.Up, false, "User upvote removed sucessfully!")
+}
 
-	//user has false for both upvote and downvote
-	if !contentVote.Upvote && !contentVote.Downvote {
-		content.Downvote += 1
-		hive.TotalDownvotes += 1
-		contentVote.Downvote = true
-		contentVote.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
-		db.Db.Save(&content)
-		db.Db.Save(&hive)
-		db.Db.Save(&contentVote)
-		c.JSON(http.StatusOK, gin.H{
-			"Message": "User successfully downvoted!",
-		})
-		return
-	}
+func (h *Handler) AddContentDownvoteByUuid(c *gin.Context) {
+	h.changeVote(c, vote.Down, true, "User successfully downvoted!")
 }
 
-func RemoveContentDownvoteByUuid(c *gin.Context) {
-	var content Content
-	var hive hive.Hive
-	var contentVote ContentVote
+func (h *Handler) RemoveContentDownvoteByUuid(c *gin.Context) {
+	h.changeVote(c, vote.Down, false, "User downvote removed sucessfully!")
+}
 
+func (h *Handler) changeVote(c *gin.Context, direction vote.Direction, cast bool, message string) {
 	authToken := c.GetHeader("Authorization")
 	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	uuid := c.Param("uuid")
-
-	//check content exist
-	if result := db.Db.Where("uuid = ?", uuid).First(&content); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
-		return
+	target := vote.Target{Type: "content", UUID: c.Param("uuid")}
+	votes := h.votes(c)
+	change := votes.Retract
+	if cast {
+		change = votes.Cast
 	}
-
-	//check hive exist
-	if result := db.Db.Where("uuid = ?", content.HiveUUID).First(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	if err := change(claims.AccountUUID, target, direction); err != nil {
+		apperr.Write(c, err)
 		return
 	}
-
-	voteQuery := map[string]interface{}{
-		"account_uuid": claims.AccountUUID,
-		"content_uuid": uuid,
-	}
-
-	//check account vote
-	if result := db.Db.Where(voteQuery).First(&contentVote); result.Error != nil {
-		//user has not voted at all
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "User has not voted on this content!",
-		})
-		return
-	}
-
-	//error if user has not already downvoted or has upvoted
-	if contentVote.Upvote || !contentVote.Downvote {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "User has not downvoted on this content!",
-		})
-		return
+	if cast {
+		metrics.VotesCast.WithLabelValues(target.Type, direction.String()).Inc()
 	}
-
-	content.Downvote -= 1
-	hive.TotalDownvotes -= 1
-	contentVote.Downvote = false
-	contentVote.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
-	db.Db.Save(&content)
-	db.Db.Save(&hive)
-	db.Db.Save(&contentVote)
 	c.JSON(http.StatusOK, gin.H{
-		"Message": "User downvote removed sucessfully!",
+		"Message": message,
 	})
 }
 
-func DeleteContentByUuid(c *gin.Context) {
-	var content Content
-	var hive hive.Hive
-
+func (h *Handler) DeleteContentByUuid(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	uuid := c.Param("uuid")
-
-	if result := db.Db.Where("uuid = ?", uuid).First(&content); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
-		return
-	}
-
-	if content.Deleted {
-		c.JSON(http.StatusBadRequest, gin.H{"Error": "content has already been deleted!"})
+	content, err := h.contents(c).Delete(c.Param("uuid"), claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
-
-	if result := db.Db.Where("uuid = ?", content.HiveUUID).First(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
-		return
-	}
-
-	hive.TotalContent -= 1
-	content.Deleted = true
-	content.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
-	db.Db.Save(&content)
-	db.Db.Save(&hive)
 	c.JSON(http.StatusOK, content)
 }
 
-func UndeleteContentByUuid(c *gin.Context) {
-	var content Content
-	var hive hive.Hive
-
+func (h *Handler) UndeleteContentByUuid(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	uuid := c.Param("uuid")
-
-	if result := db.Db.Where("uuid = ?", uuid).First(&content); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	content, err := h.contents(c).Undelete(c.Param("uuid"), claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
-
-	if !content.Deleted {
-		c.JSON(http.StatusBadRequest, gin.H{"Error": "content has not been deleted!"})
-		return
-	}
-
-	if result := db.Db.Where("uuid = ?", content.HiveUUID).First(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
-		return
-	}
-
-	hive.TotalContent += 1
-	content.Deleted = false
-	content.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
-	db.Db.Save(&content)
-	db.Db.Save(&hive)
 	c.JSON(http.StatusOK, content)
 }
 
-func UpdateContentByUuid(c *gin.Context) {
-	var content Content
-	var updateContent Content
+func (h *Handler) UpdateContentByUuid(c *gin.Context) {
+	var updateContent models.Content
 
 	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	if err := c.BindJSON(&updateContent); err != nil {
+	if err := c.ShouldBindJSON(&updateContent); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
 		return
 	}
 
-	if !utils.ValidateContentTitle(updateContent.Title) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Title must be between 1 and 256 characters.",
-		})
+	content, err := h.contents(c).Update(c.Param("uuid"), claims.AccountUUID, updateContent)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
-
-	if !utils.ValidateContentMessage(updateContent.Message) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Message must be between 1 and 5000 characters.",
-		})
-		return
-	}
-
-	uuid := c.Param("uuid")
-
-	if result := db.Db.Where("uuid = ?", uuid).First(&content); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
-		return
-	}
-	if val, ok := utils.JsonDataHasKey(updateContent, "Title"); ok {
-		content.Title, _ = val.(string)
-	}
-	if val, ok := utils.JsonDataHasKey(updateContent, "Message"); ok {
-		content.Message, _ = val.(string)
-	}
-	if val, ok := utils.JsonDataHasKey(updateContent, "Link"); ok {
-		content.Link, _ = val.(string)
-	}
-	if val, ok := utils.JsonDataHasKey(updateContent, "ImageLink"); ok {
-		content.ImageLink, _ = val.(string)
-	}
-
-	content.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
-
-	db.Db.Save(&content)
 	c.JSON(http.StatusOK, content)
 }
 
-func GetContentVotesByAccount(c *gin.Context) {
-	var contentVotes []ContentVote
-
+func (h *Handler) GetContentVotesByAccount(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
 	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	db.Db.Where("account_uuid = ?", claims.AccountUUID).Find(&contentVotes)
+	contentVotes, _ := h.votes(c).ContentVotes(claims.AccountUUID)
 
 	var result VoteResults
 	for _, item := range contentVotes {
@@ -0,0 +1,539 @@
+package content_test
+
+import (
+	"example/hivemind-be/apitest"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/automod"
+	"example/hivemind-be/content"
+	"example/hivemind-be/events"
+	"example/hivemind-be/models"
+	"example/hivemind-be/report"
+	"example/hivemind-be/repository"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// newRouter serves the content routes the way routes.Routes does, on repos.
+func newRouter(repos repository.Repos) *gin.Engine {
+	apitest.Setup()
+	h := content.NewHandler(repos)
+	router := gin.New()
+	router.GET("/content/uuid/:uuid", h.GetContentByUuid)
+	router.GET("/content/uuid/:uuid/revisions", h.GetContentRevisionsByUuid)
+	router.POST("/content", h.CreateContent)
+	router.PATCH("/content/uuid/:uuid/add-upvote", h.AddContentUpvoteByUuid)
+	router.PATCH("/content/uuid/:uuid/remove-upvote", h.RemoveContentUpvoteByUuid)
+	router.PATCH("/content/uuid/:uuid/add-downvote", h.AddContentDownvoteByUuid)
+	router.PATCH("/content/uuid/:uuid/delete", h.DeleteContentByUuid)
+	router.PATCH("/content/uuid/:uuid/undelete", h.UndeleteContentByUuid)
+	router.PATCH("/content/uuid/:uuid/update", h.UpdateContentByUuid)
+	router.PATCH("/content/uuid/:uuid/publish", h.PublishContentByUuid)
+	router.GET("/account/drafts", h.GetDrafts)
+	reports := report.NewHandler(repos)
+	router.POST("/content/uuid/:uuid/report", reports.CreateContentReport)
+	router.POST("/comment/uuid/:uuid/report", reports.CreateCommentReport)
+	return router
+}
+
+func hiveOf(t *testing.T, repos repository.Repos, uuid string) models.Hive {
+	t.Helper()
+	hive, err := repos.Hives.GetByUUID(uuid)
+	if err != nil {
+		t.Fatalf("loading hive: %v", err)
+	}
+	return hive
+}
+
+func post(t *testing.T, router *gin.Engine, auth string, body models.Content) models.Content {
+	t.Helper()
+	var created models.Content
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPost, "/content", auth, body), http.StatusCreated, &created)
+	return created
+}
+
+func TestCreateContentCountsInHive(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, _ := apitest.Account(t, repos, "owner")
+	_, auth := apitest.Account(t, repos, "author")
+	hive := apitest.Hive(t, repos, owner, "golang")
+
+	created := post(t, router, auth, models.Content{Hive: "golang", Title: "Hello", Message: "**First** post"})
+	if created.HiveUUID != hive.UUID || created.Author != "author" {
+		t.Errorf("created = %+v, want it posted by author in golang", created)
+	}
+	if created.MessageHtml != "<p><strong>First</strong> post</p>\n" {
+		t.Errorf("MessageHtml = %q", created.MessageHtml)
+	}
+	if got := hiveOf(t, repos, hive.UUID).TotalContent; got != 1 {
+		t.Errorf("TotalContent = %d, want 1", got)
+	}
+
+	var fetched models.Content
+	apitest.Decode(t, apitest.Do(t, router, http.MethodGet, "/content/uuid/"+created.UUID, auth, nil), http.StatusOK, &fetched)
+	if fetched.UUID != created.UUID {
+		t.Errorf("fetched %s, want %s", fetched.UUID, created.UUID)
+	}
+}
+
+func TestCreateContentValidates(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, auth := apitest.Account(t, repos, "owner")
+	apitest.Hive(t, repos, owner, "golang")
+
+	w := apitest.Do(t, router, http.MethodPost, "/content", "", models.Content{Hive: "golang", Title: "Hello", Message: "Hi"})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: status = %d, want 401", w.Code)
+	}
+	w = apitest.Do(t, router, http.MethodPost, "/content", auth, models.Content{Hive: "golang", Title: "", Message: "Hi"})
+	if code := apitest.Code(t, w); code != apperr.ValidationFailed {
+		t.Errorf("without a title: code = %s, want %s", code, apperr.ValidationFailed)
+	}
+	w = apitest.Do(t, router, http.MethodPost, "/content", auth, models.Content{Hive: "nohive", Title: "Hello", Message: "Hi"})
+	if code := apitest.Code(t, w); code != apperr.HiveNotFound {
+		t.Errorf("in a missing hive: code = %s, want %s", code, apperr.HiveNotFound)
+	}
+}
+
+func TestContentVotesKeepCountersInStep(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, auth := apitest.Account(t, repos, "owner")
+	_, voterAuth := apitest.Account(t, repos, "voter")
+	hive := apitest.Hive(t, repos, owner, "golang")
+	created := post(t, router, auth, models.Content{Hive: "golang", Title: "Hello", Message: "Hi"})
+	path := "/content/uuid/" + created.UUID
+
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/add-upvote", voterAuth, nil), http.StatusOK, nil)
+	w := apitest.Do(t, router, http.MethodPatch, path+"/add-upvote", voterAuth, nil)
+	if code := apitest.Code(t, w); code != apperr.AlreadyVoted {
+		t.Errorf("voting twice: code = %s, want %s", code, apperr.AlreadyVoted)
+	}
+	w = apitest.Do(t, router, http.MethodPatch, path+"/add-downvote", voterAuth, nil)
+	if code := apitest.Code(t, w); code != apperr.AlreadyVoted {
+		t.Errorf("voting the other way: code = %s, want %s", code, apperr.AlreadyVoted)
+	}
+
+	stored, _ := repos.Contents.GetByUUID(created.UUID)
+	if stored.Upvote != 1 || stored.Downvote != 0 {
+		t.Errorf("votes = +%d -%d, want +1 -0", stored.Upvote, stored.Downvote)
+	}
+	if got := hiveOf(t, repos, hive.UUID).TotalUpvotes; got != 1 {
+		t.Errorf("TotalUpvotes = %d, want 1", got)
+	}
+
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/remove-upvote", voterAuth, nil), http.StatusOK, nil)
+	w = apitest.Do(t, router, http.MethodPatch, path+"/remove-upvote", voterAuth, nil)
+	if code := apitest.Code(t, w); code != apperr.NotVoted {
+		t.Errorf("retracting twice: code = %s, want %s", code, apperr.NotVoted)
+	}
+	stored, _ = repos.Contents.GetByUUID(created.UUID)
+	if stored.Upvote != 0 {
+		t.Errorf("Upvote = %d after retracting, want 0", stored.Upvote)
+	}
+	if got := hiveOf(t, repos, hive.UUID).TotalUpvotes; got != 0 {
+		t.Errorf("TotalUpvotes = %d after retracting, want 0", got)
+	}
+}
+
+func TestShadowbannedVotesAreNotCounted(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, auth := apitest.Account(t, repos, "owner")
+	voter, voterAuth := apitest.Account(t, repos, "voter")
+	voter.Shadowbanned = true
+	if err := repos.Accounts.Save(&voter); err != nil {
+		t.Fatal(err)
+	}
+	hive := apitest.Hive(t, repos, owner, "golang")
+	created := post(t, router, auth, models.Content{Hive: "golang", Title: "Hello", Message: "Hi"})
+
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, "/content/uuid/"+created.UUID+"/add-upvote", voterAuth, nil), http.StatusOK, nil)
+	stored, _ := repos.Contents.GetByUUID(created.UUID)
+	if stored.Upvote != 0 || hiveOf(t, repos, hive.UUID).TotalUpvotes != 0 {
+		t.Errorf("a shadowbanned vote was counted: Upvote = %d", stored.Upvote)
+	}
+}
+
+func TestDeleteContentTakesItOutOfHiveTotals(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, auth := apitest.Account(t, repos, "owner")
+	_, voterAuth := apitest.Account(t, repos, "voter")
+	hive := apitest.Hive(t, repos, owner, "golang")
+	created := post(t, router, auth, models.Content{Hive: "golang", Title: "Hello", Message: "Hi"})
+	path := "/content/uuid/" + created.UUID
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/add-upvote", voterAuth, nil), http.StatusOK, nil)
+
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/delete", auth, nil), http.StatusOK, nil)
+	got := hiveOf(t, repos, hive.UUID)
+	if got.TotalContent != 0 || got.TotalUpvotes != 0 {
+		t.Errorf("after deleting: TotalContent = %d, TotalUpvotes = %d, want 0 and 0", got.TotalContent, got.TotalUpvotes)
+	}
+	w := apitest.Do(t, router, http.MethodPatch, path+"/delete", auth, nil)
+	if code := apitest.Code(t, w); code != apperr.AlreadyDeleted {
+		t.Errorf("deleting twice: code = %s, want %s", code, apperr.AlreadyDeleted)
+	}
+
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/undelete", auth, nil), http.StatusOK, nil)
+	got = hiveOf(t, repos, hive.UUID)
+	if got.TotalContent != 1 || got.TotalUpvotes != 1 {
+		t.Errorf("after restoring: TotalContent = %d, TotalUpvotes = %d, want 1 and 1", got.TotalContent, got.TotalUpvotes)
+	}
+}
+
+func TestDraftsAreOnlyForTheirAuthor(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, ownerAuth := apitest.Account(t, repos, "owner")
+	_, auth := apitest.Account(t, repos, "author")
+	hive := apitest.Hive(t, repos, owner, "golang")
+
+	draft := post(t, router, auth, models.Content{Hive: "golang", Title: "Soon", Message: "Not yet", Draft: true})
+	if !draft.Draft {
+		t.Fatalf("created = %+v, want a draft", draft)
+	}
+	if got := hiveOf(t, repos, hive.UUID).TotalContent; got != 0 {
+		t.Errorf("TotalContent = %d with only a draft, want 0", got)
+	}
+
+	//the hive owner moderates it, and still does not get to see or change the draft
+	path := "/content/uuid/" + draft.UUID
+	for _, request := range []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{http.MethodGet, path, nil},
+		{http.MethodGet, path + "/revisions", nil},
+		{http.MethodPatch, path + "/update", models.Content{Title: "Mine", Message: "Now"}},
+		{http.MethodPatch, path + "/delete", nil},
+		{http.MethodPatch, path + "/publish", nil},
+		{http.MethodPatch, path + "/add-upvote", nil},
+	} {
+		w := apitest.Do(t, router, request.method, request.path, ownerAuth, request.body)
+		if w.Code != http.StatusNotFound || apitest.Code(t, w) != apperr.ContentNotFound {
+			t.Errorf("%s %s by another account: %d %s, want 404", request.method, request.path, w.Code, w.Body.String())
+		}
+	}
+	stored, _ := repos.Contents.GetByUUID(draft.UUID)
+	if stored.Title != "Soon" || stored.Deleted || !stored.Draft {
+		t.Errorf("draft changed by another account: %+v", stored)
+	}
+
+	var drafts []models.Content
+	apitest.Decode(t, apitest.Do(t, router, http.MethodGet, "/account/drafts", auth, nil), http.StatusOK, &drafts)
+	if len(drafts) != 1 || drafts[0].UUID != draft.UUID {
+		t.Errorf("drafts = %+v, want the one draft", drafts)
+	}
+	apitest.Decode(t, apitest.Do(t, router, http.MethodGet, "/account/drafts", ownerAuth, nil), http.StatusOK, &drafts)
+	if len(drafts) != 0 {
+		t.Errorf("another account's drafts = %+v, want none", drafts)
+	}
+}
+
+func TestDraftsCannotBeReported(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, ownerAuth := apitest.Account(t, repos, "owner")
+	author, auth := apitest.Account(t, repos, "author")
+	hive := apitest.Hive(t, repos, owner, "golang")
+	draft := post(t, router, auth, models.Content{Hive: "golang", Title: "Soon", Message: "Not yet", Draft: true})
+	reply := models.Comment{UUID: uuid.NewString(), ContentUUID: draft.UUID, AccountUUID: author.UUID, Author: author.Username,
+		Message: "Note to self", Created: pq.NullTime{Time: time.Now(), Valid: true}}
+	if err := repos.Comments.Create(&reply); err != nil {
+		t.Fatal(err)
+	}
+
+	reason := models.Report{Reason: "Spam"}
+	for _, request := range []struct {
+		path string
+		auth string
+		code apperr.Code
+	}{
+		{"/content/uuid/" + draft.UUID + "/report", ownerAuth, apperr.ContentNotFound},
+		{"/content/uuid/" + draft.UUID + "/report", auth, apperr.ContentNotFound},
+		{"/comment/uuid/" + reply.UUID + "/report", ownerAuth, apperr.CommentNotFound},
+	} {
+		w := apitest.Do(t, router, http.MethodPost, request.path, request.auth, reason)
+		if w.Code != http.StatusNotFound || apitest.Code(t, w) != request.code {
+			t.Errorf("POST %s: %d %s, want 404 %s", request.path, w.Code, w.Body.String(), request.code)
+		}
+	}
+	queued, err := repos.ModQueue.List(hive.UUID, "pending")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 0 {
+		t.Errorf("modqueue = %+v, want the draft kept out of it", queued)
+	}
+}
+
+func TestAuthorEditsAndPublishesDraft(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, _ := apitest.Account(t, repos, "owner")
+	_, auth := apitest.Account(t, repos, "author")
+	hive := apitest.Hive(t, repos, owner, "golang")
+	draft := post(t, router, auth, models.Content{Hive: "golang", Title: "Soon", Message: "Not yet", Draft: true})
+	path := "/content/uuid/" + draft.UUID
+
+	later := pq.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	var updated models.Content
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/update", auth, models.Content{Title: "Now", Message: "Ready", PublishAt: later}), http.StatusOK, &updated)
+	if updated.Title != "Now" || !updated.PublishAt.Valid {
+		t.Errorf("updated = %+v, want the new title and a schedule", updated)
+	}
+
+	var revisions []struct{ Version int32 }
+	apitest.Decode(t, apitest.Do(t, router, http.MethodGet, path+"/revisions", auth, nil), http.StatusOK, &revisions)
+	if len(revisions) != 2 {
+		t.Errorf("%d revisions, want 2", len(revisions))
+	}
+
+	var published models.Content
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/publish", auth, nil), http.StatusOK, &published)
+	if published.Draft || published.PublishAt.Valid {
+		t.Errorf("published = %+v, want it live and unscheduled", published)
+	}
+	if got := hiveOf(t, repos, hive.UUID).TotalContent; got != 1 {
+		t.Errorf("TotalContent = %d after publishing, want 1", got)
+	}
+	w := apitest.Do(t, router, http.MethodPatch, path+"/publish", auth, nil)
+	if code := apitest.Code(t, w); code != apperr.AlreadyPublished {
+		t.Errorf("publishing twice: code = %s, want %s", code, apperr.AlreadyPublished)
+	}
+}
+
+// staleContents returns the content as it was read before, the way a publish racing another one reads a
+// draft the other has published since.
+type staleContents struct {
+	repository.ContentRepo
+	stale models.Content
+}
+
+func (r staleContents) GetByUUID(uuid string) (models.Content, error) {
+	return r.stale, nil
+}
+
+func TestPublishingDraftTwiceAtOncePublishesItOnce(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, _ := apitest.Account(t, repos, "owner")
+	author, auth := apitest.Account(t, repos, "author")
+	hive := apitest.Hive(t, repos, owner, "golang")
+	draft := post(t, router, auth, models.Content{Hive: "golang", Title: "Soon", Message: "Not yet", Draft: true})
+
+	first := content.NewContentService(repos)
+	if _, err := first.Publish(draft.UUID, author.UUID); err != nil {
+		t.Fatalf("publishing: %v", err)
+	}
+	due, _ := repos.Events.ListDue(time.Now(), 10)
+	for _, event := range due {
+		event.Dispatched = pq.NullTime{Time: time.Now(), Valid: true}
+		repos.Events.SaveAttempt(&event)
+	}
+
+	//the second publish read the draft before the first one committed
+	second := content.NewContentService(repos)
+	stored, _ := repos.Contents.GetByUUID(draft.UUID)
+	stored.Draft = true
+	second.Contents = staleContents{repos.Contents, stored}
+	_, err := second.Publish(draft.UUID, author.UUID)
+	if code := apperr.CodeOf(err); code != apperr.AlreadyPublished {
+		t.Errorf("publishing again: code = %s, want %s", code, apperr.AlreadyPublished)
+	}
+	if got := hiveOf(t, repos, hive.UUID).TotalContent; got != 1 {
+		t.Errorf("TotalContent = %d, want 1", got)
+	}
+	if due, _ := repos.Events.ListDue(time.Now(), 10); len(due) != 0 {
+		t.Errorf("%d more events after publishing again, want none: %+v", len(due), due)
+	}
+}
+
+func TestBannedAuthorsCannotPost(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, _ := apitest.Account(t, repos, "owner")
+	author, auth := apitest.Account(t, repos, "author")
+	hive := apitest.Hive(t, repos, owner, "golang")
+	ban := models.Ban{
+		UUID:        uuid.NewString(),
+		AccountUUID: author.UUID,
+		HiveUUID:    hive.UUID,
+		Reason:      "Spam",
+		BannedBy:    owner.UUID,
+		Active:      true,
+		Created:     pq.NullTime{Time: time.Now(), Valid: true},
+	}
+	if err := repos.Bans.Create(&ban); err != nil {
+		t.Fatal(err)
+	}
+
+	w := apitest.Do(t, router, http.MethodPost, "/content", auth, models.Content{Hive: "golang", Title: "Hello", Message: "Hi"})
+	if code := apitest.Code(t, w); code != apperr.Banned {
+		t.Errorf("posting while banned: code = %s, want %s", code, apperr.Banned)
+	}
+	if got := hiveOf(t, repos, hive.UUID).TotalContent; got != 0 {
+		t.Errorf("TotalContent = %d, want 0", got)
+	}
+}
+
+func TestAutomodRemovesMatchingContent(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, _ := apitest.Account(t, repos, "owner")
+	_, auth := apitest.Account(t, repos, "author")
+	hive := apitest.Hive(t, repos, owner, "golang")
+	system := models.Account{Username: automod.AutoModeratorName, Email: "automoderator@hivemind.invalid", UUID: automod.AutoModeratorUUID}
+	if err := repos.Accounts.Create(&system); err != nil {
+		t.Fatal(err)
+	}
+	config := models.AutomodConfig{HiveUUID: hive.UUID, Rules: `
+- Name: no ads
+  Type: content
+  Conditions:
+    Message: "(?i)buy now"
+  Actions: [remove, reply]
+  Reply: Ads are not allowed here.
+`}
+	if err := repos.Automod.SaveConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	kept := post(t, router, auth, models.Content{Hive: "golang", Title: "Hello", Message: "Hi"})
+	if kept.Removed {
+		t.Errorf("kept = %+v, want it live", kept)
+	}
+	removed := post(t, router, auth, models.Content{Hive: "golang", Title: "Deal", Message: "Buy now!"})
+	if !removed.Removed {
+		t.Errorf("removed = %+v, want it removed", removed)
+	}
+
+	replies, err := repos.Comments.ListByContent(removed.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 1 || replies[0].AccountUUID != automod.AutoModeratorUUID || replies[0].Message != "Ads are not allowed here." {
+		t.Errorf("replies = %+v, want the AutoModerator reply", replies)
+	}
+	if stored, _ := repos.Contents.GetByUUID(removed.UUID); stored.CommentCount != 1 {
+		t.Errorf("CommentCount = %d, want the reply counted", stored.CommentCount)
+	}
+}
+
+func TestReportRulesRecordTheRemoval(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, _ := apitest.Account(t, repos, "owner")
+	author, _ := apitest.Account(t, repos, "author")
+	_, reporterAuth := apitest.Account(t, repos, "reporter")
+	hive := apitest.Hive(t, repos, owner, "golang")
+	config := models.AutomodConfig{HiveUUID: hive.UUID, Rules: "- Name: reported\n  Conditions: {ReportsAtLeast: 1}\n  Actions: [remove]"}
+	if err := repos.Automod.SaveConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	//saved directly, so the removals are the only events of their items
+	now := pq.NullTime{Time: time.Now(), Valid: true}
+	reported := models.Content{UUID: uuid.NewString(), HiveUUID: hive.UUID, AccountUUID: author.UUID, Author: author.Username,
+		Title: "Hello", Message: "Hi", Created: now}
+	if err := repos.Contents.Create(&reported); err != nil {
+		t.Fatal(err)
+	}
+	reply := models.Comment{UUID: uuid.NewString(), ContentUUID: reported.UUID, AccountUUID: author.UUID, Author: author.Username,
+		Message: "Hi", Created: now}
+	if err := repos.Comments.Create(&reply); err != nil {
+		t.Fatal(err)
+	}
+
+	reason := models.Report{Reason: "Rude"}
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPost, "/comment/uuid/"+reply.UUID+"/report", reporterAuth, reason), http.StatusCreated, nil)
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPost, "/content/uuid/"+reported.UUID+"/report", reporterAuth, reason), http.StatusCreated, nil)
+
+	if stored, _ := repos.Contents.GetByUUID(reported.UUID); !stored.Removed {
+		t.Errorf("reported content = %+v, want it removed", stored)
+	}
+	if stored, _ := repos.Comments.GetByUUID(reply.UUID); !stored.Removed {
+		t.Errorf("reported comment = %+v, want it removed", stored)
+	}
+	due, err := repos.Events.ListDue(time.Now(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removals := map[string]string{}
+	for _, event := range due {
+		removals[event.AggregateUUID] = event.Type
+		if event.ActorUUID != automod.AutoModeratorUUID {
+			t.Errorf("%s by %q, want it by the AutoModerator", event.Type, event.ActorUUID)
+		}
+	}
+	want := map[string]string{reported.UUID: events.ContentRemoved, reply.UUID: events.CommentRemoved}
+	if !reflect.DeepEqual(removals, want) {
+		t.Errorf("events = %v, want %v", removals, want)
+	}
+}
+
+func TestOnlyAuthorsAndModeratorsChangeContent(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, ownerAuth := apitest.Account(t, repos, "owner")
+	_, auth := apitest.Account(t, repos, "author")
+	_, otherAuth := apitest.Account(t, repos, "other")
+	apitest.Hive(t, repos, owner, "golang")
+	created := post(t, router, auth, models.Content{Hive: "golang", Title: "Hello", Message: "Hi"})
+	path := "/content/uuid/" + created.UUID
+
+	for _, request := range []struct {
+		path string
+		body interface{}
+	}{
+		{path + "/update", models.Content{Title: "Mine", Message: "Now"}},
+		{path + "/delete", nil},
+	} {
+		w := apitest.Do(t, router, http.MethodPatch, request.path, otherAuth, request.body)
+		if code := apitest.Code(t, w); code != apperr.Forbidden {
+			t.Errorf("PATCH %s by another account: code = %s, want %s", request.path, code, apperr.Forbidden)
+		}
+	}
+	stored, _ := repos.Contents.GetByUUID(created.UUID)
+	if stored.Title != "Hello" || stored.Deleted {
+		t.Errorf("content changed by another account: %+v", stored)
+	}
+
+	//the hive owner moderates it
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/delete", ownerAuth, nil), http.StatusOK, nil)
+	w := apitest.Do(t, router, http.MethodPatch, path+"/undelete", otherAuth, nil)
+	if code := apitest.Code(t, w); code != apperr.Forbidden {
+		t.Errorf("restoring by another account: code = %s, want %s", code, apperr.Forbidden)
+	}
+}
+
+func TestModeratorsSeeHistoryOfDeletedContent(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, ownerAuth := apitest.Account(t, repos, "owner")
+	_, auth := apitest.Account(t, repos, "author")
+	_, otherAuth := apitest.Account(t, repos, "other")
+	apitest.Hive(t, repos, owner, "golang")
+	created := post(t, router, auth, models.Content{Hive: "golang", Title: "Hello", Message: "Hi"})
+	path := "/content/uuid/" + created.UUID
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/update", auth, models.Content{Title: "Hello again", Message: "Hi"}), http.StatusOK, nil)
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/delete", auth, nil), http.StatusOK, nil)
+
+	var revisions []struct{ Version int32 }
+	apitest.Decode(t, apitest.Do(t, router, http.MethodGet, path+"/revisions", ownerAuth, nil), http.StatusOK, &revisions)
+	if len(revisions) != 2 {
+		t.Errorf("%d revisions, want 2", len(revisions))
+	}
+	if w := apitest.Do(t, router, http.MethodGet, path+"/revisions", otherAuth, nil); w.Code == http.StatusOK {
+		t.Errorf("another account saw the revisions of deleted content: %s", w.Body.String())
+	}
+}
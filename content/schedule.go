@@ -0,0 +1,81 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/jobs"
+	"example/hivemind-be/metrics"
+	"example/hivemind-be/models"
+	"example/hivemind-be/notification"
+	"example/hivemind-be/repository"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PublishKind publishes the scheduled drafts that are due with PublishDue.
+const PublishKind = "content.publish"
+
+// publishBatch bounds the drafts one run publishes. The rest wait for the next run.
+const publishBatch = 100
+
+// PublishDue publishes every draft whose PublishAt has passed through ContentService.Publish, so it gets the
+// checks it would have had if the author had posted it then. A draft that fails them, because its author was
+// banned from the hive since, for example, is left a draft without a schedule and its author is told why.
+func PublishDue(ctx context.Context, gdb *gorm.DB, job jobs.Job) error {
+	gdb = gdb.WithContext(ctx)
+	var due []models.Content
+	result := gdb.Where("draft = ? AND deleted = ? AND publish_at <= ?", true, false, time.Now()).
+		Order("publish_at").Limit(publishBatch).Find(&due)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	service := NewContentService(repository.NewGormRepos(gdb))
+	for _, draft := range due {
+		_, err := service.Publish(draft.UUID, draft.AccountUUID)
+		if err == nil {
+			metrics.ContentPosted.Inc()
+			continue
+		}
+		switch apperr.CodeOf(err) {
+		case apperr.Internal:
+			return err
+		//published or deleted by its author since it was listed, so there is nothing to tell them
+		case apperr.AlreadyPublished, apperr.AlreadyDeleted:
+			continue
+		}
+		slog.Warn("could not publish a scheduled draft", "content", draft.UUID, "error", err)
+		if err := unschedule(gdb, draft, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unschedule clears the PublishAt of a draft that failed to publish and notifies its author with the reason,
+// in one transaction. The notification is made once per schedule, so rescheduling and failing again is told
+// again.
+func unschedule(gdb *gorm.DB, draft models.Content, cause error) error {
+	message := "it could not be posted"
+	var reason *apperr.Error
+	if errors.As(cause, &reason) && reason.Message != "" {
+		message = reason.Message
+	}
+	return gdb.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Model(&draft).Update("publish_at", nil); result.Error != nil {
+			return result.Error
+		}
+		return notification.Notify(repository.NewGormRepos(tx), notification.Notification{
+			AccountUUID: draft.AccountUUID,
+			Type:        notification.PublishFailed,
+			HiveUUID:    draft.HiveUUID,
+			ContentUUID: draft.UUID,
+			SourceUUID:  fmt.Sprintf("%s@%d", draft.UUID, draft.PublishAt.Time.Unix()),
+			Message:     fmt.Sprintf("Your draft %q could not be published in h/%s as scheduled: %s", draft.Title, draft.Hive, message),
+		})
+	})
+}
@@ -0,0 +1,562 @@
+package content
+
+import (
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/automod"
+	"example/hivemind-be/events"
+	"example/hivemind-be/hive"
+	"example/hivemind-be/markdown"
+	"example/hivemind-be/mention"
+	"example/hivemind-be/models"
+	"example/hivemind-be/modqueue"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/revision"
+	"example/hivemind-be/spam"
+	"example/hivemind-be/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ContentService holds the rules for posting, editing and deleting content and keeping the hive counters
+// in step. Changes are saved in one transaction through Tx together with their events.
+type ContentService struct {
+	Accounts  repository.AccountRepo
+	Hives     repository.HiveRepo
+	Contents  repository.ContentRepo
+	Comments  repository.CommentRepo
+	Mentions  repository.MentionRepo
+	Revisions repository.RevisionRepo
+	Bans      repository.BanRepo
+	Tx        repository.Transactor
+}
+
+func NewContentService(repos repository.Repos) *ContentService {
+	return &ContentService{Accounts: repos.Accounts, Hives: repos.Hives, Contents: repos.Contents, Comments: repos.Comments, Mentions: repos.Mentions, Revisions: repos.Revisions, Bans: repos.Bans, Tx: repos.Tx}
+}
+
+// revisionOf is the content as edited by editorUUID, to be saved as its next version.
+func revisionOf(content models.Content, editorUUID string) models.Revision {
+	return models.Revision{
+		ItemType:   "content",
+		ItemUUID:   content.UUID,
+		Title:      content.Title,
+		Message:    content.Message,
+		Link:       content.Link,
+		ImageLink:  content.ImageLink,
+		EditorUUID: editorUUID,
+		Created:    pq.NullTime{Time: time.Now(), Valid: true},
+	}
+}
+
+// AutomodSubject describes the content to the AutoModerator engine.
+func AutomodSubject(content models.Content) automod.Subject {
+	return automod.Subject{
+		Type:        "content",
+		UUID:        content.UUID,
+		HiveUUID:    content.HiveUUID,
+		ContentUUID: content.UUID,
+		AccountUUID: content.AccountUUID,
+		Title:       content.Title,
+		Message:     content.Message,
+		Link:        content.Link,
+	}
+}
+
+// ApplyVerdict sets the removed, locked and flair state an AutoModerator verdict asks for.
+func ApplyVerdict(content *models.Content, verdict automod.Verdict) {
+	if verdict.Remove {
+		content.Removed = true
+	}
+	if verdict.Lock {
+		content.Locked = true
+	}
+	if verdict.Flair != "" {
+		content.Flair = verdict.Flair
+	}
+}
+
+func validate(title string, message string) error {
+	if !utils.ValidateContentTitle(title) {
+		return apperr.New(apperr.ValidationFailed, "Title must be between 1 and 256 characters.")
+	}
+	if !utils.ValidateContentMessage(message) {
+		return apperr.New(apperr.ValidationFailed, "Message must be between 1 and 5000 characters.")
+	}
+	return nil
+}
+
+// present sets the stored mentions and the rendered message on each of contents.
+func (s *ContentService) present(contents []models.Content) error {
+	uuids := make([]string, len(contents))
+	for i := range contents {
+		uuids[i] = contents[i].UUID
+	}
+	mentions, err := mention.BySource(s.Mentions, "content", uuids)
+	if err != nil {
+		return err
+	}
+	for i := range contents {
+		contents[i].Mentions = mentions[contents[i].UUID]
+		contents[i].MessageHtml = markdown.Render(contents[i].Message)
+	}
+	return nil
+}
+
+func (s *ContentService) presentOne(content models.Content) (models.Content, error) {
+	contents := []models.Content{content}
+	err := s.present(contents)
+	return contents[0], err
+}
+
+func (s *ContentService) List(viewerUUID string) ([]models.Content, error) {
+	contents, err := s.Contents.ListVisible(viewerUUID)
+	if err != nil {
+		return nil, err
+	}
+	return contents, s.present(contents)
+}
+
+func (s *ContentService) ListByHive(hiveUUID string, viewerUUID string) ([]models.Content, error) {
+	contents, err := s.Contents.ListVisibleByHive(hiveUUID, viewerUUID)
+	if err != nil {
+		return nil, err
+	}
+	return contents, s.present(contents)
+}
+
+func (s *ContentService) GetByID(id int, viewerUUID string) (models.Content, error) {
+	content, err := s.Contents.GetVisibleByID(id, viewerUUID)
+	if err != nil {
+		return models.Content{}, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+	}
+	return s.presentOne(content)
+}
+
+func (s *ContentService) Get(uuid string, viewerUUID string) (models.Content, error) {
+	content, err := s.Contents.GetVisibleByUUID(uuid, viewerUUID)
+	if err != nil {
+		return models.Content{}, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+	}
+	return s.presentOne(content)
+}
+
+// hideDraft returns ContentNotFound when the content is a draft and accountUUID is not its author. Drafts of
+// other accounts are not found rather than forbidden, to moderators too.
+func hideDraft(content models.Content, accountUUID string) error {
+	if content.Draft && content.AccountUUID != accountUUID {
+		return apperr.New(apperr.ContentNotFound, "Content not found.")
+	}
+	return nil
+}
+
+// requireNotBanned returns Banned when the account is banned from the hive, and fails closed when that
+// cannot be checked.
+func (s *ContentService) requireNotBanned(accountUUID string, hiveUUID string) error {
+	banned, err := s.Bans.IsBanned(accountUUID, hiveUUID)
+	if err != nil {
+		return apperr.Wrap(apperr.Internal, "There was an error checking your bans. Please try again.", err)
+	}
+	if banned {
+		return apperr.New(apperr.Banned, "You are banned from posting in this hive.")
+	}
+	return nil
+}
+
+// isModerator reports whether the account moderates the content's hive.
+func (s *ContentService) isModerator(content models.Content, accountUUID string) (bool, error) {
+	moderator, err := hive.CheckModerator(s.Hives, s.Accounts, content.HiveUUID, accountUUID)
+	if err != nil {
+		return false, apperr.Wrap(apperr.Internal, "There was an error checking your permissions. Please try again.", err)
+	}
+	return moderator, nil
+}
+
+// authorize returns Forbidden unless the actor is the author of the content or a moderator of its hive.
+func (s *ContentService) authorize(content models.Content, actorUUID string) error {
+	if content.AccountUUID == actorUUID {
+		return nil
+	}
+	moderator, err := s.isModerator(content, actorUUID)
+	if err != nil {
+		return err
+	}
+	if !moderator {
+		return apperr.New(apperr.Forbidden, "Only the author or a moderator of this hive can change this content.")
+	}
+	return nil
+}
+
+// maxSchedule is how far ahead a draft can be scheduled.
+const maxSchedule = 365 * 24 * time.Hour
+
+// validateSchedule checks that a draft scheduled with PublishAt is due in the future, and not too far in it.
+func validateSchedule(content models.Content) error {
+	if !content.PublishAt.Valid {
+		return nil
+	}
+	if !content.Draft {
+		return apperr.New(apperr.ValidationFailed, "Only drafts can be scheduled. Set Draft to schedule this content.")
+	}
+	if !content.PublishAt.Time.After(time.Now()) || content.PublishAt.Time.After(time.Now().Add(maxSchedule)) {
+		return apperr.New(apperr.ValidationFailed, "PublishAt must be in the future and within a year.")
+	}
+	return nil
+}
+
+// Create posts content to the hive named in content.Hive, or saves it as a draft when content.Draft is set.
+// A draft with PublishAt is published then by the scheduler. Publishing runs AutoModerator rules and the
+// spam classifier, and content either of them holds back is saved removed.
+func (s *ContentService) Create(author string, accountUUID string, content models.Content) (models.Content, error) {
+	if err := validate(content.Title, content.Message); err != nil {
+		return models.Content{}, err
+	}
+	if err := validateSchedule(content); err != nil {
+		return models.Content{}, err
+	}
+
+	hive, err := s.Hives.GetByName(content.Hive)
+	if err != nil {
+		return models.Content{}, apperr.NotFoundAs(err, apperr.HiveNotFound, "Hive not found! Please use an existing hive or create a new hive first.")
+	}
+
+	if err := s.requireNotBanned(accountUUID, hive.UUID); err != nil {
+		return models.Content{}, err
+	}
+
+	content.Author = author
+	content.UUID = uuid.NewString()
+	content.HiveUUID = hive.UUID
+	content.AccountUUID = accountUUID
+	content.Upvote = 0
+	content.Downvote = 0
+	content.CommentCount = 0
+	content.Deleted = false
+	content.Removed = false
+	content.Locked = false
+	content.Flair = ""
+	content.LastEdited = pq.NullTime{Valid: false}
+	content.Created = pq.NullTime{Time: time.Now(), Valid: true}
+
+	content.MessageHtml = markdown.Render(content.Message)
+	content.Mentions, err = mention.Resolve(s.Accounts, s.Hives, content.Message)
+	if err != nil {
+		return models.Content{}, apperr.Wrap(apperr.Internal, "There was an error creating this content. Please try again.", err)
+	}
+
+	insert := func(tx repository.Repos, content *models.Content) error {
+		if err := tx.Contents.Create(content); err != nil {
+			return err
+		}
+		if err := tx.Mentions.Replace("content", content.UUID, content.Mentions); err != nil {
+			return err
+		}
+		version := revisionOf(*content, accountUUID)
+		return tx.Revisions.Append(&version)
+	}
+
+	if content.Draft {
+		err = s.Tx.Transaction(func(tx repository.Repos) error {
+			return insert(tx, &content)
+		})
+		if err != nil {
+			return models.Content{}, apperr.Wrap(apperr.Internal, "There was an error saving this draft. Please try again.", err)
+		}
+		return content, nil
+	}
+	return s.publish(content, insert)
+}
+
+// Publish makes the author's draft live, with the same checks content posted straight away goes through.
+func (s *ContentService) Publish(uuid string, accountUUID string) (models.Content, error) {
+	content, err := s.Contents.GetByUUID(uuid)
+	if err != nil {
+		return models.Content{}, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+	}
+	//only the author publishes, and the content of other accounts is not found rather than forbidden
+	if content.AccountUUID != accountUUID {
+		return models.Content{}, apperr.New(apperr.ContentNotFound, "Content not found.")
+	}
+	if !content.Draft {
+		return models.Content{}, apperr.New(apperr.AlreadyPublished, "This content has already been published.")
+	}
+	if content.Deleted {
+		return models.Content{}, apperr.New(apperr.AlreadyDeleted, "content has already been deleted!")
+	}
+	if err := validate(content.Title, content.Message); err != nil {
+		return models.Content{}, err
+	}
+
+	hive, err := s.Hives.GetByUUID(content.HiveUUID)
+	if err != nil {
+		return models.Content{}, apperr.NotFoundAs(err, apperr.HiveNotFound, "Hive not found.")
+	}
+	if err := s.requireNotBanned(accountUUID, hive.UUID); err != nil {
+		return models.Content{}, err
+	}
+
+	content, err = s.presentOne(content)
+	if err != nil {
+		return models.Content{}, err
+	}
+	return s.publish(content, func(tx repository.Repos, content *models.Content) error {
+		//the draft is checked again under a lock, so publishing it twice at once, by its author and by the
+		//scheduler say, publishes it once and the other attempt fails with AlreadyPublished
+		draft, err := tx.Contents.GetForUpdate(content.UUID)
+		if err != nil {
+			return apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+		}
+		if !draft.Draft {
+			return apperr.New(apperr.AlreadyPublished, "This content has already been published.")
+		}
+		if draft.Deleted {
+			return apperr.New(apperr.AlreadyDeleted, "content has already been deleted!")
+		}
+		return tx.Contents.Save(content)
+	})
+}
+
+// publish runs AutoModerator rules and the spam classifier on the content, then saves it live with save and
+// counts it in the hive, in one transaction with its ContentCreated event and whatever the rules do to it.
+func (s *ContentService) publish(content models.Content, save func(tx repository.Repos, content *models.Content) error) (models.Content, error) {
+	content.Draft = false
+	content.PublishAt = pq.NullTime{Valid: false}
+	content.Created = pq.NullTime{Time: time.Now(), Valid: true}
+
+	err := s.Tx.Transaction(func(tx repository.Repos) error {
+		subject := AutomodSubject(content)
+		verdict, err := automod.Evaluate(tx, subject)
+		if err != nil {
+			return err
+		}
+		ApplyVerdict(&content, verdict)
+
+		//likely spam is held for moderators instead of being published
+		spamResult, err := spam.Check(tx.Spam, spam.Item{
+			Type:        "content",
+			UUID:        content.UUID,
+			HiveUUID:    content.HiveUUID,
+			AccountUUID: content.AccountUUID,
+			Title:       content.Title,
+			Message:     content.Message,
+			Link:        content.Link,
+		})
+		if err != nil {
+			return err
+		}
+		if spamResult.Spam {
+			content.Removed = true
+		}
+
+		if err := save(tx, &content); err != nil {
+			return err
+		}
+		if err := tx.Hives.AddCounts(content.HiveUUID, repository.Counts{Content: 1}); err != nil {
+			return err
+		}
+		event := events.Content(events.ContentCreated, content, content.AccountUUID)
+		if err := tx.Events.Append(&event); err != nil {
+			return err
+		}
+		if err := automod.Dispatch(tx, verdict, subject); err != nil {
+			return err
+		}
+		if spamResult.Spam {
+			return modqueue.Enqueue(tx.ModQueue, content.HiveUUID, "content", content.UUID, "spam", spamResult.Reason())
+		}
+		return nil
+	})
+	if err != nil {
+		//a draft published or deleted meanwhile is reported as such
+		if apperr.CodeOf(err) != apperr.Internal {
+			return models.Content{}, err
+		}
+		return models.Content{}, apperr.Wrap(apperr.Internal, "There was an error creating this content. Please try again.", err)
+	}
+	return content, nil
+}
+
+// Drafts lists the account's drafts, newest first, scheduled or not.
+func (s *ContentService) Drafts(accountUUID string) ([]models.Content, error) {
+	drafts, err := s.Contents.ListDrafts(accountUUID)
+	if err != nil {
+		return nil, err
+	}
+	return drafts, s.present(drafts)
+}
+
+// History returns every version of the content with what changed in each. Only moderators of the hive see
+// the revisions of deleted and removed content, and only the author those of a draft.
+func (s *ContentService) History(uuid string, viewerUUID string) ([]revision.Entry, error) {
+	content, err := s.Contents.GetByUUID(uuid)
+	if err != nil {
+		return nil, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+	}
+	if err := hideDraft(content, viewerUUID); err != nil {
+		return nil, err
+	}
+	moderator, err := s.isModerator(content, viewerUUID)
+	if err != nil {
+		return nil, err
+	}
+	if !moderator {
+		if _, err := s.Contents.GetVisibleByUUID(uuid, viewerUUID); err != nil {
+			return nil, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+		}
+		if content.Deleted || content.Removed {
+			return nil, apperr.New(apperr.Forbidden, "Only moderators of this hive can see the revisions of deleted or removed content.")
+		}
+	}
+	revisions, err := s.Revisions.List("content", content.UUID)
+	if err != nil {
+		return nil, err
+	}
+	return revision.History(revisions), nil
+}
+
+func (s *ContentService) Delete(uuid string, actorUUID string) (models.Content, error) {
+	return s.setDeleted(uuid, actorUUID, true)
+}
+
+func (s *ContentService) Undelete(uuid string, actorUUID string) (models.Content, error) {
+	return s.setDeleted(uuid, actorUUID, false)
+}
+
+// setDeleted soft deletes or restores the content. Hive totals only count what is not deleted, so the
+// content, its live comments and the votes on both are taken out of or put back into them.
+func (s *ContentService) setDeleted(uuid string, actorUUID string, deleted bool) (models.Content, error) {
+	content, err := s.Contents.GetByUUID(uuid)
+	if err != nil {
+		return models.Content{}, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+	}
+	if err := hideDraft(content, actorUUID); err != nil {
+		return models.Content{}, err
+	}
+	if err := s.authorize(content, actorUUID); err != nil {
+		return models.Content{}, err
+	}
+
+	eventType := events.ContentRestored
+	if deleted {
+		eventType = events.ContentDeleted
+	}
+	err = s.Tx.Transaction(func(tx repository.Repos) error {
+		//the content stays locked until it is saved, so what is taken out of the hive totals is what it counted
+		content, err = tx.Contents.GetForUpdate(uuid)
+		if err != nil {
+			return apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+		}
+		if content.Deleted == deleted {
+			if deleted {
+				return apperr.New(apperr.AlreadyDeleted, "content has already been deleted!")
+			}
+			return apperr.New(apperr.NotDeleted, "content has not been deleted!")
+		}
+
+		comments, err := tx.Comments.ListByContent(content.UUID)
+		if err != nil {
+			return err
+		}
+		upvotes, downvotes := content.Upvote, content.Downvote
+		for _, comment := range comments {
+			if !comment.Deleted {
+				upvotes += comment.Upvote
+				downvotes += comment.Downvote
+			}
+		}
+
+		sign := int32(1)
+		if deleted {
+			sign = -1
+		}
+		//drafts are only counted once they are published
+		if content.Draft {
+			sign = 0
+		}
+		content.Deleted = deleted
+		content.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
+		if err := tx.Contents.Save(&content); err != nil {
+			return err
+		}
+		err = tx.Hives.AddCounts(content.HiveUUID, repository.Counts{
+			Content:   sign,
+			Comments:  sign * content.CommentCount,
+			Upvotes:   sign * upvotes,
+			Downvotes: sign * downvotes,
+		})
+		if err != nil {
+			return err
+		}
+		event := events.Content(eventType, content, actorUUID)
+		return tx.Events.Append(&event)
+	})
+	if err != nil {
+		return models.Content{}, err
+	}
+	return s.presentOne(content)
+}
+
+// Update replaces the editable fields of the content with the ones in update. The PublishAt of a draft is
+// editable too, to reschedule it or, set to null, to keep it a draft until it is published by hand.
+func (s *ContentService) Update(uuid string, actorUUID string, update models.Content) (models.Content, error) {
+	if err := validate(update.Title, update.Message); err != nil {
+		return models.Content{}, err
+	}
+
+	content, err := s.Contents.GetByUUID(uuid)
+	if err != nil {
+		return models.Content{}, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+	}
+	if err := hideDraft(content, actorUUID); err != nil {
+		return models.Content{}, err
+	}
+	if err := s.authorize(content, actorUUID); err != nil {
+		return models.Content{}, err
+	}
+
+	mentions, err := mention.Resolve(s.Accounts, s.Hives, update.Message)
+	if err != nil {
+		return models.Content{}, err
+	}
+	err = s.Tx.Transaction(func(tx repository.Repos) error {
+		//the content is read again under a lock, so votes and moderation made since are not overwritten
+		content, err = tx.Contents.GetForUpdate(uuid)
+		if err != nil {
+			return apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+		}
+		if content.Draft {
+			content.PublishAt = update.PublishAt
+			if err := validateSchedule(content); err != nil {
+				return err
+			}
+		}
+		content.Title = update.Title
+		content.Message = update.Message
+		content.Link = update.Link
+		content.ImageLink = update.ImageLink
+		content.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
+		content.MessageHtml = markdown.Render(content.Message)
+		content.Mentions = mentions
+		if err := tx.Contents.Save(&content); err != nil {
+			return err
+		}
+		if err := tx.Mentions.Replace("content", content.UUID, content.Mentions); err != nil {
+			return err
+		}
+		version := revisionOf(content, actorUUID)
+		if err := tx.Revisions.Append(&version); err != nil {
+			return err
+		}
+		//nobody else has seen a draft, so there is nothing to announce
+		if content.Draft {
+			return nil
+		}
+		event := events.Content(events.ContentUpdated, content, actorUUID)
+		return tx.Events.Append(&event)
+	})
+	if err != nil {
+		return models.Content{}, err
+	}
+	return content, nil
+}
@@ -0,0 +1,164 @@
+package counters
+
+import (
+	"context"
+	"errors"
+	"example/hivemind-be/jobs"
+	"example/hivemind-be/metrics"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockKey is the pg_advisory_xact_lock key held while reconciling so instances do not reconcile together.
+const lockKey int64 = 4817239052
+
+// ErrBusy is returned when another instance is reconciling.
+var ErrBusy = errors.New("counters are being reconciled by another instance")
+
+// Counter is a denormalized total together with the query that computes it from the rows it counts. Votes
+// from shadowbanned accounts are never counted.
+type Counter struct {
+	Table  string
+	Column string
+	Query  string //correlated with the row being counted through the table name
+}
+
+// Counters lists every denormalized total. Votes are counted on deleted content and comments too, while a
+// post's comment count and the hive totals only cover content and comments that are not deleted.
+var Counters = []Counter{
+	{"contents", "upvote", `SELECT COUNT(*) FROM content_votes v JOIN accounts a ON a.uuid = v.account_uuid
+		WHERE v.content_uuid = contents.uuid AND v.upvote AND NOT a.shadowbanned`},
+	{"contents", "downvote", `SELECT COUNT(*) FROM content_votes v JOIN accounts a ON a.uuid = v.account_uuid
+		WHERE v.content_uuid = contents.uuid AND v.downvote AND NOT a.shadowbanned`},
+	{"contents", "comment_count", `SELECT COUNT(*) FROM comments m
+		WHERE m.content_uuid = contents.uuid AND m.deleted IS NOT TRUE`},
+	{"comments", "upvote", `SELECT COUNT(*) FROM comment_votes v JOIN accounts a ON a.uuid = v.account_uuid
+		WHERE v.comment_uuid = comments.uuid AND v.upvote AND NOT a.shadowbanned`},
+	{"comments", "downvote", `SELECT COUNT(*) FROM comment_votes v JOIN accounts a ON a.uuid = v.account_uuid
+		WHERE v.comment_uuid = comments.uuid AND v.downvote AND NOT a.shadowbanned`},
+	{"hives", "total_content", `SELECT COUNT(*) FROM contents t
+		WHERE t.hive_uuid = hives.uuid AND t.deleted IS NOT TRUE AND NOT t.draft`},
+	{"hives", "total_comments", `SELECT COUNT(*) FROM comments m JOIN contents t ON t.uuid = m.content_uuid
+		WHERE t.hive_uuid = hives.uuid AND t.deleted IS NOT TRUE AND m.deleted IS NOT TRUE`},
+	{"hives", "member_count", `SELECT COUNT(*) FROM hive_members m WHERE m.hive_uuid = hives.uuid`},
+	{"hives", "total_upvotes", hiveVotes("upvote")},
+	{"hives", "total_downvotes", hiveVotes("downvote")},
+}
+
+// hiveVotes counts the votes in one direction on the hive's content and comments.
+func hiveVotes(direction string) string {
+	return `(SELECT COUNT(*) FROM content_votes v
+		JOIN contents t ON t.uuid = v.content_uuid JOIN accounts a ON a.uuid = v.account_uuid
+		WHERE t.hive_uuid = hives.uuid AND t.deleted IS NOT TRUE AND v.` + direction + ` AND NOT a.shadowbanned) +
+		(SELECT COUNT(*) FROM comment_votes v JOIN comments m ON m.uuid = v.comment_uuid
+		JOIN contents t ON t.uuid = m.content_uuid JOIN accounts a ON a.uuid = v.account_uuid
+		WHERE t.hive_uuid = hives.uuid AND t.deleted IS NOT TRUE AND m.deleted IS NOT TRUE AND v.` + direction + ` AND NOT a.shadowbanned)`
+}
+
+// Name is the counter as table.column.
+func (c Counter) Name() string {
+	return c.Table + "." + c.Column
+}
+
+// Drift is one row whose stored counter differs from what it counts. Stored is nil when the column is null.
+type Drift struct {
+	Counter string `json:"Counter"`
+	UUID    string `json:"Uuid"`
+	Stored  *int64 `json:"Stored"`
+	Actual  int64  `json:"Actual"`
+}
+
+type Report struct {
+	Checked  time.Time `json:"Checked"`
+	Drift    []Drift   `json:"Drift"`
+	Repaired bool      `json:"Repaired"`
+}
+
+// Drifted counts the drifted rows of every counter, including the ones with none.
+func (r Report) Drifted() map[string]int {
+	drifted := map[string]int{}
+	for _, counter := range Counters {
+		drifted[counter.Name()] = 0
+	}
+	for _, drift := range r.Drift {
+		drifted[drift.Counter]++
+	}
+	return drifted
+}
+
+// Reconcile compares every counter with what it counts and, with repair, overwrites the drifted ones. It
+// runs in one transaction and returns ErrBusy when another instance holds the reconcile lock.
+func Reconcile(ctx context.Context, gdb *gorm.DB, repair bool) (Report, error) {
+	report := Report{Checked: time.Now(), Drift: []Drift{}}
+	err := gdb.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if result := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", lockKey).Scan(&locked); result.Error != nil {
+			return result.Error
+		}
+		if !locked {
+			return ErrBusy
+		}
+
+		for _, counter := range Counters {
+			var drift []Drift
+			query := "SELECT ? AS counter, uuid, " + counter.Column + " AS stored, (" + counter.Query + ") AS actual" +
+				" FROM " + counter.Table + " WHERE " + counter.Column + " IS DISTINCT FROM (" + counter.Query + ")" +
+				" ORDER BY id"
+			if result := tx.Raw(query, counter.Name()).Scan(&drift); result.Error != nil {
+				return result.Error
+			}
+			report.Drift = append(report.Drift, drift...)
+			if !repair || len(drift) == 0 {
+				continue
+			}
+
+			statement := "UPDATE " + counter.Table + " SET " + counter.Column + " = (" + counter.Query + ")" +
+				" WHERE " + counter.Column + " IS DISTINCT FROM (" + counter.Query + ")"
+			if result := tx.Exec(statement); result.Error != nil {
+				return result.Error
+			}
+		}
+		report.Repaired = repair
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+
+	for counter, rows := range report.Drifted() {
+		metrics.CounterDrift.WithLabelValues(counter).Set(float64(rows))
+	}
+	return report, nil
+}
+
+// JobKind is the job that reconciles the counters, scheduled by the server.
+const JobKind = "counters.reconcile"
+
+// Job reconciles the counters and logs the drift it finds. It does nothing when another instance is already
+// reconciling.
+func Job(repair bool) jobs.Handler {
+	return func(ctx context.Context, gdb *gorm.DB, job jobs.Job) error {
+		report, err := Reconcile(ctx, gdb, repair)
+		if errors.Is(err, ErrBusy) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(report.Drift) == 0 {
+			slog.Info("counters reconciled, no drift")
+			return nil
+		}
+		args := []any{"rows", len(report.Drift), "repaired", report.Repaired}
+		for counter, rows := range report.Drifted() {
+			if rows > 0 {
+				args = append(args, counter, rows)
+			}
+		}
+		slog.Warn("counter drift found", args...)
+		return nil
+	}
+}
@@ -1,38 +1,63 @@
 package db
 
 import (
+	"example/hivemind-be/config"
 	"fmt"
+	"log"
+	"log/slog"
 	"os"
-	"strconv"
+	"time"
 
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var Db *gorm.DB
 
-func ConnectDatabase() {
-	err := godotenv.Load()
-	if err != nil {
-		fmt.Println("Error is occurred  on .env file please check")
-	}
+// queryLogger reports failed and slow queries on stderr, keeping stdout for the JSON log and command output.
+var queryLogger = logger.New(log.New(os.Stderr, "", log.LstdFlags), logger.Config{
+	SlowThreshold:             200 * time.Millisecond,
+	LogLevel:                  logger.Warn,
+	IgnoreRecordNotFoundError: true,
+})
 
-	host := os.Getenv("DB_HOST")
-	port, _ := strconv.Atoi(os.Getenv("DB_PORT"))
-	user := os.Getenv("DB_ACCOUNT")
-	dbname := os.Getenv("DB_NAME")
-	pass := os.Getenv("DB_PASSWORD")
+// Backoff between connection attempts doubles from minBackoff up to maxBackoff.
+const (
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 10 * time.Second
+)
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=require", host, user, pass, dbname, port)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// ConnectDatabase opens the connection pool shared through Db. The database is often still starting when the
+// server boots, so failed attempts are retried with backoff until cfg.ConnectTimeout has passed.
+func ConnectDatabase(cfg config.DatabaseConfig) error {
+	deadline := time.Now().Add(cfg.ConnectTimeout)
+	backoff := minBackoff
+	for attempt := 1; ; attempt++ {
+		db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{TranslateError: true, Logger: queryLogger})
+		if err == nil {
+			Db = db
+			slog.Info("connected to the database", "host", cfg.Host, "name", cfg.Name, "attempts", attempt)
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("connecting to the database after %d attempts: %w", attempt, err)
+		}
+		slog.Warn("could not connect to the database, retrying", "attempt", attempt, "backoff", backoff.String(), "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
 
+// Close closes the connection pool once the server has stopped using it.
+func Close() error {
+	if Db == nil {
+		return nil
+	}
+	sqlDB, err := Db.DB()
 	if err != nil {
-		fmt.Println("There is an error while connecting to the database ", err)
-		panic(err)
-	} else {
-		Db = db
-		fmt.Println("Successfully connected to database!")
+		return err
 	}
+	return sqlDB.Close()
 }
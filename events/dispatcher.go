@@ -0,0 +1,186 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"example/hivemind-be/metrics"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// lockKey is the pg_advisory_xact_lock key held while dispatching, so one instance dispatches at a time and
+// events of an aggregate cannot be delivered out of order by two instances.
+const lockKey int64 = 4817239053
+
+const batchSize = 100
+
+// maxAttempts is how many times an event is delivered before it is given up on, so that one broken event
+// does not hold back its aggregate forever.
+const maxAttempts = 10
+
+// Retries wait minBackoff, then twice as long each time up to maxBackoff.
+const (
+	minBackoff = 5 * time.Second
+	maxBackoff = 30 * time.Minute
+)
+
+var errBusy = errors.New("another instance is dispatching events")
+
+// Subscriber handles one event. Events are delivered at least once, so subscribers have to cope with seeing
+// an event again. An error makes the dispatcher deliver the event to every subscriber again later.
+type Subscriber func(ctx context.Context, gdb *gorm.DB, event models.Event) error
+
+type subscription struct {
+	name       string
+	subscriber Subscriber
+}
+
+var (
+	mu            sync.RWMutex
+	subscriptions []subscription
+)
+
+// Subscribe adds an in-process subscriber. Subscribers are called in the order they subscribed.
+func Subscribe(name string, subscriber Subscriber) {
+	mu.Lock()
+	defer mu.Unlock()
+	subscriptions = append(subscriptions, subscription{name, subscriber})
+}
+
+func subscribers() []subscription {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]subscription(nil), subscriptions...)
+}
+
+// Run dispatches events until ctx is done, checking the outbox every interval once it is empty.
+func Run(ctx context.Context, gdb *gorm.DB, interval time.Duration) {
+	for ctx.Err() == nil {
+		dispatched, err := Dispatch(ctx, gdb)
+		if err != nil && !errors.Is(err, errBusy) && ctx.Err() == nil {
+			slog.Error("could not dispatch events", "error", err)
+		}
+		if dispatched > 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Dispatch delivers one batch of events and returns how many it handled. Only the oldest undispatched event
+// of each aggregate is in a batch, and one that fails holds back the rest of its aggregate until it is
+// delivered or given up on.
+//
+// The transaction and its advisory lock are held while the subscribers run, for up to batchSize events, so
+// other instances skip dispatching until the batch is done. Subscribers are meant to be quick: they record
+// notifications, publish to open connections and queue webhook jobs, and leave slow work such as sending
+// webhooks to the job workers. Subscribers write through gdb rather than the transaction, so what they
+// saved stays saved even when recording the batch fails and it is delivered again.
+func Dispatch(ctx context.Context, gdb *gorm.DB) (int, error) {
+	handled := 0
+	err := gdb.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if result := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", lockKey).Scan(&locked); result.Error != nil {
+			return result.Error
+		}
+		if !locked {
+			return errBusy
+		}
+
+		var err error
+		handled, err = dispatchBatch(ctx, repository.NewGormRepos(tx).Events, gdb, time.Now())
+		return err
+	})
+	return handled, err
+}
+
+// dispatchBatch delivers the events due by now and records the outcome of each through outbox.
+func dispatchBatch(ctx context.Context, outbox repository.EventRepo, gdb *gorm.DB, now time.Time) (int, error) {
+	batch, err := outbox.ListDue(now, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	for i, event := range batch {
+		if err := record(outbox, event, deliver(ctx, gdb, event), now); err != nil {
+			return i, err
+		}
+	}
+	return len(batch), nil
+}
+
+// deliver hands the event to every subscriber, stopping at the first that fails.
+func deliver(ctx context.Context, gdb *gorm.DB, event models.Event) (err error) {
+	for _, s := range subscribers() {
+		err = func() (err error) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					err = fmt.Errorf("panic: %v", recovered)
+				}
+			}()
+			return s.subscriber(ctx, gdb, event)
+		}()
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+// record marks the event dispatched, or schedules its next attempt when delivering it failed.
+func record(outbox repository.EventRepo, event models.Event, err error, now time.Time) error {
+	log := slog.With("event", event.UUID, "type", event.Type, "aggregate", event.AggregateUUID)
+	event.Attempts++
+	result := "delivered"
+	switch {
+	case err == nil:
+		event.Dispatched = pq.NullTime{Time: now, Valid: true}
+		event.LastError = ""
+	case event.Attempts >= maxAttempts:
+		event.Dispatched = pq.NullTime{Time: now, Valid: true}
+		event.LastError = err.Error()
+		result = "given_up"
+		log.Error("giving up on an event", "attempts", event.Attempts, "error", err)
+	default:
+		wait := backoff(event.Attempts)
+		event.NextAttempt = pq.NullTime{Time: now.Add(wait), Valid: true}
+		event.LastError = err.Error()
+		result = "retried"
+		log.Warn("could not deliver an event, retrying", "error", err, "retry_in", wait.String())
+	}
+	metrics.EventsDispatched.WithLabelValues(event.Type, result).Inc()
+	return outbox.SaveAttempt(&event)
+}
+
+func backoff(attempts int32) time.Duration {
+	wait := minBackoff
+	for i := int32(1); i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// Purge deletes events dispatched before the cutoff.
+func Purge(ctx context.Context, gdb *gorm.DB, cutoff time.Time) error {
+	return gdb.WithContext(ctx).Where("dispatched < ?", cutoff).Delete(&models.Event{}).Error
+}
+
+func init() {
+	//events are delivered at least once, so an event whose delivery is retried is counted each time
+	Subscribe("metrics", func(ctx context.Context, gdb *gorm.DB, event models.Event) error {
+		metrics.DomainEvents.WithLabelValues(event.Type).Inc()
+		return nil
+	})
+}
@@ -0,0 +1,167 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// outbox remembers every attempt saved through it.
+type outbox struct {
+	repository.EventRepo
+	saved []models.Event
+}
+
+func (o *outbox) SaveAttempt(event *models.Event) error {
+	o.saved = append(o.saved, *event)
+	return o.EventRepo.SaveAttempt(event)
+}
+
+func (o *outbox) last(t *testing.T) models.Event {
+	t.Helper()
+	if len(o.saved) == 0 {
+		t.Fatal("no attempt was saved")
+	}
+	return o.saved[len(o.saved)-1]
+}
+
+// subscribeOnly replaces the subscribers with these for the rest of the test.
+func subscribeOnly(t *testing.T, these ...subscription) {
+	mu.Lock()
+	previous := subscriptions
+	subscriptions = these
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		subscriptions = previous
+		mu.Unlock()
+	})
+}
+
+func newOutbox(t *testing.T, now time.Time, aggregates ...string) *outbox {
+	t.Helper()
+	repos := repository.NewMemoryRepos()
+	for _, aggregate := range aggregates {
+		event := newEvent("test.happened", "content", aggregate, "", "", nil)
+		event.NextAttempt.Time = now
+		if err := repos.Events.Append(&event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &outbox{EventRepo: repos.Events}
+}
+
+func dispatch(t *testing.T, o *outbox, now time.Time, want int) {
+	t.Helper()
+	handled, err := dispatchBatch(context.Background(), o, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handled != want {
+		t.Fatalf("handled %d events, want %d", handled, want)
+	}
+}
+
+func TestDispatchKeepsTheOrderOfEachAggregate(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	o := newOutbox(t, now, "first", "first", "second")
+	var delivered []int64
+	failed := false
+	subscribeOnly(t, subscription{"test", func(ctx context.Context, gdb *gorm.DB, event models.Event) error {
+		if event.ID == 1 && !failed {
+			failed = true
+			return errors.New("unavailable")
+		}
+		delivered = append(delivered, event.ID)
+		return nil
+	}})
+
+	//the first event of each aggregate; the second of "first" waits behind the one that failed
+	dispatch(t, o, now, 2)
+	dispatch(t, o, now, 0)
+	dispatch(t, o, now.Add(minBackoff), 1)
+	dispatch(t, o, now.Add(minBackoff), 1)
+	dispatch(t, o, now.Add(minBackoff), 0)
+
+	if want := []int64{3, 1, 2}; !reflect.DeepEqual(delivered, want) {
+		t.Errorf("delivered %v, want %v", delivered, want)
+	}
+}
+
+func TestDispatchBacksOffUntilItGivesUp(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	o := newOutbox(t, now, "broken", "broken")
+	calls := 0
+	subscribeOnly(t, subscription{"test", func(ctx context.Context, gdb *gorm.DB, event models.Event) error {
+		if event.ID == 1 {
+			calls++
+			return errors.New("unavailable")
+		}
+		return nil
+	}})
+
+	waits := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second,
+		160 * time.Second, 320 * time.Second, 640 * time.Second, 1280 * time.Second}
+	for i, wait := range waits {
+		dispatch(t, o, now, 1)
+		event := o.last(t)
+		if event.Attempts != int32(i+1) || event.Dispatched.Valid || event.LastError != "test: unavailable" {
+			t.Fatalf("after attempt %d: event = %+v, want it retried", i+1, event)
+		}
+		if got := event.NextAttempt.Time.Sub(now); got != wait {
+			t.Fatalf("after attempt %d: next attempt in %s, want %s", i+1, got, wait)
+		}
+		dispatch(t, o, now.Add(wait-time.Second), 0)
+		now = now.Add(wait)
+	}
+
+	dispatch(t, o, now, 1)
+	if event := o.last(t); event.Attempts != maxAttempts || !event.Dispatched.Valid || event.LastError == "" {
+		t.Fatalf("after the last attempt: event = %+v, want it given up on with its error", event)
+	}
+	if calls != maxAttempts {
+		t.Errorf("delivered %d times, want %d", calls, maxAttempts)
+	}
+
+	//giving up lets the rest of the aggregate through
+	dispatch(t, o, now, 1)
+	if event := o.last(t); event.ID != 2 || !event.Dispatched.Valid || event.LastError != "" {
+		t.Errorf("event = %+v, want the next one delivered", event)
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	for attempts, want := range map[int32]time.Duration{
+		1:  minBackoff,
+		2:  2 * minBackoff,
+		9:  256 * minBackoff,
+		10: maxBackoff,
+		50: maxBackoff,
+	} {
+		if got := backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestDeliverStopsAtAPanickingSubscriber(t *testing.T) {
+	called := false
+	subscribeOnly(t,
+		subscription{"broken", func(ctx context.Context, gdb *gorm.DB, event models.Event) error { panic("boom") }},
+		subscription{"after", func(ctx context.Context, gdb *gorm.DB, event models.Event) error { called = true; return nil }},
+	)
+
+	err := deliver(context.Background(), nil, models.Event{})
+	if err == nil || err.Error() != "broken: panic: boom" {
+		t.Errorf("deliver = %v, want the panic as an error", err)
+	}
+	if called {
+		t.Error("a subscriber after the failing one was called")
+	}
+}
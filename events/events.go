@@ -0,0 +1,142 @@
+package events
+
+import (
+	"encoding/json"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Event types. Payloads are the changed row as it was saved, except for accounts, members and votes which
+// have their own payloads.
+const (
+	AccountCreated = "AccountCreated"
+
+	HiveCreated    = "HiveCreated"
+	HiveUpdated    = "HiveUpdated"
+	HiveBanned     = "HiveBanned"
+	HiveUnbanned   = "HiveUnbanned"
+	HiveArchived   = "HiveArchived"
+	HiveUnarchived = "HiveUnarchived"
+	MemberJoined   = "MemberJoined"
+	MemberLeft     = "MemberLeft"
+
+	ContentCreated  = "ContentCreated"
+	ContentUpdated  = "ContentUpdated"
+	ContentDeleted  = "ContentDeleted"
+	ContentRestored = "ContentRestored"
+	ContentRemoved  = "ContentRemoved"
+	ContentApproved = "ContentApproved"
+
+	CommentCreated  = "CommentCreated"
+	CommentUpdated  = "CommentUpdated"
+	CommentDeleted  = "CommentDeleted"
+	CommentRestored = "CommentRestored"
+	CommentRemoved  = "CommentRemoved"
+	CommentApproved = "CommentApproved"
+
+	VoteCast      = "VoteCast"
+	VoteRetracted = "VoteRetracted"
+
+	BanCreated = "BanCreated"
+	BanLifted  = "BanLifted"
+
+	NotificationCreated = "NotificationCreated"
+)
+
+// AccountPayload leaves out everything private about the account.
+type AccountPayload struct {
+	UUID     string `json:"Uuid"`
+	Username string `json:"Username"`
+	Admin    bool   `json:"Admin"`
+}
+
+// CommentPayload is the comment with the hive it was posted in.
+type CommentPayload struct {
+	models.Comment
+	HiveUUID string `json:"HiveUuid"`
+}
+
+// MemberPayload is an account joining or leaving a hive.
+type MemberPayload struct {
+	HiveUUID    string `json:"HiveUuid"`
+	AccountUUID string `json:"AccountUuid"`
+	Username    string `json:"Username"`
+}
+
+// VotePayload is one vote cast or retracted on content or a comment.
+type VotePayload struct {
+	TargetType  string `json:"TargetType"` //content or comment
+	TargetUUID  string `json:"TargetUuid"`
+	AccountUUID string `json:"AccountUuid"`
+	Direction   string `json:"Direction"` //upvote or downvote
+}
+
+// newEvent builds an outbox row. Payloads are plain structs, which always marshal.
+func newEvent(eventType string, aggregateType string, aggregateUUID string, hiveUUID string, actorUUID string, payload interface{}) models.Event {
+	encoded, _ := json.Marshal(payload)
+	now := time.Now()
+	return models.Event{
+		UUID:          uuid.NewString(),
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateUUID: aggregateUUID,
+		HiveUUID:      hiveUUID,
+		ActorUUID:     actorUUID,
+		Payload:       encoded,
+		Created:       pq.NullTime{Time: now, Valid: true},
+		NextAttempt:   pq.NullTime{Time: now, Valid: true},
+	}
+}
+
+func Account(eventType string, account models.Account) models.Event {
+	payload := AccountPayload{UUID: account.UUID, Username: account.Username, Admin: account.Admin}
+	return newEvent(eventType, "account", account.UUID, "", account.UUID, payload)
+}
+
+func Hive(eventType string, hive models.Hive, actorUUID string) models.Event {
+	return newEvent(eventType, "hive", hive.UUID, hive.UUID, actorUUID, hive)
+}
+
+// Member is filed under the hive, so joins and leaves are delivered in order.
+func Member(eventType string, member MemberPayload) models.Event {
+	return newEvent(eventType, "hive", member.HiveUUID, member.HiveUUID, member.AccountUUID, member)
+}
+
+func Content(eventType string, content models.Content, actorUUID string) models.Event {
+	return newEvent(eventType, "content", content.UUID, content.HiveUUID, actorUUID, content)
+}
+
+func Comment(eventType string, comment models.Comment, hiveUUID string, actorUUID string) models.Event {
+	return newEvent(eventType, "comment", comment.UUID, hiveUUID, actorUUID, CommentPayload{Comment: comment, HiveUUID: hiveUUID})
+}
+
+// Vote is filed under the voted item, so votes on one item are delivered in the order they were cast.
+func Vote(eventType string, vote VotePayload, hiveUUID string) models.Event {
+	return newEvent(eventType, vote.TargetType, vote.TargetUUID, hiveUUID, vote.AccountUUID, vote)
+}
+
+// Ban is filed under the ban, with the ban row as its payload. Site-wide bans have no hive.
+func Ban(eventType string, banUUID string, hiveUUID string, actorUUID string, ban interface{}) models.Event {
+	return newEvent(eventType, "ban", banUUID, hiveUUID, actorUUID, ban)
+}
+
+// Notification is filed under the account notified, with the notification as its payload.
+func Notification(accountUUID string, hiveUUID string, notification interface{}) models.Event {
+	return newEvent(NotificationCreated, "account", accountUUID, hiveUUID, "", notification)
+}
+
+// Record appends the event to the outbox through tx, for code that writes with GORM directly rather than
+// through the repositories. It appends through the event repository so it keeps the order of the aggregate.
+func Record(tx *gorm.DB, event models.Event) error {
+	return repository.NewGormRepos(tx).Events.Append(&event)
+}
+
+// Decode unmarshals the event's payload into dst.
+func Decode(event models.Event, dst interface{}) error {
+	return json.Unmarshal(event.Payload, dst)
+}
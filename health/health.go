@@ -0,0 +1,94 @@
+package health
+
+import (
+	"context"
+	"example/hivemind-be/db"
+	"example/hivemind-be/logging"
+	"example/hivemind-be/migrate"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkTimeout bounds each readiness check so a hung database fails the probe instead of stalling it.
+const checkTimeout = 2 * time.Second
+
+var draining atomic.Bool
+
+// Drain marks the instance as shutting down. Readiness fails from then on so the proxy stops routing new
+// requests here while in-flight ones finish.
+func Drain() {
+	draining.Store(true)
+}
+
+type Check struct {
+	Status string `json:"Status"`
+	Error  string `json:"Error,omitempty"`
+}
+
+type Report struct {
+	Status string           `json:"Status"`
+	Checks map[string]Check `json:"Checks,omitempty"`
+}
+
+// Healthz is the liveness probe. It only shows the process is serving requests.
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, Report{Status: "ok"})
+}
+
+// Readyz is the readiness probe. It fails while shutting down, when the database cannot be reached and when
+// migrations are pending.
+func Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), checkTimeout)
+	defer cancel()
+
+	report := Report{Status: "ok", Checks: map[string]Check{}}
+	//causes are logged rather than returned, the probe is reachable from outside
+	fail := func(name string, message string, err error) {
+		if err != nil {
+			logging.FromContext(c).Warn("readiness check failed", "check", name, "error", err)
+		}
+		report.Status = "unavailable"
+		report.Checks[name] = Check{Status: "failing", Error: message}
+	}
+
+	if draining.Load() {
+		fail("shutdown", "shutting down", nil)
+	}
+
+	if err := ping(ctx); err != nil {
+		fail("database", "database unreachable", err)
+	} else {
+		report.Checks["database"] = Check{Status: "ok"}
+
+		pending, err := migrate.Pending(ctx, db.Db)
+		switch {
+		case err != nil:
+			fail("migrations", "could not read migration state", err)
+		case len(pending) > 0:
+			fail("migrations", fmt.Sprintf("%d pending migrations", len(pending)), nil)
+		default:
+			report.Checks["migrations"] = Check{Status: "ok"}
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+func ping(ctx context.Context) error {
+	if db.Db == nil {
+		return fmt.Errorf("not connected")
+	}
+	sqlDB, err := db.Db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -1,37 +1,35 @@
 package hive
 
 import (
-	"example/hivemind-be/db"
+	"errors"
+	"example/hivemind-be/account"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/metrics"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
 	"example/hivemind-be/utils"
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"github.com/lib/pq"
 )
 
-type Hive struct {
-	ID             int32       `json:"Id" gorm:"primaryKey:type:int32"`
-	Name           string      `json:"Name"`
-	Creator        string      `json:"Creator"`
-	Description    string      `json:"Description"`
-	UUID           string      `json:"Uuid"`
-	AccountUUID    string      `json:"AccountUUID"`
-	MemberCount    int32       `json:"MemberCount"`
-	TotalUpvotes   int32       `json:"TotalUpvotes"`
-	TotalDownvotes int32       `json:"TotalDownvotes"`
-	TotalComments  int32       `json:"TotalComments"`
-	TotalContent   int32       `json:"TotalContent"`
-	Archived       bool        `json:"Archived"`
-	Banned         bool        `json:"Banned"`
-	Created        pq.NullTime `json:"Created"`
-	LastEdited     pq.NullTime `json:"LastEdited"`
+// Handler serves the hive routes through the hive service.
+type Handler struct {
+	Repos repository.Repos
 }
 
-func CreateHive(c *gin.Context) {
-	var hive Hive
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+// hives is the hive service with its queries bound to the request.
+func (h *Handler) hives(c *gin.Context) *HiveService {
+	return NewHiveService(h.Repos.WithContext(c.Request.Context()))
+}
+
+func (h *Handler) CreateHive(c *gin.Context) {
+	var hive models.Hive
 
 	authToken := c.GetHeader("Authorization")
 	claims, validToken := utils.ValidateAuthentication(c, authToken)
@@ -39,226 +37,145 @@ func CreateHive(c *gin.Context) {
 		return
 	}
 
-	if err := c.BindJSON(&hive); err != nil {
-		return
-	}
-
-	// Validate hive name
-	if !utils.ValidateHiveName(hive.Name) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Hive name should be between 1 and 30 characters long and contain only alphabetic characters.",
-		})
-		return
-	}
-
-	// Validate hive description
-	if !utils.ValidateHiveDescription(hive.Description) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Hive description should be between 1 and 256 characters long.",
-		})
+	if err := c.ShouldBindJSON(&hive); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
 		return
 	}
 
-	hive.Creator = claims.Username
-	hive.UUID = uuid.NewString()
-	hive.AccountUUID = claims.AccountUUID
-	hive.MemberCount = 0
-	hive.TotalUpvotes = 0
-	hive.TotalDownvotes = 0
-	hive.TotalComments = 0
-	hive.Archived = false
-	hive.Banned = false
-	hive.Created = pq.NullTime{Time: time.Now(), Valid: true}
-	hive.LastEdited = pq.NullTime{Valid: false}
-
-	if result := db.Db.Create(&hive); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"Error": result.Error.Error(),
-		})
+	hive, err := h.hives(c).Create(claims.Username, claims.AccountUUID, hive.Name, hive.Description)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
 
+	metrics.HivesCreated.Inc()
 	c.JSON(http.StatusCreated, hive)
 }
 
-func GetHive(c *gin.Context) {
-	var hive []Hive
+func (h *Handler) GetHive(c *gin.Context) {
 	authToken := c.GetHeader("Authorization")
 	_, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	if result := db.Db.Order("id asc").Find(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	hive, err := h.hives(c).List()
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, &hive)
 }
 
-func BanHiveByUuid(c *gin.Context) {
-	var hive Hive
+func (h *Handler) BanHiveByUuid(c *gin.Context) {
+	h.setFlag(c, (*HiveService).Ban, "%s has been banned!")
+}
 
-	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
-	if !validToken {
-		return
-	}
+func (h *Handler) UnBanHiveByUuid(c *gin.Context) {
+	h.setFlag(c, (*HiveService).Unban, "%s has been unbanned!")
+}
 
-	uuid := c.Param("uuid")
-	if result := db.Db.Where("uuid = ?", uuid).First(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
-		return
-	}
-	if hive.Banned {
-		mes := fmt.Sprintf("%s is already banned!", hive.Name)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": mes,
-		})
-		return
-	}
-	hive.Banned = true
-	db.Db.Save(&hive)
-	mes := fmt.Sprintf("%s has been banned!", hive.Name)
-	c.JSON(http.StatusOK, gin.H{
-		"Message": mes,
-	})
+func (h *Handler) ArchiveHiveByUuid(c *gin.Context) {
+	h.setFlag(c, (*HiveService).Archive, "%s has been archived!")
 }
 
-func UnBanHiveByUuid(c *gin.Context) {
-	var hive Hive
+func (h *Handler) UnArchiveHiveByUuid(c *gin.Context) {
+	h.setFlag(c, (*HiveService).Unarchive, "%s has been unarchived!")
+}
 
+func (h *Handler) setFlag(c *gin.Context, change func(s *HiveService, uuid string, actorUUID string) (models.Hive, error), message string) {
 	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	uuid := c.Param("uuid")
-	if result := db.Db.Where("uuid = ?", uuid).First(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	hive, err := change(h.hives(c), c.Param("uuid"), claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
-	if !hive.Banned {
-		mes := fmt.Sprintf("%s has not been banned!", hive.Name)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": mes,
-		})
-		return
-	}
-	hive.Banned = false
-	db.Db.Save(&hive)
-	mes := fmt.Sprintf("%s has been unbanned!", hive.Name)
+	mes := fmt.Sprintf(message, hive.Name)
 	c.JSON(http.StatusOK, gin.H{
 		"Message": mes,
 	})
 }
 
-func ArchiveHiveByUuid(c *gin.Context) {
-	var hive Hive
+func (h *Handler) JoinHiveByUuid(c *gin.Context) {
+	h.setMembership(c, (*HiveService).Join, "You have joined %s!")
+}
 
+func (h *Handler) LeaveHiveByUuid(c *gin.Context) {
+	h.setMembership(c, (*HiveService).Leave, "You have left %s!")
+}
+
+func (h *Handler) setMembership(c *gin.Context, change func(s *HiveService, uuid string, accountUUID string, username string) (models.Hive, error), message string) {
 	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	uuid := c.Param("uuid")
-	if result := db.Db.Where("uuid = ?", uuid).First(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	hive, err := change(h.hives(c), c.Param("uuid"), claims.AccountUUID, claims.Username)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
-	if hive.Archived {
-		mes := fmt.Sprintf("%s is already archived!", hive.Name)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": mes,
-		})
-		return
-	}
-	hive.Archived = true
-	db.Db.Save(&hive)
-	mes := fmt.Sprintf("%s has been archived!", hive.Name)
 	c.JSON(http.StatusOK, gin.H{
-		"Message": mes,
+		"Message": fmt.Sprintf(message, hive.Name),
 	})
 }
 
-func UnArchiveHiveByUuid(c *gin.Context) {
-	var hive Hive
+func (h *Handler) UpdateHiveByUuid(c *gin.Context) {
+	var updateHive models.Hive
 
 	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
 	if !validToken {
 		return
 	}
 
-	uuid := c.Param("uuid")
-	if result := db.Db.Where("uuid = ?", uuid).First(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
+	if err := c.ShouldBindJSON(&updateHive); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
 		return
 	}
-	if !hive.Archived {
-		mes := fmt.Sprintf("%s has not been archived!", hive.Name)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": mes,
-		})
+
+	hive, err := h.hives(c).Update(c.Param("uuid"), claims.AccountUUID, updateHive.Description)
+	if err != nil {
+		apperr.Write(c, err)
 		return
 	}
-	hive.Archived = false
-	db.Db.Save(&hive)
-	mes := fmt.Sprintf("%s has been unarchived!", hive.Name)
-	c.JSON(http.StatusOK, gin.H{
-		"Message": mes,
-	})
+	c.JSON(http.StatusOK, hive)
 }
 
-func UpdateHiveByUuid(c *gin.Context) {
-	var hive Hive
-	var updateHive Hive
-
-	authToken := c.GetHeader("Authorization")
-	_, validToken := utils.ValidateAuthentication(c, authToken)
-	if !validToken {
-		return
+// CheckModerator reports whether the account moderates the hive, through hives and accounts. Hive creators
+// moderate their own hives and administrators moderate every hive. A hive that does not exist has no
+// moderators.
+func CheckModerator(hives repository.HiveRepo, accounts repository.AccountRepo, hiveUUID string, accountUUID string) (bool, error) {
+	hive, err := hives.GetByUUID(hiveUUID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
 	}
-
-	if err := c.BindJSON(&updateHive); err != nil {
-		return
+	if err != nil {
+		return false, err
 	}
-
-	uuid := c.Param("uuid")
-
-	if result := db.Db.Where("uuid = ?", uuid).First(&hive); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": result.Error.Error(),
-		})
-		return
+	if hive.AccountUUID == accountUUID {
+		return true, nil
 	}
+	return account.CheckAdmin(accounts, accountUUID)
+}
 
-	if val, ok := utils.JsonDataHasKey(updateHive, "Description"); ok {
-		hive.Description, _ = val.(string)
+// RequireModerator writes a 403 response and returns false when the account does not moderate the hive,
+// looked up through repos. A failed lookup is written as a server error.
+func RequireModerator(c *gin.Context, repos repository.Repos, hiveUUID string, accountUUID string) bool {
+	moderator, err := CheckModerator(repos.Hives, repos.Accounts, hiveUUID, accountUUID)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error checking your permissions. Please try again.", err))
+		return false
 	}
-
-	// Validate hive description
-	if !utils.ValidateHiveDescription(hive.Description) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "Hive description should be between 1 and 256 characters long.",
-		})
-		return
+	if !moderator {
+		apperr.Write(c, apperr.New(apperr.Forbidden, "Only moderators of this hive can perform this action."))
+		return false
 	}
-
-	hive.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
-
-	db.Db.Save(&hive)
-	c.JSON(http.StatusOK, hive)
+	return true
 }
@@ -0,0 +1,206 @@
+package hive_test
+
+import (
+	"errors"
+	"example/hivemind-be/apitest"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/hive"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newRouter serves the hive routes the way routes.Routes does, on repos.
+func newRouter(repos repository.Repos) *gin.Engine {
+	apitest.Setup()
+	h := hive.NewHandler(repos)
+	router := gin.New()
+	router.GET("/hive", h.GetHive)
+	router.POST("/hive", h.CreateHive)
+	router.PATCH("/hive/uuid/:uuid/ban", h.BanHiveByUuid)
+	router.PATCH("/hive/uuid/:uuid/unban", h.UnBanHiveByUuid)
+	router.PATCH("/hive/uuid/:uuid/update", h.UpdateHiveByUuid)
+	router.PATCH("/hive/uuid/:uuid/join", h.JoinHiveByUuid)
+	router.PATCH("/hive/uuid/:uuid/leave", h.LeaveHiveByUuid)
+	return router
+}
+
+func memberCount(t *testing.T, repos repository.Repos, uuid string) int32 {
+	t.Helper()
+	stored, err := repos.Hives.GetByUUID(uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored.MemberCount
+}
+
+func TestCreateHiveIsOwnedByItsCreator(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	creator, auth := apitest.Account(t, repos, "creator")
+
+	var created models.Hive
+	w := apitest.Do(t, router, http.MethodPost, "/hive", auth, models.Hive{Name: "golang", Description: "Gophers", AccountUUID: "someone-else"})
+	apitest.Decode(t, w, http.StatusCreated, &created)
+	if created.AccountUUID != creator.UUID || created.Creator != "creator" {
+		t.Errorf("created = %+v, want it owned by creator", created)
+	}
+	if created.TotalContent != 0 || created.MemberCount != 0 {
+		t.Errorf("created = %+v, want empty totals", created)
+	}
+
+	var listed []models.Hive
+	apitest.Decode(t, apitest.Do(t, router, http.MethodGet, "/hive", auth, nil), http.StatusOK, &listed)
+	if len(listed) != 1 || listed[0].UUID != created.UUID {
+		t.Errorf("listed = %+v, want the one hive", listed)
+	}
+}
+
+func TestCreateHiveValidates(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	_, auth := apitest.Account(t, repos, "creator")
+
+	for _, body := range []models.Hive{
+		{Name: "", Description: "Gophers"},
+		{Name: "go lang", Description: "Gophers"},
+		{Name: "golang", Description: ""},
+	} {
+		w := apitest.Do(t, router, http.MethodPost, "/hive", auth, body)
+		if code := apitest.Code(t, w); code != apperr.ValidationFailed {
+			t.Errorf("creating %+v: code = %s, want %s", body, code, apperr.ValidationFailed)
+		}
+	}
+	if w := apitest.Do(t, router, http.MethodPost, "/hive", "", models.Hive{Name: "golang", Description: "Gophers"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: status = %d, want 401", w.Code)
+	}
+}
+
+func TestJoinAndLeaveKeepMemberCount(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, _ := apitest.Account(t, repos, "owner")
+	_, auth := apitest.Account(t, repos, "member")
+	created := apitest.Hive(t, repos, owner, "golang")
+	path := "/hive/uuid/" + created.UUID
+
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/join", auth, nil), http.StatusOK, nil)
+	w := apitest.Do(t, router, http.MethodPatch, path+"/join", auth, nil)
+	if code := apitest.Code(t, w); code != apperr.AlreadyMember {
+		t.Errorf("joining twice: code = %s, want %s", code, apperr.AlreadyMember)
+	}
+	if got := memberCount(t, repos, created.UUID); got != 1 {
+		t.Errorf("MemberCount = %d, want 1", got)
+	}
+
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/leave", auth, nil), http.StatusOK, nil)
+	w = apitest.Do(t, router, http.MethodPatch, path+"/leave", auth, nil)
+	if code := apitest.Code(t, w); code != apperr.NotMember {
+		t.Errorf("leaving twice: code = %s, want %s", code, apperr.NotMember)
+	}
+	if got := memberCount(t, repos, created.UUID); got != 0 {
+		t.Errorf("MemberCount = %d, want 0", got)
+	}
+}
+
+func TestBannedHivesCannotBeJoined(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, ownerAuth := apitest.Account(t, repos, "owner")
+	_, adminAuth := apitest.Admin(t, repos, "admin")
+	_, auth := apitest.Account(t, repos, "member")
+	created := apitest.Hive(t, repos, owner, "golang")
+	path := "/hive/uuid/" + created.UUID
+
+	w := apitest.Do(t, router, http.MethodPatch, path+"/ban", ownerAuth, nil)
+	if code := apitest.Code(t, w); code != apperr.Forbidden {
+		t.Errorf("banning as the owner: code = %s, want %s", code, apperr.Forbidden)
+	}
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/ban", adminAuth, nil), http.StatusOK, nil)
+	w = apitest.Do(t, router, http.MethodPatch, path+"/ban", adminAuth, nil)
+	if code := apitest.Code(t, w); code != apperr.AlreadyBanned {
+		t.Errorf("banning twice: code = %s, want %s", code, apperr.AlreadyBanned)
+	}
+	w = apitest.Do(t, router, http.MethodPatch, path+"/join", auth, nil)
+	if code := apitest.Code(t, w); code != apperr.Forbidden {
+		t.Errorf("joining a banned hive: code = %s, want %s", code, apperr.Forbidden)
+	}
+	if got := memberCount(t, repos, created.UUID); got != 0 {
+		t.Errorf("MemberCount = %d, want 0", got)
+	}
+
+	w = apitest.Do(t, router, http.MethodPatch, path+"/unban", ownerAuth, nil)
+	if code := apitest.Code(t, w); code != apperr.Forbidden {
+		t.Errorf("unbanning as the owner: code = %s, want %s", code, apperr.Forbidden)
+	}
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/unban", adminAuth, nil), http.StatusOK, nil)
+	apitest.Decode(t, apitest.Do(t, router, http.MethodPatch, path+"/join", auth, nil), http.StatusOK, nil)
+}
+
+func TestUpdateHiveOnlyChangesDescription(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(repos)
+	owner, auth := apitest.Account(t, repos, "owner")
+	created := apitest.Hive(t, repos, owner, "golang")
+
+	var updated models.Hive
+	w := apitest.Do(t, router, http.MethodPatch, "/hive/uuid/"+created.UUID+"/update", auth, models.Hive{Name: "rust", Description: "Gophers only", AccountUUID: "someone-else"})
+	apitest.Decode(t, w, http.StatusOK, &updated)
+	if updated.Name != "golang" || updated.AccountUUID != owner.UUID || updated.Description != "Gophers only" {
+		t.Errorf("updated = %+v, want only the description changed", updated)
+	}
+
+	w = apitest.Do(t, router, http.MethodPatch, "/hive/uuid/missing/update", auth, models.Hive{Description: "Gophers"})
+	if w.Code != http.StatusNotFound || apitest.Code(t, w) != apperr.HiveNotFound {
+		t.Errorf("updating a missing hive: %d %s, want 404", w.Code, w.Body.String())
+	}
+}
+
+// brokenHives fails every lookup, as a hive repository does while the database is down.
+type brokenHives struct {
+	repository.HiveRepo
+}
+
+func (brokenHives) GetByUUID(uuid string) (models.Hive, error) {
+	return models.Hive{}, errors.New("connection refused")
+}
+
+func TestRequireModeratorLooksUpThroughRepos(t *testing.T) {
+	apitest.Setup()
+	repos := repository.NewMemoryRepos()
+	owner, _ := apitest.Account(t, repos, "owner")
+	member, _ := apitest.Account(t, repos, "member")
+	admin, _ := apitest.Admin(t, repos, "admin")
+	golang := apitest.Hive(t, repos, owner, "golang")
+
+	broken := repos
+	broken.Hives = brokenHives{repos.Hives}
+	for _, test := range []struct {
+		name    string
+		repos   repository.Repos
+		account string
+		status  int
+	}{
+		{"creator", repos, owner.UUID, http.StatusOK},
+		{"administrator", repos, admin.UUID, http.StatusOK},
+		{"member", repos, member.UUID, http.StatusForbidden},
+		{"failed lookup", broken, owner.UUID, http.StatusInternalServerError},
+	} {
+		router := gin.New()
+		router.GET("/hive/uuid/:uuid/moderator/:account", func(c *gin.Context) {
+			if hive.RequireModerator(c, test.repos, c.Param("uuid"), c.Param("account")) {
+				c.Status(http.StatusOK)
+			}
+		})
+		w := apitest.Do(t, router, http.MethodGet, "/hive/uuid/"+golang.UUID+"/moderator/"+test.account, "", nil)
+		if w.Code != test.status {
+			t.Errorf("%s: status = %d, want %d: %s", test.name, w.Code, test.status, w.Body.String())
+		}
+		if test.status == http.StatusInternalServerError && apitest.Code(t, w) != apperr.Internal {
+			t.Errorf("%s: code = %s, want %s", test.name, apitest.Code(t, w), apperr.Internal)
+		}
+	}
+}
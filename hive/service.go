@@ -0,0 +1,232 @@
+package hive
+
+import (
+	"errors"
+	"example/hivemind-be/account"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/events"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/utils"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// HiveService holds the rules for creating and changing hives. Changes are saved in one transaction through
+// Tx together with their events.
+type HiveService struct {
+	Accounts repository.AccountRepo
+	Hives    repository.HiveRepo
+	Tx       repository.Transactor
+}
+
+func NewHiveService(repos repository.Repos) *HiveService {
+	return &HiveService{Accounts: repos.Accounts, Hives: repos.Hives, Tx: repos.Tx}
+}
+
+func (s *HiveService) Create(creator string, accountUUID string, name string, description string) (models.Hive, error) {
+	if !utils.ValidateHiveName(name) {
+		return models.Hive{}, apperr.New(apperr.ValidationFailed, "Hive name should be between 1 and 30 characters long and contain only alphabetic characters.")
+	}
+	if !utils.ValidateHiveDescription(description) {
+		return models.Hive{}, apperr.New(apperr.ValidationFailed, "Hive description should be between 1 and 256 characters long.")
+	}
+
+	hive := models.Hive{
+		Name:        name,
+		Creator:     creator,
+		Description: description,
+		UUID:        uuid.NewString(),
+		AccountUUID: accountUUID,
+		Created:     pq.NullTime{Time: time.Now(), Valid: true},
+		LastEdited:  pq.NullTime{Valid: false},
+	}
+	err := s.Tx.Transaction(func(tx repository.Repos) error {
+		if err := tx.Hives.Create(&hive); err != nil {
+			return err
+		}
+		event := events.Hive(events.HiveCreated, hive, accountUUID)
+		return tx.Events.Append(&event)
+	})
+	if err != nil {
+		return models.Hive{}, err
+	}
+	return hive, nil
+}
+
+func (s *HiveService) List() ([]models.Hive, error) {
+	return s.Hives.List()
+}
+
+func (s *HiveService) Get(uuid string) (models.Hive, error) {
+	hive, err := s.Hives.GetByUUID(uuid)
+	return hive, apperr.NotFoundAs(err, apperr.HiveNotFound, "Hive not found.")
+}
+
+func (s *HiveService) Ban(uuid string, actorUUID string) (models.Hive, error) {
+	return s.setFlag(uuid, actorUUID, events.HiveBanned, func(hive *models.Hive) error {
+		if hive.Banned {
+			return apperr.New(apperr.AlreadyBanned, fmt.Sprintf("%s is already banned!", hive.Name))
+		}
+		hive.Banned = true
+		return nil
+	})
+}
+
+func (s *HiveService) Unban(uuid string, actorUUID string) (models.Hive, error) {
+	return s.setFlag(uuid, actorUUID, events.HiveUnbanned, func(hive *models.Hive) error {
+		if !hive.Banned {
+			return apperr.New(apperr.NotBanned, fmt.Sprintf("%s has not been banned!", hive.Name))
+		}
+		hive.Banned = false
+		return nil
+	})
+}
+
+func (s *HiveService) Archive(uuid string, actorUUID string) (models.Hive, error) {
+	return s.setFlag(uuid, actorUUID, events.HiveArchived, func(hive *models.Hive) error {
+		if hive.Archived {
+			return apperr.New(apperr.AlreadyArchived, fmt.Sprintf("%s is already archived!", hive.Name))
+		}
+		hive.Archived = true
+		return nil
+	})
+}
+
+func (s *HiveService) Unarchive(uuid string, actorUUID string) (models.Hive, error) {
+	return s.setFlag(uuid, actorUUID, events.HiveUnarchived, func(hive *models.Hive) error {
+		if !hive.Archived {
+			return apperr.New(apperr.NotArchived, fmt.Sprintf("%s has not been archived!", hive.Name))
+		}
+		hive.Archived = false
+		return nil
+	})
+}
+
+// setFlag loads the hive, lets change check and flip one of its flags and saves it with an eventType event.
+// Only administrators ban and archive hives.
+func (s *HiveService) setFlag(uuid string, actorUUID string, eventType string, change func(hive *models.Hive) error) (models.Hive, error) {
+	admin, err := account.CheckAdmin(s.Accounts, actorUUID)
+	if err != nil {
+		return models.Hive{}, apperr.Wrap(apperr.Internal, "There was an error checking your permissions. Please try again.", err)
+	}
+	if !admin {
+		return models.Hive{}, apperr.New(apperr.Forbidden, "Only administrators can perform this action.")
+	}
+
+	return s.change(uuid, func(hive *models.Hive) (models.Event, error) {
+		if err := change(hive); err != nil {
+			return models.Event{}, err
+		}
+		return events.Hive(eventType, *hive, actorUUID), nil
+	})
+}
+
+// change loads the hive under a lock, lets fn change it and saves it with the event fn returns, in one
+// transaction. The lock keeps counters added to the hive meanwhile from being overwritten.
+func (s *HiveService) change(uuid string, fn func(hive *models.Hive) (models.Event, error)) (models.Hive, error) {
+	var hive models.Hive
+	err := s.Tx.Transaction(func(tx repository.Repos) error {
+		var err error
+		hive, err = tx.Hives.GetForUpdate(uuid)
+		if err != nil {
+			return apperr.NotFoundAs(err, apperr.HiveNotFound, "Hive not found.")
+		}
+		event, err := fn(&hive)
+		if err != nil {
+			return err
+		}
+		if err := tx.Hives.Save(&hive); err != nil {
+			return err
+		}
+		return tx.Events.Append(&event)
+	})
+	if err != nil {
+		return models.Hive{}, err
+	}
+	return hive, nil
+}
+
+// Join makes the account a member of the hive. Banned hives cannot be joined.
+func (s *HiveService) Join(uuid string, accountUUID string, username string) (models.Hive, error) {
+	hive, err := s.Get(uuid)
+	if err != nil {
+		return models.Hive{}, err
+	}
+	if hive.Banned {
+		return models.Hive{}, apperr.New(apperr.Forbidden, fmt.Sprintf("%s has been banned!", hive.Name))
+	}
+
+	member := models.HiveMember{
+		HiveUUID:    hive.UUID,
+		AccountUUID: accountUUID,
+		Joined:      pq.NullTime{Time: time.Now(), Valid: true},
+	}
+	err = s.Tx.Transaction(func(tx repository.Repos) error {
+		if err := tx.Hives.AddMember(&member); err != nil {
+			return err
+		}
+		if err := tx.Hives.AddCounts(hive.UUID, repository.Counts{Members: 1}); err != nil {
+			return err
+		}
+		event := events.Member(events.MemberJoined, events.MemberPayload{HiveUUID: hive.UUID, AccountUUID: accountUUID, Username: username})
+		return tx.Events.Append(&event)
+	})
+	if apperr.CodeOf(err) == apperr.Conflict {
+		return models.Hive{}, apperr.New(apperr.AlreadyMember, fmt.Sprintf("You are already a member of %s!", hive.Name))
+	}
+	if err != nil {
+		return models.Hive{}, err
+	}
+	hive.MemberCount += 1
+	return hive, nil
+}
+
+// Leave ends the account's membership of the hive.
+func (s *HiveService) Leave(uuid string, accountUUID string, username string) (models.Hive, error) {
+	hive, err := s.Get(uuid)
+	if err != nil {
+		return models.Hive{}, err
+	}
+	member, err := s.Hives.GetMember(hive.UUID, accountUUID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Hive{}, apperr.New(apperr.NotMember, fmt.Sprintf("You are not a member of %s!", hive.Name))
+	}
+	if err != nil {
+		return models.Hive{}, err
+	}
+
+	err = s.Tx.Transaction(func(tx repository.Repos) error {
+		if err := tx.Hives.RemoveMember(member); err != nil {
+			return err
+		}
+		if err := tx.Hives.AddCounts(hive.UUID, repository.Counts{Members: -1}); err != nil {
+			return err
+		}
+		event := events.Member(events.MemberLeft, events.MemberPayload{HiveUUID: hive.UUID, AccountUUID: accountUUID, Username: username})
+		return tx.Events.Append(&event)
+	})
+	if err != nil {
+		return models.Hive{}, err
+	}
+	hive.MemberCount -= 1
+	return hive, nil
+}
+
+func (s *HiveService) Update(uuid string, actorUUID string, description string) (models.Hive, error) {
+	if _, err := s.Get(uuid); err != nil {
+		return models.Hive{}, err
+	}
+	if !utils.ValidateHiveDescription(description) {
+		return models.Hive{}, apperr.New(apperr.ValidationFailed, "Hive description should be between 1 and 256 characters long.")
+	}
+
+	return s.change(uuid, func(hive *models.Hive) (models.Event, error) {
+		hive.Description = description
+		hive.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
+		return events.Hive(events.HiveUpdated, *hive, actorUUID), nil
+	})
+}
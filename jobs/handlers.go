@@ -0,0 +1,148 @@
+package jobs
+
+import (
+	"example/hivemind-be/account"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobStats = models.JobStats
+
+// AdminHandler serves the admin job routes through the job repository.
+type AdminHandler struct {
+	Repos repository.Repos
+}
+
+func NewAdminHandler(repos repository.Repos) *AdminHandler {
+	return &AdminHandler{Repos: repos}
+}
+
+// accounts is the account repository with its queries bound to the request.
+func (h *AdminHandler) accounts(c *gin.Context) repository.AccountRepo {
+	return h.Repos.WithContext(c.Request.Context()).Accounts
+}
+
+// jobs is the job repository with its queries bound to the request.
+func (h *AdminHandler) jobs(c *gin.Context) repository.JobRepo {
+	return h.Repos.WithContext(c.Request.Context()).Jobs
+}
+
+// GetJobs lists jobs newest first, optionally only those with a status or kind.
+func (h *AdminHandler) GetJobs(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if !account.RequireAdmin(c, h.accounts(c), claims.AccountUUID) {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	jobs, err := h.jobs(c).List(c.Query("status"), c.Query("kind"), limit)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetJobStats counts the jobs of every kind and status.
+func (h *AdminHandler) GetJobStats(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if !account.RequireAdmin(c, h.accounts(c), claims.AccountUUID) {
+		return
+	}
+
+	stats, err := h.jobs(c).Stats()
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+func (h *AdminHandler) GetJobSchedules(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if !account.RequireAdmin(c, h.accounts(c), claims.AccountUUID) {
+		return
+	}
+
+	rows, err := h.jobs(c).ListSchedules()
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+func (h *AdminHandler) GetJobByUuid(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if !account.RequireAdmin(c, h.accounts(c), claims.AccountUUID) {
+		return
+	}
+
+	job, err := h.jobs(c).GetByUUID(c.Param("uuid"))
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.JobNotFound, "Job not found. Please try again."))
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// RetryJobByUuid queues a dead job again.
+func (h *AdminHandler) RetryJobByUuid(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if !account.RequireAdmin(c, h.accounts(c), claims.AccountUUID) {
+		return
+	}
+
+	jobs := h.jobs(c)
+	job, err := jobs.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.JobNotFound, "Job not found. Please try again."))
+		return
+	}
+
+	if job.Status != Dead {
+		apperr.Write(c, apperr.New(apperr.JobNotDead, "Only dead jobs can be retried!"))
+		return
+	}
+
+	if err := Retry(jobs, &job); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error retrying this job. Please try again.", err))
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
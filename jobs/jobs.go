@@ -0,0 +1,159 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Job statuses. A queued job runs once RunAt has passed, a failed one is queued again with a backoff until it
+// runs out of attempts and is dead.
+const (
+	Queued    = "queued"
+	Running   = "running"
+	Succeeded = "succeeded"
+	Dead      = "dead"
+)
+
+// DefaultMaxAttempts is how many times a job runs before it is dead.
+const DefaultMaxAttempts = 5
+
+// Backoff bounds: the first retry waits minBackoff, each one after that twice as long up to maxBackoff.
+const (
+	minBackoff = 10 * time.Second
+	maxBackoff = time.Hour
+)
+
+type Job = models.Job
+
+// Handler runs one job against the worker's database. Returning an error retries it, unless the error is
+// Permanent.
+type Handler func(ctx context.Context, gdb *gorm.DB, job Job) error
+
+var (
+	mu       sync.RWMutex
+	handlers = map[string]Handler{}
+)
+
+// Register sets the handler for a kind of job. Every process running workers has to register the same kinds.
+func Register(kind string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[kind] = handler
+}
+
+func handlerFor(kind string) (Handler, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	handler, ok := handlers[kind]
+	return handler, ok
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error that retrying cannot fix, such as a payload that does not decode. The job is dead
+// straight away.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// Enqueue queues a job to run as soon as a worker is free. Pass a transaction to only queue it if the
+// transaction commits.
+func Enqueue(gdb *gorm.DB, kind string, payload interface{}) (Job, error) {
+	return EnqueueAt(gdb, kind, payload, time.Now())
+}
+
+// EnqueueAt queues a job to run once runAt has passed.
+func EnqueueAt(gdb *gorm.DB, kind string, payload interface{}, runAt time.Time) (Job, error) {
+	return enqueue(gdb, kind, payload, runAt, "")
+}
+
+func enqueue(gdb *gorm.DB, kind string, payload interface{}, runAt time.Time, schedule string) (Job, error) {
+	job, err := New(kind, payload, runAt)
+	if err != nil {
+		return Job{}, err
+	}
+	job.Schedule = schedule
+	if result := gdb.Create(&job); result.Error != nil {
+		return Job{}, result.Error
+	}
+	return job, nil
+}
+
+// New builds a queued job to run once runAt has passed, for callers that save it through a JobRepo.
+func New(kind string, payload interface{}, runAt time.Time) (Job, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, fmt.Errorf("encoding the %s payload: %w", kind, err)
+	}
+
+	return Job{
+		UUID:        uuid.NewString(),
+		Kind:        kind,
+		Payload:     encoded,
+		Status:      Queued,
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       pq.NullTime{Time: runAt, Valid: true},
+		Created:     pq.NullTime{Time: time.Now(), Valid: true},
+	}, nil
+}
+
+// Retry queues a dead job again with its attempts reset.
+func Retry(jobs repository.JobRepo, job *Job) error {
+	job.Status = Queued
+	job.Attempts = 0
+	job.RunAt = pq.NullTime{Time: time.Now(), Valid: true}
+	job.Finished = pq.NullTime{Valid: false}
+	return jobs.Requeue(job)
+}
+
+// backoff is how long to wait before the next attempt, with up to a tenth added so retries of jobs that
+// failed together spread out.
+func backoff(attempts int32) time.Duration {
+	wait := minBackoff
+	for i := int32(1); i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait/10)+1))
+}
+
+// CleanupKind deletes succeeded jobs once they are retention old. Dead jobs are kept until retried or
+// deleted by hand. main schedules it daily.
+const CleanupKind = "jobs.cleanup"
+
+const retention = 7 * 24 * time.Hour
+
+func init() {
+	Register(CleanupKind, func(ctx context.Context, gdb *gorm.DB, job Job) error {
+		result := gdb.WithContext(ctx).Where("status = ? AND finished < ?", Succeeded, time.Now().Add(-retention)).Delete(&Job{})
+		return result.Error
+	})
+}
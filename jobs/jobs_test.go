@@ -0,0 +1,226 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"example/hivemind-be/apitest"
+	"example/hivemind-be/config"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// longAgo is when test jobs are due, so they are claimed before any other due job in the shared database.
+var longAgo = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testWorker is a worker on the test database with a name no other worker has.
+func testWorker(gdb *gorm.DB) worker {
+	return worker{name: "test-" + uuid.NewString(), gdb: gdb, cfg: config.JobsConfig{Timeout: time.Minute}}
+}
+
+// registerKind registers handler under a kind of its own for the rest of the test and returns the kind.
+func registerKind(t *testing.T, handler Handler) string {
+	kind := "test." + uuid.NewString()
+	Register(kind, handler)
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(handlers, kind)
+		mu.Unlock()
+	})
+	return kind
+}
+
+// queue saves a job of the kind due at runAt and deletes it when the test ends.
+func queue(t *testing.T, gdb *gorm.DB, kind string, runAt time.Time, maxAttempts int32) Job {
+	t.Helper()
+	job, err := New(kind, nil, runAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.MaxAttempts = maxAttempts
+	if err := gdb.Create(&job).Error; err != nil {
+		t.Fatalf("queueing a job: %v", err)
+	}
+	t.Cleanup(func() { gdb.Delete(&Job{}, job.ID) })
+	return job
+}
+
+// claimed claims the next job with w and fails unless it is want.
+func claimed(t *testing.T, w worker, want Job) Job {
+	t.Helper()
+	job, found, err := w.claim(context.Background())
+	if err != nil {
+		t.Fatalf("claiming: %v", err)
+	}
+	if !found || job.UUID != want.UUID {
+		t.Fatalf("claimed %q (found %v), want %q", job.UUID, found, want.UUID)
+	}
+	return job
+}
+
+func reload(t *testing.T, gdb *gorm.DB, job Job) Job {
+	t.Helper()
+	var stored Job
+	if err := gdb.First(&stored, job.ID).Error; err != nil {
+		t.Fatalf("reloading the job: %v", err)
+	}
+	return stored
+}
+
+func TestBackoffDoublesUpToTheMaximum(t *testing.T) {
+	for _, test := range []struct {
+		attempts int32
+		wait     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{10, maxBackoff},
+		{100, maxBackoff},
+	} {
+		for i := 0; i < 20; i++ {
+			got := backoff(test.attempts)
+			if got < test.wait || got > test.wait+test.wait/10 {
+				t.Fatalf("backoff(%d) = %s, want between %s and a tenth more", test.attempts, got, test.wait)
+			}
+		}
+	}
+}
+
+func TestClaimSkipsJobsLockedByAnotherTransaction(t *testing.T) {
+	gdb := apitest.Database(t)
+	kind := registerKind(t, func(ctx context.Context, gdb *gorm.DB, job Job) error { return nil })
+	locked := queue(t, gdb, kind, longAgo, DefaultMaxAttempts)
+	free := queue(t, gdb, kind, longAgo.Add(time.Minute), DefaultMaxAttempts)
+
+	tx := gdb.Begin()
+	defer tx.Rollback()
+	if err := tx.Exec("SELECT id FROM jobs WHERE id = ? FOR UPDATE", locked.ID).Error; err != nil {
+		t.Fatalf("locking the first job: %v", err)
+	}
+
+	w := testWorker(gdb)
+	job := claimed(t, w, free)
+	if job.Status != Running || job.Attempts != 1 || job.LockedBy != w.name {
+		t.Errorf("claimed job = status %s, attempts %d, locked by %q; want running, 1, %q", job.Status, job.Attempts, job.LockedBy, w.name)
+	}
+	if stored := reload(t, gdb, locked); stored.Status != Queued || stored.Attempts != 0 {
+		t.Errorf("locked job = status %s, attempts %d; want it left queued", stored.Status, stored.Attempts)
+	}
+}
+
+func TestFailedJobIsRetriedAfterTheBackoff(t *testing.T) {
+	gdb := apitest.Database(t)
+	kind := registerKind(t, func(ctx context.Context, gdb *gorm.DB, job Job) error { return errors.New("unavailable") })
+	job := queue(t, gdb, kind, longAgo, DefaultMaxAttempts)
+
+	w := testWorker(gdb)
+	before := time.Now()
+	w.process(context.Background(), claimed(t, w, job))
+	after := time.Now()
+
+	stored := reload(t, gdb, job)
+	if stored.Status != Queued || stored.LastError != "unavailable" || stored.LockedBy != "" {
+		t.Errorf("job = status %s, last error %q, locked by %q; want queued, unavailable, unlocked", stored.Status, stored.LastError, stored.LockedBy)
+	}
+	earliest, latest := before.Add(minBackoff), after.Add(minBackoff+minBackoff/10)
+	if runAt := stored.RunAt.Time; runAt.Before(earliest) || runAt.After(latest) {
+		t.Errorf("job runs again at %s, want between %s and %s", runAt, earliest, latest)
+	}
+}
+
+func TestPermanentErrorKillsTheJobStraightAway(t *testing.T) {
+	gdb := apitest.Database(t)
+	kind := registerKind(t, func(ctx context.Context, gdb *gorm.DB, job Job) error {
+		return Permanent(errors.New("payload does not decode"))
+	})
+	job := queue(t, gdb, kind, longAgo, DefaultMaxAttempts)
+
+	w := testWorker(gdb)
+	w.process(context.Background(), claimed(t, w, job))
+
+	stored := reload(t, gdb, job)
+	if stored.Status != Dead || stored.Attempts != 1 || !stored.Finished.Valid {
+		t.Errorf("job = status %s, attempts %d, finished %v; want dead after 1 attempt", stored.Status, stored.Attempts, stored.Finished.Valid)
+	}
+}
+
+func TestJobIsDeadOnceItRunsOutOfAttempts(t *testing.T) {
+	gdb := apitest.Database(t)
+	kind := registerKind(t, func(ctx context.Context, gdb *gorm.DB, job Job) error { return errors.New("unavailable") })
+	job := queue(t, gdb, kind, longAgo, 2)
+
+	w := testWorker(gdb)
+	w.process(context.Background(), claimed(t, w, job))
+	if stored := reload(t, gdb, job); stored.Status != Queued {
+		t.Fatalf("after the first attempt the job is %s, want queued", stored.Status)
+	}
+
+	//bring the retry forward instead of waiting out the backoff
+	if err := gdb.Model(&Job{}).Where("id = ?", job.ID).Update("run_at", longAgo).Error; err != nil {
+		t.Fatal(err)
+	}
+	w.process(context.Background(), claimed(t, w, job))
+
+	stored := reload(t, gdb, job)
+	if stored.Status != Dead || stored.Attempts != 2 || stored.LastError != "unavailable" {
+		t.Errorf("job = status %s, attempts %d, last error %q; want dead after 2 attempts", stored.Status, stored.Attempts, stored.LastError)
+	}
+}
+
+func TestScheduleIsSkippedWhileItsLastRunIsPending(t *testing.T) {
+	gdb := apitest.Database(t)
+	ctx := context.Background()
+	kind := registerKind(t, func(ctx context.Context, gdb *gorm.DB, job Job) error { return nil })
+	name := "test." + uuid.NewString()
+	Schedule(name, "@every 1m", kind, nil)
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(schedules, name)
+		mu.Unlock()
+		gdb.Where("schedule = ?", name).Delete(&Job{})
+		gdb.Where("name = ?", name).Delete(&JobSchedule{})
+	})
+	if err := syncSchedules(ctx, gdb); err != nil {
+		t.Fatalf("syncing schedules: %v", err)
+	}
+
+	// due makes the schedule come round, runs the scheduler and returns the jobs it has enqueued so far
+	due := func() []Job {
+		t.Helper()
+		if err := gdb.Model(&JobSchedule{}).Where("name = ?", name).Update("next_run", longAgo).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := enqueueDue(ctx, gdb); err != nil {
+			t.Fatalf("enqueueing due jobs: %v", err)
+		}
+		var enqueued []Job
+		if err := gdb.Where("schedule = ?", name).Order("id").Find(&enqueued).Error; err != nil {
+			t.Fatal(err)
+		}
+		return enqueued
+	}
+
+	if enqueued := due(); len(enqueued) != 1 || enqueued[0].Kind != kind {
+		t.Fatalf("the first run enqueued %d jobs, want 1 of kind %s", len(enqueued), kind)
+	}
+	if enqueued := due(); len(enqueued) != 1 {
+		t.Fatalf("a run while the last one is queued enqueued %d jobs in all, want 1", len(enqueued))
+	}
+	var schedule JobSchedule
+	if err := gdb.First(&schedule, "name = ?", name).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !schedule.NextRun.Time.After(time.Now()) {
+		t.Errorf("a skipped run left the next run at %s, want it moved on", schedule.NextRun.Time)
+	}
+
+	if err := gdb.Model(&Job{}).Where("schedule = ?", name).Update("status", Succeeded).Error; err != nil {
+		t.Fatal(err)
+	}
+	if enqueued := due(); len(enqueued) != 2 {
+		t.Errorf("a run after the last one finished enqueued %d jobs in all, want 2", len(enqueued))
+	}
+}
@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"context"
+	"example/hivemind-be/models"
+	"log/slog"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// JobSchedule is the shared state of a schedule, so only one instance enqueues each run.
+type JobSchedule = models.JobSchedule
+
+type schedule struct {
+	name     string
+	spec     string
+	kind     string
+	payload  interface{}
+	schedule cron.Schedule
+}
+
+var schedules = map[string]schedule{}
+
+// Schedule enqueues a job of the kind whenever spec comes round. spec is a five field cron expression or a
+// descriptor such as @hourly or @every 30m, and panics when it does not parse. A run is skipped while the
+// previous one is still queued or running.
+func Schedule(name string, spec string, kind string, payload interface{}) {
+	parsed, err := cron.ParseStandard(spec)
+	if err != nil {
+		panic("jobs: schedule " + name + ": " + err.Error())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	schedules[name] = schedule{name: name, spec: spec, kind: kind, payload: payload, schedule: parsed}
+}
+
+func registeredSchedules() []schedule {
+	mu.RLock()
+	defer mu.RUnlock()
+	registered := make([]schedule, 0, len(schedules))
+	for _, s := range schedules {
+		registered = append(registered, s)
+	}
+	return registered
+}
+
+// syncSchedules adds the registered schedules that have no row yet and restarts the ones whose spec changed.
+func syncSchedules(ctx context.Context, gdb *gorm.DB) error {
+	now := time.Now()
+	for _, s := range registeredSchedules() {
+		result := gdb.WithContext(ctx).Exec(`INSERT INTO job_schedules (name, spec, next_run) VALUES (?, ?, ?)
+			ON CONFLICT (name) DO UPDATE SET spec = EXCLUDED.spec, next_run = EXCLUDED.next_run
+			WHERE job_schedules.spec <> EXCLUDED.spec`, s.name, s.spec, s.schedule.Next(now))
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+// runScheduler enqueues due scheduled jobs every interval until ctx is done.
+func runScheduler(ctx context.Context, gdb *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := enqueueDue(ctx, gdb); err != nil && ctx.Err() == nil {
+			slog.Error("could not enqueue scheduled jobs", "error", err)
+		}
+	}
+}
+
+// enqueueDue enqueues a job for every schedule that has come round and moves it to its next run. Missed runs
+// collapse into one.
+func enqueueDue(ctx context.Context, gdb *gorm.DB) error {
+	registered := map[string]schedule{}
+	names := []string{}
+	for _, s := range registeredSchedules() {
+		registered[s.name] = s
+		names = append(names, s.name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	return gdb.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var due []JobSchedule
+		result := tx.Raw("SELECT * FROM job_schedules WHERE name IN ? AND next_run <= now() FOR UPDATE SKIP LOCKED", names).Scan(&due)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		now := time.Now()
+		for _, row := range due {
+			s := registered[row.Name]
+			var pending int64
+			if result := tx.Model(&Job{}).Where("schedule = ? AND status IN ?", s.name, []string{Queued, Running}).Count(&pending); result.Error != nil {
+				return result.Error
+			}
+			if pending == 0 {
+				if _, err := enqueue(tx, s.kind, s.payload, now, s.name); err != nil {
+					return err
+				}
+			} else {
+				slog.Warn("skipping a scheduled job, the previous run has not finished", "schedule", s.name)
+			}
+
+			result := tx.Model(&JobSchedule{}).Where("name = ?", s.name).Updates(map[string]interface{}{
+				"next_run": s.schedule.Next(now),
+				"last_run": now,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,160 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"example/hivemind-be/config"
+	"example/hivemind-be/metrics"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// leaseGrace is added to the job timeout before a running job is taken to be abandoned by a worker that died,
+// and is claimed again.
+const leaseGrace = time.Minute
+
+// Run starts cfg.Workers workers and the scheduler, and blocks until ctx is done and the jobs that were
+// running have finished.
+func Run(ctx context.Context, gdb *gorm.DB, cfg config.JobsConfig) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+
+	if err := syncSchedules(ctx, gdb); err != nil {
+		slog.Error("could not sync job schedules", "error", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		w := worker{
+			name: fmt.Sprintf("%s-%d-%d", host, os.Getpid(), i),
+			gdb:  gdb,
+			cfg:  cfg,
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runScheduler(ctx, gdb, cfg.PollInterval)
+	}()
+	wg.Wait()
+}
+
+type worker struct {
+	name string
+	gdb  *gorm.DB
+	cfg  config.JobsConfig
+}
+
+// run claims and runs jobs until ctx is done, waiting a poll interval whenever the queue is empty.
+func (w worker) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, found, err := w.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("could not claim a job", "worker", w.name, "error", err)
+		}
+		if !found {
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.cfg.PollInterval):
+			}
+			continue
+		}
+		w.process(ctx, job)
+	}
+}
+
+// claim takes the next due job, or a running one locked for longer than the timeout allows, skipping rows
+// other workers have locked.
+func (w worker) claim(ctx context.Context) (Job, bool, error) {
+	var job Job
+	result := w.gdb.WithContext(ctx).Raw(`UPDATE jobs SET status = @running, attempts = attempts + 1, locked_by = @worker, locked_at = now()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = @queued AND run_at <= now()) OR (status = @running AND locked_at < @stale)
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, map[string]interface{}{
+		"running": Running,
+		"queued":  Queued,
+		"worker":  w.name,
+		"stale":   time.Now().Add(-w.cfg.Timeout - leaseGrace),
+	}).Scan(&job)
+	if result.Error != nil {
+		return Job{}, false, result.Error
+	}
+	return job, result.RowsAffected > 0, nil
+}
+
+// process runs the job and records the outcome. The job gets its own timeout rather than ctx, so shutting
+// down lets running jobs finish.
+func (w worker) process(ctx context.Context, job Job) {
+	log := slog.With("job", job.UUID, "kind", job.Kind, "attempt", job.Attempts, "worker", w.name)
+
+	var err error
+	if job.Attempts > job.MaxAttempts {
+		//only reclaimed jobs get here, their last worker died while running them
+		err = Permanent(errors.New("abandoned while running"))
+	} else if handler, ok := handlerFor(job.Kind); !ok {
+		err = fmt.Errorf("no handler for %s jobs", job.Kind)
+	} else {
+		jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), w.cfg.Timeout)
+		started := time.Now()
+		err = call(jobCtx, handler, w.gdb, job)
+		cancel()
+		log = log.With("duration_ms", time.Since(started).Milliseconds())
+	}
+
+	updates := map[string]interface{}{"locked_by": nil, "locked_at": nil}
+	result := "succeeded"
+	switch {
+	case err == nil:
+		updates["status"] = Succeeded
+		updates["finished"] = time.Now()
+		updates["last_error"] = nil
+		log.Info("job succeeded")
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		updates["status"] = Dead
+		updates["finished"] = time.Now()
+		updates["last_error"] = err.Error()
+		result = "dead"
+		log.Error("job failed for good", "error", err)
+	default:
+		wait := backoff(job.Attempts)
+		updates["status"] = Queued
+		updates["run_at"] = time.Now().Add(wait)
+		updates["last_error"] = err.Error()
+		result = "retried"
+		log.Warn("job failed, retrying", "error", err, "retry_in", wait.String())
+	}
+	metrics.JobsProcessed.WithLabelValues(job.Kind, result).Inc()
+
+	//the lock check leaves alone a job that was reclaimed after this worker took too long
+	saved := w.gdb.Model(&Job{}).Where("id = ? AND locked_by = ?", job.ID, w.name).Updates(updates)
+	if saved.Error != nil {
+		log.Error("could not record the job's outcome", "error", saved.Error)
+	}
+}
+
+// call runs the handler, turning a panic into an error.
+func call(ctx context.Context, handler Handler, gdb *gorm.DB, job Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handler(ctx, gdb, job)
+}
@@ -0,0 +1,167 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request id in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// Keys the middleware and handlers share through the gin context.
+const (
+	requestIDKey   = "logging:request_id"
+	accountUUIDKey = "logging:account_uuid"
+	loggerKey      = "logging:logger"
+)
+
+// maxRequestIDLength bounds ids taken from the caller so they cannot bloat every log line.
+const maxRequestIDLength = 128
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute names, compared case insensitively, whose values are never written. Old and
+// New are the password change fields.
+var sensitiveKeys = []string{"authorization", "password", "secret", "token", "cookie", "old", "new"}
+
+type contextKey struct{}
+
+// Setup makes a JSON logger writing to stdout the default for slog and the standard log package. level is
+// one of debug, info, warn or error.
+func Setup(level string) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(newHandler(os.Stdout, lvl)))
+}
+
+// newHandler writes JSON lines at level and above to w, with the values of sensitive attributes redacted.
+func newHandler(w io.Writer, level slog.Level) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: redact})
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if isSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if key == sensitive || (len(sensitive) > 3 && strings.Contains(key, sensitive)) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromContext returns the request's logger, which tags every line with the request id, or the default
+// logger outside of a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	if c, ok := ctx.(*gin.Context); ok {
+		if logger, ok := c.Get(loggerKey); ok {
+			return logger.(*slog.Logger)
+		}
+		ctx = c.Request.Context()
+	}
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// RequestID returns the id of the request, empty outside of the middleware.
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// SetAccount records who made the request so the access log can name them.
+func SetAccount(c *gin.Context, accountUUID string) {
+	c.Set(accountUUIDKey, accountUUID)
+}
+
+// Middleware takes the request id from the X-Request-ID header, or makes one up, echoes it in the response
+// and logs one line per request once it has been served.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+
+		logger := slog.Default().With("request_id", id)
+		c.Set(loggerKey, logger)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, logger))
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if account := c.GetString(accountUUIDKey); account != "" {
+			attrs = append(attrs, slog.String("account_uuid", account))
+		}
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", span.TraceID().String()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// validRequestID accepts short printable ASCII ids so callers cannot inject new lines or control characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Recovery logs a panic with its stack and turns it into an error handed to respond, which writes (and
+// logs) the response.
+func Recovery(respond func(c *gin.Context, err error)) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		err := fmt.Errorf("panic: %v", recovered)
+		FromContext(c).Error("recovered from a panic", "error", err, "stack", string(debug.Stack()))
+		respond(c, err)
+	})
+}
@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestSensitiveValuesAreRedacted(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(newHandler(&out, slog.LevelInfo))
+	logger.Info("login",
+		"Authorization", "Bearer abc.def.ghi",
+		"password", "hunter2",
+		"client_secret", "s3cret",
+		"refresh_token", "r3fresh",
+		"Cookie", "session=1",
+		"Old", "old-password",
+		"New", "new-password",
+		slog.Group("request", "authorization", "Bearer nested"),
+		"username", "alice",
+		"notes", "kept",
+	)
+
+	for _, secret := range []string{"abc.def.ghi", "hunter2", "s3cret", "r3fresh", "session=1", "old-password", "new-password", "nested"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("the log line contains %q: %s", secret, out.String())
+		}
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("the log line is not JSON: %v", err)
+	}
+	for _, key := range []string{"Authorization", "password", "client_secret", "refresh_token", "Cookie", "Old", "New"} {
+		if line[key] != redacted {
+			t.Errorf("%s = %v, want %s", key, line[key], redacted)
+		}
+	}
+	if request, _ := line["request"].(map[string]interface{}); request["authorization"] != redacted {
+		t.Errorf("request.authorization = %v, want %s", request["authorization"], redacted)
+	}
+	if line["username"] != "alice" || line["notes"] != "kept" {
+		t.Errorf("username = %v and notes = %v, want other attributes kept", line["username"], line["notes"])
+	}
+}
+
+func TestMalformedRequestIDsAreReplaced(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := slog.Default()
+	slog.SetDefault(slog.New(newHandler(io.Discard, slog.LevelInfo)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, RequestID(c))
+	})
+
+	for _, test := range []struct {
+		name string
+		id   string
+		kept bool
+	}{
+		{"valid", "req-123_abc.DEF", true},
+		{"longest allowed", strings.Repeat("a", maxRequestIDLength), true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"new line", "abc\ninjected=1", false},
+		{"carriage return", "abc\rdef", false},
+		{"space", "abc def", false},
+		{"tab", "abc\tdef", false},
+		{"control character", "abc\x00def", false},
+		{"non ASCII", "abcédef", false},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.id != "" {
+			request.Header.Set(RequestIDHeader, test.id)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		id := w.Header().Get(RequestIDHeader)
+		if w.Body.String() != id {
+			t.Errorf("%s: the handler saw request id %q, the response has %q", test.name, w.Body.String(), id)
+		}
+		if test.kept {
+			if id != test.id {
+				t.Errorf("%s: request id = %q, want %q kept", test.name, id, test.id)
+			}
+			continue
+		}
+		if _, err := uuid.Parse(id); err != nil || id == test.id {
+			t.Errorf("%s: request id = %q, want a new uuid in place of %q", test.name, id, test.id)
+		}
+	}
+}
+
+func TestRecoveryLogsThePanicWithItsStack(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var out bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(newHandler(&out, slog.LevelInfo)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var responded error
+	router := gin.New()
+	router.Use(Middleware(), Recovery(func(c *gin.Context, err error) {
+		responded = err
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	router.GET("/", func(c *gin.Context) {
+		panic("nil map")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError || responded == nil || responded.Error() != "panic: nil map" {
+		t.Fatalf("status = %d and respond got %v, want %d and the panic", w.Code, responded, http.StatusInternalServerError)
+	}
+
+	var logged map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err == nil && entry["stack"] != nil {
+			logged = entry
+		}
+	}
+	if logged == nil {
+		t.Fatalf("no stack was logged: %s", out.String())
+	}
+	stack, _ := logged["stack"].(string)
+	if logged["level"] != "ERROR" || logged["error"] != "panic: nil map" || !strings.Contains(stack, "TestRecoveryLogsThePanicWithItsStack") {
+		t.Errorf("logged %v, want the panic and a stack through the handler", logged)
+	}
+	if logged["request_id"] == nil {
+		t.Errorf("logged %v, want the request's logger", logged)
+	}
+}
@@ -1,24 +1,300 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/config"
+	"example/hivemind-be/content"
+	"example/hivemind-be/counters"
 	"example/hivemind-be/db"
+	"example/hivemind-be/events"
+	"example/hivemind-be/health"
+	"example/hivemind-be/jobs"
+	"example/hivemind-be/logging"
+	"example/hivemind-be/metrics"
+	"example/hivemind-be/migrate"
+	"example/hivemind-be/notification"
+	"example/hivemind-be/realtime"
+	"example/hivemind-be/repository"
 	"example/hivemind-be/routes"
+	"example/hivemind-be/schema"
+	"example/hivemind-be/spam"
+	"example/hivemind-be/token"
+	"example/hivemind-be/tracing"
+	"example/hivemind-be/webhook"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
-	router := gin.Default()
-	db.ConnectDatabase()
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	logging.Setup(cfg.LogLevel)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("could not set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	if err := db.ConnectDatabase(cfg.Database); err != nil {
+		slog.Error("could not connect to the database", "error", err)
+		os.Exit(1)
+	}
+	if err := tracing.InstrumentGorm(db.Db); err != nil {
+		slog.Warn("could not trace database queries", "error", err)
+	}
+	token.Configure(cfg.Token)
+	webhook.Configure(cfg.Webhooks)
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(args[1:]); err != nil {
+			slog.Error("migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.MigrateOnStart {
+		if err := runMigrate([]string{"up"}); err != nil {
+			slog.Error("migration failed", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	registerJobs(cfg)
+	if len(args) > 0 && args[0] == "worker" {
+		if err := runWorker(cfg.Jobs); err != nil {
+			slog.Error("worker stopped", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	sqlDB, err := db.Db.DB()
+	if err == nil {
+		err = metrics.RegisterDB(sqlDB)
+	}
+	if err != nil {
+		slog.Warn("could not export database pool metrics", "error", err)
+	}
+
+	//tracing runs first so the access log can carry the trace id
+	router := gin.New()
+	//no proxy is trusted to set X-Forwarded-For, the client address comes from the header the Fly edge sets
+	router.TrustedPlatform = "Fly-Client-IP"
+	if err := router.SetTrustedProxies(nil); err != nil {
+		slog.Error("could not configure trusted proxies", "error", err)
+		os.Exit(1)
+	}
+	router.Use(tracing.Middleware())
+	router.Use(logging.Middleware())
+	router.Use(logging.Recovery(func(c *gin.Context, err error) {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "", err))
+	}))
 	router.Use(cors.New(cors.Config{
-		AllowOrigins: []string{"*"}, // Change this to the origin you want to allow
-		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		MaxAge:       12 * time.Hour,
+		AllowOrigins:  cfg.Server.CORSOrigins,
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:  []string{"Origin", "Content-Type", "Accept", "Authorization", logging.RequestIDHeader},
+		ExposeHeaders: []string{logging.RequestIDHeader},
+		MaxAge:        12 * time.Hour,
 	}))
+	realtime.Configure(cfg.Server.CORSOrigins)
 	routes.Routes(router)
-	router.Run(os.Getenv("PORT"))
+
+	background, stopBackground := context.WithCancel(context.Background())
+	//the classifier is kept in memory, so every server process trains its own
+	go spam.Train(background, repository.NewGormRepos(db.Db).Spam)
+	workersDone := make(chan struct{})
+	go func() {
+		defer close(workersDone)
+		if cfg.Jobs.Workers > 0 {
+			runBackground(background, cfg.Jobs)
+		}
+	}()
+
+	err = serve(router, cfg.Server)
+	//running jobs finish before the database closes
+	stopBackground()
+	<-workersDone
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if flushErr := shutdownTracing(flushCtx); flushErr != nil {
+		slog.Warn("could not flush traces", "error", flushErr)
+	}
+	if err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// serve runs the HTTP server until SIGINT or SIGTERM, then stops accepting connections and gives in-flight
+// requests up to cfg.ShutdownTimeout to finish.
+func serve(handler http.Handler, cfg config.ServerConfig) error {
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	//streams stay open until the client leaves, so end them for Shutdown to drain
+	server.RegisterOnShutdown(realtime.Shutdown)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	failed := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "addr", cfg.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			failed <- err
+		}
+	}()
+
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("shutting down, draining requests", "timeout", cfg.ShutdownTimeout.String())
+	health.Drain()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// eventRetention is how long dispatched events stay in the outbox.
+const eventRetention = 7 * 24 * time.Hour
+
+// registerJobs sets every job schedule, and the handlers that are not registered by their own package, in
+// the server and the worker alike.
+func registerJobs(cfg config.Config) {
+	jobs.Schedule(jobs.CleanupKind, "@daily", jobs.CleanupKind, nil)
+	jobs.Schedule(notification.PurgeKind, "@daily", notification.PurgeKind, nil)
+	jobs.Register(counters.JobKind, counters.Job(cfg.Counters.Repair))
+	if cfg.Counters.ReconcileInterval > 0 {
+		jobs.Schedule("counters", "@every "+cfg.Counters.ReconcileInterval.String(), counters.JobKind, nil)
+	}
+	jobs.Register("events.purge", func(ctx context.Context, gdb *gorm.DB, job jobs.Job) error {
+		return events.Purge(ctx, gdb, time.Now().Add(-eventRetention))
+	})
+	jobs.Schedule("events.purge", "@daily", "events.purge", nil)
+	jobs.Register(content.PublishKind, content.PublishDue)
+	jobs.Schedule(content.PublishKind, "@every 1m", content.PublishKind, nil)
+}
+
+// runBackground runs the job workers and the event dispatcher until ctx is done and both have stopped.
+func runBackground(ctx context.Context, cfg config.JobsConfig) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		jobs.Run(ctx, db.Db, cfg)
+	}()
+	go func() {
+		defer wg.Done()
+		events.Run(ctx, db.Db, cfg.PollInterval)
+	}()
+	wg.Wait()
+}
+
+// runWorker runs the job workers and the event dispatcher without the HTTP server until SIGINT or SIGTERM,
+// for deployments that set JOB_WORKERS to 0 on the server.
+func runWorker(cfg config.JobsConfig) error {
+	if cfg.Workers < 1 {
+		return fmt.Errorf("JOB_WORKERS must be at least 1 to run the worker")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("running job workers", "workers", cfg.Workers)
+	runBackground(ctx, cfg)
+	return db.Close()
+}
+
+// runMigrate handles `migrate up`, `migrate down [steps]`, `migrate status` and `migrate check`.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [steps] | status | check")
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := migrate.Up(db.Db)
+		for _, migration := range done {
+			fmt.Printf("Applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Println("Schema is up to date.")
+		}
+		return checkModels()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive number")
+			}
+		}
+		done, err := migrate.Down(db.Db, steps)
+		for _, migration := range done {
+			fmt.Printf("Reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrate.Status(db.Db)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	case "check":
+		return checkModels()
+	}
+	return fmt.Errorf("unknown migrate command %q", args[0])
+}
+
+// checkModels fails when a model has drifted from the migrated schema.
+func checkModels() error {
+	problems, err := migrate.CheckModels(db.Db, schema.Models...)
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d differences between the models and the schema", len(problems))
+	}
+	fmt.Println("Models match the schema.")
+	return nil
 }
@@ -0,0 +1,81 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// FuzzRender checks that no source renders to a script element, an event handler attribute or a
+// javascript: URL. Text, alt text and titles may mention javascript: freely, so the scheme is checked in the
+// other attribute values, with the whitespace and control characters browsers ignore in URLs taken out.
+func FuzzRender(f *testing.F) {
+	for _, seed := range []string{
+		"<script>alert(1)</script>",
+		"<SCRIPT SRC=//example.com/x.js></SCRIPT>",
+		"<img src=x onerror=alert(1)>",
+		"<div onmouseover=\"alert(1)\">hover</div>",
+		"<a href=\"javascript:alert(1)\">click</a>",
+		"<javascript:alert(1)>",
+		"<https://example.com/?q=<script>>",
+		"<mailto:someone@example.com>",
+		"[click](javascript:alert(1))",
+		"[click](JaVaScRiPt:alert(1))",
+		"[click](java&#09;script:alert(1))",
+		"[click](&#106;avascript:alert(1))",
+		"[click](http:javascript:alert(1))",
+		"[click](https://example.com \"title\\\" onmouseover=\\\"alert(1)\")",
+		"[click][ref]\n\n[ref]: javascript:alert(1) \"title\"",
+		"![alt](javascript:alert(1) \"title\")",
+		"![alt\" onerror=\"alert(1)](https://example.com/x.png)",
+		"![alt](https://example.com/x.png 'a\" onload=\"alert(1)')",
+		"| a | b |\n|:--|--:|\n| <b onclick=alert(1)>x</b> | [y](javascript:alert(1)) |",
+		"| <script>alert(1)</script> |\n| --- |\n| `<img onerror=x>` |",
+		"```html\n<script>alert(1)</script>\n```",
+		"~~<iframe src=javascript:alert(1)>~~",
+		"javascript:alert(1) is only text here",
+		"![javascript:alert(1)](https://example.com/x.png \"javascript:alert(1)\")",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		out := Render(source)
+		if strings.Contains(strings.ToLower(out), "<script") {
+			t.Fatalf("Render(%q) = %q, has a script element", source, out)
+		}
+		tokens := html.NewTokenizer(strings.NewReader(out))
+		for {
+			kind := tokens.Next()
+			if kind == html.ErrorToken {
+				return
+			}
+			if kind != html.StartTagToken && kind != html.SelfClosingTagToken {
+				continue
+			}
+			token := tokens.Token()
+			if token.Data == "script" {
+				t.Fatalf("Render(%q) = %q, has a script element", source, out)
+			}
+			for _, attr := range token.Attr {
+				if strings.HasPrefix(strings.ToLower(attr.Key), "on") {
+					t.Fatalf("Render(%q) = %q, has the event handler %s", source, out, attr.Key)
+				}
+				if attr.Key != "alt" && attr.Key != "title" && strings.HasPrefix(urlForm(attr.Val), "javascript:") {
+					t.Fatalf("Render(%q) = %q, has a javascript: URL in %s", source, out, attr.Key)
+				}
+			}
+		}
+	})
+}
+
+// urlForm is value in lower case without the whitespace and control characters a browser skips when it
+// reads the scheme of a URL.
+func urlForm(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.ToLower(value))
+}
@@ -0,0 +1,116 @@
+package mention
+
+import (
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"regexp"
+	"strings"
+)
+
+// Types of mention.
+const (
+	Account = "account"
+	Hive    = "hive"
+)
+
+// maxMentions bounds the accounts and hives one message can mention. Later references are left as text.
+const maxMentions = 25
+
+var (
+	//a reference starts the message or follows a character that cannot be part of a word, an email address or a path
+	accountPattern = regexp.MustCompile(`(?:^|[^\w@/])(@([\w-]{1,64}))`)
+	hivePattern    = regexp.MustCompile(`(?:^|[^\w/])(h/([a-zA-Z]{1,30}))\b`)
+)
+
+// reference is one @username or h/hivename as written in a message.
+type reference struct {
+	kind string
+	name string
+	text string
+}
+
+// parse returns the references in message, accounts before hives and each account and hive once.
+func parse(message string) []reference {
+	var refs []reference
+	seen := map[string]bool{}
+	add := func(kind string, matches [][]int) {
+		for _, match := range matches {
+			ref := reference{kind: kind, text: message[match[2]:match[3]], name: message[match[4]:match[5]]}
+			if kind == Account {
+				//usernames are saved in lower case
+				ref.name = strings.ToLower(ref.name)
+			}
+			if seen[kind+":"+ref.name] {
+				continue
+			}
+			seen[kind+":"+ref.name] = true
+			refs = append(refs, ref)
+		}
+	}
+	add(Account, accountPattern.FindAllStringSubmatchIndex(message, -1))
+	add(Hive, hivePattern.FindAllStringSubmatchIndex(message, -1))
+	if len(refs) > maxMentions {
+		refs = refs[:maxMentions]
+	}
+	return refs
+}
+
+// Resolve returns the accounts and hives message mentions. References to accounts that do not exist, are
+// deleted or are banned, and to hives that do not exist or are banned, are left out.
+func Resolve(accounts repository.AccountRepo, hives repository.HiveRepo, message string) ([]models.Mention, error) {
+	refs := parse(message)
+	var usernames, names []string
+	for _, ref := range refs {
+		if ref.kind == Account {
+			usernames = append(usernames, ref.name)
+		} else {
+			names = append(names, ref.name)
+		}
+	}
+
+	found := map[string]models.Mention{}
+	matched, err := accounts.ListByUsernames(usernames)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range matched {
+		if !account.Deleted && !account.Banned {
+			found[Account+":"+account.Username] = models.Mention{Type: Account, TargetUUID: account.UUID, Name: account.Username}
+		}
+	}
+	matchedHives, err := hives.ListByNames(names)
+	if err != nil {
+		return nil, err
+	}
+	for _, hive := range matchedHives {
+		if !hive.Banned {
+			found[Hive+":"+hive.Name] = models.Mention{Type: Hive, TargetUUID: hive.UUID, Name: hive.Name}
+		}
+	}
+
+	mentions := []models.Mention{}
+	for _, ref := range refs {
+		if mention, ok := found[ref.kind+":"+ref.name]; ok {
+			mention.Text = ref.text
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions, nil
+}
+
+// BySource returns the stored mentions of the sources of sourceType, by source UUID. Sources without
+// mentions map to an empty list.
+func BySource(repo repository.MentionRepo, sourceType string, uuids []string) (map[string][]models.Mention, error) {
+	stored, err := repo.ListBySources(sourceType, uuids)
+	if err != nil {
+		return nil, err
+	}
+	bySource := make(map[string][]models.Mention, len(uuids))
+	for _, uuid := range uuids {
+		bySource[uuid] = []models.Mention{}
+	}
+	for _, mention := range stored {
+		bySource[mention.SourceUUID] = append(bySource[mention.SourceUUID], mention)
+	}
+	return bySource, nil
+}
@@ -0,0 +1,107 @@
+package mention
+
+import (
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	account := func(name string, text string) reference { return reference{kind: Account, name: name, text: text} }
+	hive := func(name string) reference { return reference{kind: Hive, name: name, text: "h/" + name} }
+
+	for _, test := range []struct {
+		name    string
+		message string
+		want    []reference
+	}{
+		{"nothing", "Hello there", nil},
+		{"start of the message", "@alice hi", []reference{account("alice", "@alice")}},
+		{"after punctuation", "Thanks (@alice), see h/golang.", []reference{account("alice", "@alice"), hive("golang")}},
+		{"usernames are folded to lower case", "@Alice and @ALICE", []reference{account("alice", "@Alice")}},
+		{"each reference once", "h/golang h/golang @bob @bob", []reference{account("bob", "@bob"), hive("golang")}},
+		{"accounts before hives", "h/rust @bob", []reference{account("bob", "@bob"), hive("rust")}},
+		{"email addresses", "mail alice@example.com or @bob", []reference{account("bob", "@bob")}},
+		{"paths", "see /users/@alice and example.com/h/golang", nil},
+		{"inside a word", "foo@bar xh/golang", nil},
+		{"hive names are letters only", "h/go_lang h/rust2", nil},
+		{"dashes in usernames", "@mary-jane", []reference{account("mary-jane", "@mary-jane")}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parse(test.message); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parse(%q) = %+v, want %+v", test.message, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseStopsAtMaxMentions(t *testing.T) {
+	var words []string
+	for i := 0; i < maxMentions+5; i++ {
+		words = append(words, fmt.Sprintf("@user%d", i))
+	}
+	words = append(words, "h/golang")
+
+	refs := parse(strings.Join(words, " "))
+	if len(refs) != maxMentions {
+		t.Fatalf("%d references, want %d", len(refs), maxMentions)
+	}
+	if last := refs[len(refs)-1]; last.name != fmt.Sprintf("user%d", maxMentions-1) {
+		t.Errorf("last reference = %+v, want the first %d accounts", last, maxMentions)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	for _, account := range []models.Account{
+		{Username: "alice", UUID: "alice-uuid"},
+		{Username: "deleted", UUID: "deleted-uuid", Deleted: true},
+		{Username: "banned", UUID: "banned-uuid", Banned: true},
+	} {
+		account.Email = account.Username + "@example.com"
+		if err := repos.Accounts.Create(&account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, hive := range []models.Hive{
+		{Name: "golang", UUID: "golang-uuid"},
+		{Name: "closed", UUID: "closed-uuid", Banned: true},
+	} {
+		if err := repos.Hives.Create(&hive); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		name    string
+		message string
+		want    []models.Mention
+	}{
+		{"nothing", "Hello", []models.Mention{}},
+		{
+			"accounts and hives",
+			"@ALICE, look at h/golang",
+			[]models.Mention{
+				{Type: Account, TargetUUID: "alice-uuid", Name: "alice", Text: "@ALICE"},
+				{Type: Hive, TargetUUID: "golang-uuid", Name: "golang", Text: "h/golang"},
+			},
+		},
+		{"missing accounts and hives", "@nobody h/nowhere", []models.Mention{}},
+		{"deleted and banned accounts", "@deleted @banned", []models.Mention{}},
+		{"banned hives", "h/closed", []models.Mention{}},
+		{"email addresses", "alice@example.com", []models.Mention{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Resolve(repos.Accounts, repos.Hives, test.message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Resolve(%q) = %+v, want %+v", test.message, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "hivemind"
+
+// Registry holds every hivemind metric. It is separate from the default registry so only what is registered
+// here is exported.
+var Registry = prometheus.NewRegistry()
+
+var (
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HivesCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hives_created_total",
+		Help:      "Hives created.",
+	})
+
+	ContentPosted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "content_posted_total",
+		Help:      "Content posted.",
+	})
+
+	CommentsPosted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comments_posted_total",
+		Help:      "Comments and replies posted.",
+	})
+
+	// VotesCast is labelled with the target ("content" or "comment") and direction ("upvote" or "downvote").
+	VotesCast = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "votes_cast_total",
+		Help:      "Votes cast, by target and direction.",
+	}, []string{"target", "direction"})
+
+	// Logins is labelled with the result, "success" or "failure".
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts, by result.",
+	}, []string{"result"})
+
+	// TokenRefreshes is labelled with the result, "success" or "failure".
+	TokenRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_refreshes_total",
+		Help:      "Token refreshes, by result.",
+	}, []string{"result"})
+
+	// JobsProcessed is labelled with the job kind and whether the run succeeded, was retried or left it dead.
+	JobsProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_processed_total",
+		Help:      "Background job runs, by kind and result.",
+	}, []string{"kind", "result"})
+
+	// EventsDispatched is labelled with the event type and whether it was delivered, retried or given up on.
+	EventsDispatched = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_dispatched_total",
+		Help:      "Domain event deliveries to subscribers, by type and result.",
+	}, []string{"type", "result"})
+
+	// DomainEvents counts the events subscribers have seen, redeliveries included.
+	DomainEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "domain_events_total",
+		Help:      "Domain events delivered, by type.",
+	}, []string{"type"})
+
+	// WebhookDeliveries is labelled with the webhook event and whether the receiver accepted it.
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts, by event and result.",
+	}, []string{"event", "result"})
+
+	// RealtimeConnections is labelled with the transport, sse or websocket.
+	RealtimeConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "realtime_connections",
+		Help:      "Open real-time connections, by transport.",
+	}, []string{"transport"})
+
+	// CounterDrift is labelled with the counter as table.column and set by every counter reconciliation.
+	CounterDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "counter_drift_rows",
+		Help:      "Rows whose denormalized counter differed from the rows it counts at the last reconciliation.",
+	}, []string{"counter"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestDuration,
+		requests,
+		HivesCreated,
+		ContentPosted,
+		CommentsPosted,
+		VotesCast,
+		Logins,
+		TokenRefreshes,
+		CounterDrift,
+		JobsProcessed,
+		EventsDispatched,
+		DomainEvents,
+		WebhookDeliveries,
+		RealtimeConnections,
+	)
+}
+
+// Result is the label value for an outcome.
+func Result(ok bool) string {
+	if ok {
+		return "success"
+	}
+	return "failure"
+}
+
+// RegisterDB exports the connection pool stats of db, such as open, in use and idle connections and how
+// long requests waited for one.
+func RegisterDB(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Middleware records the duration and status of every request. Requests are labelled with their route
+// pattern rather than their path so that uuids do not create a series each.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		requestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+		requests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
+
+// Handler serves the registry in the Prometheus text format.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry}))
+}
@@ -0,0 +1,106 @@
+package migrate
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// columnTypes lists the Postgres types a field of each GORM data type can be stored in. Fields with an
+// explicit gorm type use it as their data type.
+var columnTypes = map[schema.DataType][]string{
+	schema.Bool:           {"bool"},
+	schema.Int:            {"int2", "int4", "int8"},
+	schema.Uint:           {"int2", "int4", "int8"},
+	schema.Float:          {"float4", "float8", "numeric"},
+	schema.String:         {"varchar", "text"},
+	schema.Time:           {"timestamptz", "timestamp"},
+	schema.Bytes:          {"bytea"},
+	"jsonb":               {"jsonb"},
+	"character varying[]": {"_varchar"},
+}
+
+// CheckModels compares GORM models against the migrated schema and describes every difference: missing
+// tables, fields without a column, columns without a field, fields whose type does not fit their column and
+// nullable columns read into fields that cannot hold NULL.
+func CheckModels(gdb *gorm.DB, models ...interface{}) ([]string, error) {
+	var problems []string
+
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: gdb}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		table := stmt.Schema.Table
+
+		if !gdb.Migrator().HasTable(table) {
+			problems = append(problems, fmt.Sprintf("%s: table %s does not exist", stmt.Schema.Name, table))
+			continue
+		}
+
+		tableColumns, err := gdb.Migrator().ColumnTypes(table)
+		if err != nil {
+			return nil, err
+		}
+		columns := map[string]gorm.ColumnType{}
+		for _, columnType := range tableColumns {
+			columns[columnType.Name()] = columnType
+		}
+
+		fields := map[string]bool{}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			fields[field.DBName] = true
+			column, ok := columns[field.DBName]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s: column %s.%s does not exist", stmt.Schema.Name, field.Name, table, field.DBName))
+				continue
+			}
+
+			name := fmt.Sprintf("%s.%s: column %s.%s", stmt.Schema.Name, field.Name, table, field.DBName)
+			databaseType := strings.ToLower(column.DatabaseTypeName())
+			if fits, known := columnTypes[field.DataType]; !known {
+				problems = append(problems, fmt.Sprintf("%s: fields of type %s cannot be checked", name, field.DataType))
+			} else if !slices.Contains(fits, databaseType) {
+				problems = append(problems, fmt.Sprintf("%s is %s, which does not fit %s", name, databaseType, field.DataType))
+			}
+
+			nullable, _ := column.Nullable()
+			switch {
+			case nullable && !holdsNull(field):
+				problems = append(problems, fmt.Sprintf("%s is nullable, but %s cannot hold NULL", name, field.FieldType))
+			case !nullable && field.DefaultValue == "null":
+				problems = append(problems, fmt.Sprintf("%s is NOT NULL, but the field saves NULL when it is empty", name))
+			}
+		}
+		for _, columnType := range tableColumns {
+			if !fields[columnType.Name()] {
+				problems = append(problems, fmt.Sprintf("%s: column %s.%s has no field", stmt.Schema.Name, table, columnType.Name()))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// holdsNull reports whether reading NULL into the field keeps it apart from its zero value, or the field
+// saves its zero value as NULL anyway.
+func holdsNull(field *schema.Field) bool {
+	if field.DefaultValue == "null" {
+		return true
+	}
+	switch field.FieldType.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		return true
+	case reflect.Struct:
+		//sql.NullString, pq.NullTime and the like
+		valid, ok := field.FieldType.FieldByName("Valid")
+		return ok && valid.Type.Kind() == reflect.Bool
+	}
+	return false
+}
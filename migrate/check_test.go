@@ -0,0 +1,76 @@
+package migrate_test
+
+import (
+	"example/hivemind-be/apitest"
+	"example/hivemind-be/migrate"
+	"example/hivemind-be/schema"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/lib/pq"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestModelsMatchMigratedSchema migrates the database TEST_DATABASE_URL points at and checks every model
+// against it. The database should be empty or hold only earlier migrations of this schema.
+func TestModelsMatchMigratedSchema(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	gdb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatalf("connecting to the test database: %v", err)
+	}
+	if _, err := migrate.Up(gdb); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	problems, err := migrate.CheckModels(gdb, schema.Models...)
+	if err != nil {
+		t.Fatalf("checking models: %v", err)
+	}
+	for _, problem := range problems {
+		t.Error(problem)
+	}
+}
+
+// drifted is a model whose table below has the wrong type for Created and lets Flag be NULL.
+type drifted struct {
+	ID      int64 `gorm:"primaryKey"`
+	Created pq.NullTime
+	Flag    bool
+	Note    string `gorm:"default:null"`
+}
+
+func (drifted) TableName() string { return "check_models_drift" }
+
+func TestCheckModelsReportsTypesAndNullability(t *testing.T) {
+	gdb := apitest.Database(t)
+	err := gdb.Exec(`CREATE TABLE check_models_drift (
+    id BIGSERIAL PRIMARY KEY,
+    created character varying NOT NULL,
+    flag boolean,
+    note character varying NOT NULL
+)`).Error
+	if err != nil {
+		t.Fatalf("creating the table: %v", err)
+	}
+	t.Cleanup(func() { gdb.Exec("DROP TABLE check_models_drift") })
+
+	problems, err := migrate.CheckModels(gdb, &drifted{})
+	if err != nil {
+		t.Fatalf("checking models: %v", err)
+	}
+	want := []string{
+		"drifted.Created: column check_models_drift.created is varchar, which does not fit time",
+		"drifted.Flag: column check_models_drift.flag is nullable, but bool cannot hold NULL",
+		"drifted.Note: column check_models_drift.note is NOT NULL, but the field saves NULL when it is empty",
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+}
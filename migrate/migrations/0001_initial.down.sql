@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS comment_votes;
+
+DROP TABLE IF EXISTS comments;
+
+DROP TABLE IF EXISTS content_votes;
+
+DROP TABLE IF EXISTS contents;
+
+DROP TABLE IF EXISTS hives;
+
+DROP TABLE IF EXISTS accounts;
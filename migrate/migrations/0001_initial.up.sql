@@ -1,15 +1,3 @@
-DROP TABLE IF EXISTS comment_votes;
-
-DROP TABLE IF EXISTS comments;
-
-DROP TABLE IF EXISTS content_votes;
-
-DROP TABLE IF EXISTS contents;
-
-DROP TABLE IF EXISTS hives;
-
-DROP TABLE IF EXISTS accounts;
-
 CREATE TABLE IF NOT EXISTS accounts (
     id SERIAL PRIMARY KEY,
     username character varying NOT NULL UNIQUE,
@@ -89,4 +77,4 @@ CREATE TABLE IF NOT EXISTS comment_votes (
     comment_uuid character varying NOT NULL REFERENCES comments(uuid),
     account_uuid character varying NOT NULL REFERENCES accounts(uuid),
     last_edited timestamp with time zone NOT NULL
-);
\ No newline at end of file
+);
@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS automod_configs;
+
+DROP TABLE IF EXISTS mod_queue_items;
+
+DROP TABLE IF EXISTS reports;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS removed;
+
+ALTER TABLE contents DROP COLUMN IF EXISTS flair;
+
+ALTER TABLE contents DROP COLUMN IF EXISTS locked;
+
+ALTER TABLE contents DROP COLUMN IF EXISTS removed;
+
+DELETE FROM comment_votes WHERE comment_uuid IN (SELECT uuid FROM comments WHERE account_uuid = '00000000-0000-0000-0000-00000000a070');
+
+DELETE FROM comments WHERE account_uuid = '00000000-0000-0000-0000-00000000a070';
+
+DELETE FROM accounts WHERE uuid = '00000000-0000-0000-0000-00000000a070';
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS admin;
@@ -0,0 +1,47 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS admin boolean NOT NULL DEFAULT false;
+
+-- AutoModerator posts its replies as this account; nobody can sign in with its password
+INSERT INTO accounts (username, email, password, uuid, deleted, banned, admin, created)
+VALUES ('automoderator', 'automoderator@hivemind.invalid', '!', '00000000-0000-0000-0000-00000000a070', false, false, false, now())
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS removed boolean NOT NULL DEFAULT false;
+
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS locked boolean NOT NULL DEFAULT false;
+
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS flair character varying;
+
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS removed boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS reports (
+    id SERIAL PRIMARY KEY,
+    uuid character varying NOT NULL UNIQUE,
+    hive_uuid character varying NOT NULL REFERENCES hives(uuid),
+    item_type character varying NOT NULL,
+    item_uuid character varying NOT NULL,
+    account_uuid character varying NOT NULL REFERENCES accounts(uuid),
+    reason character varying NOT NULL,
+    created timestamp with time zone NOT NULL,
+    UNIQUE (account_uuid, item_uuid)
+);
+
+CREATE TABLE IF NOT EXISTS mod_queue_items (
+    id SERIAL PRIMARY KEY,
+    uuid character varying NOT NULL UNIQUE,
+    hive_uuid character varying NOT NULL REFERENCES hives(uuid),
+    item_type character varying NOT NULL,
+    item_uuid character varying NOT NULL,
+    source character varying NOT NULL,
+    reason character varying,
+    status character varying NOT NULL,
+    resolved_by character varying REFERENCES accounts(uuid),
+    created timestamp with time zone NOT NULL,
+    resolved timestamp with time zone
+);
+
+CREATE TABLE IF NOT EXISTS automod_configs (
+    id SERIAL PRIMARY KEY,
+    hive_uuid character varying NOT NULL UNIQUE REFERENCES hives(uuid),
+    rules character varying NOT NULL,
+    last_edited timestamp with time zone
+);
@@ -0,0 +1 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS shadowbanned boolean NOT NULL DEFAULT false;
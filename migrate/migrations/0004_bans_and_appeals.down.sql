@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS appeal_events;
+
+DROP TABLE IF EXISTS appeals;
+
+DROP TABLE IF EXISTS bans;
@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS bans (
+    id SERIAL PRIMARY KEY,
+    uuid character varying NOT NULL UNIQUE,
+    account_uuid character varying NOT NULL REFERENCES accounts(uuid),
+    hive_uuid character varying REFERENCES hives(uuid),
+    reason character varying NOT NULL,
+    banned_by character varying NOT NULL REFERENCES accounts(uuid),
+    active boolean NOT NULL,
+    created timestamp with time zone NOT NULL,
+    lifted timestamp with time zone,
+    lifted_by character varying REFERENCES accounts(uuid),
+    appeal_status character varying,
+    decided_by character varying REFERENCES accounts(uuid)
+);
+
+CREATE TABLE IF NOT EXISTS appeals (
+    id SERIAL PRIMARY KEY,
+    uuid character varying NOT NULL UNIQUE,
+    ban_uuid character varying NOT NULL UNIQUE REFERENCES bans(uuid),
+    account_uuid character varying NOT NULL REFERENCES accounts(uuid),
+    hive_uuid character varying REFERENCES hives(uuid),
+    message character varying NOT NULL,
+    status character varying NOT NULL,
+    response character varying,
+    decided_by character varying REFERENCES accounts(uuid),
+    created timestamp with time zone NOT NULL,
+    decided timestamp with time zone
+);
+
+CREATE TABLE IF NOT EXISTS appeal_events (
+    id SERIAL PRIMARY KEY,
+    appeal_uuid character varying NOT NULL REFERENCES appeals(uuid),
+    action character varying NOT NULL,
+    actor_uuid character varying NOT NULL REFERENCES accounts(uuid),
+    message character varying NOT NULL,
+    created timestamp with time zone NOT NULL
+);
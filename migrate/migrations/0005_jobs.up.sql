@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    uuid character varying NOT NULL UNIQUE,
+    kind character varying NOT NULL,
+    payload jsonb NOT NULL,
+    status character varying NOT NULL,
+    schedule character varying,
+    attempts integer NOT NULL,
+    max_attempts integer NOT NULL,
+    run_at timestamp with time zone NOT NULL,
+    last_error character varying,
+    locked_by character varying,
+    locked_at timestamp with time zone,
+    created timestamp with time zone NOT NULL,
+    finished timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS jobs_queued_idx ON jobs (run_at) WHERE status = 'queued';
+
+CREATE INDEX IF NOT EXISTS jobs_running_idx ON jobs (locked_at) WHERE status = 'running';
+
+CREATE INDEX IF NOT EXISTS jobs_status_kind_idx ON jobs (status, kind);
+
+CREATE TABLE IF NOT EXISTS job_schedules (
+    name character varying PRIMARY KEY,
+    spec character varying NOT NULL,
+    next_run timestamp with time zone NOT NULL,
+    last_run timestamp with time zone
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS events (
+    id BIGSERIAL PRIMARY KEY,
+    uuid character varying NOT NULL UNIQUE,
+    type character varying NOT NULL,
+    aggregate_type character varying NOT NULL,
+    aggregate_uuid character varying NOT NULL,
+    hive_uuid character varying,
+    actor_uuid character varying,
+    payload jsonb NOT NULL,
+    created timestamp with time zone NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt timestamp with time zone NOT NULL,
+    last_error character varying,
+    dispatched timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS events_undispatched_idx ON events (aggregate_uuid, id) WHERE dispatched IS NULL;
+
+CREATE INDEX IF NOT EXISTS events_dispatched_idx ON events (dispatched) WHERE dispatched IS NOT NULL;
@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS hive_members (
+    id SERIAL PRIMARY KEY,
+    hive_uuid character varying NOT NULL REFERENCES hives(uuid),
+    account_uuid character varying NOT NULL REFERENCES accounts(uuid),
+    joined timestamp with time zone NOT NULL,
+    UNIQUE (hive_uuid, account_uuid)
+);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id SERIAL PRIMARY KEY,
+    uuid character varying NOT NULL UNIQUE,
+    hive_uuid character varying NOT NULL REFERENCES hives(uuid),
+    url character varying NOT NULL,
+    secret character varying NOT NULL,
+    events character varying[] NOT NULL,
+    active boolean NOT NULL,
+    consecutive_failures integer NOT NULL DEFAULT 0,
+    disabled_reason character varying,
+    created_by character varying NOT NULL REFERENCES accounts(uuid),
+    created timestamp with time zone NOT NULL,
+    last_edited timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_hive_uuid_idx ON webhooks (hive_uuid);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    uuid character varying NOT NULL UNIQUE,
+    webhook_uuid character varying NOT NULL REFERENCES webhooks(uuid) ON DELETE CASCADE,
+    event_uuid character varying NOT NULL,
+    event character varying NOT NULL,
+    payload jsonb NOT NULL,
+    redelivery_of character varying,
+    status character varying NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    response_status integer,
+    response_body character varying,
+    last_error character varying,
+    duration_ms integer,
+    created timestamp with time zone NOT NULL,
+    delivered timestamp with time zone
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event_idx ON webhook_deliveries (webhook_uuid, event_uuid) WHERE redelivery_of IS NULL;
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_uuid, id);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    uuid character varying NOT NULL UNIQUE,
+    account_uuid character varying NOT NULL REFERENCES accounts(uuid),
+    type character varying NOT NULL,
+    actor_uuid character varying,
+    actor character varying,
+    hive_uuid character varying,
+    content_uuid character varying,
+    comment_uuid character varying,
+    source_uuid character varying NOT NULL,
+    message character varying NOT NULL,
+    read boolean NOT NULL DEFAULT false,
+    created timestamp with time zone NOT NULL,
+    read_at timestamp with time zone,
+    UNIQUE (account_uuid, type, source_uuid)
+);
+
+CREATE INDEX IF NOT EXISTS notifications_account_idx ON notifications (account_uuid, id);
+
+CREATE INDEX IF NOT EXISTS notifications_unread_idx ON notifications (account_uuid) WHERE read = false;
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    id SERIAL PRIMARY KEY,
+    account_uuid character varying NOT NULL REFERENCES accounts(uuid),
+    type character varying NOT NULL,
+    enabled boolean NOT NULL,
+    UNIQUE (account_uuid, type)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS mentions (
+    id BIGSERIAL PRIMARY KEY,
+    source_type character varying NOT NULL,
+    source_uuid character varying NOT NULL,
+    type character varying NOT NULL,
+    target_uuid character varying NOT NULL,
+    name character varying NOT NULL,
+    text character varying NOT NULL,
+    UNIQUE (source_type, source_uuid, type, target_uuid)
+);
+
+CREATE INDEX IF NOT EXISTS mentions_target_idx ON mentions (type, target_uuid);
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS revisions (
+    id BIGSERIAL PRIMARY KEY,
+    item_type character varying NOT NULL,
+    item_uuid character varying NOT NULL,
+    version integer NOT NULL,
+    title character varying,
+    message character varying NOT NULL,
+    link character varying,
+    image_link character varying,
+    editor_uuid character varying NOT NULL,
+    created timestamp with time zone NOT NULL,
+    UNIQUE (item_type, item_uuid, version)
+);
+
+CREATE OR REPLACE FUNCTION revisions_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'revisions cannot be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER revisions_append_only BEFORE UPDATE ON revisions
+    FOR EACH ROW EXECUTE FUNCTION revisions_append_only();
+
+-- what was posted before revisions were kept is lost, so existing items start from how they are now
+INSERT INTO revisions (item_type, item_uuid, version, title, message, link, image_link, editor_uuid, created)
+    SELECT 'content', uuid, 1, title, message, link, image_link, account_uuid, COALESCE(last_edited, created, now())
+    FROM contents;
+
+INSERT INTO revisions (item_type, item_uuid, version, message, editor_uuid, created)
+    SELECT 'comment', uuid, 1, message, account_uuid, COALESCE(last_edited, created, now())
+    FROM comments;
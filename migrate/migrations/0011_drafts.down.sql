@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS contents_scheduled_idx;
+
+DROP INDEX IF EXISTS contents_drafts_idx;
+
+ALTER TABLE contents DROP COLUMN IF EXISTS publish_at;
+
+ALTER TABLE contents DROP COLUMN IF EXISTS draft;
@@ -0,0 +1,7 @@
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS draft boolean NOT NULL DEFAULT false;
+
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS publish_at timestamp with time zone;
+
+CREATE INDEX IF NOT EXISTS contents_drafts_idx ON contents (account_uuid) WHERE draft;
+
+CREATE INDEX IF NOT EXISTS contents_scheduled_idx ON contents (publish_at) WHERE draft AND publish_at IS NOT NULL;
@@ -0,0 +1,34 @@
+ALTER TABLE mod_queue_items
+    ALTER COLUMN reason DROP NOT NULL, ALTER COLUMN reason DROP DEFAULT;
+
+ALTER TABLE comment_votes
+    ALTER COLUMN upvote DROP NOT NULL, ALTER COLUMN upvote DROP DEFAULT,
+    ALTER COLUMN downvote DROP NOT NULL, ALTER COLUMN downvote DROP DEFAULT;
+
+ALTER TABLE comments
+    ALTER COLUMN upvote DROP NOT NULL, ALTER COLUMN upvote DROP DEFAULT,
+    ALTER COLUMN downvote DROP NOT NULL, ALTER COLUMN downvote DROP DEFAULT,
+    ALTER COLUMN deleted DROP NOT NULL, ALTER COLUMN deleted DROP DEFAULT;
+
+ALTER TABLE content_votes
+    ALTER COLUMN upvote DROP NOT NULL, ALTER COLUMN upvote DROP DEFAULT,
+    ALTER COLUMN downvote DROP NOT NULL, ALTER COLUMN downvote DROP DEFAULT;
+
+ALTER TABLE contents
+    ALTER COLUMN upvote DROP NOT NULL, ALTER COLUMN upvote DROP DEFAULT,
+    ALTER COLUMN downvote DROP NOT NULL, ALTER COLUMN downvote DROP DEFAULT,
+    ALTER COLUMN comment_count DROP NOT NULL, ALTER COLUMN comment_count DROP DEFAULT,
+    ALTER COLUMN deleted DROP NOT NULL, ALTER COLUMN deleted DROP DEFAULT;
+
+ALTER TABLE hives
+    ALTER COLUMN member_count DROP NOT NULL, ALTER COLUMN member_count DROP DEFAULT,
+    ALTER COLUMN total_upvotes DROP NOT NULL, ALTER COLUMN total_upvotes DROP DEFAULT,
+    ALTER COLUMN total_downvotes DROP NOT NULL, ALTER COLUMN total_downvotes DROP DEFAULT,
+    ALTER COLUMN total_comments DROP NOT NULL, ALTER COLUMN total_comments DROP DEFAULT,
+    ALTER COLUMN total_content DROP NOT NULL, ALTER COLUMN total_content DROP DEFAULT,
+    ALTER COLUMN archived DROP NOT NULL, ALTER COLUMN archived DROP DEFAULT,
+    ALTER COLUMN banned DROP NOT NULL, ALTER COLUMN banned DROP DEFAULT;
+
+ALTER TABLE accounts
+    ALTER COLUMN deleted DROP NOT NULL, ALTER COLUMN deleted DROP DEFAULT,
+    ALTER COLUMN banned DROP NOT NULL, ALTER COLUMN banned DROP DEFAULT;
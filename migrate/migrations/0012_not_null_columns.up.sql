@@ -0,0 +1,48 @@
+UPDATE accounts SET deleted = COALESCE(deleted, false), banned = COALESCE(banned, false);
+
+ALTER TABLE accounts
+    ALTER COLUMN deleted SET DEFAULT false, ALTER COLUMN deleted SET NOT NULL,
+    ALTER COLUMN banned SET DEFAULT false, ALTER COLUMN banned SET NOT NULL;
+
+UPDATE hives SET member_count = COALESCE(member_count, 0), total_upvotes = COALESCE(total_upvotes, 0), total_downvotes = COALESCE(total_downvotes, 0), total_comments = COALESCE(total_comments, 0), total_content = COALESCE(total_content, 0), archived = COALESCE(archived, false), banned = COALESCE(banned, false);
+
+ALTER TABLE hives
+    ALTER COLUMN member_count SET DEFAULT 0, ALTER COLUMN member_count SET NOT NULL,
+    ALTER COLUMN total_upvotes SET DEFAULT 0, ALTER COLUMN total_upvotes SET NOT NULL,
+    ALTER COLUMN total_downvotes SET DEFAULT 0, ALTER COLUMN total_downvotes SET NOT NULL,
+    ALTER COLUMN total_comments SET DEFAULT 0, ALTER COLUMN total_comments SET NOT NULL,
+    ALTER COLUMN total_content SET DEFAULT 0, ALTER COLUMN total_content SET NOT NULL,
+    ALTER COLUMN archived SET DEFAULT false, ALTER COLUMN archived SET NOT NULL,
+    ALTER COLUMN banned SET DEFAULT false, ALTER COLUMN banned SET NOT NULL;
+
+UPDATE contents SET upvote = COALESCE(upvote, 0), downvote = COALESCE(downvote, 0), comment_count = COALESCE(comment_count, 0), deleted = COALESCE(deleted, false);
+
+ALTER TABLE contents
+    ALTER COLUMN upvote SET DEFAULT 0, ALTER COLUMN upvote SET NOT NULL,
+    ALTER COLUMN downvote SET DEFAULT 0, ALTER COLUMN downvote SET NOT NULL,
+    ALTER COLUMN comment_count SET DEFAULT 0, ALTER COLUMN comment_count SET NOT NULL,
+    ALTER COLUMN deleted SET DEFAULT false, ALTER COLUMN deleted SET NOT NULL;
+
+UPDATE content_votes SET upvote = COALESCE(upvote, false), downvote = COALESCE(downvote, false);
+
+ALTER TABLE content_votes
+    ALTER COLUMN upvote SET DEFAULT false, ALTER COLUMN upvote SET NOT NULL,
+    ALTER COLUMN downvote SET DEFAULT false, ALTER COLUMN downvote SET NOT NULL;
+
+UPDATE comments SET upvote = COALESCE(upvote, 0), downvote = COALESCE(downvote, 0), deleted = COALESCE(deleted, false);
+
+ALTER TABLE comments
+    ALTER COLUMN upvote SET DEFAULT 0, ALTER COLUMN upvote SET NOT NULL,
+    ALTER COLUMN downvote SET DEFAULT 0, ALTER COLUMN downvote SET NOT NULL,
+    ALTER COLUMN deleted SET DEFAULT false, ALTER COLUMN deleted SET NOT NULL;
+
+UPDATE comment_votes SET upvote = COALESCE(upvote, false), downvote = COALESCE(downvote, false);
+
+ALTER TABLE comment_votes
+    ALTER COLUMN upvote SET DEFAULT false, ALTER COLUMN upvote SET NOT NULL,
+    ALTER COLUMN downvote SET DEFAULT false, ALTER COLUMN downvote SET NOT NULL;
+
+UPDATE mod_queue_items SET reason = COALESCE(reason, '');
+
+ALTER TABLE mod_queue_items
+    ALTER COLUMN reason SET DEFAULT '', ALTER COLUMN reason SET NOT NULL;
@@ -0,0 +1,26 @@
+package models
+
+import "github.com/lib/pq"
+
+type Account struct {
+	ID           int32       `json:"Id" gorm:"primaryKey:type:int32"`
+	Username     string      `json:"Username"`
+	Email        string      `json:"Email"`
+	Password     string      `json:"Password"`
+	UUID         string      `json:"Uuid"`
+	Deleted      bool        `json:"Deleted"`
+	Banned       bool        `json:"Banned"`
+	Admin        bool        `json:"Admin"`
+	Shadowbanned bool        `json:"Shadowbanned"` //content and votes are hidden from everyone but the account itself
+	Created      pq.NullTime `json:"Created"`
+}
+
+// ShadowbanActivity is what a shadowbanned account posted and voted on in the period of a shadowban report.
+type ShadowbanActivity struct {
+	Username   string      `json:"Username"`
+	UUID       string      `json:"Uuid"`
+	Content    int64       `json:"Content"`
+	Comments   int64       `json:"Comments"`
+	Votes      int64       `json:"Votes"`
+	LastActive pq.NullTime `json:"LastActive"`
+}
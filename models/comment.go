@@ -0,0 +1,37 @@
+package models
+
+import "github.com/lib/pq"
+
+type Comment struct {
+	ID          int32       `json:"Id" gorm:"primaryKey:type:int32"`
+	Author      string      `json:"Author"`
+	Message     string      `json:"Message"`              //markdown
+	MessageHtml string      `json:"MessageHtml" gorm:"-"` //Message rendered to sanitized HTML
+	UUID        string      `json:"Uuid"`
+	AccountUUID string      `json:"AccountUUID"`
+	ContentUUID string      `json:"ContentUuid" gorm:"foreignKey:ContentUuid"` //foreign key gorm associations to content type table Uuid
+	ParentUUID  string      `json:"ParentUuid" gorm:"default:null"`            //if comment is a reply, the ParentUUID will be the UUID of the parent comment
+	Upvote      int32       `json:"Upvote"`
+	Downvote    int32       `json:"Downvote"`
+	Deleted     bool        `json:"Deleted"`
+	Removed     bool        `json:"Removed"` //set by moderators and automod
+	Created     pq.NullTime `json:"Created"`
+	LastEdited  pq.NullTime `json:"LastEdited"`
+	Mentions    []Mention   `json:"Mentions" gorm:"-"` //resolved from Message when it is saved
+}
+
+type CommentVote struct {
+	ID          int32
+	AccountUUID string
+	CommentUUID string
+	Upvote      bool
+	Downvote    bool
+	LastEdited  pq.NullTime
+}
+
+type CommentVoteGroup struct {
+	Upvote      bool   `json:"upvote"`
+	Downvote    bool   `json:"downvote"`
+	CommentUuid string `json:"comment_uuid"`
+	ContentUuid string `json:"content_uuid"`
+}
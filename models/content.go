@@ -0,0 +1,39 @@
+package models
+
+import "github.com/lib/pq"
+
+// GORM uses the name of your type as the DB table to query. Here the type is Message so gorm will use the messages table by default.
+type Content struct {
+	ID           int32       `json:"Id" gorm:"primaryKey:type:int32"` //cannot be updated
+	Hive         string      `json:"Hive"`                            //cannot be updated
+	Title        string      `json:"Title"`                           //can be updated
+	Author       string      `json:"Author"`                          //cannot be updated
+	Message      string      `json:"Message"`                         //can be updated, markdown
+	MessageHtml  string      `json:"MessageHtml" gorm:"-"`            //Message rendered to sanitized HTML
+	UUID         string      `json:"Uuid"`                            //cannot be update
+	HiveUUID     string      `json:"HiveUuid"`                        //cannot be update
+	AccountUUID  string      `json:"AccountUuid"`                     //cannot be update
+	Link         string      `json:"Link" gorm:"default:null"`        //can be updated
+	ImageLink    string      `json:"ImageLink" gorm:"default:null"`   //can be updated
+	Upvote       int32       `json:"Upvote"`                          //cannot be updated
+	Downvote     int32       `json:"Downvote"`                        //cannot be updated
+	CommentCount int32       `json:"CommentCount"`                    //cannot be updated
+	Deleted      bool        `json:"Deleted"`                         //can be updated
+	Removed      bool        `json:"Removed"`                         //set by moderators and automod
+	Locked       bool        `json:"Locked"`                          //locked content accepts no new comments
+	Flair        string      `json:"Flair" gorm:"default:null"`       //set by moderators and automod
+	Draft        bool        `json:"Draft"`                           //drafts are only shown to their author until published
+	PublishAt    pq.NullTime `json:"PublishAt"`                       //when a scheduled draft is published
+	Created      pq.NullTime `json:"Created"`                         //cannot be updated
+	LastEdited   pq.NullTime `json:"LastEdited"`                      //updated when an update occurs
+	Mentions     []Mention   `json:"Mentions" gorm:"-"`               //resolved from Message when it is saved
+}
+
+type ContentVote struct {
+	ID          int32
+	AccountUUID string
+	ContentUUID string
+	Upvote      bool
+	Downvote    bool
+	LastEdited  pq.NullTime
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+// Event is a domain event in the outbox. It is written in the same transaction as the change it describes
+// and stays undispatched until every subscriber has handled it.
+type Event struct {
+	ID            int64           `json:"Id" gorm:"primaryKey"`
+	UUID          string          `json:"Uuid"`
+	Type          string          `json:"Type"`
+	AggregateType string          `json:"AggregateType"` //account, hive, content or comment
+	AggregateUUID string          `json:"AggregateUuid"`
+	HiveUUID      string          `json:"HiveUuid" gorm:"default:null"`
+	ActorUUID     string          `json:"ActorUuid" gorm:"default:null"`
+	Payload       json.RawMessage `json:"Payload" gorm:"type:jsonb"`
+	Created       pq.NullTime     `json:"Created"`
+	Attempts      int32           `json:"Attempts"`
+	NextAttempt   pq.NullTime     `json:"NextAttempt"`
+	LastError     string          `json:"LastError" gorm:"default:null"`
+	Dispatched    pq.NullTime     `json:"Dispatched"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "github.com/lib/pq"
+
+type Hive struct {
+	ID             int32       `json:"Id" gorm:"primaryKey:type:int32"`
+	Name           string      `json:"Name"`
+	Creator        string      `json:"Creator"`
+	Description    string      `json:"Description"`
+	UUID           string      `json:"Uuid"`
+	AccountUUID    string      `json:"AccountUUID"`
+	MemberCount    int32       `json:"MemberCount"`
+	TotalUpvotes   int32       `json:"TotalUpvotes"`
+	TotalDownvotes int32       `json:"TotalDownvotes"`
+	TotalComments  int32       `json:"TotalComments"`
+	TotalContent   int32       `json:"TotalContent"`
+	Archived       bool        `json:"Archived"`
+	Banned         bool        `json:"Banned"`
+	Created        pq.NullTime `json:"Created"`
+	LastEdited     pq.NullTime `json:"LastEdited"`
+}
+
+// HiveMember is an account that joined a hive. MemberCount on the hive counts them.
+type HiveMember struct {
+	ID          int32       `json:"Id" gorm:"primaryKey:type:int32"`
+	HiveUUID    string      `json:"HiveUuid"`
+	AccountUUID string      `json:"AccountUuid"`
+	Joined      pq.NullTime `json:"Joined"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+type Job struct {
+	ID          int64           `json:"Id" gorm:"primaryKey"`
+	UUID        string          `json:"Uuid"`
+	Kind        string          `json:"Kind"`
+	Payload     json.RawMessage `json:"Payload" gorm:"type:jsonb"`
+	Status      string          `json:"Status"`
+	Schedule    string          `json:"Schedule" gorm:"default:null"` //the schedule that enqueued the job, if any
+	Attempts    int32           `json:"Attempts"`
+	MaxAttempts int32           `json:"MaxAttempts"`
+	RunAt       pq.NullTime     `json:"RunAt"`
+	LastError   string          `json:"LastError" gorm:"default:null"`
+	LockedBy    string          `json:"LockedBy" gorm:"default:null"`
+	LockedAt    pq.NullTime     `json:"LockedAt"`
+	Created     pq.NullTime     `json:"Created"`
+	Finished    pq.NullTime     `json:"Finished"`
+}
+
+// Decode unmarshals the job's payload into dst.
+func (j Job) Decode(dst interface{}) error {
+	return json.Unmarshal(j.Payload, dst)
+}
+
+// JobSchedule is the shared state of a schedule, so only one instance enqueues each run.
+type JobSchedule struct {
+	Name    string      `json:"Name" gorm:"primaryKey"`
+	Spec    string      `json:"Spec"`
+	NextRun pq.NullTime `json:"NextRun"`
+	LastRun pq.NullTime `json:"LastRun"`
+}
+
+type JobStats struct {
+	Kind   string `json:"Kind"`
+	Status string `json:"Status"`
+	Jobs   int64  `json:"Jobs"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// Mention is an account or hive the message of content or a comment refers to, as @username or h/hivename.
+// Only references to accounts and hives that exist are kept.
+type Mention struct {
+	ID         int64  `json:"-" gorm:"primaryKey"`
+	SourceType string `json:"-"` //content or comment
+	SourceUUID string `json:"-"`
+	Type       string `json:"Type"` //account or hive
+	TargetUUID string `json:"Uuid"`
+	Name       string `json:"Name"` //the username or hive name
+	Text       string `json:"Text"` //as written in the message, such as @alice
+}
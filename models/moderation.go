@@ -0,0 +1,106 @@
+package models
+
+import "github.com/lib/pq"
+
+type Ban struct {
+	ID           int32       `json:"Id" gorm:"primaryKey:type:int32"`
+	UUID         string      `json:"Uuid"`
+	AccountUUID  string      `json:"AccountUuid"`
+	HiveUUID     string      `json:"HiveUuid" gorm:"default:null"` //empty for site-wide bans
+	Reason       string      `json:"Reason"`
+	BannedBy     string      `json:"BannedBy"`
+	Active       bool        `json:"Active"`
+	Created      pq.NullTime `json:"Created"`
+	Lifted       pq.NullTime `json:"Lifted"`
+	LiftedBy     string      `json:"LiftedBy" gorm:"default:null"`
+	AppealStatus string      `json:"AppealStatus" gorm:"default:null"` //empty until the ban is appealed
+	DecidedBy    string      `json:"DecidedBy" gorm:"default:null"`    //moderator who decided the appeal
+}
+
+type Report struct {
+	ID          int32       `json:"Id" gorm:"primaryKey:type:int32"`
+	UUID        string      `json:"Uuid"`
+	HiveUUID    string      `json:"HiveUuid"`
+	ItemType    string      `json:"ItemType"` //content or comment
+	ItemUUID    string      `json:"ItemUuid"`
+	AccountUUID string      `json:"AccountUuid"`
+	Reason      string      `json:"Reason"`
+	Created     pq.NullTime `json:"Created"`
+}
+
+type ModQueueItem struct {
+	ID         int32       `json:"Id" gorm:"primaryKey:type:int32"`
+	UUID       string      `json:"Uuid"`
+	HiveUUID   string      `json:"HiveUuid"`
+	ItemType   string      `json:"ItemType"` //content or comment
+	ItemUUID   string      `json:"ItemUuid"`
+	Source     string      `json:"Source"` //what put the item in the queue, e.g. automod or report
+	Reason     string      `json:"Reason"`
+	Status     string      `json:"Status"`
+	ResolvedBy string      `json:"ResolvedBy" gorm:"default:null"` //account uuid of the moderator who resolved the item
+	Created    pq.NullTime `json:"Created"`
+	Resolved   pq.NullTime `json:"Resolved"`
+}
+
+type AutomodConfig struct {
+	ID         int32       `json:"Id" gorm:"primaryKey:type:int32"`
+	HiveUUID   string      `json:"HiveUuid"`
+	Rules      string      `json:"Rules"` //raw JSON or YAML source of the rule set
+	LastEdited pq.NullTime `json:"LastEdited"`
+}
+
+// Appeal statuses. An appeal still pending when its ban is lifted is closed.
+const (
+	AppealPending  = "pending"
+	AppealApproved = "approved"
+	AppealDenied   = "denied"
+	AppealClosed   = "closed"
+)
+
+type Appeal struct {
+	ID          int32       `json:"Id" gorm:"primaryKey:type:int32"`
+	UUID        string      `json:"Uuid"`
+	BanUUID     string      `json:"BanUuid"`
+	AccountUUID string      `json:"AccountUuid"`
+	HiveUUID    string      `json:"HiveUuid" gorm:"default:null"` //empty for appeals of site-wide bans
+	Message     string      `json:"Message"`
+	Status      string      `json:"Status"`
+	Response    string      `json:"Response" gorm:"default:null"` //moderator message sent with the decision
+	DecidedBy   string      `json:"DecidedBy" gorm:"default:null"`
+	Created     pq.NullTime `json:"Created"`
+	Decided     pq.NullTime `json:"Decided"`
+}
+
+// AppealEvent records each step of an appeal: filing it and every decision made on it.
+type AppealEvent struct {
+	ID         int32       `json:"Id" gorm:"primaryKey:type:int32"`
+	AppealUUID string      `json:"AppealUuid"`
+	Action     string      `json:"Action"` //filed, approved, denied or closed
+	ActorUUID  string      `json:"ActorUuid"`
+	Message    string      `json:"Message"`
+	Created    pq.NullTime `json:"Created"`
+}
+
+// AutomodSubject is a post or comment as AutoModerator rules see it.
+type AutomodSubject struct {
+	Type        string //content or comment
+	UUID        string
+	HiveUUID    string
+	ContentUUID string //the thread the item belongs to, for content this is its own uuid
+	ParentUUID  string
+	AccountUUID string
+	Title       string
+	Message     string
+	Link        string
+}
+
+// ModDecision is a post or comment a moderator approved or removed from the modqueue.
+type ModDecision struct {
+	ItemType string //content or comment
+	Status   string //approved or removed
+	Source   string //what put the item in the queue
+	Reason   string //why it was queued
+	Title    string
+	Message  string
+	Link     string
+}
@@ -0,0 +1,32 @@
+package models
+
+import "github.com/lib/pq"
+
+type Notification struct {
+	ID          int64       `json:"Id" gorm:"primaryKey"`
+	UUID        string      `json:"Uuid"`
+	AccountUUID string      `json:"AccountUuid"` //the account notified
+	Type        string      `json:"Type"`
+	ActorUUID   string      `json:"ActorUuid" gorm:"default:null"` //empty for moderator actions, which are anonymous
+	Actor       string      `json:"Actor" gorm:"default:null"`
+	HiveUUID    string      `json:"HiveUuid" gorm:"default:null"`
+	ContentUUID string      `json:"ContentUuid" gorm:"default:null"`
+	CommentUUID string      `json:"CommentUuid" gorm:"default:null"`
+	SourceUUID  string      `json:"-"` //the event, ban or appeal it was made for, so it is only made once
+	Message     string      `json:"Message"`
+	Read        bool        `json:"Read"`
+	Created     pq.NullTime `json:"Created"`
+	ReadAt      pq.NullTime `json:"ReadAt"`
+}
+
+// NotificationPreference turns a type of notification on or off for an account. Types without a row are on.
+type NotificationPreference struct {
+	ID          int32  `json:"Id" gorm:"primaryKey:type:int32"`
+	AccountUUID string `json:"AccountUuid"`
+	Type        string `json:"Type"`
+	Enabled     bool   `json:"Enabled"`
+}
+
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
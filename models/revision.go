@@ -0,0 +1,18 @@
+package models
+
+import "github.com/lib/pq"
+
+// Revision is one version of content or a comment, saved when it is posted and every time it is edited.
+// Revisions are never changed.
+type Revision struct {
+	ID         int64       `json:"-" gorm:"primaryKey"`
+	ItemType   string      `json:"ItemType"` //content or comment
+	ItemUUID   string      `json:"ItemUuid"`
+	Version    int32       `json:"Version"`                   //1 for the item as posted
+	Title      string      `json:"Title" gorm:"default:null"` //content only
+	Message    string      `json:"Message"`
+	Link       string      `json:"Link" gorm:"default:null"`
+	ImageLink  string      `json:"ImageLink" gorm:"default:null"`
+	EditorUUID string      `json:"EditorUuid"`
+	Created    pq.NullTime `json:"Created"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+type Webhook struct {
+	ID                  int32          `json:"Id" gorm:"primaryKey:type:int32"`
+	UUID                string         `json:"Uuid"`
+	HiveUUID            string         `json:"HiveUuid"`
+	URL                 string         `json:"Url"`
+	Secret              string         `json:"-"` //only shown when the webhook is created or its secret is rotated
+	Events              pq.StringArray `json:"Events" gorm:"type:character varying[]"`
+	Active              bool           `json:"Active"`
+	ConsecutiveFailures int32          `json:"ConsecutiveFailures"`
+	DisabledReason      string         `json:"DisabledReason" gorm:"default:null"`
+	CreatedBy           string         `json:"CreatedBy"`
+	Created             pq.NullTime    `json:"Created"`
+	LastEdited          pq.NullTime    `json:"LastEdited"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook. A redelivery is a new delivery of the same
+// payload.
+type WebhookDelivery struct {
+	ID             int64           `json:"Id" gorm:"primaryKey"`
+	UUID           string          `json:"Uuid"`
+	WebhookUUID    string          `json:"WebhookUuid"`
+	EventUUID      string          `json:"EventUuid"`
+	Event          string          `json:"Event"`
+	Payload        json.RawMessage `json:"Payload" gorm:"type:jsonb"`
+	RedeliveryOf   string          `json:"RedeliveryOf" gorm:"default:null"`
+	Status         string          `json:"Status"`
+	Attempts       int32           `json:"Attempts"`
+	ResponseStatus int32           `json:"ResponseStatus" gorm:"default:null"`
+	ResponseBody   string          `json:"ResponseBody" gorm:"default:null"` //the start of the last response
+	LastError      string          `json:"LastError" gorm:"default:null"`
+	DurationMs     int32           `json:"DurationMs" gorm:"default:null"` //how long the last attempt took
+	Created        pq.NullTime     `json:"Created"`
+	Delivered      pq.NullTime     `json:"Delivered"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
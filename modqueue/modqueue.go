@@ -0,0 +1,172 @@
+package modqueue
+
+import (
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/events"
+	"example/hivemind-be/hive"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRemoved  = "removed"
+)
+
+type ModQueueItem = models.ModQueueItem
+
+// Handler serves the modqueue routes through the modqueue repository.
+type Handler struct {
+	Repos repository.Repos
+}
+
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+// repos is the repositories with their queries bound to the request.
+func (h *Handler) repos(c *gin.Context) repository.Repos {
+	return h.Repos.WithContext(c.Request.Context())
+}
+
+// Enqueue adds an item to its hive's modqueue. An item that is already pending is not queued twice.
+func Enqueue(queue repository.ModQueueRepo, hiveUUID string, itemType string, itemUUID string, source string, reason string) error {
+	item := ModQueueItem{
+		UUID:     uuid.NewString(),
+		HiveUUID: hiveUUID,
+		ItemType: itemType,
+		ItemUUID: itemUUID,
+		Source:   source,
+		Reason:   reason,
+		Status:   StatusPending,
+		Created:  pq.NullTime{Time: time.Now(), Valid: true},
+		Resolved: pq.NullTime{Valid: false},
+	}
+	return queue.Enqueue(&item)
+}
+
+func (h *Handler) GetModQueueByHiveUuid(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	uuid := c.Param("uuid")
+	if !hive.RequireModerator(c, h.repos(c), uuid, claims.AccountUUID) {
+		return
+	}
+
+	items, err := h.repos(c).ModQueue.List(uuid, c.DefaultQuery("status", StatusPending))
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *Handler) ApproveModQueueItem(c *gin.Context) {
+	h.resolveModQueueItem(c, StatusApproved)
+}
+
+func (h *Handler) RemoveModQueueItem(c *gin.Context) {
+	h.resolveModQueueItem(c, StatusRemoved)
+}
+
+// resolveModQueueItem records a moderator decision and sets the removed flag on the underlying item to match it.
+func (h *Handler) resolveModQueueItem(c *gin.Context, status string) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	repos := h.repos(c)
+	item, err := repos.ModQueue.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.ModQueueItemNotFound, "Modqueue item not found. Please try again."))
+		return
+	}
+
+	if !hive.RequireModerator(c, repos, item.HiveUUID, claims.AccountUUID) {
+		return
+	}
+
+	if item.ItemType != "content" && item.ItemType != "comment" {
+		apperr.Write(c, apperr.New(apperr.Internal, "Unknown modqueue item type."))
+		return
+	}
+
+	err = repos.Tx.Transaction(func(tx repository.Repos) error {
+		//the item is checked again under a lock, so two moderators cannot both resolve it
+		item, err = tx.ModQueue.GetForUpdate(item.UUID)
+		if err != nil {
+			return err
+		}
+		if item.Status != StatusPending {
+			return apperr.New(apperr.AlreadyResolved, "This item has already been resolved.")
+		}
+		item.Status = status
+		item.ResolvedBy = claims.AccountUUID
+		item.Resolved = pq.NullTime{Time: time.Now(), Valid: true}
+		event, err := decide(tx, item)
+		if err != nil {
+			return err
+		}
+		if err := tx.ModQueue.Resolve(&item); err != nil {
+			return err
+		}
+		return tx.Events.Append(&event)
+	})
+	if err != nil {
+		if apperr.CodeOf(err) == apperr.Internal {
+			err = apperr.Wrap(apperr.Internal, "There was an error updating this item. Please try again.", err)
+		}
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// decide sets the removed flag of the resolved item to match the decision and returns the removed or
+// approved event for it, as it is after the decision.
+func decide(tx repository.Repos, item ModQueueItem) (models.Event, error) {
+	removed := item.Status == StatusRemoved
+	if item.ItemType == "content" {
+		content, err := tx.Contents.GetForUpdate(item.ItemUUID)
+		if err != nil {
+			return models.Event{}, err
+		}
+		content.Removed = removed
+		if err := tx.Contents.Save(&content); err != nil {
+			return models.Event{}, err
+		}
+		eventType := events.ContentApproved
+		if removed {
+			eventType = events.ContentRemoved
+		}
+		return events.Content(eventType, content, item.ResolvedBy), nil
+	}
+
+	comment, err := tx.Comments.GetForUpdate(item.ItemUUID)
+	if err != nil {
+		return models.Event{}, err
+	}
+	comment.Removed = removed
+	if err := tx.Comments.Save(&comment); err != nil {
+		return models.Event{}, err
+	}
+	eventType := events.CommentApproved
+	if removed {
+		eventType = events.CommentRemoved
+	}
+	return events.Comment(eventType, comment, item.HiveUUID, item.ResolvedBy), nil
+}
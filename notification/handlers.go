@@ -0,0 +1,218 @@
+package notification
+
+import (
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Handler serves the notification routes through the notification repository.
+type Handler struct {
+	Repos repository.Repos
+}
+
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+// notifications is the notification repository with its queries bound to the request.
+func (h *Handler) notifications(c *gin.Context) repository.NotificationRepo {
+	return h.Repos.WithContext(c.Request.Context()).Notifications
+}
+
+// Page is one page of notifications, newest first. NextCursor is passed as ?before= for the next page and
+// is empty on the last one.
+type Page struct {
+	Notifications []Notification `json:"Notifications"`
+	NextCursor    string         `json:"NextCursor"`
+}
+
+// GetNotifications lists the account's notifications newest first, 25 at a time unless ?limit= says
+// otherwise. ?unread=true leaves out the ones already read.
+func (h *Handler) GetNotifications(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "25"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 25
+	}
+
+	var cursor int64
+	if before := c.Query("before"); before != "" {
+		cursor, err = strconv.ParseInt(before, 10, 64)
+		if err != nil || cursor < 1 {
+			apperr.Write(c, apperr.New(apperr.BadRequest, "The before cursor is not valid."))
+			return
+		}
+	}
+	notifications, err := h.notifications(c).List(claims.AccountUUID, cursor, c.Query("unread") == "true", limit+1)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+
+	page := Page{Notifications: notifications}
+	if len(notifications) > limit {
+		page.Notifications = notifications[:limit]
+		page.NextCursor = strconv.FormatInt(notifications[limit-1].ID, 10)
+	}
+	if page.Notifications == nil {
+		page.Notifications = []Notification{}
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *Handler) GetUnreadCount(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	unread, err := h.notifications(c).CountUnread(claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"Unread": unread,
+	})
+}
+
+func (h *Handler) MarkNotificationRead(c *gin.Context) {
+	h.setRead(c, true)
+}
+
+func (h *Handler) MarkNotificationUnread(c *gin.Context) {
+	h.setRead(c, false)
+}
+
+func (h *Handler) setRead(c *gin.Context, read bool) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	//notifications of other accounts are not found rather than forbidden
+	notifications := h.notifications(c)
+	notification, err := notifications.GetOwned(c.Param("uuid"), claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.NotificationNotFound, "Notification not found. Please try again."))
+		return
+	}
+
+	notification.Read = read
+	notification.ReadAt = pq.NullTime{Time: time.Now(), Valid: read}
+	if err := notifications.SetRead(&notification); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error updating this notification. Please try again.", err))
+		return
+	}
+	c.JSON(http.StatusOK, notification)
+}
+
+// MarkAllNotificationsRead marks every unread notification of the account read.
+func (h *Handler) MarkAllNotificationsRead(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	updated, err := h.notifications(c).MarkAllRead(claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error updating your notifications. Please try again.", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"Updated": updated,
+	})
+}
+
+// GetPreferences returns whether each type of notification is on for the account.
+func (h *Handler) GetPreferences(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	preferences, err := preferencesOf(h.notifications(c), claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, preferences)
+}
+
+// UpdatePreferences turns the types in the body, such as {"reply": false}, on or off and leaves the rest.
+func (h *Handler) UpdatePreferences(c *gin.Context) {
+	var update map[string]bool
+
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&update); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
+		return
+	}
+
+	rows := make([]Preference, 0, len(update))
+	for kind, enabled := range update {
+		if !known(kind) {
+			apperr.Write(c, apperr.New(apperr.ValidationFailed, "Unknown notification type "+kind+"."))
+			return
+		}
+		rows = append(rows, Preference{AccountUUID: claims.AccountUUID, Type: kind, Enabled: enabled})
+	}
+
+	notifications := h.notifications(c)
+	if err := notifications.SavePreferences(rows); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error saving your preferences. Please try again.", err))
+		return
+	}
+
+	preferences, err := preferencesOf(notifications, claims.AccountUUID)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, preferences)
+}
+
+func preferencesOf(notifications repository.NotificationRepo, accountUUID string) (map[string]bool, error) {
+	rows, err := notifications.ListPreferences(accountUUID)
+	if err != nil {
+		return nil, err
+	}
+	preferences := map[string]bool{}
+	for _, kind := range Types {
+		preferences[kind] = true
+	}
+	for _, row := range rows {
+		preferences[row.Type] = row.Enabled
+	}
+	return preferences, nil
+}
+
+func known(kind string) bool {
+	for _, t := range Types {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
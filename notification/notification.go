@@ -0,0 +1,213 @@
+package notification
+
+import (
+	"context"
+	"example/hivemind-be/events"
+	"example/hivemind-be/jobs"
+	"example/hivemind-be/mention"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Notification types. Each can be turned off in an account's preferences.
+const (
+	Reply          = "reply"           //a comment on the account's content
+	CommentReply   = "comment_reply"   //a reply to the account's comment
+	Mention        = "mention"         //the account was mentioned with @username
+	ModAction      = "mod_action"      //the account's content or comment was removed, or the account was banned
+	AppealDecision = "appeal_decision" //an appeal the account filed was decided
+	PublishFailed  = "publish_failed"  //a scheduled draft of the account could not be published
+)
+
+// Types lists every notification type. Notify refuses any other.
+var Types = []string{Reply, CommentReply, Mention, ModAction, AppealDecision, PublishFailed}
+
+type Notification = models.Notification
+
+// Preference turns a type of notification on or off for an account. Types without a row are on.
+type Preference = models.NotificationPreference
+
+// Notify saves the notification through repos, with a NotificationCreated event, unless it would notify
+// accounts of their own actions or the account turned its type off. A notification made again for the same
+// source is ignored.
+func Notify(repos repository.Repos, n Notification) error {
+	if !known(n.Type) {
+		return fmt.Errorf("unknown notification type %q", n.Type)
+	}
+	if n.AccountUUID == "" || n.AccountUUID == n.ActorUUID {
+		return nil
+	}
+
+	enabled, err := repos.Notifications.IsEnabled(n.AccountUUID, n.Type)
+	if err != nil || !enabled {
+		return err
+	}
+
+	n.UUID = uuid.NewString()
+	n.Read = false
+	n.Created = pq.NullTime{Time: time.Now(), Valid: true}
+	return repos.Tx.Transaction(func(tx repository.Repos) error {
+		created, err := tx.Notifications.Create(&n)
+		if err != nil || !created {
+			return err
+		}
+		event := events.Notification(n.AccountUUID, n.HiveUUID, n)
+		return tx.Events.Append(&event)
+	})
+}
+
+// subscribe turns comments and moderator removals into notifications.
+func subscribe(ctx context.Context, gdb *gorm.DB, event models.Event) error {
+	gdb = gdb.WithContext(ctx)
+	switch event.Type {
+	case events.ContentCreated, events.ContentUpdated:
+		var content models.Content
+		if err := events.Decode(event, &content); err != nil {
+			return err
+		}
+		return notifyMentions(gdb, content.Mentions, content.Removed, Notification{
+			ActorUUID:   content.AccountUUID,
+			Actor:       content.Author,
+			HiveUUID:    content.HiveUUID,
+			ContentUUID: content.UUID,
+			SourceUUID:  content.UUID,
+			Message:     fmt.Sprintf("%s mentioned you in %q.", content.Author, content.Title),
+		})
+	case events.CommentCreated, events.CommentUpdated:
+		var comment events.CommentPayload
+		if err := events.Decode(event, &comment); err != nil {
+			return err
+		}
+		var content models.Content
+		if result := gdb.Where("uuid = ?", comment.ContentUUID).First(&content); result.Error != nil {
+			return result.Error
+		}
+		err := notifyMentions(gdb, comment.Mentions, comment.Removed, Notification{
+			ActorUUID:   comment.AccountUUID,
+			Actor:       comment.Author,
+			HiveUUID:    comment.HiveUUID,
+			ContentUUID: comment.ContentUUID,
+			CommentUUID: comment.UUID,
+			SourceUUID:  comment.UUID,
+			Message:     fmt.Sprintf("%s mentioned you in a comment on %q.", comment.Author, content.Title),
+		})
+		if err != nil || event.Type == events.CommentUpdated {
+			return err
+		}
+		return notifyReply(gdb, event)
+	case events.ContentRemoved:
+		var content models.Content
+		if err := events.Decode(event, &content); err != nil {
+			return err
+		}
+		return Notify(repository.NewGormRepos(gdb), Notification{
+			AccountUUID: content.AccountUUID,
+			Type:        ModAction,
+			HiveUUID:    content.HiveUUID,
+			ContentUUID: content.UUID,
+			SourceUUID:  event.UUID,
+			Message:     fmt.Sprintf("Your post %q was removed by the moderators of h/%s.", content.Title, content.Hive),
+		})
+	case events.CommentRemoved:
+		var comment events.CommentPayload
+		if err := events.Decode(event, &comment); err != nil {
+			return err
+		}
+		var content models.Content
+		if result := gdb.Where("uuid = ?", comment.ContentUUID).First(&content); result.Error != nil {
+			return result.Error
+		}
+		return Notify(repository.NewGormRepos(gdb), Notification{
+			AccountUUID: comment.AccountUUID,
+			Type:        ModAction,
+			HiveUUID:    comment.HiveUUID,
+			ContentUUID: comment.ContentUUID,
+			CommentUUID: comment.UUID,
+			SourceUUID:  event.UUID,
+			Message:     fmt.Sprintf("Your comment on %q was removed by the moderators of h/%s.", content.Title, content.Hive),
+		})
+	}
+	return nil
+}
+
+// notifyMentions sends n to every account mentioned, as a mention notification. Its source is the mentioning item, so an account is
+// told once however often the item is edited. Removed items and items by shadowbanned accounts are skipped.
+func notifyMentions(gdb *gorm.DB, mentions []models.Mention, removed bool, n Notification) error {
+	if len(mentions) == 0 || removed {
+		return nil
+	}
+	if shadowbanned, err := repository.NewGormRepos(gdb).Accounts.IsShadowbanned(n.ActorUUID); err != nil || shadowbanned {
+		return err
+	}
+	n.Type = Mention
+	for _, mentioned := range mentions {
+		if mentioned.Type != mention.Account {
+			continue
+		}
+		n.AccountUUID = mentioned.TargetUUID
+		if err := Notify(repository.NewGormRepos(gdb), n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyReply tells the author of the content, or of the comment replied to, about a new comment. Comments
+// automod removed and comments by shadowbanned accounts are not announced.
+func notifyReply(gdb *gorm.DB, event models.Event) error {
+	var comment events.CommentPayload
+	if err := events.Decode(event, &comment); err != nil {
+		return err
+	}
+	if comment.Removed {
+		return nil
+	}
+	if shadowbanned, err := repository.NewGormRepos(gdb).Accounts.IsShadowbanned(comment.AccountUUID); err != nil || shadowbanned {
+		return err
+	}
+
+	var content models.Content
+	if result := gdb.Where("uuid = ?", comment.ContentUUID).First(&content); result.Error != nil {
+		return result.Error
+	}
+
+	n := Notification{
+		AccountUUID: content.AccountUUID,
+		Type:        Reply,
+		ActorUUID:   comment.AccountUUID,
+		Actor:       comment.Author,
+		HiveUUID:    comment.HiveUUID,
+		ContentUUID: comment.ContentUUID,
+		CommentUUID: comment.UUID,
+		SourceUUID:  event.UUID,
+		Message:     fmt.Sprintf("%s commented on your post %q.", comment.Author, content.Title),
+	}
+	if comment.ParentUUID != "" {
+		var parent models.Comment
+		if result := gdb.Where("uuid = ?", comment.ParentUUID).First(&parent); result.Error != nil {
+			return result.Error
+		}
+		n.AccountUUID = parent.AccountUUID
+		n.Type = CommentReply
+		n.Message = fmt.Sprintf("%s replied to your comment on %q.", comment.Author, content.Title)
+	}
+	return Notify(repository.NewGormRepos(gdb), n)
+}
+
+// PurgeKind deletes read notifications once they are retention old. main schedules it daily.
+const PurgeKind = "notifications.purge"
+
+const retention = 90 * 24 * time.Hour
+
+func init() {
+	events.Subscribe("notifications", subscribe)
+	jobs.Register(PurgeKind, func(ctx context.Context, gdb *gorm.DB, job jobs.Job) error {
+		return gdb.WithContext(ctx).Where("read = ? AND created < ?", true, time.Now().Add(-retention)).Delete(&Notification{}).Error
+	})
+}
@@ -0,0 +1,34 @@
+package notification_test
+
+import (
+	"example/hivemind-be/apitest"
+	"example/hivemind-be/notification"
+	"example/hivemind-be/repository"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNotifySavesOnlyKnownTypes(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	account, _ := apitest.Account(t, repos, "reader")
+
+	for _, kind := range notification.Types {
+		n := notification.Notification{AccountUUID: account.UUID, Type: kind, SourceUUID: uuid.NewString(), Message: "Hello"}
+		if err := notification.Notify(repos, n); err != nil {
+			t.Errorf("notifying of %s: %v", kind, err)
+		}
+	}
+	unknown := notification.Notification{AccountUUID: account.UUID, Type: "birthday", SourceUUID: uuid.NewString(), Message: "Hello"}
+	if err := notification.Notify(repos, unknown); err == nil {
+		t.Error("notifying of an unknown type succeeded")
+	}
+
+	saved, err := repos.Notifications.List(account.UUID, 0, false, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != len(notification.Types) {
+		t.Errorf("saved %d notifications, want one of each of the %d types", len(saved), len(notification.Types))
+	}
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/token"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policy allows Limit requests per Period for each client, with bursts of up to Limit requests.
+type Policy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+var (
+	Auth  = Policy{Name: "auth", Limit: 10, Period: time.Minute}
+	Write = Policy{Name: "write", Limit: 30, Period: time.Minute}
+	Vote  = Policy{Name: "vote", Limit: 60, Period: time.Minute}
+	Read  = Policy{Name: "read", Limit: 300, Period: time.Minute}
+)
+
+// Decision is the outcome of taking a token from a bucket.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration //time until the bucket is full again
+	RetryAfter time.Duration //time until the next token, set when the request is not allowed
+}
+
+// Store keeps token buckets. The in-memory store only limits a single instance, a shared store such as
+// Redis can implement this interface to limit across instances.
+type Store interface {
+	Take(key string, policy Policy) (Decision, error)
+}
+
+// Backend is the store used by Middleware.
+var Backend Store = NewMemoryStore()
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   map[string]*bucket{},
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (policy Policy) rate() float64 {
+	return float64(policy.Limit) / policy.Period.Seconds()
+}
+
+func (store *MemoryStore) Take(key string, policy Policy) (Decision, error) {
+	now := store.now()
+	rate := policy.rate()
+	capacity := float64(policy.Limit)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.sweep(now)
+
+	b, ok := store.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		store.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	decision := Decision{Limit: policy.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = time.Duration((capacity - b.tokens) / rate * float64(time.Second))
+	return decision, nil
+}
+
+// sweep drops buckets that have had time to refill completely, since they are the same as new buckets.
+// It runs at most once a minute and must be called with the lock held.
+func (store *MemoryStore) sweep(now time.Time) {
+	if now.Sub(store.lastSweep) < time.Minute {
+		return
+	}
+	store.lastSweep = now
+
+	for key, b := range store.buckets {
+		if now.Sub(b.last) > time.Hour {
+			delete(store.buckets, key)
+		}
+	}
+}
+
+// clientKey identifies the caller by account uuid when the request carries a valid token and by IP otherwise. The IP
+// is the one the router trusts, see main.go, never a forwarding header the client set itself.
+func clientKey(c *gin.Context) string {
+	authToken := c.GetHeader("Authorization")
+	if strings.Contains(authToken, " ") {
+		if claims, err := token.ParseToken(authToken); err == nil && claims.AccountUUID != "" {
+			return "account:" + claims.AccountUUID
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// Middleware limits requests using the policy's token bucket for the caller. Requests over the limit are
+// rejected with 429. If the store fails the request is allowed through.
+func Middleware(policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := fmt.Sprintf("%s:%s", policy.Name, clientKey(c))
+		decision, err := Backend.Take(key, policy)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		c.Header("X-RateLimit-Reset", seconds(decision.Reset))
+
+		if !decision.Allowed {
+			c.Header("Retry-After", seconds(decision.RetryAfter))
+			apperr.Write(c, apperr.New(apperr.RateLimited, "Too many requests. Please try again later."))
+			return
+		}
+		c.Next()
+	}
+}
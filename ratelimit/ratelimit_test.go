@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"example/hivemind-be/apitest"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/token"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clock is a time source the tests move by hand.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newStore() (*MemoryStore, *clock) {
+	c := &clock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.lastSweep = c.now
+	store.now = func() time.Time { return c.now }
+	return store, c
+}
+
+// one token a second, up to three at once
+var testPolicy = Policy{Name: "test", Limit: 3, Period: 3 * time.Second}
+
+func take(t *testing.T, store *MemoryStore, key string) Decision {
+	t.Helper()
+	decision, err := store.Take(key, testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decision
+}
+
+func TestTakeAllowsABurstThenRefills(t *testing.T) {
+	store, c := newStore()
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		decision := take(t, store, "client")
+		if !decision.Allowed || decision.Remaining != remaining || decision.Limit != 3 {
+			t.Fatalf("decision = %+v, want allowed with %d remaining", decision, remaining)
+		}
+	}
+	decision := take(t, store, "client")
+	if decision.Allowed || decision.Remaining != 0 || decision.RetryAfter != time.Second || decision.Reset != 3*time.Second {
+		t.Errorf("over the burst: decision = %+v, want denied, retry after 1s and full after 3s", decision)
+	}
+
+	c.advance(500 * time.Millisecond)
+	if decision := take(t, store, "client"); decision.Allowed || decision.RetryAfter != 500*time.Millisecond {
+		t.Errorf("half a token later: decision = %+v, want denied, retry after 500ms", decision)
+	}
+	c.advance(500 * time.Millisecond)
+	if decision := take(t, store, "client"); !decision.Allowed || decision.Remaining != 0 {
+		t.Errorf("a token later: decision = %+v, want allowed with 0 remaining", decision)
+	}
+
+	//a bucket never holds more than the limit
+	c.advance(time.Minute)
+	if decision := take(t, store, "client"); !decision.Allowed || decision.Remaining != 2 || decision.Reset != time.Second {
+		t.Errorf("after a long wait: decision = %+v, want allowed with 2 remaining", decision)
+	}
+}
+
+func TestTakeKeepsABucketPerKey(t *testing.T) {
+	store, _ := newStore()
+	for i := 0; i < 3; i++ {
+		take(t, store, "first")
+	}
+	if decision := take(t, store, "first"); decision.Allowed {
+		t.Errorf("first: decision = %+v, want denied", decision)
+	}
+	if decision := take(t, store, "second"); !decision.Allowed || decision.Remaining != 2 {
+		t.Errorf("second: decision = %+v, want a full bucket", decision)
+	}
+}
+
+func TestSweepDropsIdleBuckets(t *testing.T) {
+	store, c := newStore()
+	take(t, store, "idle")
+	c.advance(2 * time.Hour)
+	take(t, store, "active")
+	if _, ok := store.buckets["idle"]; ok || len(store.buckets) != 1 {
+		t.Errorf("buckets = %v, want only the active one", store.buckets)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	apitest.Setup()
+	store, c := newStore()
+	previous := Backend
+	Backend = store
+	t.Cleanup(func() { Backend = previous })
+
+	router := gin.New()
+	router.GET("/limited", Middleware(testPolicy), func(c *gin.Context) { c.Status(http.StatusOK) })
+	get := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		w := get("")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		if got, want := w.Header().Get("X-RateLimit-Remaining"), strconv.Itoa(remaining); got != want {
+			t.Errorf("X-RateLimit-Remaining = %s, want %s", got, want)
+		}
+		if w.Header().Get("X-RateLimit-Limit") != "3" || w.Header().Get("Retry-After") != "" {
+			t.Errorf("headers = %v, want a limit of 3 and no Retry-After", w.Header())
+		}
+	}
+
+	c.advance(200 * time.Millisecond)
+	w := get("")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("over the limit: status = %d, want 429", w.Code)
+	}
+	//both round up to whole seconds
+	if w.Header().Get("Retry-After") != "1" || w.Header().Get("X-RateLimit-Reset") != "3" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("headers = %v, want Retry-After 1, X-RateLimit-Reset 3 and nothing remaining", w.Header())
+	}
+	var envelope apperr.Envelope
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil || envelope.Code != apperr.RateLimited {
+		t.Errorf("body = %s, want %s", w.Body.String(), apperr.RateLimited)
+	}
+
+	//a signed in client is limited by account, not by the address it shares
+	signed, err := token.CreateToken("member", "member-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := get("Bearer " + signed); w.Code != http.StatusOK {
+		t.Errorf("signed in: status = %d, want 200", w.Code)
+	}
+	c.advance(time.Second)
+	if w := get(""); w.Code != http.StatusOK {
+		t.Errorf("a second later: status = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,150 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// Message is one update pushed to the clients subscribed to its topic.
+type Message struct {
+	Topic string          `json:"Topic"`
+	Event string          `json:"Event"`
+	Data  json.RawMessage `json:"Data"`
+}
+
+// Broker fans messages out to subscribers. Memory only reaches clients of the instance that published, so
+// running more than one API instance needs a broker shared between them, such as one built on Postgres
+// LISTEN/NOTIFY or Redis.
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+	// Subscribe returns a channel of the messages published to any of the topics. The channel is closed when
+	// ctx is done, when the broker closes, or when the subscriber falls too far behind.
+	Subscribe(ctx context.Context, topics []string) (<-chan Message, error)
+	// Close ends every subscription, for shutdown.
+	Close() error
+}
+
+var errClosed = errors.New("the broker is closed")
+
+// bufferSize is how many messages a subscriber can fall behind by before it is dropped.
+const bufferSize = 64
+
+type subscriber struct {
+	topics []string
+	ch     chan Message
+}
+
+// Memory is an in-process Broker.
+type Memory struct {
+	mu     sync.Mutex
+	topics map[string]map[*subscriber]struct{}
+	closed bool
+}
+
+func NewMemory() *Memory {
+	return &Memory{topics: map[string]map[*subscriber]struct{}{}}
+}
+
+func (m *Memory) Publish(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return errClosed
+	}
+	for sub := range m.topics[msg.Topic] {
+		select {
+		case sub.ch <- msg:
+		default:
+			//a client that cannot keep up reconnects rather than holding back everyone else
+			m.remove(sub)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, topics []string) (<-chan Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, errClosed
+	}
+
+	sub := &subscriber{topics: topics, ch: make(chan Message, bufferSize)}
+	for _, topic := range topics {
+		if m.topics[topic] == nil {
+			m.topics[topic] = map[*subscriber]struct{}{}
+		}
+		m.topics[topic][sub] = struct{}{}
+	}
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.remove(sub)
+	}()
+	return sub.ch, nil
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for _, subs := range m.topics {
+		for sub := range subs {
+			m.remove(sub)
+		}
+	}
+	return nil
+}
+
+// remove unsubscribes sub from all its topics and closes its channel, once. m.mu must be held.
+func (m *Memory) remove(sub *subscriber) {
+	subscribed := false
+	for _, topic := range sub.topics {
+		if _, ok := m.topics[topic][sub]; ok {
+			subscribed = true
+			delete(m.topics[topic], sub)
+			if len(m.topics[topic]) == 0 {
+				delete(m.topics, topic)
+			}
+		}
+	}
+	if subscribed {
+		close(sub.ch)
+	}
+}
+
+var (
+	brokerMu sync.RWMutex
+	broker   Broker = NewMemory()
+)
+
+// SetBroker replaces the in-process broker, before the server starts.
+func SetBroker(b Broker) {
+	brokerMu.Lock()
+	defer brokerMu.Unlock()
+	broker = b
+}
+
+func current() Broker {
+	brokerMu.RLock()
+	defer brokerMu.RUnlock()
+	return broker
+}
+
+// Publish sends data, marshalled to JSON, to the subscribers of topic.
+func Publish(ctx context.Context, topic string, event string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return current().Publish(ctx, Message{Topic: topic, Event: event, Data: encoded})
+}
+
+// Shutdown closes the broker so open streams end and the server can drain.
+func Shutdown() {
+	current().Close()
+}
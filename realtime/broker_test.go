@@ -0,0 +1,116 @@
+package realtime_test
+
+import (
+	"context"
+	"example/hivemind-be/realtime"
+	"testing"
+	"time"
+)
+
+// receive waits for the next message on messages, failing when none arrives or the channel closes.
+func receive(t *testing.T, messages <-chan realtime.Message) realtime.Message {
+	t.Helper()
+	select {
+	case msg, open := <-messages:
+		if !open {
+			t.Fatal("the subscription closed")
+		}
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message arrived")
+	}
+	return realtime.Message{}
+}
+
+// closed waits for messages to close, draining anything still buffered.
+func closed(t *testing.T, messages <-chan realtime.Message) {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, open := <-messages:
+			if !open {
+				return
+			}
+		case <-deadline:
+			t.Fatal("the subscription stayed open")
+		}
+	}
+}
+
+func TestMemoryDeliversOnlyTheSubscribedTopics(t *testing.T) {
+	broker := realtime.NewMemory()
+	messages, err := broker.Subscribe(context.Background(), []string{"content:a", "hive:b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, topic := range []string{"content:other", "content:a", "hive:b"} {
+		if err := broker.Publish(context.Background(), realtime.Message{Topic: topic, Event: "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if msg := receive(t, messages); msg.Topic != "content:a" {
+		t.Errorf("first message is for %s, want content:a", msg.Topic)
+	}
+	if msg := receive(t, messages); msg.Topic != "hive:b" {
+		t.Errorf("second message is for %s, want hive:b", msg.Topic)
+	}
+	select {
+	case msg := <-messages:
+		t.Errorf("received %+v from a topic that was not subscribed to", msg)
+	default:
+	}
+}
+
+func TestMemoryClosesSubscriptionsWhenTheyEnd(t *testing.T) {
+	broker := realtime.NewMemory()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled, err := broker.Subscribe(ctx, []string{"content:a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	closed(t, cancelled)
+
+	open, err := broker.Subscribe(context.Background(), []string{"content:a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Close(); err != nil {
+		t.Fatal(err)
+	}
+	closed(t, open)
+	if _, err := broker.Subscribe(context.Background(), []string{"content:a"}); err == nil {
+		t.Error("subscribing to a closed broker succeeded")
+	}
+	if err := broker.Publish(context.Background(), realtime.Message{Topic: "content:a"}); err == nil {
+		t.Error("publishing to a closed broker succeeded")
+	}
+}
+
+func TestMemoryDropsASubscriberThatFallsBehind(t *testing.T) {
+	broker := realtime.NewMemory()
+	slow, err := broker.Subscribe(context.Background(), []string{"content:a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//far more than a subscriber buffers, without ever reading
+	for i := 0; i < 1000; i++ {
+		if err := broker.Publish(context.Background(), realtime.Message{Topic: "content:a", Event: "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	closed(t, slow)
+
+	fresh, err := broker.Subscribe(context.Background(), []string{"content:a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Publish(context.Background(), realtime.Message{Topic: "content:a", Event: "after"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, fresh); msg.Event != "after" {
+		t.Errorf("a new subscriber received %q, want after", msg.Event)
+	}
+}
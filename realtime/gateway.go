@@ -0,0 +1,247 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/hive"
+	"example/hivemind-be/logging"
+	"example/hivemind-be/metrics"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/utils"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// maxTopics bounds the topics one connection follows.
+const maxTopics = 20
+
+// heartbeat is how often an idle connection is pinged, so proxies do not close it.
+const heartbeat = 25 * time.Second
+
+// writeWait bounds one write to a WebSocket client.
+const writeWait = 10 * time.Second
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// Configure lets WebSocket connections be opened from the origins also allowed by CORS, "*" allowing any.
+func Configure(origins []string) {
+	upgrader.CheckOrigin = func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		for _, allowed := range origins {
+			if allowed == "*" || allowed == origin {
+				return true
+			}
+		}
+		return origin == ""
+	}
+}
+
+// Handler serves the real-time streams, checking the topics they follow through the repositories.
+type Handler struct {
+	Repos repository.Repos
+}
+
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+// authorize checks the token and resolves ?topics= to broker topics, writing an error response and returning
+// false when it cannot. Browsers cannot set headers on an EventSource, so the token can be in ?token= too.
+// Topics are content:<uuid>, hive:<uuid> and notifications, for the account's own notifications.
+func (h *Handler) authorize(c *gin.Context) ([]string, bool) {
+	authToken := c.GetHeader("Authorization")
+	if authToken == "" {
+		authToken = c.Query("token")
+	}
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return nil, false
+	}
+
+	var topics []string
+	seen := map[string]bool{}
+	for _, requested := range strings.Split(c.Query("topics"), ",") {
+		requested = strings.TrimSpace(requested)
+		if requested == "" || seen[requested] {
+			continue
+		}
+		seen[requested] = true
+
+		topic, err := resolve(h.Repos.WithContext(c.Request.Context()), requested, claims.AccountUUID)
+		if err != nil {
+			if apperr.CodeOf(err) == apperr.Internal {
+				err = apperr.Wrap(apperr.Internal, "There was an error checking the topics. Please try again.", err)
+			}
+			apperr.Write(c, err)
+			return nil, false
+		}
+		topics = append(topics, topic)
+	}
+
+	if len(topics) == 0 || len(topics) > maxTopics {
+		apperr.Write(c, apperr.New(apperr.ValidationFailed, "Topics must list between 1 and 20 topics."))
+		return nil, false
+	}
+	return topics, true
+}
+
+// resolve turns a requested topic into a broker topic the account may follow. A thread can be followed by
+// whoever can read its content: drafts and the content of shadowbanned accounts only by their author, and
+// removed or deleted content only by its author and the hive's moderators.
+func resolve(repos repository.Repos, requested string, accountUUID string) (string, error) {
+	if requested == "notifications" {
+		return AccountTopic(accountUUID), nil
+	}
+
+	kind, uuid, _ := strings.Cut(requested, ":")
+	switch kind {
+	case "content":
+		content, err := repos.Contents.GetVisibleByUUID(uuid, accountUUID)
+		if err != nil {
+			return "", apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+		}
+		if (content.Removed || content.Deleted) && content.AccountUUID != accountUUID {
+			moderator, err := hive.CheckModerator(repos.Hives, repos.Accounts, content.HiveUUID, accountUUID)
+			if err != nil {
+				return "", err
+			}
+			if !moderator {
+				return "", apperr.New(apperr.ContentNotFound, "Content not found.")
+			}
+		}
+		return ContentTopic(uuid), nil
+	case "hive":
+		if _, err := repos.Hives.GetByUUID(uuid); err != nil {
+			return "", apperr.NotFoundAs(err, apperr.HiveNotFound, "Hive not found.")
+		}
+		return HiveTopic(uuid), nil
+	}
+	return "", apperr.New(apperr.ValidationFailed, "Unknown topic "+requested+".")
+}
+
+// Stream sends the messages of the requested topics as Server-Sent Events, each named after its event with
+// the message as JSON data. Messages published while a client is disconnected are not replayed.
+func (h *Handler) Stream(c *gin.Context) {
+	topics, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	messages, err := current().Subscribe(ctx, topics)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "Real-time updates are not available right now.", err))
+		return
+	}
+
+	metrics.RealtimeConnections.WithLabelValues("sse").Inc()
+	defer metrics.RealtimeConnections.WithLabelValues("sse").Dec()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("subscribed", gin.H{"Topics": topics})
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case msg, open := <-messages:
+			if !open {
+				return false
+			}
+			c.SSEvent(msg.Event, msg)
+			return true
+		case <-ticker.C:
+			c.SSEvent("ping", gin.H{})
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// Socket is Stream over a WebSocket, for clients that prefer one. Each message is a JSON text frame, and
+// anything the client sends is ignored.
+func (h *Handler) Socket(c *gin.Context) {
+	topics, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		//the upgrader has already answered with an error
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	messages, err := current().Subscribe(ctx, topics)
+	if err != nil {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "unavailable"), time.Now().Add(writeWait))
+		return
+	}
+
+	metrics.RealtimeConnections.WithLabelValues("websocket").Inc()
+	defer metrics.RealtimeConnections.WithLabelValues("websocket").Dec()
+
+	//reading is what notices the client going away
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	write := func(msg interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		encoded, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(websocket.TextMessage, encoded)
+	}
+	if err := write(Message{Event: "subscribed", Data: encode(gin.H{"Topics": topics})}); err != nil {
+		return
+	}
+	for {
+		select {
+		case msg, open := <-messages:
+			if !open {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(writeWait))
+				return
+			}
+			if err := write(msg); err != nil {
+				logging.FromContext(c).Debug("websocket write failed", "error", err)
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func encode(v interface{}) json.RawMessage {
+	encoded, _ := json.Marshal(v)
+	return encoded
+}
@@ -0,0 +1,276 @@
+package realtime_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"example/hivemind-be/apitest"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/models"
+	"example/hivemind-be/realtime"
+	"example/hivemind-be/repository"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/lib/pq"
+)
+
+// newRouter serves the realtime routes the way routes.Routes does, on repos, with a broker of its own.
+func newRouter(t *testing.T, repos repository.Repos) *gin.Engine {
+	apitest.Setup()
+	realtime.SetBroker(realtime.NewMemory())
+	t.Cleanup(realtime.Shutdown)
+
+	h := realtime.NewHandler(repos)
+	router := gin.New()
+	router.GET("/realtime/sse", h.Stream)
+	router.GET("/realtime/ws", h.Socket)
+	return router
+}
+
+func post(t *testing.T, repos repository.Repos, author models.Account, hiveUUID string, change func(*models.Content)) models.Content {
+	t.Helper()
+	content := models.Content{
+		UUID:        uuid.NewString(),
+		HiveUUID:    hiveUUID,
+		AccountUUID: author.UUID,
+		Author:      author.Username,
+		Title:       "A post",
+		Message:     "Hello",
+		Created:     pq.NullTime{Time: time.Now(), Valid: true},
+	}
+	if change != nil {
+		change(&content)
+	}
+	if err := repos.Contents.Create(&content); err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// event is one Server-Sent Event.
+type event struct {
+	name string
+	data string
+}
+
+// nextEvent reads the next event from an SSE stream.
+func nextEvent(t *testing.T, stream *bufio.Reader) event {
+	t.Helper()
+	var e event
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && e.name != "":
+			return e
+		case strings.HasPrefix(line, "event:"):
+			e.name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			e.data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+}
+
+// stream opens an SSE stream of topics and returns it once it reports what it subscribed to.
+func stream(t *testing.T, server *httptest.Server, auth string, topics string) (*bufio.Reader, []string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/realtime/sse?topics="+topics, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", auth)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	body := bufio.NewReader(resp.Body)
+	subscribed := nextEvent(t, body)
+	var payload struct{ Topics []string }
+	if err := json.Unmarshal([]byte(subscribed.data), &payload); subscribed.name != "subscribed" || err != nil {
+		t.Fatalf("first event = %+v, want subscribed", subscribed)
+	}
+	return body, payload.Topics
+}
+
+// brokenContents fails every lookup, as a content repository does while the database is down.
+type brokenContents struct {
+	repository.ContentRepo
+}
+
+func (brokenContents) GetVisibleByUUID(uuid string, viewerUUID string) (models.Content, error) {
+	return models.Content{}, errors.New("connection refused")
+}
+
+func TestTopicsAreOnlyForWhatTheAccountCanRead(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(t, repos)
+	owner, ownerAuth := apitest.Account(t, repos, "owner")
+	author, authorAuth := apitest.Account(t, repos, "author")
+	shadowbanned, _ := apitest.Account(t, repos, "shadowbanned")
+	_, readerAuth := apitest.Account(t, repos, "reader")
+	golang := apitest.Hive(t, repos, owner, "golang")
+
+	shadowbanned.Shadowbanned = true
+	if err := repos.Accounts.Save(&shadowbanned); err != nil {
+		t.Fatal(err)
+	}
+	published := post(t, repos, author, golang.UUID, nil)
+	draft := post(t, repos, author, golang.UUID, func(c *models.Content) { c.Draft = true })
+	removed := post(t, repos, author, golang.UUID, func(c *models.Content) { c.Removed = true })
+	hidden := post(t, repos, shadowbanned, golang.UUID, nil)
+
+	for _, test := range []struct {
+		name   string
+		auth   string
+		topics string
+		code   apperr.Code
+	}{
+		{"someone else's draft", readerAuth, "content:" + draft.UUID, apperr.ContentNotFound},
+		{"removed content", readerAuth, "content:" + removed.UUID, apperr.ContentNotFound},
+		{"a shadowbanned author's thread", readerAuth, "content:" + hidden.UUID, apperr.ContentNotFound},
+		{"missing content", readerAuth, "content:" + uuid.NewString(), apperr.ContentNotFound},
+		{"missing hive", readerAuth, "hive:" + uuid.NewString(), apperr.HiveNotFound},
+		{"unknown kind", readerAuth, "account:" + author.UUID, apperr.ValidationFailed},
+		{"no topics", readerAuth, "", apperr.ValidationFailed},
+		{"one readable and one not", readerAuth, "content:" + published.UUID + ",content:" + draft.UUID, apperr.ContentNotFound},
+	} {
+		w := apitest.Do(t, router, http.MethodGet, "/realtime/sse?topics="+test.topics, test.auth, nil)
+		if code := apitest.Code(t, w); code != test.code {
+			t.Errorf("%s: code = %s, want %s", test.name, code, test.code)
+		}
+	}
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	for _, test := range []struct {
+		name   string
+		auth   string
+		topics string
+		want   []string
+	}{
+		{"published content and its hive", readerAuth, "content:" + published.UUID + ",hive:" + golang.UUID,
+			[]string{realtime.ContentTopic(published.UUID), realtime.HiveTopic(golang.UUID)}},
+		{"the author's own draft", authorAuth, "content:" + draft.UUID, []string{realtime.ContentTopic(draft.UUID)}},
+		{"removed content to its author", authorAuth, "content:" + removed.UUID, []string{realtime.ContentTopic(removed.UUID)}},
+		{"removed content to a moderator", ownerAuth, "content:" + removed.UUID, []string{realtime.ContentTopic(removed.UUID)}},
+		{"the account's own notifications", readerAuth, "notifications", nil},
+	} {
+		_, topics := stream(t, server, test.auth, test.topics)
+		if test.want != nil && !reflect.DeepEqual(topics, test.want) {
+			t.Errorf("%s: topics = %q, want %q", test.name, topics, test.want)
+		}
+		if test.want == nil && (len(topics) != 1 || !strings.HasPrefix(topics[0], "account:")) {
+			t.Errorf("%s: topics = %q, want the account's topic", test.name, topics)
+		}
+	}
+}
+
+func TestTopicLookupFailuresAreServerErrors(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	broken := repos
+	broken.Contents = brokenContents{repos.Contents}
+	router := newRouter(t, broken)
+	_, auth := apitest.Account(t, repos, "reader")
+
+	w := apitest.Do(t, router, http.MethodGet, "/realtime/sse?topics=content:"+uuid.NewString(), auth, nil)
+	if w.Code != http.StatusInternalServerError || apitest.Code(t, w) != apperr.Internal {
+		t.Errorf("status = %d, want %d: %s", w.Code, http.StatusInternalServerError, w.Body.String())
+	}
+}
+
+func TestStreamSendsPublishedMessages(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(t, repos)
+	owner, auth := apitest.Account(t, repos, "owner")
+	golang := apitest.Hive(t, repos, owner, "golang")
+	published := post(t, repos, owner, golang.UUID, nil)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	body, _ := stream(t, server, auth, "content:"+published.UUID)
+	count := realtime.VoteCount{TargetType: "content", TargetUUID: published.UUID, Upvote: 3}
+	if err := realtime.Publish(context.Background(), realtime.HiveTopic(golang.UUID), realtime.ContentCreated, published); err != nil {
+		t.Fatal(err)
+	}
+	if err := realtime.Publish(context.Background(), realtime.ContentTopic(published.UUID), realtime.VoteCountChanged, count); err != nil {
+		t.Fatal(err)
+	}
+
+	//the hive message is for a topic the stream does not follow, so the vote count comes first
+	received := nextEvent(t, body)
+	var msg realtime.Message
+	if err := json.Unmarshal([]byte(received.data), &msg); err != nil {
+		t.Fatal(err)
+	}
+	var got realtime.VoteCount
+	if err := json.Unmarshal(msg.Data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if received.name != realtime.VoteCountChanged || msg.Topic != realtime.ContentTopic(published.UUID) || got != count {
+		t.Errorf("received %s %+v with %+v, want %s with %+v", received.name, msg, got, realtime.VoteCountChanged, count)
+	}
+}
+
+func TestSocketSendsPublishedMessages(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(t, repos)
+	reader, auth := apitest.Account(t, repos, "reader")
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	header := http.Header{}
+	header.Set("Authorization", auth)
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/realtime/ws?topics=notifications", header)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+	resp.Body.Close()
+
+	var subscribed realtime.Message
+	if err := conn.ReadJSON(&subscribed); err != nil || subscribed.Event != "subscribed" {
+		t.Fatalf("first message = %+v (%v), want subscribed", subscribed, err)
+	}
+
+	if err := realtime.Publish(context.Background(), realtime.AccountTopic(reader.UUID), realtime.NotificationCreated, gin.H{"Message": "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var msg realtime.Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	if msg.Event != realtime.NotificationCreated || msg.Topic != realtime.AccountTopic(reader.UUID) || string(msg.Data) != `{"Message":"hi"}` {
+		t.Errorf("received %+v, want the notification", msg)
+	}
+}
+
+func TestSocketRejectsUnreadableTopicsBeforeUpgrading(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	router := newRouter(t, repos)
+	_, auth := apitest.Account(t, repos, "reader")
+
+	w := apitest.Do(t, router, http.MethodGet, "/realtime/ws?topics=hive:"+uuid.NewString(), auth, nil)
+	if code := apitest.Code(t, w); code != apperr.HiveNotFound {
+		t.Errorf("code = %s, want %s", code, apperr.HiveNotFound)
+	}
+}
@@ -0,0 +1,115 @@
+package realtime
+
+import (
+	"context"
+	"example/hivemind-be/events"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+
+	"gorm.io/gorm"
+)
+
+// Events pushed to clients.
+const (
+	ContentCreated      = "content-created"
+	ContentEdited       = "content-edited"
+	CommentCreated      = "comment-created"
+	CommentEdited       = "comment-edited"
+	VoteCountChanged    = "vote-count-changed"
+	NotificationCreated = "notification-created"
+)
+
+// Topics are a content thread, a hive feed or an account's notifications.
+func ContentTopic(uuid string) string { return "content:" + uuid }
+func HiveTopic(uuid string) string    { return "hive:" + uuid }
+func AccountTopic(uuid string) string { return "account:" + uuid }
+
+// VoteCount is the Data of a vote-count-changed event.
+type VoteCount struct {
+	TargetType string `json:"TargetType"` //content or comment
+	TargetUUID string `json:"TargetUuid"`
+	Upvote     int32  `json:"Upvote"`
+	Downvote   int32  `json:"Downvote"`
+}
+
+// subscribe publishes domain events to the topics clients follow. Publishing never fails the event: a client
+// that misses a message catches up when it reloads.
+func subscribe(ctx context.Context, gdb *gorm.DB, event models.Event) error {
+	gdb = gdb.WithContext(ctx)
+	switch event.Type {
+	case events.ContentCreated, events.ContentUpdated:
+		var content models.Content
+		if err := events.Decode(event, &content); err != nil {
+			return err
+		}
+		if skip, err := hidden(gdb, content.Removed, content.AccountUUID); err != nil || skip {
+			return err
+		}
+		if event.Type == events.ContentCreated {
+			Publish(ctx, HiveTopic(content.HiveUUID), ContentCreated, content)
+			return nil
+		}
+		Publish(ctx, ContentTopic(content.UUID), ContentEdited, content)
+		Publish(ctx, HiveTopic(content.HiveUUID), ContentEdited, content)
+	case events.CommentCreated, events.CommentUpdated:
+		var comment events.CommentPayload
+		if err := events.Decode(event, &comment); err != nil {
+			return err
+		}
+		if skip, err := hidden(gdb, comment.Removed, comment.AccountUUID); err != nil || skip {
+			return err
+		}
+		name := CommentCreated
+		if event.Type == events.CommentUpdated {
+			name = CommentEdited
+		}
+		Publish(ctx, ContentTopic(comment.ContentUUID), name, comment)
+	case events.VoteCast, events.VoteRetracted:
+		return publishVoteCount(ctx, gdb, event)
+	case events.NotificationCreated:
+		Publish(ctx, AccountTopic(event.AggregateUUID), NotificationCreated, event.Payload)
+	}
+	return nil
+}
+
+// publishVoteCount sends the item's totals as they are now, so a late or repeated message does no harm.
+// Content totals go to its thread and hive feed, comment totals to the thread of their content.
+func publishVoteCount(ctx context.Context, gdb *gorm.DB, event models.Event) error {
+	var vote events.VotePayload
+	if err := events.Decode(event, &vote); err != nil {
+		return err
+	}
+	count := VoteCount{TargetType: vote.TargetType, TargetUUID: vote.TargetUUID}
+
+	if vote.TargetType == "content" {
+		var content models.Content
+		if result := gdb.Where("uuid = ?", vote.TargetUUID).First(&content); result.Error != nil {
+			return result.Error
+		}
+		count.Upvote, count.Downvote = content.Upvote, content.Downvote
+		Publish(ctx, ContentTopic(content.UUID), VoteCountChanged, count)
+		Publish(ctx, HiveTopic(content.HiveUUID), VoteCountChanged, count)
+		return nil
+	}
+
+	var comment models.Comment
+	if result := gdb.Where("uuid = ?", vote.TargetUUID).First(&comment); result.Error != nil {
+		return result.Error
+	}
+	count.Upvote, count.Downvote = comment.Upvote, comment.Downvote
+	Publish(ctx, ContentTopic(comment.ContentUUID), VoteCountChanged, count)
+	return nil
+}
+
+// hidden reports whether an item is one other readers cannot see, because it was removed or its author is
+// shadowbanned. Nothing is published while that cannot be told; the event is retried instead.
+func hidden(gdb *gorm.DB, removed bool, authorUUID string) (bool, error) {
+	if removed {
+		return true, nil
+	}
+	return repository.NewGormRepos(gdb).Accounts.IsShadowbanned(authorUUID)
+}
+
+func init() {
+	events.Subscribe("realtime", subscribe)
+}
@@ -0,0 +1,194 @@
+package report
+
+import (
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/automod"
+	"example/hivemind-be/comment"
+	"example/hivemind-be/content"
+	"example/hivemind-be/events"
+	"example/hivemind-be/models"
+	"example/hivemind-be/modqueue"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/utils"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Report = models.Report
+
+// Handler serves the report routes through the report repository.
+type Handler struct {
+	Repos repository.Repos
+}
+
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+// repos is the repositories with their queries bound to the request.
+func (h *Handler) repos(c *gin.Context) repository.Repos {
+	return h.Repos.WithContext(c.Request.Context())
+}
+
+func (h *Handler) CreateContentReport(c *gin.Context) {
+	var newReport Report
+
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&newReport); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
+		return
+	}
+
+	repos := h.repos(c)
+	reportedContent, err := repos.Contents.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found. Please try again."))
+		return
+	}
+	//reporting a draft would show it to the hive's moderators before it is published
+	if reportedContent.Draft {
+		apperr.Write(c, apperr.New(apperr.ContentNotFound, "Content not found. Please try again."))
+		return
+	}
+
+	newReport.HiveUUID = reportedContent.HiveUUID
+	newReport.ItemType = "content"
+	newReport.ItemUUID = reportedContent.UUID
+	newReport.AccountUUID = claims.AccountUUID
+	if !fileReport(c, repos, &newReport) {
+		return
+	}
+
+	subject := content.AutomodSubject(reportedContent)
+	applyRules(repos, newReport, subject, func(tx repository.Repos, verdict automod.Verdict) error {
+		locked, err := tx.Contents.GetForUpdate(reportedContent.UUID)
+		if err != nil {
+			return err
+		}
+		removed := verdict.Remove && !locked.Removed
+		content.ApplyVerdict(&locked, verdict)
+		if err := tx.Contents.Save(&locked); err != nil || !removed {
+			return err
+		}
+		event := events.Content(events.ContentRemoved, locked, automod.AutoModeratorUUID)
+		return tx.Events.Append(&event)
+	})
+
+	c.JSON(http.StatusCreated, newReport)
+}
+
+func (h *Handler) CreateCommentReport(c *gin.Context) {
+	var newReport Report
+
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&newReport); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
+		return
+	}
+
+	repos := h.repos(c)
+	reportedComment, err := repos.Comments.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.CommentNotFound, "Comment not found. Please try again."))
+		return
+	}
+
+	reportedContent, err := repos.Contents.GetByUUID(reportedComment.ContentUUID)
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found. Please try again."))
+		return
+	}
+	if reportedContent.Draft {
+		apperr.Write(c, apperr.New(apperr.CommentNotFound, "Comment not found. Please try again."))
+		return
+	}
+
+	newReport.HiveUUID = reportedContent.HiveUUID
+	newReport.ItemType = "comment"
+	newReport.ItemUUID = reportedComment.UUID
+	newReport.AccountUUID = claims.AccountUUID
+	if !fileReport(c, repos, &newReport) {
+		return
+	}
+
+	subject := comment.AutomodSubject(reportedComment, reportedContent.HiveUUID)
+	applyRules(repos, newReport, subject, func(tx repository.Repos, verdict automod.Verdict) error {
+		if !verdict.Remove {
+			return nil
+		}
+		locked, err := tx.Comments.GetForUpdate(reportedComment.UUID)
+		if err != nil || locked.Removed {
+			return err
+		}
+		locked.Removed = true
+		if err := tx.Comments.Save(&locked); err != nil {
+			return err
+		}
+		event := events.Comment(events.CommentRemoved, locked, reportedContent.HiveUUID, automod.AutoModeratorUUID)
+		return tx.Events.Append(&event)
+	})
+
+	c.JSON(http.StatusCreated, newReport)
+}
+
+// applyRules runs the hive's report rules on the reported item and, when any match, saves what apply changes
+// on the item in one transaction with the rest of the verdict. apply reads the item again under a lock, so
+// votes and comments counted since the report was filed are not overwritten. The report is filed either way, so a failure
+// is logged rather than returned.
+func applyRules(repos repository.Repos, newReport Report, subject automod.Subject, apply func(tx repository.Repos, verdict automod.Verdict) error) {
+	err := repos.Tx.Transaction(func(tx repository.Repos) error {
+		verdict, err := automod.EvaluateReported(tx, subject)
+		if err != nil || len(verdict.Matched) == 0 {
+			return err
+		}
+		if err := apply(tx, verdict); err != nil {
+			return err
+		}
+		return automod.Dispatch(tx, verdict, subject)
+	})
+	if err != nil {
+		slog.Error("could not apply AutoModerator rules to a report", "report", newReport.UUID, "error", err)
+	}
+}
+
+// fileReport saves a report and queues the reported item for moderators. An account can report an item once.
+func fileReport(c *gin.Context, repos repository.Repos, newReport *Report) bool {
+	if !utils.ValidateReportReason(newReport.Reason) {
+		apperr.Write(c, apperr.New(apperr.ValidationFailed, "Reason must be between 1 and 256 characters."))
+		return false
+	}
+
+	newReport.UUID = uuid.NewString()
+	newReport.Created = pq.NullTime{Time: time.Now(), Valid: true}
+
+	err := repos.Tx.Transaction(func(tx repository.Repos) error {
+		if err := tx.Reports.Create(newReport); err != nil {
+			return err
+		}
+		return modqueue.Enqueue(tx.ModQueue, newReport.HiveUUID, newReport.ItemType, newReport.ItemUUID, "report", newReport.Reason)
+	})
+	if apperr.CodeOf(err) == apperr.Conflict {
+		apperr.Write(c, apperr.New(apperr.AlreadyReported, "User has already reported this "+newReport.ItemType+"!"))
+		return false
+	}
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error creating this report. Please try again.", err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,1001 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormAccountRepo struct{ db *gorm.DB }
+type gormHiveRepo struct{ db *gorm.DB }
+type gormContentRepo struct{ db *gorm.DB }
+type gormCommentRepo struct{ db *gorm.DB }
+type gormVoteRepo struct{ db *gorm.DB }
+type gormMentionRepo struct{ db *gorm.DB }
+type gormRevisionRepo struct{ db *gorm.DB }
+type gormEventRepo struct{ db *gorm.DB }
+type gormWebhookRepo struct{ db *gorm.DB }
+type gormJobRepo struct{ db *gorm.DB }
+type gormNotificationRepo struct{ db *gorm.DB }
+type gormBanRepo struct{ db *gorm.DB }
+type gormAppealRepo struct{ db *gorm.DB }
+type gormReportRepo struct{ db *gorm.DB }
+type gormModQueueRepo struct{ db *gorm.DB }
+type gormAutomodRepo struct{ db *gorm.DB }
+type gormSpamRepo struct{ db *gorm.DB }
+type gormTransactor struct{ db *gorm.DB }
+
+// NewGormRepos returns repositories backed by Postgres through GORM.
+func NewGormRepos(db *gorm.DB) Repos {
+	return Repos{
+		Accounts:      gormAccountRepo{db},
+		Hives:         gormHiveRepo{db},
+		Contents:      gormContentRepo{db},
+		Comments:      gormCommentRepo{db},
+		Votes:         gormVoteRepo{db},
+		Mentions:      gormMentionRepo{db},
+		Revisions:     gormRevisionRepo{db},
+		Events:        gormEventRepo{db},
+		Webhooks:      gormWebhookRepo{db},
+		Jobs:          gormJobRepo{db},
+		Notifications: gormNotificationRepo{db},
+		Bans:          gormBanRepo{db},
+		Appeals:       gormAppealRepo{db},
+		Reports:       gormReportRepo{db},
+		ModQueue:      gormModQueueRepo{db},
+		Automod:       gormAutomodRepo{db},
+		Spam:          gormSpamRepo{db},
+		Tx:            gormTransactor{db},
+	}
+}
+
+func (r gormAccountRepo) withContext(ctx context.Context) AccountRepo {
+	return gormAccountRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormHiveRepo) withContext(ctx context.Context) HiveRepo {
+	return gormHiveRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormContentRepo) withContext(ctx context.Context) ContentRepo {
+	return gormContentRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormCommentRepo) withContext(ctx context.Context) CommentRepo {
+	return gormCommentRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormVoteRepo) withContext(ctx context.Context) VoteRepo {
+	return gormVoteRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormMentionRepo) withContext(ctx context.Context) MentionRepo {
+	return gormMentionRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormRevisionRepo) withContext(ctx context.Context) RevisionRepo {
+	return gormRevisionRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormEventRepo) withContext(ctx context.Context) EventRepo {
+	return gormEventRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormWebhookRepo) withContext(ctx context.Context) WebhookRepo {
+	return gormWebhookRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormJobRepo) withContext(ctx context.Context) JobRepo {
+	return gormJobRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormNotificationRepo) withContext(ctx context.Context) NotificationRepo {
+	return gormNotificationRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormBanRepo) withContext(ctx context.Context) BanRepo {
+	return gormBanRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormAppealRepo) withContext(ctx context.Context) AppealRepo {
+	return gormAppealRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormReportRepo) withContext(ctx context.Context) ReportRepo {
+	return gormReportRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormModQueueRepo) withContext(ctx context.Context) ModQueueRepo {
+	return gormModQueueRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormAutomodRepo) withContext(ctx context.Context) AutomodRepo {
+	return gormAutomodRepo{r.db.WithContext(ctx)}
+}
+
+func (r gormSpamRepo) withContext(ctx context.Context) SpamRepo {
+	return gormSpamRepo{r.db.WithContext(ctx)}
+}
+
+func (t gormTransactor) withContext(ctx context.Context) Transactor {
+	return gormTransactor{t.db.WithContext(ctx)}
+}
+
+func (t gormTransactor) Transaction(fn func(tx Repos) error) error {
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		return fn(NewGormRepos(tx))
+	})
+}
+
+// translate maps GORM's not found and duplicate key errors to domain errors.
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return apperr.Wrap(apperr.Conflict, "Already exists.", err)
+	}
+	return err
+}
+
+// Lookup translates the error of a lookup made directly through GORM, reporting a missing row with code and
+// message.
+func Lookup(err error, code apperr.Code, message string) error {
+	return apperr.NotFoundAs(translate(err), code, message)
+}
+
+// nullable is value, or nil when it is empty so an update writes null rather than "".
+func nullable[T comparable](value T) interface{} {
+	var zero T
+	if value == zero {
+		return nil
+	}
+	return value
+}
+
+// addCounts adds each nonzero delta to its counter column on the row with the UUID, in one UPDATE.
+func addCounts(db *gorm.DB, model interface{}, uuid string, deltas map[string]int32) error {
+	updates := map[string]interface{}{}
+	for column, delta := range deltas {
+		if delta != 0 {
+			updates[column] = gorm.Expr("COALESCE("+column+", 0) + ?", delta)
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return db.Model(model).Where("uuid = ?", uuid).UpdateColumns(updates).Error
+}
+
+// forUpdate locks the rows a query reads until the transaction ends.
+var forUpdate = clause.Locking{Strength: "UPDATE"}
+
+// visibleTo is a query scope that hides rows created by shadowbanned accounts from everyone except the
+// account that created them.
+func visibleTo(viewerUUID string) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("account_uuid = ? OR account_uuid NOT IN (SELECT uuid FROM accounts WHERE shadowbanned = ?)", viewerUUID, true)
+	}
+}
+
+// ownDraftsOf is a query scope on contents that hides drafts from everyone except their author.
+func ownDraftsOf(viewerUUID string) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("draft = ? OR account_uuid = ?", false, viewerUUID)
+	}
+}
+
+func (r gormAccountRepo) Create(account *models.Account) error {
+	return r.db.Create(account).Error
+}
+
+func (r gormAccountRepo) GetByUUID(uuid string) (models.Account, error) {
+	var account models.Account
+	err := r.db.Where("uuid = ?", uuid).First(&account).Error
+	return account, translate(err)
+}
+
+func (r gormAccountRepo) GetByEmail(email string) (models.Account, error) {
+	var account models.Account
+	err := r.db.Where("email = ?", email).First(&account).Error
+	return account, translate(err)
+}
+
+func (r gormAccountRepo) ListByUsernames(usernames []string) ([]models.Account, error) {
+	var accounts []models.Account
+	if len(usernames) == 0 {
+		return accounts, nil
+	}
+	err := r.db.Where("username IN ?", usernames).Find(&accounts).Error
+	return accounts, err
+}
+
+func (r gormAccountRepo) Save(account *models.Account) error {
+	return r.db.Save(account).Error
+}
+
+func (r gormAccountRepo) Karma(accountUUID string) (int64, error) {
+	var karma int64
+	err := r.db.Raw(`SELECT (SELECT COALESCE(SUM(upvote - downvote), 0) FROM contents WHERE account_uuid = ?) +
+		(SELECT COALESCE(SUM(upvote - downvote), 0) FROM comments WHERE account_uuid = ?)`, accountUUID, accountUUID).Scan(&karma).Error
+	return karma, err
+}
+
+func (r gormAccountRepo) IsShadowbanned(accountUUID string) (bool, error) {
+	var shadowbanned int64
+	err := r.db.Model(&models.Account{}).Where("uuid = ? AND shadowbanned = ?", accountUUID, true).Count(&shadowbanned).Error
+	return shadowbanned > 0, err
+}
+
+func (r gormAccountRepo) SetBanned(uuid string, banned bool) error {
+	return r.db.Model(&models.Account{}).Where("uuid = ?", uuid).Update("banned", banned).Error
+}
+
+func (r gormAccountRepo) SetShadowbanned(uuid string, shadowbanned bool) (bool, error) {
+	result := r.db.Model(&models.Account{}).Where("uuid = ? AND shadowbanned <> ?", uuid, shadowbanned).Update("shadowbanned", shadowbanned)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormAccountRepo) ShadowbanReport(since time.Time) ([]models.ShadowbanActivity, error) {
+	var report []models.ShadowbanActivity
+	err := r.db.Raw(`SELECT a.username, a.uuid,
+		(SELECT COUNT(*) FROM contents t WHERE t.account_uuid = a.uuid AND t.created > @since) AS content,
+		(SELECT COUNT(*) FROM comments m WHERE m.account_uuid = a.uuid AND m.created > @since) AS comments,
+		(SELECT COUNT(*) FROM content_votes v WHERE v.account_uuid = a.uuid AND v.last_edited > @since AND (v.upvote OR v.downvote)) +
+		(SELECT COUNT(*) FROM comment_votes v WHERE v.account_uuid = a.uuid AND v.last_edited > @since AND (v.upvote OR v.downvote)) AS votes,
+		GREATEST(
+			(SELECT MAX(t.created) FROM contents t WHERE t.account_uuid = a.uuid),
+			(SELECT MAX(m.created) FROM comments m WHERE m.account_uuid = a.uuid)) AS last_active
+		FROM accounts a WHERE a.shadowbanned ORDER BY a.username`, map[string]interface{}{"since": since}).
+		Scan(&report).Error
+	return report, err
+}
+
+func (r gormHiveRepo) Create(hive *models.Hive) error {
+	return r.db.Create(hive).Error
+}
+
+func (r gormHiveRepo) List() ([]models.Hive, error) {
+	var hives []models.Hive
+	err := r.db.Order("id asc").Find(&hives).Error
+	return hives, err
+}
+
+func (r gormHiveRepo) GetByUUID(uuid string) (models.Hive, error) {
+	var hive models.Hive
+	err := r.db.Where("uuid = ?", uuid).First(&hive).Error
+	return hive, translate(err)
+}
+
+func (r gormHiveRepo) GetForUpdate(uuid string) (models.Hive, error) {
+	var hive models.Hive
+	err := r.db.Clauses(forUpdate).Where("uuid = ?", uuid).First(&hive).Error
+	return hive, translate(err)
+}
+
+func (r gormHiveRepo) GetByName(name string) (models.Hive, error) {
+	var hive models.Hive
+	err := r.db.Where("name = ?", name).First(&hive).Error
+	return hive, translate(err)
+}
+
+func (r gormHiveRepo) ListByNames(names []string) ([]models.Hive, error) {
+	var hives []models.Hive
+	if len(names) == 0 {
+		return hives, nil
+	}
+	err := r.db.Where("name IN ?", names).Find(&hives).Error
+	return hives, err
+}
+
+func (r gormHiveRepo) Save(hive *models.Hive) error {
+	return r.db.Save(hive).Error
+}
+
+func (r gormHiveRepo) AddCounts(uuid string, counts Counts) error {
+	return addCounts(r.db, &models.Hive{}, uuid, map[string]int32{
+		"member_count":    counts.Members,
+		"total_content":   counts.Content,
+		"total_comments":  counts.Comments,
+		"total_upvotes":   counts.Upvotes,
+		"total_downvotes": counts.Downvotes,
+	})
+}
+
+func (r gormHiveRepo) GetMember(hiveUUID string, accountUUID string) (models.HiveMember, error) {
+	var member models.HiveMember
+	err := r.db.Where("hive_uuid = ? AND account_uuid = ?", hiveUUID, accountUUID).First(&member).Error
+	return member, translate(err)
+}
+
+func (r gormHiveRepo) AddMember(member *models.HiveMember) error {
+	return translate(r.db.Create(member).Error)
+}
+
+func (r gormHiveRepo) RemoveMember(member models.HiveMember) error {
+	return r.db.Delete(&member).Error
+}
+
+func (r gormContentRepo) Create(content *models.Content) error {
+	return r.db.Create(content).Error
+}
+
+func (r gormContentRepo) GetByUUID(uuid string) (models.Content, error) {
+	var content models.Content
+	err := r.db.Where("uuid = ?", uuid).First(&content).Error
+	return content, translate(err)
+}
+
+func (r gormContentRepo) GetForUpdate(uuid string) (models.Content, error) {
+	var content models.Content
+	err := r.db.Clauses(forUpdate).Where("uuid = ?", uuid).First(&content).Error
+	return content, translate(err)
+}
+
+func (r gormContentRepo) GetVisibleByID(id int, viewerUUID string) (models.Content, error) {
+	var content models.Content
+	err := r.db.Scopes(visibleTo(viewerUUID), ownDraftsOf(viewerUUID)).First(&content, id).Error
+	return content, translate(err)
+}
+
+func (r gormContentRepo) GetVisibleByUUID(uuid string, viewerUUID string) (models.Content, error) {
+	var content models.Content
+	err := r.db.Scopes(visibleTo(viewerUUID), ownDraftsOf(viewerUUID)).Where("uuid = ?", uuid).First(&content).Error
+	return content, translate(err)
+}
+
+func (r gormContentRepo) ListVisible(viewerUUID string) ([]models.Content, error) {
+	var content []models.Content
+	err := r.db.Scopes(visibleTo(viewerUUID)).Where("removed = ? AND draft = ?", false, false).Order("id asc").Find(&content).Error
+	return content, err
+}
+
+func (r gormContentRepo) ListVisibleByHive(hiveUUID string, viewerUUID string) ([]models.Content, error) {
+	var content []models.Content
+	err := r.db.Scopes(visibleTo(viewerUUID)).Where("hive_uuid = ? AND removed = ? AND draft = ?", hiveUUID, false, false).Find(&content).Error
+	return content, err
+}
+
+func (r gormContentRepo) ListDrafts(accountUUID string) ([]models.Content, error) {
+	var content []models.Content
+	err := r.db.Where("account_uuid = ? AND draft = ? AND deleted = ?", accountUUID, true, false).Order("id desc").Find(&content).Error
+	return content, err
+}
+
+func (r gormContentRepo) Save(content *models.Content) error {
+	return r.db.Save(content).Error
+}
+
+func (r gormContentRepo) AddCounts(uuid string, counts Counts) error {
+	return addCounts(r.db, &models.Content{}, uuid, map[string]int32{
+		"upvote":        counts.Upvotes,
+		"downvote":      counts.Downvotes,
+		"comment_count": counts.Comments,
+	})
+}
+
+func (r gormCommentRepo) Create(comment *models.Comment) error {
+	return r.db.Create(comment).Error
+}
+
+func (r gormCommentRepo) GetByUUID(uuid string) (models.Comment, error) {
+	var comment models.Comment
+	err := r.db.Where("uuid = ?", uuid).First(&comment).Error
+	return comment, translate(err)
+}
+
+func (r gormCommentRepo) GetForUpdate(uuid string) (models.Comment, error) {
+	var comment models.Comment
+	err := r.db.Clauses(forUpdate).Where("uuid = ?", uuid).First(&comment).Error
+	return comment, translate(err)
+}
+
+func (r gormCommentRepo) GetVisibleByUUID(uuid string, viewerUUID string) (models.Comment, error) {
+	var comment models.Comment
+	err := r.db.Scopes(visibleTo(viewerUUID)).Where("uuid = ?", uuid).First(&comment).Error
+	return comment, translate(err)
+}
+
+func (r gormCommentRepo) ListByContent(contentUUID string) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := r.db.Where("content_uuid = ?", contentUUID).Find(&comments).Error
+	return comments, err
+}
+
+func (r gormCommentRepo) ListVisibleByContent(contentUUID string, viewerUUID string) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := r.db.Scopes(visibleTo(viewerUUID)).Where("content_uuid = ?", contentUUID).Order("created DESC").Find(&comments).Error
+	return comments, err
+}
+
+func (r gormCommentRepo) ListVisibleReplies(parentUUID string, viewerUUID string) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := r.db.Scopes(visibleTo(viewerUUID)).Where("parent_uuid = ?", parentUUID).Find(&comments).Error
+	return comments, err
+}
+
+func (r gormCommentRepo) Save(comment *models.Comment) error {
+	return r.db.Save(comment).Error
+}
+
+func (r gormCommentRepo) AddCounts(uuid string, counts Counts) error {
+	return addCounts(r.db, &models.Comment{}, uuid, map[string]int32{
+		"upvote":   counts.Upvotes,
+		"downvote": counts.Downvotes,
+	})
+}
+
+func (r gormVoteRepo) GetContentVote(accountUUID string, contentUUID string) (models.ContentVote, error) {
+	var vote models.ContentVote
+	voteQuery := map[string]interface{}{
+		"account_uuid": accountUUID,
+		"content_uuid": contentUUID,
+	}
+	err := r.db.Where(voteQuery).First(&vote).Error
+	return vote, translate(err)
+}
+
+func (r gormVoteRepo) SaveContentVote(vote *models.ContentVote) error {
+	return r.db.Save(vote).Error
+}
+
+func (r gormVoteRepo) ListContentVotesByAccount(accountUUID string) ([]models.ContentVote, error) {
+	var votes []models.ContentVote
+	err := r.db.Where("account_uuid = ?", accountUUID).Find(&votes).Error
+	return votes, err
+}
+
+func (r gormVoteRepo) GetCommentVote(accountUUID string, commentUUID string) (models.CommentVote, error) {
+	var vote models.CommentVote
+	voteQuery := map[string]interface{}{
+		"account_uuid": accountUUID,
+		"comment_uuid": commentUUID,
+	}
+	err := r.db.Where(voteQuery).First(&vote).Error
+	return vote, translate(err)
+}
+
+func (r gormVoteRepo) SaveCommentVote(vote *models.CommentVote) error {
+	return r.db.Save(vote).Error
+}
+
+func (r gormVoteRepo) ListCommentVoteGroupsByAccount(accountUUID string) ([]models.CommentVoteGroup, error) {
+	var results []models.CommentVoteGroup
+	err := r.db.Table("comment_votes AS v").
+		Select("DISTINCT v.upvote, v.downvote, v.comment_uuid, c.content_uuid").
+		Joins("LEFT JOIN comments AS c ON v.comment_uuid = c.uuid").
+		Where("v.account_uuid = ?", accountUUID).
+		Where("c.content_uuid IS NOT NULL").
+		Order("c.content_uuid").
+		Scan(&results).Error
+	return results, err
+}
+
+// accountVoteStatements add ? times each vote of account ? to the counters the vote affects.
+var accountVoteStatements = []string{
+	`UPDATE contents SET upvote = upvote + ? FROM content_votes v
+		WHERE v.content_uuid = contents.uuid AND v.account_uuid = ? AND v.upvote`,
+	`UPDATE contents SET downvote = downvote + ? FROM content_votes v
+		WHERE v.content_uuid = contents.uuid AND v.account_uuid = ? AND v.downvote`,
+	`UPDATE hives SET total_upvotes = total_upvotes + ? * s.votes FROM (
+		SELECT t.hive_uuid, COUNT(*) AS votes FROM content_votes v JOIN contents t ON v.content_uuid = t.uuid
+		WHERE v.account_uuid = ? AND v.upvote AND t.deleted IS NOT TRUE GROUP BY t.hive_uuid) s
+		WHERE hives.uuid = s.hive_uuid`,
+	`UPDATE hives SET total_downvotes = total_downvotes + ? * s.votes FROM (
+		SELECT t.hive_uuid, COUNT(*) AS votes FROM content_votes v JOIN contents t ON v.content_uuid = t.uuid
+		WHERE v.account_uuid = ? AND v.downvote AND t.deleted IS NOT TRUE GROUP BY t.hive_uuid) s
+		WHERE hives.uuid = s.hive_uuid`,
+	`UPDATE hives SET total_upvotes = total_upvotes + ? * s.votes FROM (
+		SELECT t.hive_uuid, COUNT(*) AS votes FROM comment_votes v JOIN comments m ON v.comment_uuid = m.uuid
+		JOIN contents t ON m.content_uuid = t.uuid
+		WHERE v.account_uuid = ? AND v.upvote AND m.deleted IS NOT TRUE AND t.deleted IS NOT TRUE GROUP BY t.hive_uuid) s
+		WHERE hives.uuid = s.hive_uuid`,
+	`UPDATE hives SET total_downvotes = total_downvotes + ? * s.votes FROM (
+		SELECT t.hive_uuid, COUNT(*) AS votes FROM comment_votes v JOIN comments m ON v.comment_uuid = m.uuid
+		JOIN contents t ON m.content_uuid = t.uuid
+		WHERE v.account_uuid = ? AND v.downvote AND m.deleted IS NOT TRUE AND t.deleted IS NOT TRUE GROUP BY t.hive_uuid) s
+		WHERE hives.uuid = s.hive_uuid`,
+	`UPDATE comments SET upvote = upvote + ? FROM comment_votes v
+		WHERE v.comment_uuid = comments.uuid AND v.account_uuid = ? AND v.upvote`,
+	`UPDATE comments SET downvote = downvote + ? FROM comment_votes v
+		WHERE v.comment_uuid = comments.uuid AND v.account_uuid = ? AND v.downvote`,
+}
+
+func (r gormVoteRepo) AddAccountVotes(accountUUID string, sign int32) error {
+	for _, statement := range accountVoteStatements {
+		if err := r.db.Exec(statement, sign, accountUUID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r gormMentionRepo) Replace(sourceType string, sourceUUID string, mentions []models.Mention) error {
+	if err := r.db.Where("source_type = ? AND source_uuid = ?", sourceType, sourceUUID).Delete(&models.Mention{}).Error; err != nil {
+		return err
+	}
+	if len(mentions) == 0 {
+		return nil
+	}
+	rows := make([]models.Mention, len(mentions))
+	for i, mention := range mentions {
+		mention.ID = 0
+		mention.SourceType = sourceType
+		mention.SourceUUID = sourceUUID
+		rows[i] = mention
+	}
+	return r.db.Create(&rows).Error
+}
+
+func (r gormMentionRepo) ListBySources(sourceType string, sourceUUIDs []string) ([]models.Mention, error) {
+	var mentions []models.Mention
+	if len(sourceUUIDs) == 0 {
+		return mentions, nil
+	}
+	err := r.db.Where("source_type = ? AND source_uuid IN ?", sourceType, sourceUUIDs).Order("id asc").Find(&mentions).Error
+	return mentions, err
+}
+
+func (r gormRevisionRepo) Append(revision *models.Revision) error {
+	err := r.db.Model(&models.Revision{}).Select("COALESCE(MAX(version), 0) + 1").
+		Where("item_type = ? AND item_uuid = ?", revision.ItemType, revision.ItemUUID).Scan(&revision.Version).Error
+	if err != nil {
+		return err
+	}
+	return translate(r.db.Create(revision).Error)
+}
+
+func (r gormRevisionRepo) List(itemType string, itemUUID string) ([]models.Revision, error) {
+	var revisions []models.Revision
+	err := r.db.Where("item_type = ? AND item_uuid = ?", itemType, itemUUID).Order("version asc").Find(&revisions).Error
+	return revisions, err
+}
+
+// Append holds a transaction lock on the aggregate before inserting, so transactions appending events of one
+// aggregate insert them one after the other. An event's ID is taken when it is inserted rather than when its
+// transaction commits, and without the lock a later event could commit first and be dispatched while an
+// earlier one of its aggregate is not yet visible to ListDue.
+func (r gormEventRepo) Append(event *models.Event) error {
+	if err := r.db.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", event.AggregateUUID).Error; err != nil {
+		return err
+	}
+	return r.db.Create(event).Error
+}
+
+func (r gormEventRepo) ListDue(now time.Time, limit int) ([]models.Event, error) {
+	var events []models.Event
+	err := r.db.Raw(`SELECT * FROM events e
+		WHERE e.dispatched IS NULL AND e.next_attempt <= ? AND NOT EXISTS (
+			SELECT 1 FROM events p WHERE p.aggregate_uuid = e.aggregate_uuid AND p.dispatched IS NULL AND p.id < e.id
+		)
+		ORDER BY e.id
+		LIMIT ?`, now, limit).Scan(&events).Error
+	return events, err
+}
+
+func (r gormEventRepo) SaveAttempt(event *models.Event) error {
+	updates := map[string]interface{}{
+		"attempts":     event.Attempts,
+		"next_attempt": event.NextAttempt,
+		"last_error":   nil,
+		"dispatched":   event.Dispatched,
+	}
+	if event.LastError != "" {
+		updates["last_error"] = event.LastError
+	}
+	return r.db.Model(&models.Event{}).Where("id = ?", event.ID).Updates(updates).Error
+}
+
+func (r gormWebhookRepo) Create(hook *models.Webhook) error {
+	return r.db.Create(hook).Error
+}
+
+func (r gormWebhookRepo) GetByUUID(uuid string) (models.Webhook, error) {
+	var hook models.Webhook
+	err := r.db.Where("uuid = ?", uuid).First(&hook).Error
+	return hook, translate(err)
+}
+
+func (r gormWebhookRepo) ListByHive(hiveUUID string) ([]models.Webhook, error) {
+	var hooks []models.Webhook
+	err := r.db.Where("hive_uuid = ?", hiveUUID).Order("created").Find(&hooks).Error
+	return hooks, err
+}
+
+func (r gormWebhookRepo) CountByHive(hiveUUID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Webhook{}).Where("hive_uuid = ?", hiveUUID).Count(&count).Error
+	return count, err
+}
+
+func (r gormWebhookRepo) Update(hook *models.Webhook) error {
+	//Save would write an empty reason as "" rather than null
+	return r.db.Model(hook).Updates(map[string]interface{}{
+		"url":                  hook.URL,
+		"events":               hook.Events,
+		"active":               hook.Active,
+		"consecutive_failures": hook.ConsecutiveFailures,
+		"disabled_reason":      nullable(hook.DisabledReason),
+		"secret":               hook.Secret,
+		"last_edited":          hook.LastEdited,
+	}).Error
+}
+
+func (r gormWebhookRepo) Delete(hook models.Webhook) error {
+	return r.db.Delete(&hook).Error
+}
+
+func (r gormWebhookRepo) AddFailure(hook models.Webhook) (int32, error) {
+	var failures int32
+	err := r.db.Raw("UPDATE webhooks SET consecutive_failures = consecutive_failures + 1 WHERE id = ? RETURNING consecutive_failures", hook.ID).Scan(&failures).Error
+	return failures, err
+}
+
+func (r gormWebhookRepo) ResetFailures(hook models.Webhook) error {
+	return r.db.Model(&models.Webhook{}).Where("id = ?", hook.ID).Update("consecutive_failures", 0).Error
+}
+
+func (r gormWebhookRepo) Disable(hook models.Webhook, reason string) error {
+	return r.db.Model(&models.Webhook{}).Where("id = ?", hook.ID).Updates(map[string]interface{}{
+		"active":          false,
+		"disabled_reason": reason,
+	}).Error
+}
+
+func (r gormWebhookRepo) CreateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+func (r gormWebhookRepo) GetDelivery(uuid string) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.db.Where("uuid = ?", uuid).First(&delivery).Error
+	return delivery, translate(err)
+}
+
+func (r gormWebhookRepo) ListDeliveries(webhookUUID string, status string, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	query := r.db.Where("webhook_uuid = ?", webhookUUID).Order("id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r gormWebhookRepo) SaveDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"response_status": nullable(delivery.ResponseStatus),
+		"response_body":   nullable(delivery.ResponseBody),
+		"last_error":      nullable(delivery.LastError),
+		"duration_ms":     nullable(delivery.DurationMs),
+		"delivered":       delivery.Delivered,
+	}).Error
+}
+
+func (r gormJobRepo) Create(job *models.Job) error {
+	return r.db.Create(job).Error
+}
+
+func (r gormJobRepo) GetByUUID(uuid string) (models.Job, error) {
+	var job models.Job
+	err := r.db.Where("uuid = ?", uuid).First(&job).Error
+	return job, translate(err)
+}
+
+func (r gormJobRepo) List(status string, kind string, limit int) ([]models.Job, error) {
+	var jobs []models.Job
+	query := r.db.Order("id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	err := query.Find(&jobs).Error
+	return jobs, err
+}
+
+func (r gormJobRepo) Stats() ([]models.JobStats, error) {
+	var stats []models.JobStats
+	err := r.db.Model(&models.Job{}).Select("kind, status, COUNT(*) AS jobs").Group("kind, status").Order("kind, status").Scan(&stats).Error
+	return stats, err
+}
+
+func (r gormJobRepo) ListSchedules() ([]models.JobSchedule, error) {
+	var schedules []models.JobSchedule
+	err := r.db.Order("name").Find(&schedules).Error
+	return schedules, err
+}
+
+func (r gormJobRepo) Requeue(job *models.Job) error {
+	return r.db.Model(job).Where("status = ?", "dead").Updates(map[string]interface{}{
+		"status":   job.Status,
+		"attempts": job.Attempts,
+		"run_at":   job.RunAt,
+		"finished": job.Finished,
+	}).Error
+}
+
+func (r gormNotificationRepo) Create(notification *models.Notification) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_uuid"}, {Name: "type"}, {Name: "source_uuid"}},
+		DoNothing: true,
+	}).Create(notification)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormNotificationRepo) IsEnabled(accountUUID string, notificationType string) (bool, error) {
+	var disabled int64
+	err := r.db.Model(&models.NotificationPreference{}).
+		Where("account_uuid = ? AND type = ? AND enabled = ?", accountUUID, notificationType, false).
+		Count(&disabled).Error
+	return disabled == 0, err
+}
+
+func (r gormNotificationRepo) GetOwned(uuid string, accountUUID string) (models.Notification, error) {
+	var notification models.Notification
+	err := r.db.Where("uuid = ? AND account_uuid = ?", uuid, accountUUID).First(&notification).Error
+	return notification, translate(err)
+}
+
+func (r gormNotificationRepo) List(accountUUID string, before int64, unread bool, limit int) ([]models.Notification, error) {
+	var notifications []models.Notification
+	query := r.db.Where("account_uuid = ?", accountUUID).Order("id DESC").Limit(limit)
+	if before != 0 {
+		query = query.Where("id < ?", before)
+	}
+	if unread {
+		query = query.Where("read = ?", false)
+	}
+	err := query.Find(&notifications).Error
+	return notifications, err
+}
+
+func (r gormNotificationRepo) CountUnread(accountUUID string) (int64, error) {
+	var unread int64
+	err := r.db.Model(&models.Notification{}).Where("account_uuid = ? AND read = ?", accountUUID, false).Count(&unread).Error
+	return unread, err
+}
+
+func (r gormNotificationRepo) SetRead(notification *models.Notification) error {
+	return r.db.Model(notification).Updates(map[string]interface{}{"read": notification.Read, "read_at": notification.ReadAt}).Error
+}
+
+func (r gormNotificationRepo) MarkAllRead(accountUUID string) (int64, error) {
+	result := r.db.Model(&models.Notification{}).Where("account_uuid = ? AND read = ?", accountUUID, false).
+		Updates(map[string]interface{}{"read": true, "read_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+func (r gormNotificationRepo) ListPreferences(accountUUID string) ([]models.NotificationPreference, error) {
+	var preferences []models.NotificationPreference
+	err := r.db.Where("account_uuid = ?", accountUUID).Find(&preferences).Error
+	return preferences, err
+}
+
+func (r gormNotificationRepo) SavePreferences(preferences []models.NotificationPreference) error {
+	if len(preferences) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_uuid"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled"}),
+	}).Create(&preferences).Error
+}
+
+func (r gormBanRepo) Create(ban *models.Ban) error {
+	return r.db.Create(ban).Error
+}
+
+func (r gormBanRepo) GetByUUID(uuid string) (models.Ban, error) {
+	var ban models.Ban
+	err := r.db.Where("uuid = ?", uuid).First(&ban).Error
+	return ban, translate(err)
+}
+
+func (r gormBanRepo) GetForUpdate(uuid string) (models.Ban, error) {
+	var ban models.Ban
+	err := r.db.Clauses(forUpdate).Where("uuid = ?", uuid).First(&ban).Error
+	return ban, translate(err)
+}
+
+func (r gormBanRepo) Save(ban *models.Ban) error {
+	return r.db.Save(ban).Error
+}
+
+func (r gormBanRepo) HasActive(accountUUID string, hiveUUID string) (bool, error) {
+	var count int64
+	query := r.db.Model(&models.Ban{}).Where("account_uuid = ? AND active = ?", accountUUID, true)
+	if hiveUUID == "" {
+		query = query.Where("hive_uuid IS NULL")
+	} else {
+		query = query.Where("hive_uuid = ?", hiveUUID)
+	}
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+func (r gormBanRepo) ListByHive(hiveUUID string) ([]models.Ban, error) {
+	var bans []models.Ban
+	err := r.db.Where("hive_uuid = ?", hiveUUID).Order("created DESC").Find(&bans).Error
+	return bans, err
+}
+
+func (r gormBanRepo) ListByAccount(accountUUID string) ([]models.Ban, error) {
+	var bans []models.Ban
+	err := r.db.Where("account_uuid = ?", accountUUID).Order("created DESC").Find(&bans).Error
+	return bans, err
+}
+
+func (r gormBanRepo) IsBanned(accountUUID string, hiveUUID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Ban{}).
+		Where("account_uuid = ? AND active = ? AND (hive_uuid IS NULL OR hive_uuid = ?)", accountUUID, true, hiveUUID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r gormAppealRepo) Create(appeal *models.Appeal) error {
+	return translate(r.db.Create(appeal).Error)
+}
+
+func (r gormAppealRepo) GetByUUID(uuid string) (models.Appeal, error) {
+	var appeal models.Appeal
+	err := r.db.Where("uuid = ?", uuid).First(&appeal).Error
+	return appeal, translate(err)
+}
+
+func (r gormAppealRepo) GetForUpdate(uuid string) (models.Appeal, error) {
+	var appeal models.Appeal
+	err := r.db.Clauses(forUpdate).Where("uuid = ?", uuid).First(&appeal).Error
+	return appeal, translate(err)
+}
+
+func (r gormAppealRepo) GetByBan(banUUID string) (models.Appeal, error) {
+	var appeal models.Appeal
+	err := r.db.Where("ban_uuid = ?", banUUID).First(&appeal).Error
+	return appeal, translate(err)
+}
+
+func (r gormAppealRepo) ListQueue(hiveUUID string, status string) ([]models.Appeal, error) {
+	var appeals []models.Appeal
+	query := r.db.Where("status = ?", status)
+	if hiveUUID == "" {
+		query = query.Where("hive_uuid IS NULL")
+	} else {
+		query = query.Where("hive_uuid = ?", hiveUUID)
+	}
+	err := query.Order("created asc").Find(&appeals).Error
+	return appeals, err
+}
+
+func (r gormAppealRepo) ListByAccount(accountUUID string) ([]models.Appeal, error) {
+	var appeals []models.Appeal
+	err := r.db.Where("account_uuid = ?", accountUUID).Order("created DESC").Find(&appeals).Error
+	return appeals, err
+}
+
+func (r gormAppealRepo) Save(appeal *models.Appeal) error {
+	return r.db.Save(appeal).Error
+}
+
+func (r gormAppealRepo) AddEvent(event *models.AppealEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r gormAppealRepo) ListEvents(appealUUID string) ([]models.AppealEvent, error) {
+	var events []models.AppealEvent
+	err := r.db.Where("appeal_uuid = ?", appealUUID).Order("created asc").Find(&events).Error
+	return events, err
+}
+
+func (r gormReportRepo) Create(report *models.Report) error {
+	return translate(r.db.Create(report).Error)
+}
+
+func (r gormReportRepo) CountByItem(itemUUID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Report{}).Where("item_uuid = ?", itemUUID).Count(&count).Error
+	return count, err
+}
+
+func (r gormModQueueRepo) Enqueue(item *models.ModQueueItem) error {
+	var pending int64
+	if err := r.db.Model(&models.ModQueueItem{}).Where("item_uuid = ? AND status = ?", item.ItemUUID, "pending").Count(&pending).Error; err != nil {
+		return err
+	}
+	if pending > 0 {
+		return nil
+	}
+	return r.db.Create(item).Error
+}
+
+func (r gormModQueueRepo) GetByUUID(uuid string) (models.ModQueueItem, error) {
+	var item models.ModQueueItem
+	err := r.db.Where("uuid = ?", uuid).First(&item).Error
+	return item, translate(err)
+}
+
+func (r gormModQueueRepo) GetForUpdate(uuid string) (models.ModQueueItem, error) {
+	var item models.ModQueueItem
+	err := r.db.Clauses(forUpdate).Where("uuid = ?", uuid).First(&item).Error
+	return item, translate(err)
+}
+
+func (r gormModQueueRepo) List(hiveUUID string, status string) ([]models.ModQueueItem, error) {
+	var items []models.ModQueueItem
+	err := r.db.Where("hive_uuid = ? AND status = ?", hiveUUID, status).Order("created asc").Find(&items).Error
+	return items, err
+}
+
+func (r gormModQueueRepo) Resolve(item *models.ModQueueItem) error {
+	return r.db.Model(item).Updates(map[string]interface{}{
+		"status":      item.Status,
+		"resolved_by": item.ResolvedBy,
+		"resolved":    item.Resolved,
+	}).Error
+}
+
+func (r gormAutomodRepo) GetConfig(hiveUUID string) (models.AutomodConfig, error) {
+	var config models.AutomodConfig
+	err := r.db.Where("hive_uuid = ?", hiveUUID).First(&config).Error
+	return config, translate(err)
+}
+
+func (r gormAutomodRepo) SaveConfig(config *models.AutomodConfig) error {
+	return r.db.Save(config).Error
+}
+
+func (r gormAutomodRepo) ListSubjects(hiveUUID string, limit int) ([]models.AutomodSubject, error) {
+	var subjects []models.AutomodSubject
+	err := r.db.Raw(`SELECT 'content' AS type, uuid, hive_uuid, uuid AS content_uuid, '' AS parent_uuid, account_uuid,
+			title, message, COALESCE(link, '') AS link, created
+		FROM contents WHERE hive_uuid = @hive AND deleted = false AND draft = false
+		UNION ALL
+		SELECT 'comment' AS type, c.uuid, t.hive_uuid, c.content_uuid, COALESCE(c.parent_uuid, '') AS parent_uuid,
+			c.account_uuid, '' AS title, c.message, '' AS link, c.created
+		FROM comments AS c JOIN contents AS t ON c.content_uuid = t.uuid
+		WHERE t.hive_uuid = @hive AND c.deleted = false AND t.draft = false
+		ORDER BY created DESC LIMIT @limit`, map[string]interface{}{"hive": hiveUUID, "limit": limit}).
+		Scan(&subjects).Error
+	return subjects, err
+}
+
+func (r gormSpamRepo) CountDuplicates(itemType string, message string, hiveUUID string, since time.Time) (int64, error) {
+	var count int64
+	query := r.db.Table("contents AS t").Where("t.message = ? AND t.created > ?", message, since)
+	if itemType == "comment" {
+		query = r.db.Table("comments AS m").Joins("JOIN contents AS t ON t.uuid = m.content_uuid").
+			Where("m.message = ? AND m.created > ?", message, since)
+	}
+	err := query.Where("t.hive_uuid <> ?", hiveUUID).Count(&count).Error
+	return count, err
+}
+
+func (r gormSpamRepo) CountRecent(accountUUID string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Raw(`SELECT (SELECT COUNT(*) FROM contents WHERE account_uuid = ? AND created > ?) +
+		(SELECT COUNT(*) FROM comments WHERE account_uuid = ? AND created > ?)`, accountUUID, since, accountUUID, since).Scan(&count).Error
+	return count, err
+}
+
+func (r gormSpamRepo) ListDecisions() ([]models.ModDecision, error) {
+	var decisions []models.ModDecision
+	err := r.db.Raw(`SELECT m.id, m.item_type, m.status, m.source, m.reason, t.title, t.message, COALESCE(t.link, '') AS link
+		FROM mod_queue_items m JOIN contents t ON m.item_type = 'content' AND m.item_uuid = t.uuid
+		WHERE m.status IN @decided
+		UNION ALL
+		SELECT m.id, m.item_type, m.status, m.source, m.reason, '' AS title, c.message, '' AS link
+		FROM mod_queue_items m JOIN comments c ON m.item_type = 'comment' AND m.item_uuid = c.uuid
+		WHERE m.status IN @decided
+		ORDER BY id`, map[string]interface{}{"decided": []string{"approved", "removed"}}).
+		Scan(&decisions).Error
+	return decisions, err
+}
@@ -0,0 +1,108 @@
+package repository_test
+
+import (
+	"encoding/json"
+	"example/hivemind-be/apitest"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func testEvent(aggregateUUID string) models.Event {
+	now := pq.NullTime{Time: time.Now(), Valid: true}
+	return models.Event{
+		UUID:          uuid.NewString(),
+		Type:          "test.happened",
+		AggregateType: "content",
+		AggregateUUID: aggregateUUID,
+		Payload:       json.RawMessage(`{}`),
+		Created:       now,
+		NextAttempt:   now,
+	}
+}
+
+func TestEventsOfAnAggregateAreAppendedInCommitOrder(t *testing.T) {
+	gdb := apitest.Database(t)
+	aggregate := uuid.NewString()
+	t.Cleanup(func() { gdb.Where("aggregate_uuid = ?", aggregate).Delete(&models.Event{}) })
+
+	first := gdb.Begin()
+	defer first.Rollback()
+	earlier := testEvent(aggregate)
+	if err := repository.NewGormRepos(first).Events.Append(&earlier); err != nil {
+		t.Fatalf("appending the first event: %v", err)
+	}
+
+	later := testEvent(aggregate)
+	appended := make(chan error, 1)
+	go func() {
+		appended <- repository.NewGormRepos(gdb).Tx.Transaction(func(tx repository.Repos) error {
+			return tx.Events.Append(&later)
+		})
+	}()
+
+	select {
+	case err := <-appended:
+		t.Fatalf("the second event was appended before the first committed: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if err := first.Commit().Error; err != nil {
+		t.Fatalf("committing the first event: %v", err)
+	}
+	if err := <-appended; err != nil {
+		t.Fatalf("appending the second event: %v", err)
+	}
+	if earlier.ID >= later.ID {
+		t.Errorf("IDs = %d then %d, want them in commit order", earlier.ID, later.ID)
+	}
+}
+
+func TestListSubjectsTakesTheNewestPublishedPostsAndCommentsTogether(t *testing.T) {
+	repos := repository.NewGormRepos(apitest.Database(t))
+	owner, _ := apitest.Account(t, repos, "subjects-"+uuid.NewString()[:8])
+	hive := apitest.Hive(t, repos, owner, "subjects-"+uuid.NewString()[:8])
+
+	start := time.Now().Add(-time.Hour)
+	at := func(minutes int) pq.NullTime {
+		return pq.NullTime{Time: start.Add(time.Duration(minutes) * time.Minute), Valid: true}
+	}
+	post := func(title string, draft bool, minutes int) models.Content {
+		content := models.Content{UUID: uuid.NewString(), HiveUUID: hive.UUID, AccountUUID: owner.UUID, Author: owner.Username,
+			Title: title, Message: title, Draft: draft, Created: at(minutes)}
+		if err := repos.Contents.Create(&content); err != nil {
+			t.Fatalf("creating %s: %v", title, err)
+		}
+		return content
+	}
+	older := post("older", false, 0)
+	newer := post("newer", false, 2)
+	post("draft", true, 4)
+	for _, minutes := range []int{1, 3} {
+		comment := models.Comment{UUID: uuid.NewString(), ContentUUID: older.UUID, AccountUUID: owner.UUID, Author: owner.Username,
+			Message: "comment", Created: at(minutes)}
+		if err := repos.Comments.Create(&comment); err != nil {
+			t.Fatalf("creating a comment: %v", err)
+		}
+	}
+
+	subjects, err := repos.Automod.ListSubjects(hive.UUID, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, subject := range subjects {
+		got = append(got, subject.Type+" "+subject.Message)
+	}
+	want := []string{"comment comment", "content newer", "comment comment"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListSubjects = %q, want %q", got, want)
+	}
+	if subjects[1].UUID != newer.UUID {
+		t.Errorf("second subject = %s, want the newer post %s", subjects[1].UUID, newer.UUID)
+	}
+}
@@ -0,0 +1,1600 @@
+package repository
+
+import (
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// memoryStore holds every table of the in-memory repositories behind one lock, so the repositories see
+// each other's writes the way tables in one database do.
+type memoryStore struct {
+	mu sync.Mutex
+	memoryTables
+}
+
+type memoryTables struct {
+	accounts      []models.Account
+	hives         []models.Hive
+	contents      []models.Content
+	comments      []models.Comment
+	contentVotes  []models.ContentVote
+	commentVotes  []models.CommentVote
+	events        []models.Event
+	members       []models.HiveMember
+	mentions      []models.Mention
+	revisions     []models.Revision
+	webhooks      []models.Webhook
+	deliveries    []models.WebhookDelivery
+	jobs          []models.Job
+	schedules     []models.JobSchedule
+	notifications []models.Notification
+	preferences   []models.NotificationPreference
+	bans          []models.Ban
+	appeals       []models.Appeal
+	appealEvents  []models.AppealEvent
+	reports       []models.Report
+	modQueue      []models.ModQueueItem
+	automod       []models.AutomodConfig
+}
+
+// clone copies every table, so writes to the store leave the copy as it was.
+func (t memoryTables) clone() memoryTables {
+	return memoryTables{
+		accounts:      rows(t.accounts),
+		hives:         rows(t.hives),
+		contents:      rows(t.contents),
+		comments:      rows(t.comments),
+		contentVotes:  rows(t.contentVotes),
+		commentVotes:  rows(t.commentVotes),
+		events:        rows(t.events),
+		members:       rows(t.members),
+		mentions:      rows(t.mentions),
+		revisions:     rows(t.revisions),
+		webhooks:      rows(t.webhooks),
+		deliveries:    rows(t.deliveries),
+		jobs:          rows(t.jobs),
+		schedules:     rows(t.schedules),
+		notifications: rows(t.notifications),
+		preferences:   rows(t.preferences),
+		bans:          rows(t.bans),
+		appeals:       rows(t.appeals),
+		appealEvents:  rows(t.appealEvents),
+		reports:       rows(t.reports),
+		modQueue:      rows(t.modQueue),
+		automod:       rows(t.automod),
+	}
+}
+
+func rows[T any](table []T) []T {
+	return append([]T(nil), table...)
+}
+
+type memoryAccountRepo struct{ store *memoryStore }
+type memoryHiveRepo struct{ store *memoryStore }
+type memoryContentRepo struct{ store *memoryStore }
+type memoryCommentRepo struct{ store *memoryStore }
+type memoryVoteRepo struct{ store *memoryStore }
+type memoryMentionRepo struct{ store *memoryStore }
+type memoryRevisionRepo struct{ store *memoryStore }
+type memoryEventRepo struct{ store *memoryStore }
+type memoryWebhookRepo struct{ store *memoryStore }
+type memoryJobRepo struct{ store *memoryStore }
+type memoryNotificationRepo struct{ store *memoryStore }
+type memoryBanRepo struct{ store *memoryStore }
+type memoryAppealRepo struct{ store *memoryStore }
+type memoryReportRepo struct{ store *memoryStore }
+type memoryModQueueRepo struct{ store *memoryStore }
+type memoryAutomodRepo struct{ store *memoryStore }
+type memorySpamRepo struct{ store *memoryStore }
+
+// memoryTransactor runs fn against the same repositories and puts every table back the way it was when fn
+// returns an error. Transactions are not isolated from writes made outside them while they run.
+type memoryTransactor struct {
+	repos *Repos
+	store *memoryStore
+}
+
+// NewMemoryRepos returns repositories that keep everything in memory. They are meant for tests.
+func NewMemoryRepos() Repos {
+	store := &memoryStore{}
+	repos := &Repos{
+		Accounts:      memoryAccountRepo{store},
+		Hives:         memoryHiveRepo{store},
+		Contents:      memoryContentRepo{store},
+		Comments:      memoryCommentRepo{store},
+		Votes:         memoryVoteRepo{store},
+		Mentions:      memoryMentionRepo{store},
+		Revisions:     memoryRevisionRepo{store},
+		Events:        memoryEventRepo{store},
+		Webhooks:      memoryWebhookRepo{store},
+		Jobs:          memoryJobRepo{store},
+		Notifications: memoryNotificationRepo{store},
+		Bans:          memoryBanRepo{store},
+		Appeals:       memoryAppealRepo{store},
+		Reports:       memoryReportRepo{store},
+		ModQueue:      memoryModQueueRepo{store},
+		Automod:       memoryAutomodRepo{store},
+		Spam:          memorySpamRepo{store},
+	}
+	repos.Tx = memoryTransactor{repos, store}
+	return *repos
+}
+
+func (t memoryTransactor) Transaction(fn func(tx Repos) error) error {
+	t.store.mu.Lock()
+	saved := t.store.memoryTables.clone()
+	t.store.mu.Unlock()
+
+	if err := fn(*t.repos); err != nil {
+		t.store.mu.Lock()
+		t.store.memoryTables = saved
+		t.store.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// visible reports whether a row created by authorUUID may be shown to the viewer. Must hold the lock.
+func (store *memoryStore) visible(authorUUID string, viewerUUID string) bool {
+	if authorUUID == viewerUUID {
+		return true
+	}
+	for _, account := range store.accounts {
+		if account.UUID == authorUUID {
+			return !account.Shadowbanned
+		}
+	}
+	return true
+}
+
+func (r memoryAccountRepo) Create(account *models.Account) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	account.ID = int32(len(r.store.accounts) + 1)
+	r.store.accounts = append(r.store.accounts, *account)
+	return nil
+}
+
+func (r memoryAccountRepo) GetByUUID(uuid string) (models.Account, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, account := range r.store.accounts {
+		if account.UUID == uuid {
+			return account, nil
+		}
+	}
+	return models.Account{}, ErrNotFound
+}
+
+func (r memoryAccountRepo) GetByEmail(email string) (models.Account, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, account := range r.store.accounts {
+		if account.Email == email {
+			return account, nil
+		}
+	}
+	return models.Account{}, ErrNotFound
+}
+
+func (r memoryAccountRepo) ListByUsernames(usernames []string) ([]models.Account, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var accounts []models.Account
+	for _, account := range r.store.accounts {
+		if contains(usernames, account.Username) {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+func (r memoryAccountRepo) Save(account *models.Account) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.accounts {
+		if r.store.accounts[i].ID == account.ID {
+			r.store.accounts[i] = *account
+			return nil
+		}
+	}
+	account.ID = int32(len(r.store.accounts) + 1)
+	r.store.accounts = append(r.store.accounts, *account)
+	return nil
+}
+
+func (r memoryAccountRepo) Karma(accountUUID string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var karma int64
+	for _, content := range r.store.contents {
+		if content.AccountUUID == accountUUID {
+			karma += int64(content.Upvote - content.Downvote)
+		}
+	}
+	for _, comment := range r.store.comments {
+		if comment.AccountUUID == accountUUID {
+			karma += int64(comment.Upvote - comment.Downvote)
+		}
+	}
+	return karma, nil
+}
+
+func (r memoryAccountRepo) IsShadowbanned(accountUUID string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, account := range r.store.accounts {
+		if account.UUID == accountUUID {
+			return account.Shadowbanned, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryAccountRepo) SetBanned(uuid string, banned bool) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.accounts {
+		if r.store.accounts[i].UUID == uuid {
+			r.store.accounts[i].Banned = banned
+		}
+	}
+	return nil
+}
+
+func (r memoryAccountRepo) SetShadowbanned(uuid string, shadowbanned bool) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.accounts {
+		if r.store.accounts[i].UUID == uuid && r.store.accounts[i].Shadowbanned != shadowbanned {
+			r.store.accounts[i].Shadowbanned = shadowbanned
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryAccountRepo) ShadowbanReport(since time.Time) ([]models.ShadowbanActivity, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var report []models.ShadowbanActivity
+	for _, account := range r.store.accounts {
+		if !account.Shadowbanned {
+			continue
+		}
+		activity := models.ShadowbanActivity{Username: account.Username, UUID: account.UUID}
+		active := func(created pq.NullTime) {
+			if created.Valid && (!activity.LastActive.Valid || created.Time.After(activity.LastActive.Time)) {
+				activity.LastActive = created
+			}
+		}
+		for _, content := range r.store.contents {
+			if content.AccountUUID == account.UUID {
+				active(content.Created)
+				if content.Created.Time.After(since) {
+					activity.Content++
+				}
+			}
+		}
+		for _, comment := range r.store.comments {
+			if comment.AccountUUID == account.UUID {
+				active(comment.Created)
+				if comment.Created.Time.After(since) {
+					activity.Comments++
+				}
+			}
+		}
+		for _, vote := range r.store.contentVotes {
+			if vote.AccountUUID == account.UUID && vote.LastEdited.Time.After(since) && (vote.Upvote || vote.Downvote) {
+				activity.Votes++
+			}
+		}
+		for _, vote := range r.store.commentVotes {
+			if vote.AccountUUID == account.UUID && vote.LastEdited.Time.After(since) && (vote.Upvote || vote.Downvote) {
+				activity.Votes++
+			}
+		}
+		report = append(report, activity)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Username < report[j].Username })
+	return report, nil
+}
+
+func (r memoryHiveRepo) Create(hive *models.Hive) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	hive.ID = int32(len(r.store.hives) + 1)
+	r.store.hives = append(r.store.hives, *hive)
+	return nil
+}
+
+func (r memoryHiveRepo) List() ([]models.Hive, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return append([]models.Hive{}, r.store.hives...), nil
+}
+
+func (r memoryHiveRepo) GetByUUID(uuid string) (models.Hive, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, hive := range r.store.hives {
+		if hive.UUID == uuid {
+			return hive, nil
+		}
+	}
+	return models.Hive{}, ErrNotFound
+}
+
+func (r memoryHiveRepo) GetForUpdate(uuid string) (models.Hive, error) {
+	return r.GetByUUID(uuid)
+}
+
+func (r memoryHiveRepo) GetByName(name string) (models.Hive, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, hive := range r.store.hives {
+		if hive.Name == name {
+			return hive, nil
+		}
+	}
+	return models.Hive{}, ErrNotFound
+}
+
+func (r memoryHiveRepo) ListByNames(names []string) ([]models.Hive, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var hives []models.Hive
+	for _, hive := range r.store.hives {
+		if contains(names, hive.Name) {
+			hives = append(hives, hive)
+		}
+	}
+	return hives, nil
+}
+
+func (r memoryHiveRepo) Save(hive *models.Hive) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.hives {
+		if r.store.hives[i].ID == hive.ID {
+			r.store.hives[i] = *hive
+			return nil
+		}
+	}
+	hive.ID = int32(len(r.store.hives) + 1)
+	r.store.hives = append(r.store.hives, *hive)
+	return nil
+}
+
+func (r memoryHiveRepo) AddCounts(uuid string, counts Counts) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.hives {
+		if r.store.hives[i].UUID == uuid {
+			hive := &r.store.hives[i]
+			hive.MemberCount += counts.Members
+			hive.TotalContent += counts.Content
+			hive.TotalComments += counts.Comments
+			hive.TotalUpvotes += counts.Upvotes
+			hive.TotalDownvotes += counts.Downvotes
+		}
+	}
+	return nil
+}
+
+func (r memoryHiveRepo) GetMember(hiveUUID string, accountUUID string) (models.HiveMember, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, member := range r.store.members {
+		if member.HiveUUID == hiveUUID && member.AccountUUID == accountUUID {
+			return member, nil
+		}
+	}
+	return models.HiveMember{}, ErrNotFound
+}
+
+func (r memoryHiveRepo) AddMember(member *models.HiveMember) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, existing := range r.store.members {
+		if existing.HiveUUID == member.HiveUUID && existing.AccountUUID == member.AccountUUID {
+			return apperr.New(apperr.Conflict, "Already exists.")
+		}
+	}
+	member.ID = int32(len(r.store.members) + 1)
+	r.store.members = append(r.store.members, *member)
+	return nil
+}
+
+func (r memoryHiveRepo) RemoveMember(member models.HiveMember) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.members {
+		if r.store.members[i].ID == member.ID {
+			r.store.members = append(r.store.members[:i], r.store.members[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r memoryContentRepo) Create(content *models.Content) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	content.ID = int32(len(r.store.contents) + 1)
+	r.store.contents = append(r.store.contents, *content)
+	return nil
+}
+
+func (r memoryContentRepo) GetByUUID(uuid string) (models.Content, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, content := range r.store.contents {
+		if content.UUID == uuid {
+			return content, nil
+		}
+	}
+	return models.Content{}, ErrNotFound
+}
+
+func (r memoryContentRepo) GetForUpdate(uuid string) (models.Content, error) {
+	return r.GetByUUID(uuid)
+}
+
+func (r memoryContentRepo) GetVisibleByID(id int, viewerUUID string) (models.Content, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, content := range r.store.contents {
+		if int(content.ID) == id && r.store.visible(content.AccountUUID, viewerUUID) && (!content.Draft || content.AccountUUID == viewerUUID) {
+			return content, nil
+		}
+	}
+	return models.Content{}, ErrNotFound
+}
+
+func (r memoryContentRepo) GetVisibleByUUID(uuid string, viewerUUID string) (models.Content, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, content := range r.store.contents {
+		if content.UUID == uuid && r.store.visible(content.AccountUUID, viewerUUID) && (!content.Draft || content.AccountUUID == viewerUUID) {
+			return content, nil
+		}
+	}
+	return models.Content{}, ErrNotFound
+}
+
+func (r memoryContentRepo) ListVisible(viewerUUID string) ([]models.Content, error) {
+	return r.list(func(content models.Content) bool { return true }, viewerUUID), nil
+}
+
+func (r memoryContentRepo) ListVisibleByHive(hiveUUID string, viewerUUID string) ([]models.Content, error) {
+	return r.list(func(content models.Content) bool { return content.HiveUUID == hiveUUID }, viewerUUID), nil
+}
+
+func (r memoryContentRepo) ListDrafts(accountUUID string) ([]models.Content, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var drafts []models.Content
+	for i := len(r.store.contents) - 1; i >= 0; i-- {
+		content := r.store.contents[i]
+		if content.AccountUUID == accountUUID && content.Draft && !content.Deleted {
+			drafts = append(drafts, content)
+		}
+	}
+	return drafts, nil
+}
+
+func (r memoryContentRepo) list(match func(models.Content) bool, viewerUUID string) []models.Content {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var contents []models.Content
+	for _, content := range r.store.contents {
+		if !content.Removed && !content.Draft && match(content) && r.store.visible(content.AccountUUID, viewerUUID) {
+			contents = append(contents, content)
+		}
+	}
+	return contents
+}
+
+func (r memoryContentRepo) Save(content *models.Content) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.contents {
+		if r.store.contents[i].ID == content.ID {
+			r.store.contents[i] = *content
+			return nil
+		}
+	}
+	content.ID = int32(len(r.store.contents) + 1)
+	r.store.contents = append(r.store.contents, *content)
+	return nil
+}
+
+func (r memoryContentRepo) AddCounts(uuid string, counts Counts) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.contents {
+		if r.store.contents[i].UUID == uuid {
+			content := &r.store.contents[i]
+			content.Upvote += counts.Upvotes
+			content.Downvote += counts.Downvotes
+			content.CommentCount += counts.Comments
+		}
+	}
+	return nil
+}
+
+func (r memoryCommentRepo) Create(comment *models.Comment) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	comment.ID = int32(len(r.store.comments) + 1)
+	r.store.comments = append(r.store.comments, *comment)
+	return nil
+}
+
+func (r memoryCommentRepo) GetByUUID(uuid string) (models.Comment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, comment := range r.store.comments {
+		if comment.UUID == uuid {
+			return comment, nil
+		}
+	}
+	return models.Comment{}, ErrNotFound
+}
+
+func (r memoryCommentRepo) GetForUpdate(uuid string) (models.Comment, error) {
+	return r.GetByUUID(uuid)
+}
+
+func (r memoryCommentRepo) GetVisibleByUUID(uuid string, viewerUUID string) (models.Comment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, comment := range r.store.comments {
+		if comment.UUID == uuid && r.store.visible(comment.AccountUUID, viewerUUID) {
+			return comment, nil
+		}
+	}
+	return models.Comment{}, ErrNotFound
+}
+
+func (r memoryCommentRepo) ListByContent(contentUUID string) ([]models.Comment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var comments []models.Comment
+	for _, comment := range r.store.comments {
+		if comment.ContentUUID == contentUUID {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+func (r memoryCommentRepo) ListVisibleByContent(contentUUID string, viewerUUID string) ([]models.Comment, error) {
+	comments := r.list(func(comment models.Comment) bool { return comment.ContentUUID == contentUUID }, viewerUUID)
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].Created.Time.After(comments[j].Created.Time)
+	})
+	return comments, nil
+}
+
+func (r memoryCommentRepo) ListVisibleReplies(parentUUID string, viewerUUID string) ([]models.Comment, error) {
+	return r.list(func(comment models.Comment) bool { return comment.ParentUUID == parentUUID }, viewerUUID), nil
+}
+
+func (r memoryCommentRepo) list(match func(models.Comment) bool, viewerUUID string) []models.Comment {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var comments []models.Comment
+	for _, comment := range r.store.comments {
+		if match(comment) && r.store.visible(comment.AccountUUID, viewerUUID) {
+			comments = append(comments, comment)
+		}
+	}
+	return comments
+}
+
+func (r memoryCommentRepo) Save(comment *models.Comment) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.comments {
+		if r.store.comments[i].ID == comment.ID {
+			r.store.comments[i] = *comment
+			return nil
+		}
+	}
+	comment.ID = int32(len(r.store.comments) + 1)
+	r.store.comments = append(r.store.comments, *comment)
+	return nil
+}
+
+func (r memoryCommentRepo) AddCounts(uuid string, counts Counts) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.comments {
+		if r.store.comments[i].UUID == uuid {
+			r.store.comments[i].Upvote += counts.Upvotes
+			r.store.comments[i].Downvote += counts.Downvotes
+		}
+	}
+	return nil
+}
+
+func (r memoryVoteRepo) GetContentVote(accountUUID string, contentUUID string) (models.ContentVote, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, vote := range r.store.contentVotes {
+		if vote.AccountUUID == accountUUID && vote.ContentUUID == contentUUID {
+			return vote, nil
+		}
+	}
+	return models.ContentVote{}, ErrNotFound
+}
+
+func (r memoryVoteRepo) SaveContentVote(vote *models.ContentVote) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.contentVotes {
+		if r.store.contentVotes[i].ID == vote.ID {
+			r.store.contentVotes[i] = *vote
+			return nil
+		}
+	}
+	vote.ID = int32(len(r.store.contentVotes) + 1)
+	r.store.contentVotes = append(r.store.contentVotes, *vote)
+	return nil
+}
+
+func (r memoryVoteRepo) ListContentVotesByAccount(accountUUID string) ([]models.ContentVote, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var votes []models.ContentVote
+	for _, vote := range r.store.contentVotes {
+		if vote.AccountUUID == accountUUID {
+			votes = append(votes, vote)
+		}
+	}
+	return votes, nil
+}
+
+func (r memoryVoteRepo) GetCommentVote(accountUUID string, commentUUID string) (models.CommentVote, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, vote := range r.store.commentVotes {
+		if vote.AccountUUID == accountUUID && vote.CommentUUID == commentUUID {
+			return vote, nil
+		}
+	}
+	return models.CommentVote{}, ErrNotFound
+}
+
+func (r memoryVoteRepo) SaveCommentVote(vote *models.CommentVote) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.commentVotes {
+		if r.store.commentVotes[i].ID == vote.ID {
+			r.store.commentVotes[i] = *vote
+			return nil
+		}
+	}
+	vote.ID = int32(len(r.store.commentVotes) + 1)
+	r.store.commentVotes = append(r.store.commentVotes, *vote)
+	return nil
+}
+
+func (r memoryVoteRepo) ListCommentVoteGroupsByAccount(accountUUID string) ([]models.CommentVoteGroup, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var results []models.CommentVoteGroup
+	for _, vote := range r.store.commentVotes {
+		if vote.AccountUUID != accountUUID {
+			continue
+		}
+		for _, comment := range r.store.comments {
+			if comment.UUID == vote.CommentUUID {
+				results = append(results, models.CommentVoteGroup{
+					Upvote:      vote.Upvote,
+					Downvote:    vote.Downvote,
+					CommentUuid: vote.CommentUUID,
+					ContentUuid: comment.ContentUUID,
+				})
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].ContentUuid < results[j].ContentUuid
+	})
+	return results, nil
+}
+
+func (r memoryMentionRepo) Replace(sourceType string, sourceUUID string, mentions []models.Mention) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	kept := r.store.mentions[:0]
+	for _, mention := range r.store.mentions {
+		if mention.SourceType != sourceType || mention.SourceUUID != sourceUUID {
+			kept = append(kept, mention)
+		}
+	}
+	r.store.mentions = kept
+	for _, mention := range mentions {
+		mention.ID = int64(len(r.store.mentions) + 1)
+		mention.SourceType = sourceType
+		mention.SourceUUID = sourceUUID
+		r.store.mentions = append(r.store.mentions, mention)
+	}
+	return nil
+}
+
+func (r memoryMentionRepo) ListBySources(sourceType string, sourceUUIDs []string) ([]models.Mention, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var mentions []models.Mention
+	for _, mention := range r.store.mentions {
+		if mention.SourceType == sourceType && contains(sourceUUIDs, mention.SourceUUID) {
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions, nil
+}
+
+func (r memoryRevisionRepo) Append(revision *models.Revision) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	revision.Version = 1
+	for _, existing := range r.store.revisions {
+		if existing.ItemType == revision.ItemType && existing.ItemUUID == revision.ItemUUID && existing.Version >= revision.Version {
+			revision.Version = existing.Version + 1
+		}
+	}
+	revision.ID = int64(len(r.store.revisions) + 1)
+	r.store.revisions = append(r.store.revisions, *revision)
+	return nil
+}
+
+func (r memoryRevisionRepo) List(itemType string, itemUUID string) ([]models.Revision, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var revisions []models.Revision
+	for _, revision := range r.store.revisions {
+		if revision.ItemType == itemType && revision.ItemUUID == itemUUID {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions, nil
+}
+
+func (r memoryEventRepo) Append(event *models.Event) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	event.ID = int64(len(r.store.events) + 1)
+	r.store.events = append(r.store.events, *event)
+	return nil
+}
+
+func (r memoryEventRepo) ListDue(now time.Time, limit int) ([]models.Event, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var events []models.Event
+	pending := map[string]bool{}
+	for _, event := range r.store.events {
+		if event.Dispatched.Valid {
+			continue
+		}
+		if !pending[event.AggregateUUID] && !event.NextAttempt.Time.After(now) && len(events) < limit {
+			events = append(events, event)
+		}
+		pending[event.AggregateUUID] = true
+	}
+	return events, nil
+}
+
+func (r memoryEventRepo) SaveAttempt(event *models.Event) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.events {
+		if r.store.events[i].ID == event.ID {
+			stored := &r.store.events[i]
+			stored.Attempts = event.Attempts
+			stored.NextAttempt = event.NextAttempt
+			stored.LastError = event.LastError
+			stored.Dispatched = event.Dispatched
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r memoryWebhookRepo) Create(hook *models.Webhook) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	hook.ID = int32(len(r.store.webhooks) + 1)
+	r.store.webhooks = append(r.store.webhooks, *hook)
+	return nil
+}
+
+func (r memoryWebhookRepo) GetByUUID(uuid string) (models.Webhook, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, hook := range r.store.webhooks {
+		if hook.UUID == uuid {
+			return hook, nil
+		}
+	}
+	return models.Webhook{}, ErrNotFound
+}
+
+func (r memoryWebhookRepo) ListByHive(hiveUUID string) ([]models.Webhook, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var hooks []models.Webhook
+	for _, hook := range r.store.webhooks {
+		if hook.HiveUUID == hiveUUID {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
+func (r memoryWebhookRepo) CountByHive(hiveUUID string) (int64, error) {
+	hooks, err := r.ListByHive(hiveUUID)
+	return int64(len(hooks)), err
+}
+
+func (r memoryWebhookRepo) Update(hook *models.Webhook) error {
+	return r.change(hook.ID, func(stored *models.Webhook) {
+		stored.URL = hook.URL
+		stored.Events = hook.Events
+		stored.Active = hook.Active
+		stored.ConsecutiveFailures = hook.ConsecutiveFailures
+		stored.DisabledReason = hook.DisabledReason
+		stored.Secret = hook.Secret
+		stored.LastEdited = hook.LastEdited
+	})
+}
+
+func (r memoryWebhookRepo) Delete(hook models.Webhook) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.webhooks {
+		if r.store.webhooks[i].ID == hook.ID {
+			r.store.webhooks = append(r.store.webhooks[:i], r.store.webhooks[i+1:]...)
+			break
+		}
+	}
+	kept := r.store.deliveries[:0]
+	for _, delivery := range r.store.deliveries {
+		if delivery.WebhookUUID != hook.UUID {
+			kept = append(kept, delivery)
+		}
+	}
+	r.store.deliveries = kept
+	return nil
+}
+
+func (r memoryWebhookRepo) AddFailure(hook models.Webhook) (int32, error) {
+	var failures int32
+	err := r.change(hook.ID, func(stored *models.Webhook) {
+		stored.ConsecutiveFailures++
+		failures = stored.ConsecutiveFailures
+	})
+	return failures, err
+}
+
+func (r memoryWebhookRepo) ResetFailures(hook models.Webhook) error {
+	return r.change(hook.ID, func(stored *models.Webhook) {
+		stored.ConsecutiveFailures = 0
+	})
+}
+
+func (r memoryWebhookRepo) Disable(hook models.Webhook, reason string) error {
+	return r.change(hook.ID, func(stored *models.Webhook) {
+		stored.Active = false
+		stored.DisabledReason = reason
+	})
+}
+
+// change applies fn to the stored webhook with the ID, if there is one.
+func (r memoryWebhookRepo) change(id int32, fn func(stored *models.Webhook)) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.webhooks {
+		if r.store.webhooks[i].ID == id {
+			fn(&r.store.webhooks[i])
+		}
+	}
+	return nil
+}
+
+func (r memoryWebhookRepo) CreateDelivery(delivery *models.WebhookDelivery) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	delivery.ID = int64(len(r.store.deliveries) + 1)
+	r.store.deliveries = append(r.store.deliveries, *delivery)
+	return nil
+}
+
+func (r memoryWebhookRepo) GetDelivery(uuid string) (models.WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, delivery := range r.store.deliveries {
+		if delivery.UUID == uuid {
+			return delivery, nil
+		}
+	}
+	return models.WebhookDelivery{}, ErrNotFound
+}
+
+func (r memoryWebhookRepo) ListDeliveries(webhookUUID string, status string, limit int) ([]models.WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var deliveries []models.WebhookDelivery
+	for i := len(r.store.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		delivery := r.store.deliveries[i]
+		if delivery.WebhookUUID == webhookUUID && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r memoryWebhookRepo) SaveDelivery(delivery *models.WebhookDelivery) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.deliveries {
+		if r.store.deliveries[i].ID == delivery.ID {
+			r.store.deliveries[i] = *delivery
+		}
+	}
+	return nil
+}
+
+func (r memoryJobRepo) Create(job *models.Job) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	job.ID = int64(len(r.store.jobs) + 1)
+	r.store.jobs = append(r.store.jobs, *job)
+	return nil
+}
+
+func (r memoryJobRepo) GetByUUID(uuid string) (models.Job, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, job := range r.store.jobs {
+		if job.UUID == uuid {
+			return job, nil
+		}
+	}
+	return models.Job{}, ErrNotFound
+}
+
+func (r memoryJobRepo) List(status string, kind string, limit int) ([]models.Job, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var jobs []models.Job
+	for i := len(r.store.jobs) - 1; i >= 0 && len(jobs) < limit; i-- {
+		job := r.store.jobs[i]
+		if (status == "" || job.Status == status) && (kind == "" || job.Kind == kind) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (r memoryJobRepo) Stats() ([]models.JobStats, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var stats []models.JobStats
+	counted := map[[2]string]int{}
+	for _, job := range r.store.jobs {
+		key := [2]string{job.Kind, job.Status}
+		if i, ok := counted[key]; ok {
+			stats[i].Jobs++
+			continue
+		}
+		counted[key] = len(stats)
+		stats = append(stats, models.JobStats{Kind: job.Kind, Status: job.Status, Jobs: 1})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Kind != stats[j].Kind {
+			return stats[i].Kind < stats[j].Kind
+		}
+		return stats[i].Status < stats[j].Status
+	})
+	return stats, nil
+}
+
+func (r memoryJobRepo) ListSchedules() ([]models.JobSchedule, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	schedules := append([]models.JobSchedule{}, r.store.schedules...)
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].Name < schedules[j].Name
+	})
+	return schedules, nil
+}
+
+func (r memoryJobRepo) Requeue(job *models.Job) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.jobs {
+		if r.store.jobs[i].ID == job.ID && r.store.jobs[i].Status == "dead" {
+			r.store.jobs[i].Status = job.Status
+			r.store.jobs[i].Attempts = job.Attempts
+			r.store.jobs[i].RunAt = job.RunAt
+			r.store.jobs[i].Finished = job.Finished
+		}
+	}
+	return nil
+}
+
+func (r memoryVoteRepo) AddAccountVotes(accountUUID string, sign int32) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	hives := map[string]int{}
+	for i := range r.store.hives {
+		hives[r.store.hives[i].UUID] = i
+	}
+	contents := map[string]int{}
+	for i := range r.store.contents {
+		contents[r.store.contents[i].UUID] = i
+	}
+	addVote := func(up *int32, down *int32, vote bool, upvote bool) {
+		if !vote {
+			return
+		}
+		if upvote {
+			*up += sign
+		} else {
+			*down += sign
+		}
+	}
+	for _, vote := range r.store.contentVotes {
+		i, ok := contents[vote.ContentUUID]
+		if vote.AccountUUID != accountUUID || !ok {
+			continue
+		}
+		content := &r.store.contents[i]
+		addVote(&content.Upvote, &content.Downvote, vote.Upvote || vote.Downvote, vote.Upvote)
+		if h, ok := hives[content.HiveUUID]; ok && !content.Deleted {
+			hive := &r.store.hives[h]
+			addVote(&hive.TotalUpvotes, &hive.TotalDownvotes, vote.Upvote || vote.Downvote, vote.Upvote)
+		}
+	}
+	for _, vote := range r.store.commentVotes {
+		if vote.AccountUUID != accountUUID {
+			continue
+		}
+		for i := range r.store.comments {
+			comment := &r.store.comments[i]
+			if comment.UUID != vote.CommentUUID {
+				continue
+			}
+			addVote(&comment.Upvote, &comment.Downvote, vote.Upvote || vote.Downvote, vote.Upvote)
+			c, ok := contents[comment.ContentUUID]
+			if !ok || comment.Deleted || r.store.contents[c].Deleted {
+				continue
+			}
+			if h, ok := hives[r.store.contents[c].HiveUUID]; ok {
+				hive := &r.store.hives[h]
+				addVote(&hive.TotalUpvotes, &hive.TotalDownvotes, vote.Upvote || vote.Downvote, vote.Upvote)
+			}
+		}
+	}
+	return nil
+}
+
+func (r memoryNotificationRepo) Create(notification *models.Notification) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, existing := range r.store.notifications {
+		if existing.AccountUUID == notification.AccountUUID && existing.Type == notification.Type && existing.SourceUUID == notification.SourceUUID {
+			return false, nil
+		}
+	}
+	notification.ID = int64(len(r.store.notifications) + 1)
+	r.store.notifications = append(r.store.notifications, *notification)
+	return true, nil
+}
+
+func (r memoryNotificationRepo) IsEnabled(accountUUID string, notificationType string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, preference := range r.store.preferences {
+		if preference.AccountUUID == accountUUID && preference.Type == notificationType {
+			return preference.Enabled, nil
+		}
+	}
+	return true, nil
+}
+
+func (r memoryNotificationRepo) GetOwned(uuid string, accountUUID string) (models.Notification, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, notification := range r.store.notifications {
+		if notification.UUID == uuid && notification.AccountUUID == accountUUID {
+			return notification, nil
+		}
+	}
+	return models.Notification{}, ErrNotFound
+}
+
+func (r memoryNotificationRepo) List(accountUUID string, before int64, unread bool, limit int) ([]models.Notification, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var notifications []models.Notification
+	for i := len(r.store.notifications) - 1; i >= 0 && len(notifications) < limit; i-- {
+		notification := r.store.notifications[i]
+		if notification.AccountUUID == accountUUID && (before == 0 || notification.ID < before) && (!unread || !notification.Read) {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications, nil
+}
+
+func (r memoryNotificationRepo) CountUnread(accountUUID string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var unread int64
+	for _, notification := range r.store.notifications {
+		if notification.AccountUUID == accountUUID && !notification.Read {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+func (r memoryNotificationRepo) SetRead(notification *models.Notification) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.notifications {
+		if r.store.notifications[i].ID == notification.ID {
+			r.store.notifications[i].Read = notification.Read
+			r.store.notifications[i].ReadAt = notification.ReadAt
+		}
+	}
+	return nil
+}
+
+func (r memoryNotificationRepo) MarkAllRead(accountUUID string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var updated int64
+	for i := range r.store.notifications {
+		if r.store.notifications[i].AccountUUID == accountUUID && !r.store.notifications[i].Read {
+			r.store.notifications[i].Read = true
+			r.store.notifications[i].ReadAt = pq.NullTime{Time: time.Now(), Valid: true}
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+func (r memoryNotificationRepo) ListPreferences(accountUUID string) ([]models.NotificationPreference, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var preferences []models.NotificationPreference
+	for _, preference := range r.store.preferences {
+		if preference.AccountUUID == accountUUID {
+			preferences = append(preferences, preference)
+		}
+	}
+	return preferences, nil
+}
+
+func (r memoryNotificationRepo) SavePreferences(preferences []models.NotificationPreference) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, preference := range preferences {
+		replaced := false
+		for i := range r.store.preferences {
+			if r.store.preferences[i].AccountUUID == preference.AccountUUID && r.store.preferences[i].Type == preference.Type {
+				r.store.preferences[i].Enabled = preference.Enabled
+				replaced = true
+			}
+		}
+		if !replaced {
+			preference.ID = int32(len(r.store.preferences) + 1)
+			r.store.preferences = append(r.store.preferences, preference)
+		}
+	}
+	return nil
+}
+
+func (r memoryBanRepo) Create(ban *models.Ban) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	ban.ID = int32(len(r.store.bans) + 1)
+	r.store.bans = append(r.store.bans, *ban)
+	return nil
+}
+
+func (r memoryBanRepo) IsBanned(accountUUID string, hiveUUID string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, ban := range r.store.bans {
+		if ban.AccountUUID == accountUUID && ban.Active && (ban.HiveUUID == "" || ban.HiveUUID == hiveUUID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryBanRepo) GetByUUID(uuid string) (models.Ban, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, ban := range r.store.bans {
+		if ban.UUID == uuid {
+			return ban, nil
+		}
+	}
+	return models.Ban{}, ErrNotFound
+}
+
+func (r memoryBanRepo) GetForUpdate(uuid string) (models.Ban, error) {
+	return r.GetByUUID(uuid)
+}
+
+func (r memoryBanRepo) Save(ban *models.Ban) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.bans {
+		if r.store.bans[i].ID == ban.ID {
+			r.store.bans[i] = *ban
+		}
+	}
+	return nil
+}
+
+func (r memoryBanRepo) HasActive(accountUUID string, hiveUUID string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, ban := range r.store.bans {
+		if ban.AccountUUID == accountUUID && ban.Active && ban.HiveUUID == hiveUUID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryBanRepo) ListByHive(hiveUUID string) ([]models.Ban, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var bans []models.Ban
+	for i := len(r.store.bans) - 1; i >= 0; i-- {
+		if r.store.bans[i].HiveUUID == hiveUUID && hiveUUID != "" {
+			bans = append(bans, r.store.bans[i])
+		}
+	}
+	return bans, nil
+}
+
+func (r memoryBanRepo) ListByAccount(accountUUID string) ([]models.Ban, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var bans []models.Ban
+	for i := len(r.store.bans) - 1; i >= 0; i-- {
+		if r.store.bans[i].AccountUUID == accountUUID {
+			bans = append(bans, r.store.bans[i])
+		}
+	}
+	return bans, nil
+}
+
+func (r memoryAppealRepo) Create(appeal *models.Appeal) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, existing := range r.store.appeals {
+		if existing.BanUUID == appeal.BanUUID {
+			return apperr.New(apperr.Conflict, "Already exists.")
+		}
+	}
+	appeal.ID = int32(len(r.store.appeals) + 1)
+	r.store.appeals = append(r.store.appeals, *appeal)
+	return nil
+}
+
+func (r memoryAppealRepo) GetByUUID(uuid string) (models.Appeal, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, appeal := range r.store.appeals {
+		if appeal.UUID == uuid {
+			return appeal, nil
+		}
+	}
+	return models.Appeal{}, ErrNotFound
+}
+
+func (r memoryAppealRepo) GetForUpdate(uuid string) (models.Appeal, error) {
+	return r.GetByUUID(uuid)
+}
+
+func (r memoryAppealRepo) GetByBan(banUUID string) (models.Appeal, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, appeal := range r.store.appeals {
+		if appeal.BanUUID == banUUID {
+			return appeal, nil
+		}
+	}
+	return models.Appeal{}, ErrNotFound
+}
+
+func (r memoryAppealRepo) ListQueue(hiveUUID string, status string) ([]models.Appeal, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var appeals []models.Appeal
+	for _, appeal := range r.store.appeals {
+		if appeal.HiveUUID == hiveUUID && appeal.Status == status {
+			appeals = append(appeals, appeal)
+		}
+	}
+	return appeals, nil
+}
+
+func (r memoryAppealRepo) ListByAccount(accountUUID string) ([]models.Appeal, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var appeals []models.Appeal
+	for i := len(r.store.appeals) - 1; i >= 0; i-- {
+		if r.store.appeals[i].AccountUUID == accountUUID {
+			appeals = append(appeals, r.store.appeals[i])
+		}
+	}
+	return appeals, nil
+}
+
+func (r memoryAppealRepo) Save(appeal *models.Appeal) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.appeals {
+		if r.store.appeals[i].ID == appeal.ID {
+			r.store.appeals[i] = *appeal
+		}
+	}
+	return nil
+}
+
+func (r memoryAppealRepo) AddEvent(event *models.AppealEvent) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	event.ID = int32(len(r.store.appealEvents) + 1)
+	r.store.appealEvents = append(r.store.appealEvents, *event)
+	return nil
+}
+
+func (r memoryAppealRepo) ListEvents(appealUUID string) ([]models.AppealEvent, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var events []models.AppealEvent
+	for _, event := range r.store.appealEvents {
+		if event.AppealUUID == appealUUID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r memoryReportRepo) Create(report *models.Report) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, existing := range r.store.reports {
+		if existing.AccountUUID == report.AccountUUID && existing.ItemUUID == report.ItemUUID {
+			return apperr.New(apperr.Conflict, "Already exists.")
+		}
+	}
+	report.ID = int32(len(r.store.reports) + 1)
+	r.store.reports = append(r.store.reports, *report)
+	return nil
+}
+
+func (r memoryReportRepo) CountByItem(itemUUID string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var count int64
+	for _, report := range r.store.reports {
+		if report.ItemUUID == itemUUID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memoryModQueueRepo) Enqueue(item *models.ModQueueItem) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, existing := range r.store.modQueue {
+		if existing.ItemUUID == item.ItemUUID && existing.Status == "pending" {
+			return nil
+		}
+	}
+	item.ID = int32(len(r.store.modQueue) + 1)
+	r.store.modQueue = append(r.store.modQueue, *item)
+	return nil
+}
+
+func (r memoryModQueueRepo) GetByUUID(uuid string) (models.ModQueueItem, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, item := range r.store.modQueue {
+		if item.UUID == uuid {
+			return item, nil
+		}
+	}
+	return models.ModQueueItem{}, ErrNotFound
+}
+
+func (r memoryModQueueRepo) GetForUpdate(uuid string) (models.ModQueueItem, error) {
+	return r.GetByUUID(uuid)
+}
+
+func (r memoryModQueueRepo) List(hiveUUID string, status string) ([]models.ModQueueItem, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var items []models.ModQueueItem
+	for _, item := range r.store.modQueue {
+		if item.HiveUUID == hiveUUID && item.Status == status {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (r memoryModQueueRepo) Resolve(item *models.ModQueueItem) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.modQueue {
+		if r.store.modQueue[i].ID == item.ID {
+			r.store.modQueue[i].Status = item.Status
+			r.store.modQueue[i].ResolvedBy = item.ResolvedBy
+			r.store.modQueue[i].Resolved = item.Resolved
+		}
+	}
+	return nil
+}
+
+func (r memoryAutomodRepo) GetConfig(hiveUUID string) (models.AutomodConfig, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, config := range r.store.automod {
+		if config.HiveUUID == hiveUUID {
+			return config, nil
+		}
+	}
+	return models.AutomodConfig{}, ErrNotFound
+}
+
+func (r memoryAutomodRepo) SaveConfig(config *models.AutomodConfig) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.automod {
+		if r.store.automod[i].ID == config.ID {
+			r.store.automod[i] = *config
+			return nil
+		}
+	}
+	config.ID = int32(len(r.store.automod) + 1)
+	r.store.automod = append(r.store.automod, *config)
+	return nil
+}
+
+func (r memoryAutomodRepo) ListSubjects(hiveUUID string, limit int) ([]models.AutomodSubject, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	published := map[string]bool{}
+	var subjects []models.AutomodSubject
+	var created []time.Time
+	for _, content := range r.store.contents {
+		if content.HiveUUID != hiveUUID || content.Draft {
+			continue
+		}
+		published[content.UUID] = true
+		if !content.Deleted {
+			subjects = append(subjects, models.AutomodSubject{Type: "content", UUID: content.UUID, HiveUUID: hiveUUID, ContentUUID: content.UUID,
+				AccountUUID: content.AccountUUID, Title: content.Title, Message: content.Message, Link: content.Link})
+			created = append(created, content.Created.Time)
+		}
+	}
+	for _, comment := range r.store.comments {
+		if published[comment.ContentUUID] && !comment.Deleted {
+			subjects = append(subjects, models.AutomodSubject{Type: "comment", UUID: comment.UUID, HiveUUID: hiveUUID, ContentUUID: comment.ContentUUID,
+				ParentUUID: comment.ParentUUID, AccountUUID: comment.AccountUUID, Message: comment.Message})
+			created = append(created, comment.Created.Time)
+		}
+	}
+
+	order := make([]int, len(subjects))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return created[order[i]].After(created[order[j]]) })
+	newest := make([]models.AutomodSubject, 0, len(subjects))
+	for i := 0; i < len(order) && i < limit; i++ {
+		newest = append(newest, subjects[order[i]])
+	}
+	return newest, nil
+}
+
+func (r memorySpamRepo) CountDuplicates(itemType string, message string, hiveUUID string, since time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	hives := map[string]string{}
+	for _, content := range r.store.contents {
+		hives[content.UUID] = content.HiveUUID
+	}
+	var count int64
+	if itemType == "comment" {
+		for _, comment := range r.store.comments {
+			if comment.Message == message && comment.Created.Time.After(since) && hives[comment.ContentUUID] != hiveUUID {
+				count++
+			}
+		}
+		return count, nil
+	}
+	for _, content := range r.store.contents {
+		if content.Message == message && content.Created.Time.After(since) && content.HiveUUID != hiveUUID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memorySpamRepo) CountRecent(accountUUID string, since time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var count int64
+	for _, content := range r.store.contents {
+		if content.AccountUUID == accountUUID && content.Created.Time.After(since) {
+			count++
+		}
+	}
+	for _, comment := range r.store.comments {
+		if comment.AccountUUID == accountUUID && comment.Created.Time.After(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memorySpamRepo) ListDecisions() ([]models.ModDecision, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var decisions []models.ModDecision
+	for _, item := range r.store.modQueue {
+		if item.Status != "approved" && item.Status != "removed" {
+			continue
+		}
+		decision := models.ModDecision{ItemType: item.ItemType, Status: item.Status, Source: item.Source, Reason: item.Reason}
+		found := false
+		for _, content := range r.store.contents {
+			if item.ItemType == "content" && content.UUID == item.ItemUUID {
+				decision.Title, decision.Message, decision.Link = content.Title, content.Message, content.Link
+				found = true
+			}
+		}
+		for _, comment := range r.store.comments {
+			if item.ItemType == "comment" && comment.UUID == item.ItemUUID {
+				decision.Message = comment.Message
+				found = true
+			}
+		}
+		if found {
+			decisions = append(decisions, decision)
+		}
+	}
+	return decisions, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package repository_test
+
+import (
+	"errors"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"testing"
+)
+
+func TestMemoryTransactionRollsBackOnError(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	kept := models.Account{Username: "kept", Email: "kept@example.com", UUID: "kept"}
+	if err := repos.Accounts.Create(&kept); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("failed")
+	err := repos.Tx.Transaction(func(tx repository.Repos) error {
+		dropped := models.Account{Username: "dropped", Email: "dropped@example.com", UUID: "dropped"}
+		if err := tx.Accounts.Create(&dropped); err != nil {
+			return err
+		}
+		kept.Banned = true
+		if err := tx.Accounts.Save(&kept); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Transaction = %v, want %v", err, failed)
+	}
+
+	if _, err := repos.Accounts.GetByUUID("dropped"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("an account created in the failed transaction was kept: %v", err)
+	}
+	if stored, _ := repos.Accounts.GetByUUID("kept"); stored.Banned {
+		t.Errorf("a change made in the failed transaction was kept: %+v", stored)
+	}
+
+	err = repos.Tx.Transaction(func(tx repository.Repos) error {
+		committed := models.Account{Username: "committed", Email: "committed@example.com", UUID: "committed"}
+		return tx.Accounts.Create(&committed)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Accounts.GetByUUID("committed"); err != nil {
+		t.Errorf("an account created in a committed transaction is missing: %v", err)
+	}
+}
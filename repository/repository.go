@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"context"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/models"
+	"time"
+)
+
+// ErrNotFound is returned when a lookup matches no row.
+var ErrNotFound = apperr.New(apperr.NotFound, "Not found.")
+
+// Counts are added to the denormalized counters of a row by the AddCounts methods. They are added in place
+// in the database, so changes made at the same time are all counted and the rest of the row is left alone.
+type Counts struct {
+	Content   int32
+	Comments  int32
+	Upvotes   int32
+	Downvotes int32
+	Members   int32
+}
+
+type AccountRepo interface {
+	Create(account *models.Account) error
+	GetByUUID(uuid string) (models.Account, error)
+	GetByEmail(email string) (models.Account, error)
+	ListByUsernames(usernames []string) ([]models.Account, error)
+	Save(account *models.Account) error
+	// Karma is the net score of everything the account has posted.
+	Karma(accountUUID string) (int64, error)
+	// IsShadowbanned reports whether the account is shadowbanned. An account that does not exist is not.
+	IsShadowbanned(accountUUID string) (bool, error)
+	SetBanned(uuid string, banned bool) error
+	// SetShadowbanned sets the account's shadowbanned flag and reports whether it changed, so of two
+	// requests making the same change only one sees it.
+	SetShadowbanned(uuid string, shadowbanned bool) (bool, error)
+	// ShadowbanReport lists every shadowbanned account by username, with what it posted and voted on since
+	// then.
+	ShadowbanReport(since time.Time) ([]models.ShadowbanActivity, error)
+}
+
+type HiveRepo interface {
+	Create(hive *models.Hive) error
+	List() ([]models.Hive, error)
+	GetByUUID(uuid string) (models.Hive, error)
+	// GetForUpdate is GetByUUID that locks the hive until the transaction ends.
+	GetForUpdate(uuid string) (models.Hive, error)
+	GetByName(name string) (models.Hive, error)
+	ListByNames(names []string) ([]models.Hive, error)
+	Save(hive *models.Hive) error
+	// AddCounts adds to the hive's member count and its content, comment and vote totals.
+	AddCounts(uuid string, counts Counts) error
+	GetMember(hiveUUID string, accountUUID string) (models.HiveMember, error)
+	AddMember(member *models.HiveMember) error
+	RemoveMember(member models.HiveMember) error
+}
+
+// ContentRepo lookups named Visible hide content created by shadowbanned accounts and drafts from everyone
+// except its author, and the Visible lists also leave out removed content and every draft.
+type ContentRepo interface {
+	Create(content *models.Content) error
+	GetByUUID(uuid string) (models.Content, error)
+	// GetForUpdate is GetByUUID that locks the content until the transaction ends.
+	GetForUpdate(uuid string) (models.Content, error)
+	GetVisibleByID(id int, viewerUUID string) (models.Content, error)
+	GetVisibleByUUID(uuid string, viewerUUID string) (models.Content, error)
+	ListVisible(viewerUUID string) ([]models.Content, error)
+	ListVisibleByHive(hiveUUID string, viewerUUID string) ([]models.Content, error)
+	ListDrafts(accountUUID string) ([]models.Content, error)
+	Save(content *models.Content) error
+	// AddCounts adds to the content's votes and comment count.
+	AddCounts(uuid string, counts Counts) error
+}
+
+// CommentRepo lookups named Visible hide comments created by shadowbanned accounts from everyone except
+// their author.
+type CommentRepo interface {
+	Create(comment *models.Comment) error
+	GetByUUID(uuid string) (models.Comment, error)
+	// GetForUpdate is GetByUUID that locks the comment until the transaction ends.
+	GetForUpdate(uuid string) (models.Comment, error)
+	GetVisibleByUUID(uuid string, viewerUUID string) (models.Comment, error)
+	ListByContent(contentUUID string) ([]models.Comment, error)
+	ListVisibleByContent(contentUUID string, viewerUUID string) ([]models.Comment, error)
+	ListVisibleReplies(parentUUID string, viewerUUID string) ([]models.Comment, error)
+	Save(comment *models.Comment) error
+	// AddCounts adds to the comment's votes.
+	AddCounts(uuid string, counts Counts) error
+}
+
+type VoteRepo interface {
+	GetContentVote(accountUUID string, contentUUID string) (models.ContentVote, error)
+	SaveContentVote(vote *models.ContentVote) error
+	ListContentVotesByAccount(accountUUID string) ([]models.ContentVote, error)
+	GetCommentVote(accountUUID string, commentUUID string) (models.CommentVote, error)
+	SaveCommentVote(vote *models.CommentVote) error
+	ListCommentVoteGroupsByAccount(accountUUID string) ([]models.CommentVoteGroup, error)
+	// AddAccountVotes adds sign times each of the account's votes to the votes of what it voted on and to
+	// the totals of their hives. Votes on deleted content and comments stay out of the hive totals.
+	AddAccountVotes(accountUUID string, sign int32) error
+}
+
+// MentionRepo stores the accounts and hives mentioned in content and comments, by source type and UUID.
+type MentionRepo interface {
+	// Replace swaps the mentions of the source for the ones given.
+	Replace(sourceType string, sourceUUID string, mentions []models.Mention) error
+	ListBySources(sourceType string, sourceUUIDs []string) ([]models.Mention, error)
+}
+
+// RevisionRepo keeps every version of content and comments.
+type RevisionRepo interface {
+	// Append saves revision as the next version of its item.
+	Append(revision *models.Revision) error
+	List(itemType string, itemUUID string) ([]models.Revision, error)
+}
+
+// EventRepo is the outbox. Appending in a transaction publishes the event only if the transaction commits.
+type EventRepo interface {
+	// Append adds the event to the outbox. Appends of one aggregate are serialized until their transactions
+	// end, so the events of an aggregate are numbered in the order they commit in.
+	Append(event *models.Event) error
+	// ListDue lists up to limit events due by now, oldest first. Only the oldest undispatched event of each
+	// aggregate is listed.
+	ListDue(now time.Time, limit int) ([]models.Event, error)
+	// SaveAttempt saves the attempts, next attempt, last error and dispatch time of an event.
+	SaveAttempt(event *models.Event) error
+}
+
+// WebhookRepo stores webhooks and the log of their deliveries.
+type WebhookRepo interface {
+	Create(hook *models.Webhook) error
+	GetByUUID(uuid string) (models.Webhook, error)
+	ListByHive(hiveUUID string) ([]models.Webhook, error)
+	CountByHive(hiveUUID string) (int64, error)
+	// Update saves the fields a moderator can change, with its failures and the reason it was disabled.
+	Update(hook *models.Webhook) error
+	// Delete deletes the webhook with its deliveries.
+	Delete(hook models.Webhook) error
+	// AddFailure counts one more failed delivery in a row and returns how many there are now.
+	AddFailure(hook models.Webhook) (int32, error)
+	ResetFailures(hook models.Webhook) error
+	Disable(hook models.Webhook, reason string) error
+	CreateDelivery(delivery *models.WebhookDelivery) error
+	GetDelivery(uuid string) (models.WebhookDelivery, error)
+	// ListDeliveries lists the webhook's deliveries newest first, only those with status unless it is empty.
+	ListDeliveries(webhookUUID string, status string, limit int) ([]models.WebhookDelivery, error)
+	// SaveDelivery saves the status, attempts and outcome of the last attempt of a delivery.
+	SaveDelivery(delivery *models.WebhookDelivery) error
+}
+
+// JobRepo reads and queues jobs for the admin routes. Workers claim jobs through GORM directly.
+type JobRepo interface {
+	Create(job *models.Job) error
+	GetByUUID(uuid string) (models.Job, error)
+	// List lists jobs newest first, only those with status and kind unless they are empty.
+	List(status string, kind string, limit int) ([]models.Job, error)
+	Stats() ([]models.JobStats, error)
+	ListSchedules() ([]models.JobSchedule, error)
+	// Requeue saves the status, attempts, run time and finish time of a job, if it is still dead.
+	Requeue(job *models.Job) error
+}
+
+// NotificationRepo stores the notifications of an account and keeps track of which are read.
+type NotificationRepo interface {
+	// Create saves the notification and reports whether it is new. A notification made again for the same
+	// account, type and source is not saved again.
+	Create(notification *models.Notification) (bool, error)
+	// IsEnabled reports whether the account gets notifications of the type. Types are on unless turned off.
+	IsEnabled(accountUUID string, notificationType string) (bool, error)
+	// GetOwned returns the notification only if it was sent to the account.
+	GetOwned(uuid string, accountUUID string) (models.Notification, error)
+	// List lists the account's notifications newest first with an ID below before, unless it is 0.
+	List(accountUUID string, before int64, unread bool, limit int) ([]models.Notification, error)
+	CountUnread(accountUUID string) (int64, error)
+	SetRead(notification *models.Notification) error
+	// MarkAllRead marks every unread notification of the account read and returns how many there were.
+	MarkAllRead(accountUUID string) (int64, error)
+	ListPreferences(accountUUID string) ([]models.NotificationPreference, error)
+	// SavePreferences adds the preferences, replacing the ones the account already has for their types.
+	SavePreferences(preferences []models.NotificationPreference) error
+}
+
+// BanRepo stores the bans of accounts from hives and from the whole site.
+type BanRepo interface {
+	Create(ban *models.Ban) error
+	GetByUUID(uuid string) (models.Ban, error)
+	// GetForUpdate is GetByUUID that locks the ban until the transaction ends.
+	GetForUpdate(uuid string) (models.Ban, error)
+	Save(ban *models.Ban) error
+	// IsBanned reports whether the account has an active ban from the whole site or from the hive.
+	IsBanned(accountUUID string, hiveUUID string) (bool, error)
+	// HasActive reports whether the account has an active ban from the hive, or from the whole site when
+	// hiveUUID is empty.
+	HasActive(accountUUID string, hiveUUID string) (bool, error)
+	// ListByHive lists the bans from the hive newest first.
+	ListByHive(hiveUUID string) ([]models.Ban, error)
+	// ListByAccount lists the bans of the account newest first.
+	ListByAccount(accountUUID string) ([]models.Ban, error)
+}
+
+// AppealRepo stores the appeals of bans with the history of each.
+type AppealRepo interface {
+	// Create saves the appeal. A ban can be appealed once, so appealing it again is a conflict.
+	Create(appeal *models.Appeal) error
+	GetByUUID(uuid string) (models.Appeal, error)
+	// GetForUpdate is GetByUUID that locks the appeal until the transaction ends.
+	GetForUpdate(uuid string) (models.Appeal, error)
+	GetByBan(banUUID string) (models.Appeal, error)
+	// ListQueue lists the appeals with the status of bans from the hive, or of site-wide bans when
+	// hiveUUID is empty, oldest first.
+	ListQueue(hiveUUID string, status string) ([]models.Appeal, error)
+	// ListByAccount lists the appeals the account filed newest first.
+	ListByAccount(accountUUID string) ([]models.Appeal, error)
+	Save(appeal *models.Appeal) error
+	AddEvent(event *models.AppealEvent) error
+	// ListEvents lists the history of the appeal oldest first.
+	ListEvents(appealUUID string) ([]models.AppealEvent, error)
+}
+
+type ReportRepo interface {
+	// Create saves the report. An account can report an item once, so reporting it again is a conflict.
+	Create(report *models.Report) error
+	CountByItem(itemUUID string) (int64, error)
+}
+
+// ModQueueRepo holds the items waiting for a moderator's decision.
+type ModQueueRepo interface {
+	// Enqueue adds the item, unless the same item is already pending.
+	Enqueue(item *models.ModQueueItem) error
+	GetByUUID(uuid string) (models.ModQueueItem, error)
+	// GetForUpdate is GetByUUID that locks the item until the transaction ends.
+	GetForUpdate(uuid string) (models.ModQueueItem, error)
+	// List lists the hive's items with the status oldest first.
+	List(hiveUUID string, status string) ([]models.ModQueueItem, error)
+	// Resolve saves the status of the item with who resolved it and when.
+	Resolve(item *models.ModQueueItem) error
+}
+
+// AutomodRepo stores the AutoModerator rule set of each hive.
+type AutomodRepo interface {
+	GetConfig(hiveUUID string) (models.AutomodConfig, error)
+	SaveConfig(config *models.AutomodConfig) error
+	// ListSubjects lists up to limit of the hive's newest published content and comments that are not
+	// deleted, newest first, for rules to be tried on.
+	ListSubjects(hiveUUID string, limit int) ([]models.AutomodSubject, error)
+}
+
+// SpamRepo counts what the spam heuristics look at.
+type SpamRepo interface {
+	// CountDuplicates counts the content, or the comments when itemType is comment, posted since then with
+	// the message in hives other than hiveUUID.
+	CountDuplicates(itemType string, message string, hiveUUID string, since time.Time) (int64, error)
+	// CountRecent counts the content and comments the account posted since then.
+	CountRecent(accountUUID string, since time.Time) (int64, error)
+	// ListDecisions lists the content and comments moderators approved or removed from the modqueue, in
+	// the order they were queued.
+	ListDecisions() ([]models.ModDecision, error)
+}
+
+// Transactor runs fn with repositories whose writes commit together, or not at all when fn returns an error.
+type Transactor interface {
+	Transaction(fn func(tx Repos) error) error
+}
+
+// Repos bundles one implementation of every repository.
+type Repos struct {
+	Accounts      AccountRepo
+	Hives         HiveRepo
+	Contents      ContentRepo
+	Comments      CommentRepo
+	Votes         VoteRepo
+	Mentions      MentionRepo
+	Revisions     RevisionRepo
+	Events        EventRepo
+	Webhooks      WebhookRepo
+	Jobs          JobRepo
+	Notifications NotificationRepo
+	Bans          BanRepo
+	Appeals       AppealRepo
+	Reports       ReportRepo
+	ModQueue      ModQueueRepo
+	Automod       AutomodRepo
+	Spam          SpamRepo
+	Tx            Transactor
+}
+
+// contextBinder is implemented by repositories that can run their queries under a context.
+type contextBinder[T any] interface {
+	withContext(ctx context.Context) T
+}
+
+func bind[T any](repo T, ctx context.Context) T {
+	if binder, ok := any(repo).(contextBinder[T]); ok {
+		return binder.withContext(ctx)
+	}
+	return repo
+}
+
+// WithContext returns the repositories with their queries bound to ctx, so they are cancelled with the
+// request and traced as part of it. Repositories with no use for a context are returned unchanged.
+func (r Repos) WithContext(ctx context.Context) Repos {
+	return Repos{
+		Accounts:      bind(r.Accounts, ctx),
+		Hives:         bind(r.Hives, ctx),
+		Contents:      bind(r.Contents, ctx),
+		Comments:      bind(r.Comments, ctx),
+		Votes:         bind(r.Votes, ctx),
+		Mentions:      bind(r.Mentions, ctx),
+		Revisions:     bind(r.Revisions, ctx),
+		Events:        bind(r.Events, ctx),
+		Webhooks:      bind(r.Webhooks, ctx),
+		Jobs:          bind(r.Jobs, ctx),
+		Notifications: bind(r.Notifications, ctx),
+		Bans:          bind(r.Bans, ctx),
+		Appeals:       bind(r.Appeals, ctx),
+		Reports:       bind(r.Reports, ctx),
+		ModQueue:      bind(r.ModQueue, ctx),
+		Automod:       bind(r.Automod, ctx),
+		Spam:          bind(r.Spam, ctx),
+		Tx:            bind(r.Tx, ctx),
+	}
+}
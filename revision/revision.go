@@ -0,0 +1,145 @@
+package revision
+
+import (
+	"example/hivemind-be/models"
+	"strings"
+)
+
+// Line ops of a diff.
+const (
+	Kept    = "="
+	Added   = "+"
+	Removed = "-"
+)
+
+// maxCells bounds the table the line diff fills. Larger edits are shown as every old line removed and every
+// new line added.
+const maxCells = 250000
+
+// Line is one line of a field in a diff.
+type Line struct {
+	Op   string `json:"Op"`
+	Text string `json:"Text"`
+}
+
+// Change is a field that differs from the previous version, line by line.
+type Change struct {
+	Field string `json:"Field"`
+	Lines []Line `json:"Lines"`
+}
+
+// Entry is a revision with what changed since the version before it. The first version has no changes.
+type Entry struct {
+	models.Revision
+	Changes []Change `json:"Changes"`
+}
+
+// History pairs each of revisions, oldest first, with its diff against the one before it.
+func History(revisions []models.Revision) []Entry {
+	entries := make([]Entry, len(revisions))
+	for i, revision := range revisions {
+		entries[i] = Entry{Revision: revision, Changes: []Change{}}
+		if i == 0 {
+			continue
+		}
+		previous := revisions[i-1]
+		for _, field := range []struct {
+			name     string
+			old, new string
+		}{
+			{"Title", previous.Title, revision.Title},
+			{"Message", previous.Message, revision.Message},
+			{"Link", previous.Link, revision.Link},
+			{"ImageLink", previous.ImageLink, revision.ImageLink},
+		} {
+			if field.old != field.new {
+				entries[i].Changes = append(entries[i].Changes, Change{Field: field.name, Lines: Diff(field.old, field.new)})
+			}
+		}
+	}
+	return entries
+}
+
+// Diff compares old and new line by line, keeping the longest run of lines common to both.
+func Diff(old string, new string) []Line {
+	a, b := split(old), split(new)
+
+	//lines shared at the start and end are kept without filling the table for them
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []Line
+	for _, text := range a[:prefix] {
+		lines = append(lines, Line{Op: Kept, Text: text})
+	}
+	lines = append(lines, middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, Line{Op: Kept, Text: text})
+	}
+	return lines
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// middle diffs what is left between the common prefix and suffix through a longest common subsequence table.
+func middle(a []string, b []string) []Line {
+	var lines []Line
+	if (len(a)+1)*(len(b)+1) > maxCells {
+		for _, text := range a {
+			lines = append(lines, Line{Op: Removed, Text: text})
+		}
+		for _, text := range b {
+			lines = append(lines, Line{Op: Added, Text: text})
+		}
+		return lines
+	}
+
+	//common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, Line{Op: Kept, Text: a[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, Line{Op: Removed, Text: a[i]})
+			i++
+		default:
+			lines = append(lines, Line{Op: Added, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, Line{Op: Removed, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, Line{Op: Added, Text: b[j]})
+	}
+	return lines
+}
@@ -2,60 +2,171 @@ package routes
 
 import (
 	"example/hivemind-be/account"
+	"example/hivemind-be/appeal"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/automod"
+	"example/hivemind-be/ban"
 	"example/hivemind-be/comment"
 	"example/hivemind-be/content"
+	"example/hivemind-be/db"
+	"example/hivemind-be/health"
 	"example/hivemind-be/hive"
+	"example/hivemind-be/jobs"
+	"example/hivemind-be/metrics"
+	"example/hivemind-be/modqueue"
+	"example/hivemind-be/notification"
+	"example/hivemind-be/ratelimit"
+	"example/hivemind-be/realtime"
+	"example/hivemind-be/report"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/spam"
+	"example/hivemind-be/webhook"
 
 	"github.com/gin-gonic/gin"
 )
 
 func Routes(router *gin.Engine) {
+	router.Use(metrics.Middleware())
+
+	auth := ratelimit.Middleware(ratelimit.Auth)
+	write := ratelimit.Middleware(ratelimit.Write)
+	vote := ratelimit.Middleware(ratelimit.Vote)
+	read := ratelimit.Middleware(ratelimit.Read)
+
+	repos := repository.NewGormRepos(db.Db)
+	accounts := account.NewHandler(repos)
+	hives := hive.NewHandler(repos)
+	contents := content.NewHandler(repos)
+	comments := comment.NewHandler(repos)
+	webhooks := webhook.NewHandler(repos)
+	adminJobs := jobs.NewAdminHandler(repos)
+	notifications := notification.NewHandler(repos)
+	reports := report.NewHandler(repos)
+	automods := automod.NewHandler(repos)
+	modQueue := modqueue.NewHandler(repos)
+	streams := realtime.NewHandler(repos)
+	bans := ban.NewHandler(repos)
+	appeals := appeal.NewHandler(repos)
+	spamAdmin := spam.NewHandler(repos)
+
+	// Probes
+	router.GET("/healthz", health.Healthz)
+	router.GET("/readyz", health.Readyz)
+	router.GET("/metrics", metrics.Handler())
+
+	router.NoRoute(func(c *gin.Context) {
+		apperr.Write(c, apperr.New(apperr.NotFound, "Route not found."))
+	})
+
 	// Content
-	router.GET("/content", content.GetContent)
-	router.GET("/content/id/:id", content.GetContentById)
-	router.GET("/content/uuid/:uuid", content.GetContentByUuid)
-	router.GET("/content/votes", content.GetContentVotesByAccount)
-	router.POST("/content", content.CreateContent)
-	router.PATCH("/content/uuid/:uuid/add-upvote", content.AddContentUpvoteByUuid)
-	router.PATCH("/content/uuid/:uuid/remove-upvote", content.RemoveContentUpvoteByUuid)
-	router.PATCH("/content/uuid/:uuid/add-downvote", content.AddContentDownvoteByUuid)
-	router.PATCH("/content/uuid/:uuid/remove-downvote", content.RemoveContentDownvoteByUuid)
-	router.PATCH("/content/uuid/:uuid/delete", content.DeleteContentByUuid)
-	router.PATCH("/content/uuid/:uuid/undelete", content.UndeleteContentByUuid)
-	router.PATCH("/content/uuid/:uuid/update", content.UpdateContentByUuid)
+	router.GET("/content", read, contents.GetContent)
+	router.GET("/content/id/:id", read, contents.GetContentById)
+	router.GET("/content/uuid/:uuid", read, contents.GetContentByUuid)
+	router.GET("/content/uuid/:uuid/revisions", read, contents.GetContentRevisionsByUuid)
+	router.GET("/content/votes", read, contents.GetContentVotesByAccount)
+	router.POST("/content", write, contents.CreateContent)
+	router.PATCH("/content/uuid/:uuid/add-upvote", vote, contents.AddContentUpvoteByUuid)
+	router.PATCH("/content/uuid/:uuid/remove-upvote", vote, contents.RemoveContentUpvoteByUuid)
+	router.PATCH("/content/uuid/:uuid/add-downvote", vote, contents.AddContentDownvoteByUuid)
+	router.PATCH("/content/uuid/:uuid/remove-downvote", vote, contents.RemoveContentDownvoteByUuid)
+	router.PATCH("/content/uuid/:uuid/delete", write, contents.DeleteContentByUuid)
+	router.PATCH("/content/uuid/:uuid/undelete", write, contents.UndeleteContentByUuid)
+	router.PATCH("/content/uuid/:uuid/update", write, contents.UpdateContentByUuid)
+	router.PATCH("/content/uuid/:uuid/publish", write, contents.PublishContentByUuid)
+	router.POST("/content/uuid/:uuid/report", write, reports.CreateContentReport)
 
 	// Comment via Content
-	router.GET("/content/uuid/:uuid/comment", comment.GetCommentsByContentUuid)
-	router.POST("/content/uuid/:uuid/comment", comment.CreateComment)
-	router.POST("/content/uuid/:uuid/comment/:parentuuid/reply", comment.CreateCommentReply)
+	router.GET("/content/uuid/:uuid/comment", read, comments.GetCommentsByContentUuid)
+	router.POST("/content/uuid/:uuid/comment", write, comments.CreateComment)
+	router.POST("/content/uuid/:uuid/comment/:parentuuid/reply", write, comments.CreateCommentReply)
 
 	// Comment
-	router.GET("/comment/uuid/:uuid", comment.GetCommentByUuid)
-	router.GET("/comment/uuid/:uuid/replies", comment.GetCommentByUuidWithReplies)
-	router.GET("/comment/votes", comment.GetCommentVotesByAccount)
-	router.PATCH("/comment/uuid/:uuid/delete", comment.DeleteCommentByUuid)
-	router.PATCH("/comment/uuid/:uuid/undelete", comment.UndeleteCommentByUuid)
-	router.PATCH("/comment/uuid/:uuid/update", comment.UpdateCommentByUuid)
-	router.PATCH("/comment/uuid/:uuid/add-upvote", comment.AddCommentUpvoteByUuid)
-	router.PATCH("/comment/uuid/:uuid/remove-upvote", comment.RemoveCommentUpvoteByUuid)
-	router.PATCH("/comment/uuid/:uuid/add-downvote", comment.AddCommentDownvoteByUuid)
-	router.PATCH("/comment/uuid/:uuid/remove-downvote", comment.RemoveCommentDownvoteByUuid)
+	router.GET("/comment/uuid/:uuid", read, comments.GetCommentByUuid)
+	router.GET("/comment/uuid/:uuid/replies", read, comments.GetCommentByUuidWithReplies)
+	router.GET("/comment/uuid/:uuid/revisions", read, comments.GetCommentRevisionsByUuid)
+	router.GET("/comment/votes", read, comments.GetCommentVotesByAccount)
+	router.PATCH("/comment/uuid/:uuid/delete", write, comments.DeleteCommentByUuid)
+	router.PATCH("/comment/uuid/:uuid/undelete", write, comments.UndeleteCommentByUuid)
+	router.PATCH("/comment/uuid/:uuid/update", write, comments.UpdateCommentByUuid)
+	router.PATCH("/comment/uuid/:uuid/add-upvote", vote, comments.AddCommentUpvoteByUuid)
+	router.PATCH("/comment/uuid/:uuid/remove-upvote", vote, comments.RemoveCommentUpvoteByUuid)
+	router.PATCH("/comment/uuid/:uuid/add-downvote", vote, comments.AddCommentDownvoteByUuid)
+	router.PATCH("/comment/uuid/:uuid/remove-downvote", vote, comments.RemoveCommentDownvoteByUuid)
+	router.POST("/comment/uuid/:uuid/report", write, reports.CreateCommentReport)
 
 	// Hive
-	router.GET("/hive", hive.GetHive)
-	router.GET("/hive/uuid/:uuid/content", content.GetContentByHiveUuid)
-	router.POST("/hive", hive.CreateHive)
-	router.PATCH("/hive/uuid/:uuid/ban", hive.BanHiveByUuid)
-	router.PATCH("/hive/uuid/:uuid/unban", hive.UnBanHiveByUuid)
-	router.PATCH("/hive/uuid/:uuid/archive", hive.ArchiveHiveByUuid)
-	router.PATCH("/hive/uuid/:uuid/unarchive", hive.UnArchiveHiveByUuid)
-	router.PATCH("/hive/uuid/:uuid/update", hive.UpdateHiveByUuid)
+	router.GET("/hive", read, hives.GetHive)
+	router.GET("/hive/uuid/:uuid/content", read, contents.GetContentByHiveUuid)
+	router.POST("/hive", write, hives.CreateHive)
+	router.PATCH("/hive/uuid/:uuid/ban", write, hives.BanHiveByUuid)
+	router.PATCH("/hive/uuid/:uuid/unban", write, hives.UnBanHiveByUuid)
+	router.PATCH("/hive/uuid/:uuid/archive", write, hives.ArchiveHiveByUuid)
+	router.PATCH("/hive/uuid/:uuid/unarchive", write, hives.UnArchiveHiveByUuid)
+	router.PATCH("/hive/uuid/:uuid/update", write, hives.UpdateHiveByUuid)
+	router.PATCH("/hive/uuid/:uuid/join", write, hives.JoinHiveByUuid)
+	router.PATCH("/hive/uuid/:uuid/leave", write, hives.LeaveHiveByUuid)
+
+	// Moderation
+	router.GET("/hive/uuid/:uuid/automod", read, automods.GetAutomodRules)
+	router.PATCH("/hive/uuid/:uuid/automod/update", write, automods.UpdateAutomodRules)
+	router.POST("/hive/uuid/:uuid/automod/dry-run", write, automods.DryRunAutomodRules)
+	router.GET("/hive/uuid/:uuid/modqueue", read, modQueue.GetModQueueByHiveUuid)
+	router.PATCH("/modqueue/uuid/:uuid/approve", write, modQueue.ApproveModQueueItem)
+	router.PATCH("/modqueue/uuid/:uuid/remove", write, modQueue.RemoveModQueueItem)
+	router.GET("/hive/uuid/:uuid/bans", read, bans.GetBansByHiveUuid)
+	router.POST("/hive/uuid/:uuid/bans", write, bans.CreateHiveBan)
+	router.PATCH("/ban/uuid/:uuid/lift", write, bans.LiftBanByUuid)
+	router.GET("/hive/uuid/:uuid/appeals", read, appeals.GetAppealsByHiveUuid)
+
+	// Webhook
+	router.GET("/hive/uuid/:uuid/webhooks", read, webhooks.GetWebhooksByHiveUuid)
+	router.POST("/hive/uuid/:uuid/webhooks", write, webhooks.CreateWebhook)
+	router.PATCH("/webhook/uuid/:uuid/update", write, webhooks.UpdateWebhookByUuid)
+	router.PATCH("/webhook/uuid/:uuid/delete", write, webhooks.DeleteWebhookByUuid)
+	router.GET("/webhook/uuid/:uuid/deliveries", read, webhooks.GetDeliveriesByWebhookUuid)
+	router.POST("/webhook/delivery/uuid/:uuid/redeliver", write, webhooks.RedeliverByUuid)
+
+	// Appeal
+	router.POST("/ban/uuid/:uuid/appeal", write, appeals.CreateAppeal)
+	router.GET("/appeal/uuid/:uuid", read, appeals.GetAppealByUuid)
+	router.PATCH("/appeal/uuid/:uuid/approve", write, appeals.ApproveAppealByUuid)
+	router.PATCH("/appeal/uuid/:uuid/deny", write, appeals.DenyAppealByUuid)
+
+	// Admin
+	router.POST("/admin/spam/retrain", write, spamAdmin.RetrainSpamClassifier)
+	router.GET("/admin/spam/stats", read, spamAdmin.GetSpamClassifierStats)
+	router.PATCH("/admin/account/uuid/:uuid/shadowban", write, accounts.ShadowbanAccountByUuid)
+	router.PATCH("/admin/account/uuid/:uuid/unshadowban", write, accounts.UnShadowbanAccountByUuid)
+	router.GET("/admin/shadowban/report", read, accounts.GetShadowbanReport)
+	router.POST("/admin/bans", write, bans.CreateSiteBan)
+	router.GET("/admin/appeals", read, appeals.GetSiteAppeals)
+	router.GET("/admin/jobs", read, adminJobs.GetJobs)
+	router.GET("/admin/jobs/stats", read, adminJobs.GetJobStats)
+	router.GET("/admin/jobs/schedules", read, adminJobs.GetJobSchedules)
+	router.GET("/admin/jobs/uuid/:uuid", read, adminJobs.GetJobByUuid)
+	router.PATCH("/admin/jobs/uuid/:uuid/retry", write, adminJobs.RetryJobByUuid)
 
 	// Account
-	router.POST("/account/create", account.CreateAccount)
-	router.POST("/account/login", account.AccountLogin)
-	router.POST("/account/token/refresh", account.RefreshAuthToken)
-	router.GET("/account/token/validate", account.ValidateAccountToken)
-	router.GET("/account", account.GetAccount)
-	router.PATCH("/account/change-password", account.ChangePassword)
+	router.POST("/account/create", auth, accounts.CreateAccount)
+	router.POST("/account/login", auth, accounts.AccountLogin)
+	router.POST("/account/token/refresh", auth, account.RefreshAuthToken)
+	router.GET("/account/token/validate", read, account.ValidateAccountToken)
+	router.GET("/account", read, accounts.GetAccount)
+	router.GET("/account/drafts", read, contents.GetDrafts)
+	router.PATCH("/account/change-password", auth, accounts.ChangePassword)
+	router.GET("/account/bans", read, bans.GetBansByAccount)
+	router.GET("/account/appeals", read, appeals.GetAppealsByAccount)
+
+	// Realtime
+	router.GET("/realtime/sse", read, streams.Stream)
+	router.GET("/realtime/ws", read, streams.Socket)
+
+	// Notification
+	router.GET("/notifications", read, notifications.GetNotifications)
+	router.GET("/notifications/unread-count", read, notifications.GetUnreadCount)
+	router.PATCH("/notifications/read-all", write, notifications.MarkAllNotificationsRead)
+	router.PATCH("/notification/uuid/:uuid/read", write, notifications.MarkNotificationRead)
+	router.PATCH("/notification/uuid/:uuid/unread", write, notifications.MarkNotificationUnread)
+	router.GET("/notifications/preferences", read, notifications.GetPreferences)
+	router.PATCH("/notifications/preferences/update", write, notifications.UpdatePreferences)
 }
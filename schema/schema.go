@@ -0,0 +1,30 @@
+package schema
+
+import "example/hivemind-be/models"
+
+// Models are the GORM models backed by migrated tables. `migrate check`, startup and the migration tests
+// compare them against the schema.
+var Models = []interface{}{
+	&models.Account{},
+	&models.Hive{},
+	&models.Content{},
+	&models.ContentVote{},
+	&models.Comment{},
+	&models.CommentVote{},
+	&models.Report{},
+	&models.ModQueueItem{},
+	&models.AutomodConfig{},
+	&models.Ban{},
+	&models.Appeal{},
+	&models.AppealEvent{},
+	&models.HiveMember{},
+	&models.Event{},
+	&models.Job{},
+	&models.JobSchedule{},
+	&models.Webhook{},
+	&models.WebhookDelivery{},
+	&models.Notification{},
+	&models.NotificationPreference{},
+	&models.Mention{},
+	&models.Revision{},
+}
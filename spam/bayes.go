@@ -0,0 +1,100 @@
+package spam
+
+import (
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// NaiveBayes is a multinomial naive Bayes model over message tokens with Laplace smoothing.
+type NaiveBayes struct {
+	spamDocs   int
+	hamDocs    int
+	spamTokens int
+	hamTokens  int
+	spamCounts map[string]int
+	hamCounts  map[string]int
+	vocabulary map[string]struct{}
+}
+
+var tokenLinkPattern = regexp.MustCompile(`https?://[^\s<>"')\]]+`)
+
+func NewNaiveBayes() *NaiveBayes {
+	return &NaiveBayes{
+		spamCounts: map[string]int{},
+		hamCounts:  map[string]int{},
+		vocabulary: map[string]struct{}{},
+	}
+}
+
+// Learn adds a single labelled document to the model.
+func (nb *NaiveBayes) Learn(text string, isSpam bool) {
+	tokens := Tokenize(text)
+	if isSpam {
+		nb.spamDocs++
+		nb.spamTokens += len(tokens)
+	} else {
+		nb.hamDocs++
+		nb.hamTokens += len(tokens)
+	}
+
+	for _, token := range tokens {
+		nb.vocabulary[token] = struct{}{}
+		if isSpam {
+			nb.spamCounts[token]++
+		} else {
+			nb.hamCounts[token]++
+		}
+	}
+}
+
+// Trained reports whether the model has seen examples of both classes.
+func (nb *NaiveBayes) Trained() bool {
+	return nb.spamDocs > 0 && nb.hamDocs > 0
+}
+
+// Probability returns the probability that the text is spam. An untrained model returns 0.5.
+func (nb *NaiveBayes) Probability(text string) float64 {
+	if !nb.Trained() {
+		return 0.5
+	}
+
+	vocab := float64(len(nb.vocabulary))
+	total := float64(nb.spamDocs + nb.hamDocs)
+	logSpam := math.Log(float64(nb.spamDocs) / total)
+	logHam := math.Log(float64(nb.hamDocs) / total)
+
+	for _, token := range Tokenize(text) {
+		logSpam += math.Log((float64(nb.spamCounts[token]) + 1) / (float64(nb.spamTokens) + vocab))
+		logHam += math.Log((float64(nb.hamCounts[token]) + 1) / (float64(nb.hamTokens) + vocab))
+	}
+
+	// equivalent to exp(logSpam) / (exp(logSpam) + exp(logHam)) without underflowing
+	return 1 / (1 + math.Exp(logHam-logSpam))
+}
+
+// Tokenize lowercases text and splits it into words. Links are reduced to a token for their domain so that
+// the model learns which sites are spammed rather than individual URLs.
+func Tokenize(text string) []string {
+	var tokens []string
+
+	for _, link := range tokenLinkPattern.FindAllString(text, -1) {
+		if parsed, err := url.Parse(link); err == nil && parsed.Hostname() != "" {
+			tokens = append(tokens, "domain:"+strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www."))
+		}
+	}
+	text = tokenLinkPattern.ReplaceAllString(text, " ")
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '$'
+	})
+	for _, word := range words {
+		if len(word) < 2 || len(word) > 32 {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
@@ -0,0 +1,342 @@
+package spam
+
+import (
+	"context"
+	"example/hivemind-be/account"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/utils"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Threshold is the score at or above which new content is held in the modqueue instead of being published.
+const Threshold = 0.85
+
+// SpamClassifier scores new posts and comments, looking up what it needs to know about other posts through
+// counts. Implementations must be safe for concurrent use.
+type SpamClassifier interface {
+	Score(counts repository.SpamRepo, item Item) (Result, error)
+	Train(samples []Sample) Stats
+	Stats() Stats
+}
+
+// Item is a post or comment to be scored.
+type Item struct {
+	Type        string //content or comment
+	UUID        string
+	HiveUUID    string
+	AccountUUID string
+	Title       string
+	Message     string
+	Link        string
+}
+
+// Sample is a moderator decision used as training data. Items removed after being queued as spam are spam
+// and approved items are not.
+type Sample struct {
+	Text string
+	Spam bool
+}
+
+type Result struct {
+	Score   float64  `json:"Score"`
+	Bayes   float64  `json:"Bayes"`
+	Signals []string `json:"Signals"`
+	Spam    bool     `json:"Spam"`
+}
+
+type Stats struct {
+	Samples   int       `json:"Samples"`
+	SpamCount int       `json:"SpamCount"`
+	HamCount  int       `json:"HamCount"`
+	Evaluated int       `json:"Evaluated"` //size of the held out set precision and recall were measured on
+	Precision float64   `json:"Precision"`
+	Recall    float64   `json:"Recall"`
+	Threshold float64   `json:"Threshold"`
+	TrainedAt time.Time `json:"TrainedAt"`
+}
+
+// Classifier is the classifier used when content is created. It can be replaced by another implementation.
+var Classifier SpamClassifier = NewDefaultClassifier()
+
+// RetrainInterval is how often the server retrains the classifier from new moderator decisions.
+const RetrainInterval = time.Hour
+
+// DefaultClassifier combines a naive Bayes model trained on moderator decisions with heuristics for
+// duplicated text, link density and posting velocity.
+type DefaultClassifier struct {
+	mu    sync.RWMutex
+	model *NaiveBayes
+	stats Stats
+}
+
+func NewDefaultClassifier() *DefaultClassifier {
+	return &DefaultClassifier{
+		model: NewNaiveBayes(),
+		stats: Stats{Threshold: Threshold},
+	}
+}
+
+func (item Item) text() string {
+	return strings.TrimSpace(item.Title + " " + item.Message + " " + item.Link)
+}
+
+// Score combines the model's score with the heuristics. Until a model is trained the heuristics score alone.
+func (classifier *DefaultClassifier) Score(counts repository.SpamRepo, item Item) (Result, error) {
+	var result Result
+
+	classifier.mu.RLock()
+	trained := classifier.model.Trained()
+	if trained {
+		result.Bayes = classifier.model.Probability(item.text())
+	}
+	classifier.mu.RUnlock()
+
+	// each signal is an independent probability of spam, combined with a noisy-or
+	notSpam := 1.0
+	if trained {
+		notSpam *= 1 - result.Bayes
+		if result.Bayes >= Threshold {
+			result.Signals = append(result.Signals, fmt.Sprintf("model score %.2f", result.Bayes))
+		}
+	}
+	signals, err := heuristics(counts, item)
+	if err != nil {
+		return Result{}, err
+	}
+	for _, signal := range signals {
+		notSpam *= 1 - signal.weight
+		result.Signals = append(result.Signals, signal.name)
+	}
+
+	result.Score = 1 - notSpam
+	result.Spam = result.Score >= Threshold
+	return result, nil
+}
+
+// Train replaces the model with one built from the samples. Every fifth sample is first held out to measure
+// precision and recall of the model alone, then the final model is trained on all samples.
+func (classifier *DefaultClassifier) Train(samples []Sample) Stats {
+	stats := Stats{
+		Samples:   len(samples),
+		Threshold: Threshold,
+		TrainedAt: time.Now(),
+	}
+
+	holdout := NewNaiveBayes()
+	var evaluation []Sample
+	for i, sample := range samples {
+		if sample.Spam {
+			stats.SpamCount++
+		} else {
+			stats.HamCount++
+		}
+		if i%5 == 4 {
+			evaluation = append(evaluation, sample)
+			continue
+		}
+		holdout.Learn(sample.Text, sample.Spam)
+	}
+
+	var truePositive, falsePositive, falseNegative int
+	if holdout.Trained() {
+		for _, sample := range evaluation {
+			predicted := holdout.Probability(sample.Text) >= Threshold
+			switch {
+			case predicted && sample.Spam:
+				truePositive++
+			case predicted && !sample.Spam:
+				falsePositive++
+			case !predicted && sample.Spam:
+				falseNegative++
+			}
+		}
+		stats.Evaluated = len(evaluation)
+	}
+	if truePositive+falsePositive > 0 {
+		stats.Precision = float64(truePositive) / float64(truePositive+falsePositive)
+	}
+	if truePositive+falseNegative > 0 {
+		stats.Recall = float64(truePositive) / float64(truePositive+falseNegative)
+	}
+
+	model := NewNaiveBayes()
+	for _, sample := range samples {
+		model.Learn(sample.Text, sample.Spam)
+	}
+
+	classifier.mu.Lock()
+	classifier.model = model
+	classifier.stats = stats
+	classifier.mu.Unlock()
+	return stats
+}
+
+func (classifier *DefaultClassifier) Stats() Stats {
+	classifier.mu.RLock()
+	defer classifier.mu.RUnlock()
+	return classifier.stats
+}
+
+type signal struct {
+	name   string
+	weight float64
+}
+
+// heuristics returns the signals that do not depend on the trained model.
+func heuristics(counts repository.SpamRepo, item Item) ([]signal, error) {
+	var signals []signal
+
+	// the same text posted in other hives
+	if item.Message != "" {
+		duplicates, err := counts.CountDuplicates(item.Type, item.Message, item.HiveUUID, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			return nil, err
+		}
+		if duplicates >= 3 {
+			signals = append(signals, signal{fmt.Sprintf("duplicated %d times", duplicates), 0.7})
+		} else if duplicates > 0 {
+			signals = append(signals, signal{fmt.Sprintf("duplicated %d times", duplicates), 0.35})
+		}
+	}
+
+	// mostly links and little text
+	words := len(strings.Fields(item.Message))
+	links := len(tokenLinkPattern.FindAllString(item.Message, -1))
+	if words > 0 && links > 0 {
+		density := float64(links) / float64(words)
+		if density >= 0.5 {
+			signals = append(signals, signal{fmt.Sprintf("link density %.2f", density), 0.5})
+		} else if density >= 0.2 {
+			signals = append(signals, signal{fmt.Sprintf("link density %.2f", density), 0.25})
+		}
+	}
+
+	// many posts from the same account in a short time
+	recent, err := counts.CountRecent(item.AccountUUID, time.Now().Add(-10*time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	if recent >= 10 {
+		signals = append(signals, signal{fmt.Sprintf("%d posts in 10 minutes", recent), 0.7})
+	} else if recent >= 5 {
+		signals = append(signals, signal{fmt.Sprintf("%d posts in 10 minutes", recent), 0.35})
+	}
+
+	return signals, nil
+}
+
+// LoadSamples reads the resolved modqueue decisions as training data through decisions. Items removed for
+// reasons other than spam are left out.
+func LoadSamples(decisions repository.SpamRepo) ([]Sample, error) {
+	decided, err := decisions.ListDecisions()
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]Sample, 0, len(decided))
+	for _, decision := range decided {
+		isSpam := decision.Status == "removed"
+		if isSpam && !queuedAsSpam(decision) {
+			// removed for something else, which says nothing about whether it was spam
+			continue
+		}
+		item := Item{Title: decision.Title, Message: decision.Message, Link: decision.Link}
+		samples = append(samples, Sample{Text: item.text(), Spam: isSpam})
+	}
+	return samples, nil
+}
+
+// queuedAsSpam reports whether the item was queued as spam, either by the classifier or by a report or
+// automod rule whose reason names spam.
+func queuedAsSpam(decision models.ModDecision) bool {
+	return decision.Source == "spam" || strings.Contains(strings.ToLower(decision.Reason), "spam")
+}
+
+// Retrain rebuilds the classifier from the current moderator decisions.
+func Retrain(decisions repository.SpamRepo) (Stats, error) {
+	samples, err := LoadSamples(decisions)
+	if err != nil {
+		return Stats{}, err
+	}
+	return Classifier.Train(samples), nil
+}
+
+// Train trains the classifier when the server starts and again every RetrainInterval until ctx is done. A
+// failed training is logged and tried again at the next interval, and the classifier keeps scoring with the
+// model it had, or with the heuristics alone while it has none.
+func Train(ctx context.Context, decisions repository.SpamRepo) {
+	ticker := time.NewTicker(RetrainInterval)
+	defer ticker.Stop()
+	for {
+		if stats, err := Retrain(decisions); err != nil {
+			slog.Error("could not train the spam classifier", "error", err)
+		} else {
+			slog.Info("trained the spam classifier", "samples", stats.Samples, "precision", stats.Precision, "recall", stats.Recall)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check scores an item with the configured classifier.
+func Check(counts repository.SpamRepo, item Item) (Result, error) {
+	return Classifier.Score(counts, item)
+}
+
+// Reason describes a result for the modqueue.
+func (result Result) Reason() string {
+	return fmt.Sprintf("Spam score %.2f: %s", result.Score, strings.Join(result.Signals, ", "))
+}
+
+// Handler serves the spam classifier admin routes, training it from the spam repository.
+type Handler struct {
+	Repos repository.Repos
+}
+
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+func (h *Handler) RetrainSpamClassifier(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if !account.RequireAdmin(c, h.Repos.WithContext(c.Request.Context()).Accounts, claims.AccountUUID) {
+		return
+	}
+
+	stats, err := Retrain(h.Repos.WithContext(c.Request.Context()).Spam)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error loading training data. Please try again.", err))
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+func (h *Handler) GetSpamClassifierStats(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if !account.RequireAdmin(c, h.Repos.WithContext(c.Request.Context()).Accounts, claims.AccountUUID) {
+		return
+	}
+
+	c.JSON(http.StatusOK, Classifier.Stats())
+}
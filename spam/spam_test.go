@@ -0,0 +1,171 @@
+package spam_test
+
+import (
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/spam"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestTokenize(t *testing.T) {
+	got := spam.Tokenize("Buy CHEAP pills at https://www.Pills.example/buy?now=1 for $5, a bargain!")
+	want := []string{"domain:pills.example", "buy", "cheap", "pills", "at", "for", "$5", "bargain"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %q, want %q", got, want)
+	}
+}
+
+func TestNaiveBayes(t *testing.T) {
+	model := spam.NewNaiveBayes()
+	model.Learn("cheap pills buy now", true)
+	if model.Trained() || model.Probability("cheap pills") != 0.5 {
+		t.Errorf("a model that has only seen spam: Trained = %v, Probability = %v, want false and 0.5", model.Trained(), model.Probability("cheap pills"))
+	}
+
+	model.Learn("cheap watches buy today", true)
+	model.Learn("how do generics work in go", false)
+	model.Learn("a question about go modules", false)
+	if !model.Trained() {
+		t.Fatal("Trained = false after both classes")
+	}
+	if p := model.Probability("buy cheap pills"); p < 0.85 {
+		t.Errorf("Probability(spam) = %.2f, want at least 0.85", p)
+	}
+	if p := model.Probability("go generics question"); p > 0.15 {
+		t.Errorf("Probability(ham) = %.2f, want at most 0.15", p)
+	}
+}
+
+func TestTrainMeasuresPrecisionAndRecallOnHeldOutSamples(t *testing.T) {
+	spamText := spam.Sample{Text: "buy cheap pills now at https://pills.example", Spam: true}
+	hamText := spam.Sample{Text: "how do generics work in go modules", Spam: false}
+	var samples []spam.Sample
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			samples = append(samples, spamText)
+		} else {
+			samples = append(samples, hamText)
+		}
+	}
+	//every fifth sample is held out: 4 and 14 are spam, 9 is ham and 19 is ham that reads like spam
+	samples[19] = spam.Sample{Text: "cheap pills buy now", Spam: false}
+
+	classifier := spam.NewDefaultClassifier()
+	stats := classifier.Train(samples)
+	if stats.Samples != 20 || stats.SpamCount != 10 || stats.HamCount != 10 || stats.Evaluated != 4 {
+		t.Errorf("stats = %+v, want 20 samples, 10 of each and 4 evaluated", stats)
+	}
+	if math.Abs(stats.Precision-2.0/3) > 1e-9 || stats.Recall != 1 {
+		t.Errorf("precision = %.2f, recall = %.2f, want 0.67 and 1", stats.Precision, stats.Recall)
+	}
+	if got := classifier.Stats(); got != stats {
+		t.Errorf("Stats = %+v, want the last training's %+v", got, stats)
+	}
+}
+
+func TestTrainWithoutBothClassesEvaluatesNothing(t *testing.T) {
+	stats := spam.NewDefaultClassifier().Train([]spam.Sample{{Text: "buy now", Spam: true}, {Text: "buy today", Spam: true}})
+	if stats.Evaluated != 0 || stats.Precision != 0 || stats.Recall != 0 {
+		t.Errorf("stats = %+v, want nothing evaluated", stats)
+	}
+}
+
+// thread saves a post in hiveUUID with a comment for each of messages.
+func thread(t *testing.T, repos repository.Repos, hiveUUID string, messages ...string) {
+	t.Helper()
+	now := pq.NullTime{Time: time.Now(), Valid: true}
+	content := models.Content{UUID: hiveUUID + "-post", HiveUUID: hiveUUID, AccountUUID: "poster", Message: "Welcome", Created: now}
+	if err := repos.Contents.Create(&content); err != nil {
+		t.Fatal(err)
+	}
+	for i, message := range messages {
+		comment := models.Comment{UUID: hiveUUID + "-comment-" + string(rune('a'+i)), ContentUUID: content.UUID, AccountUUID: "commenter", Message: message, Created: now}
+		if err := repos.Comments.Create(&comment); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScoreCountsCommentDuplicatesInOtherHives(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	thread(t, repos, "golang", "Visit my site", "Visit my site")
+	thread(t, repos, "rust", "Visit my site", "Nice post")
+
+	classifier := spam.NewDefaultClassifier()
+	for _, test := range []struct {
+		hive    string
+		signals []string
+	}{
+		{"python", []string{"duplicated 3 times"}},
+		{"golang", []string{"duplicated 1 times"}},
+		{"rust", []string{"duplicated 2 times"}},
+	} {
+		result, err := classifier.Score(repos.Spam, spam.Item{Type: "comment", HiveUUID: test.hive, AccountUUID: "author", Message: "Visit my site"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(result.Signals, test.signals) {
+			t.Errorf("in %s: Signals = %q, want %q", test.hive, result.Signals, test.signals)
+		}
+		if result.Bayes != 0 {
+			t.Errorf("in %s: Bayes = %.2f before training, want 0", test.hive, result.Bayes)
+		}
+	}
+}
+
+func TestScoreCombinesSignals(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	thread(t, repos, "golang", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j")
+
+	result, err := spam.NewDefaultClassifier().Score(repos.Spam, spam.Item{
+		Type:        "comment",
+		HiveUUID:    "golang",
+		AccountUUID: "commenter",
+		Message:     "https://a.example https://b.example",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"link density 1.00", "10 posts in 10 minutes"}
+	if !reflect.DeepEqual(result.Signals, want) {
+		t.Errorf("Signals = %q, want %q", result.Signals, want)
+	}
+	//1 - (1 - 0.5) * (1 - 0.7)
+	if math.Abs(result.Score-0.85) > 1e-9 || !result.Spam {
+		t.Errorf("Score = %.2f, Spam = %v, want 0.85 and spam", result.Score, result.Spam)
+	}
+}
+
+func TestLoadSamplesLabelsOnlyRemovalsQueuedAsSpam(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+	thread(t, repos, "golang", "Buy cheap pills", "You are an idiot", "Spam spam links", "Great answer")
+	for _, item := range []models.ModQueueItem{
+		{ItemUUID: "golang-comment-a", Source: "spam", Reason: "Spam score 0.91: duplicated 3 times", Status: "removed"},
+		{ItemUUID: "golang-comment-b", Source: "report", Reason: "Harassment", Status: "removed"},
+		{ItemUUID: "golang-comment-c", Source: "report", Reason: "This is SPAM", Status: "removed"},
+		{ItemUUID: "golang-comment-d", Source: "report", Reason: "Harassment", Status: "approved"},
+	} {
+		item.ItemType = "comment"
+		if err := repos.ModQueue.Enqueue(&item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	samples, err := spam.LoadSamples(repos.Spam)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []spam.Sample{
+		{Text: "Buy cheap pills", Spam: true},
+		{Text: "Spam spam links", Spam: true},
+		{Text: "Great answer", Spam: false},
+	}
+	if !reflect.DeepEqual(samples, want) {
+		t.Errorf("LoadSamples = %+v, want %+v", samples, want)
+	}
+}
@@ -1,9 +1,9 @@
 package token
 
 import (
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/config"
 	"fmt"
-	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -11,8 +11,33 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var secretKey = []byte(os.Getenv("TOKEN_SECRET"))
-var refreshSecretKey = []byte(os.Getenv("REFRESH_TOKEN_SECRET"))
+var secretKey []byte
+var refreshSecretKey []byte
+var previousSecretKey []byte
+var previousRefreshSecretKey []byte
+var lifetime time.Duration
+var refreshLifetime time.Duration
+
+// Configure sets the signing secrets and lifetimes. It is called once at startup before any token is issued.
+func Configure(cfg config.TokenConfig) {
+	secretKey = []byte(cfg.Secret)
+	refreshSecretKey = []byte(cfg.RefreshSecret)
+	previousSecretKey = []byte(cfg.PreviousSecret)
+	previousRefreshSecretKey = []byte(cfg.PreviousRefreshSecret)
+	lifetime = cfg.Lifetime
+	refreshLifetime = cfg.RefreshLifetime
+}
+
+// verificationKeys accepts tokens signed with the current secret or, after a rotation, the previous one.
+// New tokens are only ever signed with the current secret.
+func verificationKeys(current []byte, previous []byte) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if len(previous) == 0 {
+			return current, nil
+		}
+		return jwt.VerificationKeySet{Keys: []jwt.VerificationKey{current, previous}}, nil
+	}
+}
 
 type UserClaim struct {
 	jwt.RegisteredClaims
@@ -32,14 +57,14 @@ type RefreshUserClaim struct {
 // The token is signed using the HS256 signing method and contains the following claims:
 // - "username": the username of the account
 // - "accountUuid": the UUID of the account
-// - "exp": the expiration time of the token, set to 24 hours from the current time
+// - "exp": the expiration time of the token, the configured token lifetime from the current time
 // The function returns the generated token string and an error if any occurred.
 func CreateToken(username string, uuid string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, UserClaim{
 		RegisteredClaims: jwt.RegisteredClaims{},
 		AccountUUID:      uuid,
 		Username:         username,
-		Exp:              time.Now().Add(time.Minute * 2).Unix(),
+		Exp:              time.Now().Add(lifetime).Unix(),
 	})
 
 	tokenString, err := token.SignedString(secretKey)
@@ -54,7 +79,7 @@ func CreateRefreshToken(username string, uuid string) (string, error) {
 		RegisteredClaims: jwt.RegisteredClaims{},
 		AccountUUID:      uuid,
 		Username:         username,
-		Exp:              time.Now().Add(time.Minute * 120).Unix(),
+		Exp:              time.Now().Add(refreshLifetime).Unix(),
 	})
 
 	tokenString, err := token.SignedString(refreshSecretKey)
@@ -68,9 +93,7 @@ func CreateRefreshToken(username string, uuid string) (string, error) {
 // It takes a token string as input and returns an error if the token is invalid.
 func VerifyToken(tokenString string) error {
 	authToken := strings.Split(tokenString, " ")[1]
-	token, err := jwt.Parse(authToken, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
-	})
+	token, err := jwt.Parse(authToken, verificationKeys(secretKey, previousSecretKey))
 
 	if err != nil {
 		return err
@@ -86,9 +109,7 @@ func VerifyToken(tokenString string) error {
 // VerifyRefreshToken verifies the validity of a Refresh JWT token.
 // It takes a token string as input and returns an error if the token is invalid.
 func VerifyRefreshToken(tokenString string) error {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return refreshSecretKey, nil
-	})
+	token, err := jwt.Parse(tokenString, verificationKeys(refreshSecretKey, previousRefreshSecretKey))
 
 	if err != nil {
 		return err
@@ -103,9 +124,7 @@ func VerifyRefreshToken(tokenString string) error {
 
 func ParseToken(tokenString string) (*UserClaim, error) {
 	authToken := strings.Split(tokenString, " ")[1]
-	token, err := jwt.ParseWithClaims(authToken, &UserClaim{}, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
-	})
+	token, err := jwt.ParseWithClaims(authToken, &UserClaim{}, verificationKeys(secretKey, previousSecretKey))
 
 	if err != nil {
 		return nil, err
@@ -120,9 +139,7 @@ func ParseToken(tokenString string) (*UserClaim, error) {
 }
 
 func ParseRefreshToken(tokenString string) (*RefreshUserClaim, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &RefreshUserClaim{}, func(token *jwt.Token) (interface{}, error) {
-		return refreshSecretKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &RefreshUserClaim{}, verificationKeys(refreshSecretKey, previousRefreshSecretKey))
 
 	if err != nil {
 		return nil, err
@@ -138,15 +155,11 @@ func ParseRefreshToken(tokenString string) (*RefreshUserClaim, error) {
 
 func CheckToken(c *gin.Context, authToken string) bool {
 	if authToken == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "No token found in request.",
-		})
+		apperr.Write(c, apperr.New(apperr.Unauthorized, "No token found in request."))
 		return false
 	}
 	if err := VerifyToken(authToken); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"Error": "Unauthorized.",
-		})
+		apperr.Write(c, apperr.New(apperr.Unauthorized, "Unauthorized."))
 		return false
 	}
 	return true
@@ -154,15 +167,11 @@ func CheckToken(c *gin.Context, authToken string) bool {
 
 func CheckRefreshToken(c *gin.Context, refreshToken string) bool {
 	if refreshToken == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error": "No token found in request.",
-		})
+		apperr.Write(c, apperr.New(apperr.Unauthorized, "No token found in request."))
 		return false
 	}
 	if err := VerifyRefreshToken(refreshToken); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"Error": "Unauthorized.",
-		})
+		apperr.Write(c, apperr.New(apperr.Unauthorized, "Unauthorized."))
 		return false
 	}
 	return true
@@ -171,15 +180,11 @@ func CheckRefreshToken(c *gin.Context, refreshToken string) bool {
 func CheckTokenNotExpired(c *gin.Context, authToken string) bool {
 	claims, err := ParseToken(authToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"Error": "Unauthorized.",
-		})
+		apperr.Write(c, apperr.New(apperr.Unauthorized, "Unauthorized."))
 		return false
 	}
 	if time.Now().Unix() > claims.Exp {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"Error": "Token has expired.",
-		})
+		apperr.Write(c, apperr.New(apperr.TokenExpired, "Token has expired."))
 		return false
 	}
 	return true
@@ -188,15 +193,11 @@ func CheckTokenNotExpired(c *gin.Context, authToken string) bool {
 func CheckRefreshTokenNotExpired(c *gin.Context, refreshToken string) bool {
 	claims, err := ParseRefreshToken(refreshToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"Error": "Unauthorized.",
-		})
+		apperr.Write(c, apperr.New(apperr.Unauthorized, "Unauthorized."))
 		return false
 	}
 	if time.Now().Unix() > claims.Exp {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"Error": "Token has expired.",
-		})
+		apperr.Write(c, apperr.New(apperr.TokenExpired, "Token has expired."))
 		return false
 	}
 	return true
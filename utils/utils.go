@@ -1,8 +1,9 @@
 package utils
 
 import (
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/logging"
 	"example/hivemind-be/token"
-	"net/http"
 	"reflect"
 	"regexp"
 	"unicode"
@@ -34,48 +35,28 @@ func JsonDataHasKey(data interface{}, key string) (interface{}, bool) {
 }
 
 func ValidateAuthentication(c *gin.Context, authToken string) (*token.UserClaim, bool) {
-	validToken := token.CheckToken(c, authToken)
-	expire := token.CheckTokenNotExpired(c, authToken)
-
-	if !validToken {
-		return nil, false
-	}
-	if !expire {
+	if !token.CheckToken(c, authToken) || !token.CheckTokenNotExpired(c, authToken) {
 		return nil, false
 	}
 	claims, err := token.ParseToken(authToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"Error": "Unauthorized.",
-		})
+		apperr.Write(c, apperr.New(apperr.Unauthorized, "Unauthorized."))
 		return nil, false
 	}
+	logging.SetAccount(c, claims.AccountUUID)
 	return claims, true
 }
 
 func ValidateRefreshAuthentication(c *gin.Context, refreshToken string) (*token.RefreshUserClaim, bool) {
-	validToken := token.CheckRefreshToken(c, refreshToken)
-	expire := token.CheckRefreshTokenNotExpired(c, refreshToken)
-
-	if !validToken {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"Error": "Unauthorized.",
-		})
-		return nil, false
-	}
-	if !expire {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"Error": "Token has expired.",
-		})
+	if !token.CheckRefreshToken(c, refreshToken) || !token.CheckRefreshTokenNotExpired(c, refreshToken) {
 		return nil, false
 	}
 	claims, err := token.ParseRefreshToken(refreshToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"Error": "Unauthorized.",
-		})
+		apperr.Write(c, apperr.New(apperr.Unauthorized, "Unauthorized."))
 		return nil, false
 	}
+	logging.SetAccount(c, claims.AccountUUID)
 	return claims, true
 }
 
@@ -103,6 +84,14 @@ func ValidateContentMessage(message string) bool {
 	}
 }
 
+func ValidateReportReason(reason string) bool {
+	if len(reason) >= 1 && len(reason) <= 256 {
+		return true
+	} else {
+		return false
+	}
+}
+
 func ValidateHiveName(name string) bool {
 	namePattern := "^[a-zA-Z]{1,30}$"
 	nameRegex, err := regexp.Compile(namePattern)
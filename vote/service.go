@@ -0,0 +1,228 @@
+package vote
+
+import (
+	"errors"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/events"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Direction int
+
+const (
+	Up Direction = iota
+	Down
+)
+
+func (direction Direction) String() string {
+	if direction == Up {
+		return "upvote"
+	}
+	return "downvote"
+}
+
+// Target is the content or comment a vote is cast on.
+type Target struct {
+	Type string //content or comment
+	UUID string
+}
+
+// VoteService holds the rules for casting and retracting votes and keeping the vote counters in step. A vote
+// is checked and saved with the counters it changes in one transaction through Tx together with its event.
+type VoteService struct {
+	Comments repository.CommentRepo
+	Votes    repository.VoteRepo
+	Tx       repository.Transactor
+}
+
+func NewVoteService(repos repository.Repos) *VoteService {
+	return &VoteService{
+		Comments: repos.Comments,
+		Votes:    repos.Votes,
+		Tx:       repos.Tx,
+	}
+}
+
+// Cast records the account's vote. Each account votes at most once per item and has to retract its vote
+// before voting the other way.
+func (s *VoteService) Cast(accountUUID string, target Target, direction Direction) error {
+	return s.change(accountUUID, target, direction, true)
+}
+
+// Retract takes back the account's vote in the given direction.
+func (s *VoteService) Retract(accountUUID string, target Target, direction Direction) error {
+	return s.change(accountUUID, target, direction, false)
+}
+
+func (s *VoteService) change(accountUUID string, target Target, direction Direction, cast bool) error {
+	switch target.Type {
+	case "content":
+		return s.changeContentVote(accountUUID, target, direction, cast)
+	case "comment":
+		return s.changeCommentVote(accountUUID, target, direction, cast)
+	}
+	return apperr.New(apperr.ValidationFailed, fmt.Sprintf("Cannot vote on %s.", target.Type))
+}
+
+func (s *VoteService) changeContentVote(accountUUID string, target Target, direction Direction, cast bool) error {
+	return s.Tx.Transaction(func(tx repository.Repos) error {
+		//the content stays locked until the vote is saved, so the account's votes on it are checked one at a time
+		content, err := tx.Contents.GetForUpdate(target.UUID)
+		if err != nil {
+			return apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+		}
+		//drafts cannot be voted on before they are published
+		if content.Draft {
+			return apperr.New(apperr.ContentNotFound, "Content not found.")
+		}
+		vote, err := tx.Votes.GetContentVote(accountUUID, target.UUID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if err := check(err == nil, vote.Upvote, vote.Downvote, target, direction, cast); err != nil {
+			return err
+		}
+
+		//votes from shadowbanned accounts are recorded but not counted
+		shadowbanned, err := tx.Accounts.IsShadowbanned(accountUUID)
+		if err != nil {
+			return err
+		}
+		if !shadowbanned {
+			votes := voteCounts(direction, step(cast))
+			if err := tx.Contents.AddCounts(content.UUID, votes); err != nil {
+				return err
+			}
+			if !content.Deleted {
+				if err := tx.Hives.AddCounts(content.HiveUUID, votes); err != nil {
+					return err
+				}
+			}
+		}
+		vote.AccountUUID = accountUUID
+		vote.ContentUUID = target.UUID
+		vote.Upvote = cast && direction == Up
+		vote.Downvote = cast && direction == Down
+		vote.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
+		if err := tx.Votes.SaveContentVote(&vote); err != nil {
+			return err
+		}
+		event := voteEvent(accountUUID, target, direction, cast, content.HiveUUID)
+		return tx.Events.Append(&event)
+	})
+}
+
+func (s *VoteService) changeCommentVote(accountUUID string, target Target, direction Direction, cast bool) error {
+	comment, err := s.Comments.GetByUUID(target.UUID)
+	if err != nil {
+		return apperr.NotFoundAs(err, apperr.CommentNotFound, "Comment not found.")
+	}
+	return s.Tx.Transaction(func(tx repository.Repos) error {
+		//the content is locked before the comment, the same order deleting either of them locks them in
+		content, err := tx.Contents.GetForUpdate(comment.ContentUUID)
+		if err != nil {
+			return apperr.NotFoundAs(err, apperr.ContentNotFound, "Content not found.")
+		}
+		comment, err := tx.Comments.GetForUpdate(target.UUID)
+		if err != nil {
+			return apperr.NotFoundAs(err, apperr.CommentNotFound, "Comment not found.")
+		}
+		vote, err := tx.Votes.GetCommentVote(accountUUID, target.UUID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if err := check(err == nil, vote.Upvote, vote.Downvote, target, direction, cast); err != nil {
+			return err
+		}
+
+		//votes from shadowbanned accounts are recorded but not counted
+		shadowbanned, err := tx.Accounts.IsShadowbanned(accountUUID)
+		if err != nil {
+			return err
+		}
+		if !shadowbanned {
+			votes := voteCounts(direction, step(cast))
+			if err := tx.Comments.AddCounts(comment.UUID, votes); err != nil {
+				return err
+			}
+			//hive totals count votes on comments as well, unless the comment or its content is deleted
+			if !comment.Deleted && !content.Deleted {
+				if err := tx.Hives.AddCounts(content.HiveUUID, votes); err != nil {
+					return err
+				}
+			}
+		}
+		vote.AccountUUID = accountUUID
+		vote.CommentUUID = target.UUID
+		vote.Upvote = cast && direction == Up
+		vote.Downvote = cast && direction == Down
+		vote.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
+		if err := tx.Votes.SaveCommentVote(&vote); err != nil {
+			return err
+		}
+		event := voteEvent(accountUUID, target, direction, cast, content.HiveUUID)
+		return tx.Events.Append(&event)
+	})
+}
+
+func voteEvent(accountUUID string, target Target, direction Direction, cast bool, hiveUUID string) models.Event {
+	eventType := events.VoteRetracted
+	if cast {
+		eventType = events.VoteCast
+	}
+	return events.Vote(eventType, events.VotePayload{
+		TargetType:  target.Type,
+		TargetUUID:  target.UUID,
+		AccountUUID: accountUUID,
+		Direction:   direction.String(),
+	}, hiveUUID)
+}
+
+// voteCounts counts delta votes in the direction.
+func voteCounts(direction Direction, delta int32) repository.Counts {
+	if direction == Up {
+		return repository.Counts{Upvotes: delta}
+	}
+	return repository.Counts{Downvotes: delta}
+}
+
+// check applies the voting rules to the account's current vote on the target. exists is false when the
+// account has never voted on it.
+func check(exists bool, upvote bool, downvote bool, target Target, direction Direction, cast bool) error {
+	if cast {
+		if upvote || downvote {
+			return apperr.New(apperr.AlreadyVoted, fmt.Sprintf("User has already voted on this %s!", target.Type))
+		}
+		return nil
+	}
+
+	if !exists {
+		return apperr.New(apperr.NotVoted, fmt.Sprintf("User has not voted on this %s!", target.Type))
+	}
+	if (direction == Up && (!upvote || downvote)) || (direction == Down && (upvote || !downvote)) {
+		return apperr.New(apperr.NotVoted, fmt.Sprintf("User has not %sd on this %s!", direction, target.Type))
+	}
+	return nil
+}
+
+func step(cast bool) int32 {
+	if cast {
+		return 1
+	}
+	return -1
+}
+
+// ContentVotes lists the account's votes on content.
+func (s *VoteService) ContentVotes(accountUUID string) ([]models.ContentVote, error) {
+	return s.Votes.ListContentVotesByAccount(accountUUID)
+}
+
+// CommentVotes lists the account's votes on comments along with the content each comment belongs to.
+func (s *VoteService) CommentVotes(accountUUID string) ([]models.CommentVoteGroup, error) {
+	return s.Votes.ListCommentVoteGroupsByAccount(accountUUID)
+}
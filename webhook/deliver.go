@@ -0,0 +1,222 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/config"
+	"example/hivemind-be/jobs"
+	"example/hivemind-be/metrics"
+	"example/hivemind-be/repository"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Headers sent with every delivery. The signature is "sha256=" and the hex HMAC-SHA256 of the timestamp, a
+// dot and the body, keyed with the webhook's secret.
+const (
+	EventHeader     = "X-Hivemind-Event"
+	DeliveryHeader  = "X-Hivemind-Delivery"
+	TimestampHeader = "X-Hivemind-Timestamp"
+	SignatureHeader = "X-Hivemind-Signature"
+)
+
+// maxResponseBody is how much of a receiver's response is kept in the delivery log.
+const maxResponseBody = 1024
+
+var errPrivateAddress = errors.New("webhooks cannot be sent to private or loopback addresses")
+
+var client = newClient(settings)
+
+// newClient builds the client deliveries are sent with. It does not follow redirects, and unless private
+// addresses are allowed it refuses to connect to them whatever the URL's host resolves to.
+func newClient(cfg config.WebhooksConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivate {
+		dialer.Control = func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || private(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func private(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+}
+
+// Sign is the signature header value for a body sent at timestamp.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// attempt is the outcome of sending a delivery once.
+type attempt struct {
+	status   int
+	body     string
+	duration time.Duration
+	err      error
+}
+
+func send(ctx context.Context, hook Webhook, delivery Delivery) attempt {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return attempt{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "hivemind-webhooks")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.UUID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, delivery.Payload))
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return attempt{duration: time.Since(started), err: err}
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+
+	result := attempt{status: resp.StatusCode, body: string(body), duration: time.Since(started)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.err = fmt.Errorf("receiver answered %d", resp.StatusCode)
+	}
+	return result
+}
+
+// deliver is the job handler. It sends a pending delivery and logs the attempt, leaving retries to the jobs
+// queue. Deliveries to webhooks that were deleted or disabled in the meantime fail without being sent.
+func deliver(ctx context.Context, gdb *gorm.DB, job jobs.Job) error {
+	return deliverWith(ctx, repository.NewGormRepos(gdb).WithContext(ctx), job)
+}
+
+func deliverWith(ctx context.Context, repos repository.Repos, job jobs.Job) error {
+	var payload deliverJob
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	delivery, err := repos.Webhooks.GetDelivery(payload.DeliveryUUID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+	if delivery.Status != Pending {
+		return nil
+	}
+
+	hook, err := repos.Webhooks.GetByUUID(delivery.WebhookUUID)
+	if err != nil {
+		return err
+	}
+	if !hook.Active {
+		delivery.Status = Failed
+		delivery.LastError = "the webhook is disabled"
+		return repos.Webhooks.SaveDelivery(&delivery)
+	}
+
+	outcome := send(ctx, hook, delivery)
+	final := outcome.err == nil || job.Attempts >= job.MaxAttempts
+	if err := record(repos, hook, delivery, outcome, final); err != nil {
+		slog.Error("could not record a webhook delivery", "delivery", delivery.UUID, "error", err)
+	}
+	return outcome.err
+}
+
+// record logs the attempt on the delivery and keeps count of the webhook's failures in a row, disabling it
+// once there are too many.
+func record(repos repository.Repos, hook Webhook, delivery Delivery, outcome attempt, final bool) error {
+	metrics.WebhookDeliveries.WithLabelValues(delivery.Event, metrics.Result(outcome.err == nil)).Inc()
+
+	delivery.Attempts++
+	delivery.ResponseStatus = int32(outcome.status)
+	delivery.ResponseBody = ""
+	delivery.LastError = ""
+	delivery.DurationMs = int32(outcome.duration.Milliseconds())
+	if outcome.status != 0 {
+		delivery.ResponseBody = outcome.body
+	}
+	switch {
+	case outcome.err == nil:
+		delivery.Status = Succeeded
+		delivery.Delivered = pq.NullTime{Time: time.Now(), Valid: true}
+	case final:
+		delivery.Status = Failed
+		delivery.LastError = outcome.err.Error()
+	default:
+		delivery.LastError = outcome.err.Error()
+	}
+
+	return repos.Tx.Transaction(func(tx repository.Repos) error {
+		if err := tx.Webhooks.SaveDelivery(&delivery); err != nil {
+			return err
+		}
+
+		if outcome.err == nil {
+			return tx.Webhooks.ResetFailures(hook)
+		}
+
+		failures, err := tx.Webhooks.AddFailure(hook)
+		if err != nil {
+			return err
+		}
+		if int(failures) < settings.MaxFailures {
+			return nil
+		}
+
+		slog.Warn("disabling a webhook after repeated failures", "webhook", hook.UUID, "hive", hook.HiveUUID, "failures", failures)
+		return tx.Webhooks.Disable(hook, fmt.Sprintf("Disabled after %d failed deliveries in a row. Last error: %s", failures, outcome.err))
+	})
+}
+
+// validateURL checks a webhook URL when it is registered. Host names are checked again when they are
+// resolved for each delivery.
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil || len(raw) > 2048 {
+		return apperr.New(apperr.ValidationFailed, "Url must be an absolute http or https URL of at most 2048 characters.")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && private(ip) && !settings.AllowPrivate {
+		return apperr.New(apperr.ValidationFailed, "Url cannot point at a private or loopback address.")
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"example/hivemind-be/config"
+	"example/hivemind-be/jobs"
+	"example/hivemind-be/repository"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// receiver is a webhook endpoint that answers every request with status and remembers the last one.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests int
+	header   http.Header
+	body     []byte
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	r := &receiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests++
+		r.header = req.Header.Clone()
+		r.body = body
+		w.WriteHeader(r.status)
+		w.Write([]byte("thanks"))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// configure allows deliveries to the loopback receivers and restores the settings after the test.
+func configure(t *testing.T, maxFailures int) {
+	previous := settings
+	Configure(config.WebhooksConfig{Timeout: 5 * time.Second, MaxFailures: maxFailures, AllowPrivate: true})
+	t.Cleanup(func() { Configure(previous) })
+}
+
+func newHook(t *testing.T, repos repository.Repos, url string) Webhook {
+	t.Helper()
+	hook := Webhook{
+		UUID:     uuid.NewString(),
+		HiveUUID: uuid.NewString(),
+		URL:      url,
+		Secret:   newSecret(),
+		Events:   pq.StringArray{ContentCreated},
+		Active:   true,
+		Created:  pq.NullTime{Time: time.Now(), Valid: true},
+	}
+	if err := repos.Webhooks.Create(&hook); err != nil {
+		t.Fatal(err)
+	}
+	return hook
+}
+
+// queue saves a pending delivery to the webhook and returns the job that sends it.
+func queue(t *testing.T, repos repository.Repos, hook Webhook) (Delivery, jobs.Job) {
+	t.Helper()
+	payload, err := json.Marshal(Body{EventUUID: uuid.NewString(), Event: ContentCreated, HiveUUID: hook.HiveUUID, Data: json.RawMessage(`{"Title":"Hello"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivery := newDelivery(hook.UUID, uuid.NewString(), ContentCreated, payload)
+	if err := repos.Webhooks.CreateDelivery(&delivery); err != nil {
+		t.Fatal(err)
+	}
+	job, err := jobs.New(DeliverKind, deliverJob{DeliveryUUID: delivery.UUID}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delivery, job
+}
+
+// run sends the delivery as the given attempt of its job, the way a worker would.
+func run(repos repository.Repos, job jobs.Job, attempt int32) error {
+	job.Attempts = attempt
+	return deliverWith(context.Background(), repos, job)
+}
+
+func stored(t *testing.T, repos repository.Repos, delivery Delivery) (Delivery, Webhook) {
+	t.Helper()
+	delivery, err := repos.Webhooks.GetDelivery(delivery.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook, err := repos.Webhooks.GetByUUID(delivery.WebhookUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delivery, hook
+}
+
+func TestDeliverySignsTheBody(t *testing.T) {
+	configure(t, 10)
+	repos := repository.NewMemoryRepos()
+	r := newReceiver(t, http.StatusOK)
+	hook := newHook(t, repos, r.URL)
+	hook.ConsecutiveFailures = 2
+	if err := repos.Webhooks.Update(&hook); err != nil {
+		t.Fatal(err)
+	}
+	delivery, job := queue(t, repos, hook)
+
+	if err := run(repos, job, 1); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	timestamp := r.header.Get(TimestampHeader)
+	if timestamp == "" {
+		t.Fatalf("no %s header", TimestampHeader)
+	}
+	if got, want := r.header.Get(SignatureHeader), Sign(hook.Secret, timestamp, r.body); got != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+	}
+	if !strings.HasPrefix(r.header.Get(SignatureHeader), "sha256=") {
+		t.Errorf("%s = %q, want a sha256= signature", SignatureHeader, r.header.Get(SignatureHeader))
+	}
+	if string(r.body) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", r.body, delivery.Payload)
+	}
+	if r.header.Get(EventHeader) != ContentCreated || r.header.Get(DeliveryHeader) != delivery.UUID {
+		t.Errorf("%s = %q and %s = %q, want %q and %q", EventHeader, r.header.Get(EventHeader), DeliveryHeader, r.header.Get(DeliveryHeader), ContentCreated, delivery.UUID)
+	}
+
+	delivery, hook = stored(t, repos, delivery)
+	if delivery.Status != Succeeded || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusOK || delivery.ResponseBody != "thanks" || !delivery.Delivered.Valid {
+		t.Errorf("delivery = %+v, want succeeded on the first attempt", delivery)
+	}
+	if hook.ConsecutiveFailures != 0 {
+		t.Errorf("ConsecutiveFailures = %d after a success, want 0", hook.ConsecutiveFailures)
+	}
+}
+
+func TestFailedDeliveryIsRetriedUntilTheLastAttempt(t *testing.T) {
+	configure(t, 10)
+	repos := repository.NewMemoryRepos()
+	r := newReceiver(t, http.StatusInternalServerError)
+	hook := newHook(t, repos, r.URL)
+	delivery, job := queue(t, repos, hook)
+
+	for attempt := int32(1); attempt < job.MaxAttempts; attempt++ {
+		if err := run(repos, job, attempt); err == nil {
+			t.Fatalf("attempt %d: no error for a 500, so it would not be retried", attempt)
+		}
+		got, _ := stored(t, repos, delivery)
+		if got.Status != Pending || got.Attempts != attempt || got.ResponseStatus != http.StatusInternalServerError || got.LastError == "" {
+			t.Fatalf("after attempt %d: delivery = %+v, want pending with the error", attempt, got)
+		}
+	}
+
+	if err := run(repos, job, job.MaxAttempts); err == nil {
+		t.Fatal("last attempt: no error for a 500")
+	}
+	got, hook := stored(t, repos, delivery)
+	if got.Status != Failed || got.Attempts != job.MaxAttempts || got.Delivered.Valid {
+		t.Errorf("after the last attempt: delivery = %+v, want failed", got)
+	}
+	if hook.ConsecutiveFailures != job.MaxAttempts || !hook.Active {
+		t.Errorf("webhook = %+v, want %d failures and still active", hook, job.MaxAttempts)
+	}
+	if r.requests != int(job.MaxAttempts) {
+		t.Errorf("receiver got %d requests, want %d", r.requests, job.MaxAttempts)
+	}
+}
+
+func TestWebhookIsDisabledAfterMaxFailures(t *testing.T) {
+	const maxFailures = 3
+	configure(t, maxFailures)
+	repos := repository.NewMemoryRepos()
+	r := newReceiver(t, http.StatusBadGateway)
+	hook := newHook(t, repos, r.URL)
+
+	for i := 1; i <= maxFailures; i++ {
+		delivery, job := queue(t, repos, hook)
+		run(repos, job, 1)
+		_, hook = stored(t, repos, delivery)
+		if active := i < maxFailures; hook.Active != active {
+			t.Fatalf("after %d failures: Active = %v, want %v", i, hook.Active, active)
+		}
+	}
+	if !strings.Contains(hook.DisabledReason, "3 failed deliveries") || !strings.Contains(hook.DisabledReason, "502") {
+		t.Errorf("DisabledReason = %q, want the failures and the last error", hook.DisabledReason)
+	}
+
+	//deliveries still queued for the disabled webhook fail without being sent
+	delivery, job := queue(t, repos, hook)
+	if err := run(repos, job, 1); err != nil {
+		t.Fatalf("deliver to a disabled webhook: %v", err)
+	}
+	if got, _ := stored(t, repos, delivery); got.Status != Failed || got.Attempts != 0 {
+		t.Errorf("delivery = %+v, want failed without an attempt", got)
+	}
+	if r.requests != maxFailures {
+		t.Errorf("receiver got %d requests, want %d", r.requests, maxFailures)
+	}
+}
@@ -0,0 +1,309 @@
+package webhook
+
+import (
+	"example/hivemind-be/apperr"
+	"example/hivemind-be/hive"
+	"example/hivemind-be/repository"
+	"example/hivemind-be/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Handler serves the webhook routes through the webhook repository.
+type Handler struct {
+	Repos repository.Repos
+}
+
+func NewHandler(repos repository.Repos) *Handler {
+	return &Handler{Repos: repos}
+}
+
+// maxWebhooks is how many webhooks one hive can register.
+const maxWebhooks = 10
+
+type NewWebhook struct {
+	URL    string   `json:"Url"`
+	Events []string `json:"Events"`
+}
+
+// UpdateWebhook changes the fields that are set. Activating a disabled webhook clears its failures.
+type UpdateWebhook struct {
+	URL          *string  `json:"Url"`
+	Events       []string `json:"Events"`
+	Active       *bool    `json:"Active"`
+	RotateSecret bool     `json:"RotateSecret"`
+}
+
+// WebhookWithSecret is sent when the secret is new, the only times it is shown.
+type WebhookWithSecret struct {
+	Webhook
+	Secret string `json:"Secret"`
+}
+
+// repos is the repositories with their queries bound to the request.
+func (h *Handler) repos(c *gin.Context) repository.Repos {
+	return h.Repos.WithContext(c.Request.Context())
+}
+
+// validateEvents checks that events names at least one webhook event and nothing else, and drops repeats.
+func validateEvents(names []string) (pq.StringArray, error) {
+	var unique pq.StringArray
+	seen := map[string]bool{}
+	for _, name := range names {
+		known := false
+		for _, event := range eventTypes {
+			known = known || event == name
+		}
+		if !known {
+			return nil, apperr.New(apperr.ValidationFailed, "Events must be content.created, comment.created, content.removed or member.joined.")
+		}
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, apperr.New(apperr.ValidationFailed, "Events must name at least one event.")
+	}
+	return unique, nil
+}
+
+// moderatedWebhook loads a webhook, writing an error response and returning false
+// when it does not exist or the account does not moderate its hive.
+func moderatedWebhook(c *gin.Context, repos repository.Repos, uuid string, accountUUID string) (Webhook, bool) {
+	hook, err := repos.Webhooks.GetByUUID(uuid)
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.WebhookNotFound, "Webhook not found. Please try again."))
+		return hook, false
+	}
+	return hook, hive.RequireModerator(c, repos, hook.HiveUUID, accountUUID)
+}
+
+func (h *Handler) GetWebhooksByHiveUuid(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	uuid := c.Param("uuid")
+	repos := h.repos(c)
+	if !hive.RequireModerator(c, repos, uuid, claims.AccountUUID) {
+		return
+	}
+
+	hooks, err := repos.Webhooks.ListByHive(uuid)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var newWebhook NewWebhook
+
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&newWebhook); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
+		return
+	}
+
+	hiveUUID := c.Param("uuid")
+	repos := h.repos(c)
+	if !hive.RequireModerator(c, repos, hiveUUID, claims.AccountUUID) {
+		return
+	}
+
+	if err := validateURL(newWebhook.URL); err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	subscribed, err := validateEvents(newWebhook.Events)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+
+	webhooks := repos.Webhooks
+	existing, err := webhooks.CountByHive(hiveUUID)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	if existing >= maxWebhooks {
+		apperr.Write(c, apperr.New(apperr.TooManyWebhooks, "A hive can have at most 10 webhooks."))
+		return
+	}
+
+	hook := Webhook{
+		UUID:      uuid.NewString(),
+		HiveUUID:  hiveUUID,
+		URL:       newWebhook.URL,
+		Secret:    newSecret(),
+		Events:    subscribed,
+		Active:    true,
+		CreatedBy: claims.AccountUUID,
+		Created:   pq.NullTime{Time: time.Now(), Valid: true},
+	}
+	if err := webhooks.Create(&hook); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error creating this webhook. Please try again.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, WebhookWithSecret{Webhook: hook, Secret: hook.Secret})
+}
+
+func (h *Handler) UpdateWebhookByUuid(c *gin.Context) {
+	var update UpdateWebhook
+
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&update); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.BadRequest, "Request body is not valid JSON.", err))
+		return
+	}
+
+	repos := h.repos(c)
+	webhooks := repos.Webhooks
+	hook, ok := moderatedWebhook(c, repos, c.Param("uuid"), claims.AccountUUID)
+	if !ok {
+		return
+	}
+
+	if update.URL != nil {
+		if err := validateURL(*update.URL); err != nil {
+			apperr.Write(c, err)
+			return
+		}
+		hook.URL = *update.URL
+	}
+	if update.Events != nil {
+		subscribed, err := validateEvents(update.Events)
+		if err != nil {
+			apperr.Write(c, err)
+			return
+		}
+		hook.Events = subscribed
+	}
+	if update.Active != nil {
+		if *update.Active && !hook.Active {
+			hook.ConsecutiveFailures = 0
+			hook.DisabledReason = ""
+		}
+		hook.Active = *update.Active
+	}
+	if update.RotateSecret {
+		hook.Secret = newSecret()
+	}
+	hook.LastEdited = pq.NullTime{Time: time.Now(), Valid: true}
+
+	if err := webhooks.Update(&hook); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error updating this webhook. Please try again.", err))
+		return
+	}
+
+	if update.RotateSecret {
+		c.JSON(http.StatusOK, WebhookWithSecret{Webhook: hook, Secret: hook.Secret})
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhookByUuid deletes the webhook with its delivery log. Deliveries still queued are dropped.
+func (h *Handler) DeleteWebhookByUuid(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	repos := h.repos(c)
+	webhooks := repos.Webhooks
+	hook, ok := moderatedWebhook(c, repos, c.Param("uuid"), claims.AccountUUID)
+	if !ok {
+		return
+	}
+
+	if err := webhooks.Delete(hook); err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error deleting this webhook. Please try again.", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"Message": "The webhook has been deleted!",
+	})
+}
+
+// GetDeliveriesByWebhookUuid lists the webhook's deliveries newest first, optionally only those with a status.
+func (h *Handler) GetDeliveriesByWebhookUuid(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	repos := h.repos(c)
+	webhooks := repos.Webhooks
+	hook, ok := moderatedWebhook(c, repos, c.Param("uuid"), claims.AccountUUID)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	deliveries, err := webhooks.ListDeliveries(hook.UUID, c.Query("status"), limit)
+	if err != nil {
+		apperr.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RedeliverByUuid sends a past delivery again, whatever its status.
+func (h *Handler) RedeliverByUuid(c *gin.Context) {
+	authToken := c.GetHeader("Authorization")
+	claims, validToken := utils.ValidateAuthentication(c, authToken)
+	if !validToken {
+		return
+	}
+
+	repos := h.repos(c)
+	original, err := repos.Webhooks.GetDelivery(c.Param("uuid"))
+	if err != nil {
+		apperr.Write(c, apperr.NotFoundAs(err, apperr.DeliveryNotFound, "Delivery not found. Please try again."))
+		return
+	}
+
+	hook, ok := moderatedWebhook(c, repos, original.WebhookUUID, claims.AccountUUID)
+	if !ok {
+		return
+	}
+
+	if !hook.Active {
+		apperr.Write(c, apperr.New(apperr.WebhookDisabled, "This webhook is disabled. Enable it before redelivering."))
+		return
+	}
+
+	delivery, err := Redeliver(repos, original)
+	if err != nil {
+		apperr.Write(c, apperr.Wrap(apperr.Internal, "There was an error redelivering. Please try again.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, delivery)
+}
@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"example/hivemind-be/config"
+	"example/hivemind-be/events"
+	"example/hivemind-be/jobs"
+	"example/hivemind-be/models"
+	"example/hivemind-be/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Webhook events a hive can subscribe to.
+const (
+	ContentCreated = "content.created"
+	CommentCreated = "comment.created"
+	ContentRemoved = "content.removed"
+	MemberJoined   = "member.joined"
+)
+
+// eventTypes maps the domain events that are sent to webhooks to the name they are sent as.
+var eventTypes = map[string]string{
+	events.ContentCreated: ContentCreated,
+	events.CommentCreated: CommentCreated,
+	events.ContentRemoved: ContentRemoved,
+	events.MemberJoined:   MemberJoined,
+}
+
+// Delivery statuses. A pending delivery is retried by the jobs queue until it succeeds or runs out of
+// attempts and has failed.
+const (
+	Pending   = "pending"
+	Succeeded = "succeeded"
+	Failed    = "failed"
+)
+
+// DeliverKind is the job that sends one delivery.
+const DeliverKind = "webhook.deliver"
+
+type Webhook = models.Webhook
+
+// Delivery is one event sent, or to be sent, to a webhook.
+type Delivery = models.WebhookDelivery
+
+// Body is what a receiver is sent. EventUUID is the same for redeliveries, so receivers can ignore repeats.
+type Body struct {
+	EventUUID string          `json:"EventUuid"`
+	Event     string          `json:"Event"`
+	HiveUUID  string          `json:"HiveUuid"`
+	Created   time.Time       `json:"Created"`
+	Data      json.RawMessage `json:"Data"`
+}
+
+type deliverJob struct {
+	DeliveryUUID string `json:"DeliveryUuid"`
+}
+
+var settings = config.WebhooksConfig{Timeout: 10 * time.Second, MaxFailures: 10}
+
+// Configure sets the delivery timeout, failure limit and address checks. It is called once at startup.
+func Configure(cfg config.WebhooksConfig) {
+	settings = cfg
+	client = newClient(cfg)
+}
+
+// newSecret is the key deliveries of a webhook are signed with.
+func newSecret() string {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return "whsec_" + hex.EncodeToString(key)
+}
+
+// subscribe queues a delivery of the event to every active webhook of its hive subscribed to it. Deliveries
+// are unique per webhook and event, so an event seen again is not sent twice.
+func subscribe(ctx context.Context, gdb *gorm.DB, event models.Event) error {
+	name, ok := eventTypes[event.Type]
+	if !ok || event.HiveUUID == "" {
+		return nil
+	}
+	gdb = gdb.WithContext(ctx)
+
+	if skip, err := hidden(gdb, event); err != nil || skip {
+		return err
+	}
+
+	var hooks []Webhook
+	if result := gdb.Where("hive_uuid = ? AND active = ? AND ? = ANY(events)", event.HiveUUID, true, name).Find(&hooks); result.Error != nil {
+		return result.Error
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(Body{
+		EventUUID: event.UUID,
+		Event:     name,
+		HiveUUID:  event.HiveUUID,
+		Created:   event.Created.Time,
+		Data:      event.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		delivery := newDelivery(hook.UUID, event.UUID, name, payload)
+		err := gdb.Transaction(func(tx *gorm.DB) error {
+			result := tx.Clauses(clause.OnConflict{
+				Columns:     []clause.Column{{Name: "webhook_uuid"}, {Name: "event_uuid"}},
+				TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "redelivery_of IS NULL"}}},
+				DoNothing:   true,
+			}).Create(&delivery)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			_, err := jobs.Enqueue(tx, DeliverKind, deliverJob{DeliveryUUID: delivery.UUID})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hidden reports whether the event is about something other members cannot see: new content or comments
+// that automod removed on arrival or that were posted by a shadowbanned account.
+func hidden(gdb *gorm.DB, event models.Event) (bool, error) {
+	if event.Type != events.ContentCreated && event.Type != events.CommentCreated {
+		return false, nil
+	}
+
+	var item struct {
+		Removed bool `json:"Removed"`
+	}
+	if err := events.Decode(event, &item); err != nil {
+		return false, err
+	}
+	if item.Removed {
+		return true, nil
+	}
+
+	return repository.NewGormRepos(gdb).Accounts.IsShadowbanned(event.ActorUUID)
+}
+
+func newDelivery(webhookUUID string, eventUUID string, event string, payload json.RawMessage) Delivery {
+	return Delivery{
+		UUID:        uuid.NewString(),
+		WebhookUUID: webhookUUID,
+		EventUUID:   eventUUID,
+		Event:       event,
+		Payload:     payload,
+		Status:      Pending,
+		Created:     pq.NullTime{Time: time.Now(), Valid: true},
+	}
+}
+
+// Redeliver queues the payload of a delivery again as a new delivery.
+func Redeliver(repos repository.Repos, original Delivery) (Delivery, error) {
+	delivery := newDelivery(original.WebhookUUID, original.EventUUID, original.Event, original.Payload)
+	delivery.RedeliveryOf = original.UUID
+	err := repos.Tx.Transaction(func(tx repository.Repos) error {
+		if err := tx.Webhooks.CreateDelivery(&delivery); err != nil {
+			return err
+		}
+		job, err := jobs.New(DeliverKind, deliverJob{DeliveryUUID: delivery.UUID}, time.Now())
+		if err != nil {
+			return err
+		}
+		return tx.Jobs.Create(&job)
+	})
+	return delivery, err
+}
+
+func init() {
+	events.Subscribe("webhooks", subscribe)
+	jobs.Register(DeliverKind, deliver)
+}